@@ -0,0 +1,630 @@
+package agreements
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/middleware"
+	"home_solutions/backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const maxDrawnSignatureBytes = 512 << 10
+
+// Template is an agreement text with merge fields: {{client_name}}, {{property_address}}, {{fee}},
+// {{inspection_date}}, {{inspector_name}} and {{company_name}}
+type Template struct {
+	TemplateID int    `json:"template_id"`
+	Name       string `json:"name"`
+	Body       string `json:"body"`
+	Active     bool   `json:"active"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// Agreement is an agreement issued for one inspection. Once signed it can't be changed; the
+// database rejects updates to signed rows.
+type Agreement struct {
+	AgreementID    string  `json:"agreement_id"`
+	InspectionID   string  `json:"inspection_id"`
+	TemplateID     *int    `json:"template_id"`
+	Status         string  `json:"status"`
+	ClientName     string  `json:"client_name"`
+	ClientEmail    string  `json:"client_email"`
+	Fee            *string `json:"fee"`
+	Body           string  `json:"body"`
+	BodyHash       string  `json:"body_hash"`
+	SignerName     string  `json:"signer_name,omitempty"`
+	SignatureType  string  `json:"signature_type,omitempty"`
+	Signature      string  `json:"signature,omitempty"`
+	SignerIP       string  `json:"signer_ip,omitempty"`
+	SignerAgent    string  `json:"signer_user_agent,omitempty"`
+	SignedAt       *string `json:"signed_at,omitempty"`
+	DocumentHash   string  `json:"document_hash,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+	SigningToken   string  `json:"signing_token,omitempty"`
+	SigningURLPath string  `json:"signing_url_path,omitempty"`
+}
+
+// signedDocument is the canonical record hashed at signing time
+type signedDocument struct {
+	AgreementID   string `json:"agreement_id"`
+	InspectionID  string `json:"inspection_id"`
+	Body          string `json:"body"`
+	BodyHash      string `json:"body_hash"`
+	SignerName    string `json:"signer_name"`
+	SignatureType string `json:"signature_type"`
+	Signature     string `json:"signature"`
+	SignerIP      string `json:"signer_ip"`
+	SignerAgent   string `json:"signer_user_agent"`
+	SignedAt      string `json:"signed_at"`
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (d signedDocument) hash() string {
+	raw, _ := json.Marshal(d)
+	return sha256Hex(raw)
+}
+
+var mergeFieldPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// Merge fills a template's merge fields; unknown fields are left in place
+func Merge(body string, fields map[string]string) string {
+	return mergeFieldPattern.ReplaceAllStringFunc(body, func(match string) string {
+		if v, ok := fields[mergeFieldPattern.FindStringSubmatch(match)[1]]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+// HasSignedAgreement reports whether the client has signed an agreement for the inspection
+func HasSignedAgreement(db *sql.DB, inspectionID string) (bool, error) {
+	var signed bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspection_agreements WHERE inspection_id = ? AND status = 'signed')`, inspectionID).Scan(&signed)
+	return signed, err
+}
+
+// CanViewReport lets staff read any inspection's report and homeowners only the reports of their own
+// inspections, once each agreement is signed. It writes the error response and returns false otherwise.
+func CanViewReport(db *sql.DB, w http.ResponseWriter, r *http.Request, inspectionIDs ...string) bool {
+	if middleware.IsStaff(r) {
+		return true
+	}
+	callerID := middleware.CallerID(r)
+	for _, id := range inspectionIDs {
+		var customerID sql.NullInt64
+		err := db.QueryRow(`SELECT customer_id FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`, id).Scan(&customerID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return false
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading inspection %s: %v", id, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return false
+		}
+		if callerID == nil || !customerID.Valid || int(customerID.Int64) != *callerID {
+			http.Error(w, "Not allowed to view this inspection", http.StatusForbidden)
+			return false
+		}
+		signed, err := HasSignedAgreement(db, id)
+		if err != nil {
+			log.Printf("[Agreements] Error checking agreement: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return false
+		}
+		if !signed {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":              "The inspection agreement must be signed before the report is released",
+				"agreement_required": true,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// RequireSignedAgreement withholds an inspection's report from everyone but staff and the homeowner
+// it belongs to, and from the homeowner until the agreement is signed. It expects JWTAuthMiddleware
+// to run first; shared links are served by the sharing handlers instead.
+func RequireSignedAgreement(db *sql.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || CanViewReport(db, w, r, mux.Vars(r)["inspection_id"]) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// ListTemplates returns the agreement templates
+func ListTemplates(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "manage agreements") {
+			return
+		}
+		rows, err := db.Query(`SELECT template_id, name, body, active, created_at, updated_at FROM agreement_templates ORDER BY name`)
+		if err != nil {
+			log.Printf("[Agreements] Error listing templates: %v", err)
+			http.Error(w, "Failed to fetch templates", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		templates := []Template{}
+		for rows.Next() {
+			var t Template
+			if err := rows.Scan(&t.TemplateID, &t.Name, &t.Body, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+				log.Printf("[Agreements] Error scanning template: %v", err)
+				http.Error(w, "Failed to read templates", http.StatusInternalServerError)
+				return
+			}
+			templates = append(templates, t)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(templates)
+	}
+}
+
+// SaveTemplate creates a template, or updates one when the route has a template_id. Agreements
+// already issued keep the text they were issued with.
+func SaveTemplate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "manage agreements") {
+			return
+		}
+		var t Template
+		if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(t.Name) == "" || strings.TrimSpace(t.Body) == "" {
+			http.Error(w, "name and body are required", http.StatusBadRequest)
+			return
+		}
+
+		status := http.StatusOK
+		if idParam, ok := mux.Vars(r)["template_id"]; ok {
+			id, err := strconv.Atoi(idParam)
+			if err != nil {
+				http.Error(w, "Invalid template_id", http.StatusBadRequest)
+				return
+			}
+			res, err := db.Exec(`UPDATE agreement_templates SET name = ?, body = ?, active = ? WHERE template_id = ?`, t.Name, t.Body, t.Active, id)
+			if err != nil {
+				log.Printf("[Agreements] Error updating template %d: %v", id, err)
+				http.Error(w, "Failed to save template", http.StatusInternalServerError)
+				return
+			}
+			if n, _ := res.RowsAffected(); n == 0 {
+				var exists bool
+				db.QueryRow(`SELECT EXISTS(SELECT 1 FROM agreement_templates WHERE template_id = ?)`, id).Scan(&exists)
+				if !exists {
+					http.Error(w, "Template not found", http.StatusNotFound)
+					return
+				}
+			}
+			t.TemplateID = id
+		} else {
+			res, err := db.Exec(`INSERT INTO agreement_templates (name, body, active) VALUES (?, ?, ?)`, t.Name, t.Body, t.Active)
+			if err != nil {
+				log.Printf("[Agreements] Error creating template: %v", err)
+				http.Error(w, "Failed to save template", http.StatusInternalServerError)
+				return
+			}
+			id, _ := res.LastInsertId()
+			t.TemplateID = int(id)
+			status = http.StatusCreated
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Template saved", "template_id": t.TemplateID})
+	}
+}
+
+// mergeFieldsFor gathers the values for an inspection's merge fields. Client name and fee default
+// to the inspection's customer and invoice, and can be overridden by the caller.
+func mergeFieldsFor(db *sql.DB, inspectionID string) (map[string]string, string, error) {
+	var street, city, state, postal string
+	var inspectionDate, scheduledStart, customerFirst, customerLast, customerEmail, inspectorFirst, inspectorLast, company, fee sql.NullString
+	err := db.QueryRow(`
+		SELECT p.street, p.city, p.state, p.postal_code,
+		       i.inspection_date, i.scheduled_start,
+		       cu.first_name, cu.last_name, cu.email,
+		       iu.first_name, iu.last_name, COALESCE(o.name, ins.company_name),
+		       (SELECT CAST(amount AS CHAR) FROM invoices WHERE inspection_id = i.inspection_id ORDER BY created_at DESC LIMIT 1)
+		FROM inspections i
+		JOIN properties p ON p.property_id = i.property_id
+		LEFT JOIN users cu ON cu.user_id = i.customer_id
+		LEFT JOIN inspectors ins ON ins.inspector_id = i.inspector_id
+		LEFT JOIN users iu ON iu.user_id = ins.user_id
+		LEFT JOIN organizations o ON o.organization_id = ins.organization_id
+		WHERE i.inspection_id = ?`, inspectionID).Scan(&street, &city, &state, &postal, &inspectionDate, &scheduledStart,
+		&customerFirst, &customerLast, &customerEmail, &inspectorFirst, &inspectorLast, &company, &fee)
+	if err != nil {
+		return nil, "", err
+	}
+
+	date := inspectionDate.String
+	if scheduledStart.Valid && len(scheduledStart.String) >= 10 {
+		date = scheduledStart.String[:10]
+	}
+	fields := map[string]string{
+		"client_name":      strings.TrimSpace(customerFirst.String + " " + customerLast.String),
+		"property_address": fmt.Sprintf("%s, %s, %s %s", street, city, state, postal),
+		"fee":              fee.String,
+		"inspection_date":  date,
+		"inspector_name":   strings.TrimSpace(inspectorFirst.String + " " + inspectorLast.String),
+		"company_name":     company.String,
+	}
+	return fields, customerEmail.String, nil
+}
+
+const agreementSelect = `
+	SELECT agreement_id, inspection_id, template_id, status, client_name, COALESCE(client_email, ''),
+	       CAST(fee AS CHAR), body, body_hash, COALESCE(signer_name, ''), COALESCE(signature_type, ''),
+	       COALESCE(signature, ''), COALESCE(signer_ip, ''), COALESCE(signer_user_agent, ''), signed_at,
+	       COALESCE(document_hash, ''), created_at
+	FROM inspection_agreements`
+
+func scanAgreement(scanner interface{ Scan(...interface{}) error }) (*Agreement, error) {
+	var a Agreement
+	var templateID sql.NullInt64
+	var fee, signedAt sql.NullString
+	err := scanner.Scan(&a.AgreementID, &a.InspectionID, &templateID, &a.Status, &a.ClientName, &a.ClientEmail,
+		&fee, &a.Body, &a.BodyHash, &a.SignerName, &a.SignatureType, &a.Signature, &a.SignerIP, &a.SignerAgent,
+		&signedAt, &a.DocumentHash, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if templateID.Valid {
+		id := int(templateID.Int64)
+		a.TemplateID = &id
+	}
+	if fee.Valid {
+		a.Fee = &fee.String
+	}
+	if signedAt.Valid {
+		a.SignedAt = &signedAt.String
+	}
+	return &a, nil
+}
+
+// IssueAgreement renders a template for an inspection and returns the signing link to send to the
+// client. Any unsigned agreement issued earlier for the inspection is voided.
+func IssueAgreement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "manage agreements") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+
+		var req struct {
+			TemplateID  int     `json:"template_id"`
+			ClientName  string  `json:"client_name"`
+			ClientEmail string  `json:"client_email"`
+			Fee         *string `json:"fee"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var template Template
+		err := db.QueryRow(`SELECT template_id, body FROM agreement_templates WHERE template_id = ? AND active`, req.TemplateID).
+			Scan(&template.TemplateID, &template.Body)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Active template not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading template %d: %v", req.TemplateID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		fields, customerEmail, err := mergeFieldsFor(db, inspectionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading merge fields for %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if req.ClientName != "" {
+			fields["client_name"] = req.ClientName
+		}
+		if req.Fee != nil {
+			if _, err := strconv.ParseFloat(*req.Fee, 64); err != nil {
+				http.Error(w, "fee must be a number", http.StatusBadRequest)
+				return
+			}
+			fields["fee"] = *req.Fee
+		}
+		if fields["client_name"] == "" {
+			http.Error(w, "client_name is required when the inspection has no customer", http.StatusBadRequest)
+			return
+		}
+		email := req.ClientEmail
+		if email == "" {
+			email = customerEmail
+		}
+		var fee interface{}
+		if fields["fee"] != "" {
+			fee = fields["fee"]
+		}
+
+		token, tokenHash, err := utils.NewLinkToken()
+		if err != nil {
+			http.Error(w, "Failed to issue agreement", http.StatusInternalServerError)
+			return
+		}
+		body := Merge(template.Body, fields)
+		agreement := Agreement{
+			AgreementID:  uuid.New().String(),
+			InspectionID: inspectionID,
+			TemplateID:   &template.TemplateID,
+			Status:       "pending",
+			ClientName:   fields["client_name"],
+			ClientEmail:  email,
+			Body:         body,
+			BodyHash:     sha256Hex([]byte(body)),
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`UPDATE inspection_agreements SET status = 'void' WHERE inspection_id = ? AND status = 'pending'`, inspectionID)
+		if err == nil {
+			_, err = tx.Exec(`
+				INSERT INTO inspection_agreements (agreement_id, inspection_id, template_id, status, client_name, client_email, fee, body, body_hash, signing_token_hash)
+				VALUES (?, ?, ?, 'pending', ?, ?, ?, ?, ?, ?)`,
+				agreement.AgreementID, inspectionID, template.TemplateID, agreement.ClientName, email, fee, body, agreement.BodyHash, tokenHash)
+		}
+		var issued *Agreement
+		if err == nil {
+			issued, err = scanAgreement(tx.QueryRow(agreementSelect+` WHERE agreement_id = ?`, agreement.AgreementID))
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error issuing agreement for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to issue agreement", http.StatusInternalServerError)
+			return
+		}
+		// Only the hash is stored, so this is the one response that carries the signing link
+		issued.SigningToken = token
+		issued.SigningURLPath = "/api/agreements/sign/" + token
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(issued)
+	}
+}
+
+// GetInspectionAgreement returns the inspection's current agreement (signed, or the latest issued).
+// The signing link is only returned when the agreement is issued; reissue it to get a new one.
+func GetInspectionAgreement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "manage agreements") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+		agreement, err := scanAgreement(db.QueryRow(agreementSelect+`
+			WHERE inspection_id = ? AND status <> 'void'
+			ORDER BY status = 'signed' DESC, created_at DESC LIMIT 1`, inspectionID))
+		if err == sql.ErrNoRows {
+			http.Error(w, "No agreement issued for this inspection", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading agreement for %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(agreement)
+	}
+}
+
+// VerifyAgreement recomputes the hash of a signed agreement to show it hasn't been altered
+func VerifyAgreement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		agreement, err := scanAgreement(db.QueryRow(agreementSelect+` WHERE agreement_id = ?`, mux.Vars(r)["agreement_id"]))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Agreement not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading agreement: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if agreement.Status != "signed" || agreement.SignedAt == nil {
+			http.Error(w, "Agreement has not been signed", http.StatusConflict)
+			return
+		}
+
+		computed := signedDocument{
+			AgreementID:   agreement.AgreementID,
+			InspectionID:  agreement.InspectionID,
+			Body:          agreement.Body,
+			BodyHash:      agreement.BodyHash,
+			SignerName:    agreement.SignerName,
+			SignatureType: agreement.SignatureType,
+			Signature:     agreement.Signature,
+			SignerIP:      agreement.SignerIP,
+			SignerAgent:   agreement.SignerAgent,
+			SignedAt:      *agreement.SignedAt,
+		}.hash()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"agreement_id":  agreement.AgreementID,
+			"document_hash": agreement.DocumentHash,
+			"computed_hash": computed,
+			"body_intact":   sha256Hex([]byte(agreement.Body)) == agreement.BodyHash,
+			"valid":         computed == agreement.DocumentHash,
+			"signed_at":     agreement.SignedAt,
+		})
+	}
+}
+
+// GetAgreementForSigning is the public view of an agreement behind its signing link
+func GetAgreementForSigning(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		agreement, err := scanAgreement(db.QueryRow(agreementSelect+` WHERE signing_token_hash = ?`, utils.HashLinkToken(mux.Vars(r)["token"])))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Agreement not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading agreement: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"agreement_id": agreement.AgreementID,
+			"status":       agreement.Status,
+			"client_name":  agreement.ClientName,
+			"body":         agreement.Body,
+			"body_hash":    agreement.BodyHash,
+			"signed_at":    agreement.SignedAt,
+		})
+	}
+}
+
+func validateSignature(signatureType, signature string) string {
+	switch signatureType {
+	case "typed":
+		if strings.TrimSpace(signature) == "" {
+			return "a typed signature must not be empty"
+		}
+	case "drawn":
+		const prefix = "data:image/png;base64,"
+		if !strings.HasPrefix(signature, prefix) {
+			return "a drawn signature must be a PNG data URL"
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(signature, prefix))
+		if err != nil || len(data) == 0 {
+			return "a drawn signature must be valid base64 PNG data"
+		}
+		if len(data) > maxDrawnSignatureBytes {
+			return "the drawn signature image is too large"
+		}
+	default:
+		return "signature_type must be typed or drawn"
+	}
+	return ""
+}
+
+// SignAgreement records the client's signature. The client echoes the body_hash it was shown, so the
+// signature is bound to that exact text; the signed record is then hashed and frozen.
+func SignAgreement(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 2*maxDrawnSignatureBytes)
+		var req struct {
+			SignerName    string `json:"signer_name"`
+			SignatureType string `json:"signature_type"`
+			Signature     string `json:"signature"`
+			BodyHash      string `json:"body_hash"`
+			Agree         bool   `json:"agree"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if !req.Agree || strings.TrimSpace(req.SignerName) == "" {
+			http.Error(w, "signer_name and agree are required", http.StatusBadRequest)
+			return
+		}
+		if msg := validateSignature(req.SignatureType, req.Signature); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+
+		agreement, err := scanAgreement(db.QueryRow(agreementSelect+` WHERE signing_token_hash = ?`, utils.HashLinkToken(mux.Vars(r)["token"])))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Agreement not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Agreements] Error loading agreement: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if agreement.Status != "pending" {
+			http.Error(w, "This agreement can no longer be signed", http.StatusConflict)
+			return
+		}
+		if req.BodyHash != agreement.BodyHash {
+			http.Error(w, "The agreement text has changed; reload it before signing", http.StatusConflict)
+			return
+		}
+
+		doc := signedDocument{
+			AgreementID:   agreement.AgreementID,
+			InspectionID:  agreement.InspectionID,
+			Body:          agreement.Body,
+			BodyHash:      agreement.BodyHash,
+			SignerName:    strings.TrimSpace(req.SignerName),
+			SignatureType: req.SignatureType,
+			Signature:     req.Signature,
+			SignerIP:      middleware.ClientIP(r),
+			SignerAgent:   r.UserAgent(),
+			SignedAt:      time.Now().UTC().Format("2006-01-02 15:04:05"),
+		}
+		documentHash := doc.hash()
+
+		res, err := db.Exec(`
+			UPDATE inspection_agreements
+			SET status = 'signed', signer_name = ?, signature_type = ?, signature = ?, signer_ip = ?,
+			    signer_user_agent = ?, signed_at = ?, document_hash = ?
+			WHERE agreement_id = ? AND status = 'pending'`,
+			doc.SignerName, doc.SignatureType, doc.Signature, doc.SignerIP, doc.SignerAgent, doc.SignedAt, documentHash, agreement.AgreementID)
+		if err != nil {
+			log.Printf("[Agreements] Error signing agreement %s: %v", agreement.AgreementID, err)
+			http.Error(w, "Failed to sign agreement", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "This agreement can no longer be signed", http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":       "Agreement signed",
+			"agreement_id":  agreement.AgreementID,
+			"signed_at":     doc.SignedAt,
+			"document_hash": documentHash,
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,8 @@ import (
 	"os"
 	"strings"
 
+	"home_solutions/backend/handlers/inspections"
+
 	"github.com/gorilla/mux"
 )
 
@@ -127,8 +130,13 @@ Uploaded Inspection Photo Descriptions:
 	return result.Choices[0].Message.Content, nil
 }
 
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 // Save analysis result to the database
-func SaveAnalysisResult(db *sql.DB, inspectionID string, analysisText string) error {
+func SaveAnalysisResult(db execer, inspectionID string, analysisText string) error {
 	const query = `
 		INSERT INTO inspection_analysis (inspection_id, analysis_text, created_at)
 		VALUES (?, ?, NOW())
@@ -196,6 +204,11 @@ func AnalyzeAndSaveHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Inspection ID not found", http.StatusBadRequest)
 			return
 		}
+		// Checked up front so a locked report doesn't cost an analysis call, and again when saving
+		if err := inspections.EnsureEditable(db, req.InspectionID); errors.Is(err, inspections.ErrInspectionLocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 
 		result, err := AnalyzeInspection(req.Text, req.PhotoDescriptions)
 		if err != nil {
@@ -204,7 +217,22 @@ func AnalyzeAndSaveHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		if err := SaveAnalysisResult(db, req.InspectionID, result); err != nil {
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "DB error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		if err := inspections.EnsureEditable(tx, req.InspectionID); err != nil {
+			if errors.Is(err, inspections.ErrInspectionLocked) {
+				http.Error(w, err.Error(), http.StatusConflict)
+			} else {
+				http.Error(w, "DB error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if err := SaveAnalysisResult(tx, req.InspectionID, result); err != nil {
 			log.Println("❌ Failed to save analysis to DB:", err)
 			http.Error(w, "Failed to save analysis", http.StatusInternalServerError)
 			return
@@ -213,11 +241,15 @@ func AnalyzeAndSaveHandler(db *sql.DB) http.HandlerFunc {
 		parsedCards := ParseIssueCards(result)
 		score, breakdown := CalculateHomeHealthScore(parsedCards)
 
-		if err := SaveHomeHealthScore(db, req.PropertyID, req.InspectionID, score, breakdown, "professional"); err != nil {
+		if err := SaveHomeHealthScore(tx, req.PropertyID, req.InspectionID, score, breakdown, "professional"); err != nil {
 			log.Println("❌ Failed to save home health score to DB:", err)
 			http.Error(w, "Failed to save health score", http.StatusInternalServerError)
 			return
 		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Failed to save analysis", http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -345,7 +377,7 @@ func mapIssueToCategory(title string) string {
 }
 
 // Save home health score to database
-func SaveHomeHealthScore(db *sql.DB, propertyID, inspectionID string, score float64, breakdown map[string]float64, source string) error {
+func SaveHomeHealthScore(db execer, propertyID, inspectionID string, score float64, breakdown map[string]float64, source string) error {
 	breakdownJSON, err := json.Marshal(breakdown)
 	if err != nil {
 		return fmt.Errorf("failed to marshal breakdown: %v", err)
@@ -0,0 +1,239 @@
+package archive
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// ArchiveFormat identifies an inspection archive; ArchiveSchemaVersion is bumped whenever the
+// manifest layout changes, and imports refuse versions they don't know.
+const (
+	ArchiveFormat        = "home_solutions.inspection_archive"
+	ArchiveSchemaVersion = 1
+	manifestName         = "manifest.json"
+)
+
+// ManifestProperty is the inspected property, with everything needed to find or recreate it
+type ManifestProperty struct {
+	PropertyID       string   `json:"property_id"`
+	Street           string   `json:"street"`
+	City             string   `json:"city"`
+	State            string   `json:"state"`
+	PostalCode       string   `json:"postal_code"`
+	PostalCodeSuffix string   `json:"postal_code_suffix"`
+	Country          string   `json:"country"`
+	YearBuilt        *int     `json:"year_built"`
+	SquareFootage    *int     `json:"square_footage"`
+	Bedrooms         *int     `json:"bedrooms"`
+	Bathrooms        *float32 `json:"bathrooms"`
+	LotSize          *float64 `json:"lot_size"`
+	PropertyType     *string  `json:"property_type"`
+	Latitude         *float64 `json:"latitude"`
+	Longitude        *float64 `json:"longitude"`
+}
+
+// ManifestInspection is the inspection's cover details; Status is informational, imports always start in progress
+type ManifestInspection struct {
+	publishing.SnapshotInspection
+	Status string `json:"status"`
+}
+
+// ManifestPhoto points at a photo file inside the archive
+type ManifestPhoto struct {
+	PhotoID  int    `json:"photo_id"`
+	ItemName string `json:"item_name"`
+	File     string `json:"file"`
+	SHA256   string `json:"sha256"`
+}
+
+// Manifest is the manifest.json at the root of an inspection archive. Ids are those of the
+// exporting system; an import assigns new ones and remaps the references between them.
+type Manifest struct {
+	Format        string                          `json:"format"`
+	SchemaVersion int                             `json:"schema_version"`
+	ExportedAt    string                          `json:"exported_at"` // UTC, RFC 3339
+	Property      ManifestProperty                `json:"property"`
+	Inspection    ManifestInspection              `json:"inspection"`
+	Sections      []publishing.SnapshotSection    `json:"sections"`
+	Photos        []ManifestPhoto                 `json:"photos"`
+	Defects       []inspections.Defect            `json:"defects"` // every status, manual ones included
+	Analysis      *string                         `json:"analysis"`
+	HealthScore   *publishing.SnapshotHealthScore `json:"health_score"`
+}
+
+// buildManifest collects the current contents of an inspection. Photos whose file is missing
+// from uploads are left out, since the archive couldn't carry them.
+func buildManifest(db *sql.DB, inspectionID string) (*Manifest, error) {
+	snap, err := publishing.BuildSnapshot(db, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Format:        ArchiveFormat,
+		SchemaVersion: ArchiveSchemaVersion,
+		ExportedAt:    time.Now().UTC().Format(time.RFC3339),
+		Inspection:    ManifestInspection{SnapshotInspection: snap.Inspection},
+		Sections:      snap.Sections,
+		Photos:        []ManifestPhoto{},
+		Analysis:      snap.Analysis,
+		HealthScore:   snap.HealthScore,
+	}
+
+	var status sql.NullString
+	if err := db.QueryRow(`SELECT status FROM inspections WHERE inspection_id = ?`, inspectionID).Scan(&status); err != nil {
+		return nil, err
+	}
+	m.Inspection.Status = status.String
+
+	p := &m.Property
+	var suffix sql.NullString
+	err = db.QueryRow(`
+		SELECT property_id, street, city, state, postal_code, postal_code_suffix, country, year_built, square_footage,
+			bedrooms, bathrooms, lot_size, property_type, latitude, longitude
+		FROM properties WHERE property_id = ?`, snap.Property.PropertyID).Scan(
+		&p.PropertyID, &p.Street, &p.City, &p.State, &p.PostalCode, &suffix, &p.Country, &p.YearBuilt, &p.SquareFootage,
+		&p.Bedrooms, &p.Bathrooms, &p.LotSize, &p.PropertyType, &p.Latitude, &p.Longitude)
+	if err != nil {
+		return nil, fmt.Errorf("loading property: %v", err)
+	}
+	p.PostalCodeSuffix = suffix.String
+
+	for _, photo := range snap.Photos {
+		if photo.SHA256 == "" {
+			log.Printf("[Archive] Photo %d of %s has no file at %s; leaving it out", photo.PhotoID, inspectionID, photo.URL)
+			continue
+		}
+		m.Photos = append(m.Photos, ManifestPhoto{
+			PhotoID:  photo.PhotoID,
+			ItemName: photo.ItemName,
+			File:     fmt.Sprintf("photos/%d%s", photo.PhotoID, strings.ToLower(path.Ext(photo.URL))),
+			SHA256:   photo.SHA256,
+		})
+	}
+
+	m.Defects, err = inspections.LoadDefects(db, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("loading defects: %v", err)
+	}
+	return m, nil
+}
+
+// photoURLs maps the manifest's photo ids back to their stored files
+func photoURLs(db *sql.DB, inspectionID string) (map[int]string, error) {
+	rows, err := db.Query(`SELECT photo_id, photo_url FROM inspection_photos WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	urls := map[int]string{}
+	for rows.Next() {
+		var id int
+		var url string
+		if err := rows.Scan(&id, &url); err != nil {
+			return nil, err
+		}
+		urls[id] = url
+	}
+	return urls, rows.Err()
+}
+
+// ExportInspection streams the inspection as a ZIP archive: manifest.json plus every photo file
+// under photos/. The manifest is assembled first so a database error can still return a status.
+func ExportInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "export or import inspections") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+
+		var reportID sql.NullString
+		err := db.QueryRow(`SELECT report_id FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionID).Scan(&reportID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Archive] Error loading inspection %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		manifest, err := buildManifest(db, inspectionID)
+		if err != nil {
+			log.Printf("[Archive] Error building manifest for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to export inspection", http.StatusInternalServerError)
+			return
+		}
+		urls, err := photoURLs(db, inspectionID)
+		if err != nil {
+			log.Printf("[Archive] Error loading photos for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to export inspection", http.StatusInternalServerError)
+			return
+		}
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			http.Error(w, "Failed to export inspection", http.StatusInternalServerError)
+			return
+		}
+
+		name := inspectionID
+		if reportID.Valid && reportID.String != "" {
+			name = reportID.String
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "inspection-"+name+".zip"))
+
+		// From here on the response is committed; failures can only be logged
+		zw := zip.NewWriter(w)
+		if err := writeEntry(zw, manifestName, zip.Deflate, func(dst io.Writer) error {
+			_, err := dst.Write(data)
+			return err
+		}); err != nil {
+			log.Printf("[Archive] Error writing manifest for %s: %v", inspectionID, err)
+			return
+		}
+		for _, photo := range manifest.Photos {
+			// Photos are already compressed; storing them saves time without growing the archive
+			err := writeEntry(zw, photo.File, zip.Store, func(dst io.Writer) error {
+				f, err := os.Open("." + urls[photo.PhotoID])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = io.Copy(dst, f)
+				return err
+			})
+			if err != nil {
+				log.Printf("[Archive] Error writing photo %d for %s: %v", photo.PhotoID, inspectionID, err)
+				return
+			}
+		}
+		if err := zw.Close(); err != nil {
+			log.Printf("[Archive] Error finishing archive for %s: %v", inspectionID, err)
+		}
+	}
+}
+
+func writeEntry(zw *zip.Writer, name string, method uint16, write func(io.Writer) error) error {
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return write(dst)
+}
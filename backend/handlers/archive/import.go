@@ -0,0 +1,330 @@
+package archive
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/properties"
+	"home_solutions/backend/middleware"
+)
+
+const (
+	maxArchiveSize  = 500 << 20
+	maxManifestSize = 20 << 20
+	maxPhotoSize    = 50 << 20
+)
+
+// ImportResult describes the property and inspection created from an archive, with the id maps
+// from the archive's photos and defects to the new ones
+type ImportResult struct {
+	PropertyID      string `json:"property_id"`
+	PropertyCreated bool   `json:"property_created"`
+	*inspections.ImportResult
+}
+
+// archiveError is a problem with the uploaded archive itself, reported to the caller as a 400
+type archiveError struct{ msg string }
+
+func (e *archiveError) Error() string { return e.msg }
+
+func invalidArchive(format string, args ...interface{}) error {
+	return &archiveError{msg: fmt.Sprintf(format, args...)}
+}
+
+// readManifest loads and checks manifest.json, and indexes the archive's files by name
+func readManifest(zr *zip.Reader) (*Manifest, map[string]*zip.File, error) {
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	mf, ok := files[manifestName]
+	if !ok {
+		return nil, nil, invalidArchive("archive has no %s", manifestName)
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, nil, invalidArchive("unreadable %s: %v", manifestName, err)
+	}
+	defer rc.Close()
+
+	// Peek at the header first so a newer archive is reported as such rather than as a decode error
+	data, err := io.ReadAll(io.LimitReader(rc, maxManifestSize+1))
+	if err != nil {
+		return nil, nil, invalidArchive("unreadable %s: %v", manifestName, err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, nil, invalidArchive("%s is too large", manifestName)
+	}
+	var header struct {
+		Format        string `json:"format"`
+		SchemaVersion int    `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, nil, invalidArchive("%s is not valid JSON", manifestName)
+	}
+	if header.Format != ArchiveFormat {
+		return nil, nil, invalidArchive("not an inspection archive (format %q)", header.Format)
+	}
+	if header.SchemaVersion < 1 || header.SchemaVersion > ArchiveSchemaVersion {
+		return nil, nil, invalidArchive("unsupported schema version %d (supported: %d)", header.SchemaVersion, ArchiveSchemaVersion)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, invalidArchive("invalid manifest: %v", err)
+	}
+	p := m.Property
+	if p.Street == "" || p.City == "" || p.State == "" || p.PostalCode == "" {
+		return nil, nil, invalidArchive("manifest property is missing its address")
+	}
+	if in := m.Inspection.InspectionDate; in != nil {
+		if _, err := time.Parse("2006-01-02", *in); err != nil {
+			return nil, nil, invalidArchive("invalid inspection date %q", *in)
+		}
+	}
+	for _, s := range m.Sections {
+		if _, ok := inspections.SectionByKey(s.Key); !ok {
+			return nil, nil, invalidArchive("unknown section %q", s.Key)
+		}
+		for _, item := range s.Items {
+			if strings.TrimSpace(item.ItemName) == "" {
+				return nil, nil, invalidArchive("%s item without a name", s.Key)
+			}
+			if _, ok := inspections.NormalizeInspectionStatus(item.InspectionStatus); !ok {
+				return nil, nil, invalidArchive("%s item %q has invalid inspection_status %q", s.Key, item.ItemName, item.InspectionStatus)
+			}
+		}
+	}
+	for _, d := range m.Defects {
+		if _, ok := inspections.SectionByKey(d.Section); !ok {
+			return nil, nil, invalidArchive("defect %d is in unknown section %q", d.DefectID, d.Section)
+		}
+		if s, ok := inspections.ParseSeverity(d.Severity); !ok || s == inspections.SeverityNone {
+			return nil, nil, invalidArchive("defect %d has invalid severity %q", d.DefectID, d.Severity)
+		}
+		if d.Status != inspections.DefectOpen && d.Status != inspections.DefectCleared && d.Status != inspections.DefectDismissed {
+			return nil, nil, invalidArchive("defect %d has invalid status %q", d.DefectID, d.Status)
+		}
+		if d.CostMin != nil && d.CostMax != nil && *d.CostMin > *d.CostMax {
+			return nil, nil, invalidArchive("defect %d has cost_min above cost_max", d.DefectID)
+		}
+	}
+	for _, photo := range m.Photos {
+		if _, ok := files[photo.File]; !ok {
+			return nil, nil, invalidArchive("photo %d is listed but %s is not in the archive", photo.PhotoID, photo.File)
+		}
+	}
+	return &m, files, nil
+}
+
+// storePhotos copies the archive's photos into uploads, checking each against its manifest digest.
+// It returns the stored URLs by archive photo id; on error, files already written are removed.
+func storePhotos(m *Manifest, files map[string]*zip.File) (map[int]string, error) {
+	urls := map[int]string{}
+	for _, photo := range m.Photos {
+		url, err := storePhoto(photo, files[photo.File])
+		if err != nil {
+			removePhotos(urls)
+			return nil, err
+		}
+		urls[photo.PhotoID] = url
+	}
+	return urls, nil
+}
+
+func storePhoto(photo ManifestPhoto, f *zip.File) (string, error) {
+	ext := strings.ToLower(path.Ext(photo.File))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic":
+	default:
+		return "", invalidArchive("photo %d has unsupported file type %q", photo.PhotoID, ext)
+	}
+	if f.UncompressedSize64 > maxPhotoSize {
+		return "", invalidArchive("photo %d is too large", photo.PhotoID)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", invalidArchive("unreadable photo %d: %v", photo.PhotoID, err)
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	url, err := inspections.StoreInspectionPhotoFile(io.TeeReader(io.LimitReader(rc, maxPhotoSize), h), photo.File)
+	if err != nil {
+		return "", err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != photo.SHA256 {
+		os.Remove("." + url)
+		return "", invalidArchive("photo %d does not match its checksum", photo.PhotoID)
+	}
+	return url, nil
+}
+
+func removePhotos(urls map[int]string) {
+	for _, url := range urls {
+		if err := os.Remove("." + url); err != nil {
+			log.Printf("[Archive] Error removing imported photo %s: %v", url, err)
+		}
+	}
+}
+
+// importManifest recreates the manifest's property and inspection in one transaction
+func importManifest(db *sql.DB, m *Manifest, urls map[int]string, authorID *int) (*ImportResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	p := m.Property
+	propertyID, created, err := properties.FindOrCreateProperty(tx, properties.AddressDetails{
+		Street:           p.Street,
+		City:             p.City,
+		State:            p.State,
+		PostalCode:       p.PostalCode,
+		PostalCodeSuffix: p.PostalCodeSuffix,
+		Country:          p.Country,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = properties.FillPropertyDetails(tx, properties.PropertyDetails{
+		PropertyID:    propertyID,
+		YearBuilt:     p.YearBuilt,
+		SquareFootage: p.SquareFootage,
+		Bedrooms:      p.Bedrooms,
+		Bathrooms:     p.Bathrooms,
+		LotSize:       p.LotSize,
+		PropertyType:  p.PropertyType,
+		Latitude:      p.Latitude,
+		Longitude:     p.Longitude,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	in := m.Inspection
+	imported := inspections.ImportedInspection{
+		PropertyID:        propertyID,
+		Temperature:       in.Temperature,
+		Weather:           in.Weather,
+		GroundCondition:   in.GroundCondition,
+		RainLastThreeDays: in.RainLastThreeDays,
+		RadonTest:         in.RadonTest,
+		MoldTest:          in.MoldTest,
+		SOPStandard:       in.SOPStandard,
+		Items:             map[string][]inspections.WorksheetItem{},
+		Defects:           m.Defects,
+	}
+	if in.InspectionDate != nil {
+		imported.InspectionDate = *in.InspectionDate
+	}
+	for _, s := range m.Sections {
+		imported.Items[s.Key] = append(imported.Items[s.Key], s.Items...)
+	}
+	for _, photo := range m.Photos {
+		imported.Photos = append(imported.Photos, inspections.ImportedPhoto{SourceID: photo.PhotoID, ItemName: photo.ItemName, URL: urls[photo.PhotoID]})
+	}
+
+	result, err := inspections.ImportInspection(tx, imported, authorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Analysis != nil {
+		if _, err := tx.Exec(`INSERT INTO inspection_analysis (inspection_id, analysis_text) VALUES (?, ?)`, result.InspectionID, *m.Analysis); err != nil {
+			return nil, err
+		}
+	}
+	if m.HealthScore != nil {
+		breakdown, err := json.Marshal(m.HealthScore.Breakdown)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(`INSERT INTO home_health_score (property_id, inspection_id, score, breakdown) VALUES (?, ?, ?, ?)`,
+			propertyID, result.InspectionID, m.HealthScore.Score, string(breakdown))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &ImportResult{PropertyID: propertyID, PropertyCreated: created, ImportResult: result}, nil
+}
+
+// ImportInspection recreates an inspection from an archive made by ExportInspection, uploaded as
+// the multipart field "archive". The property is matched by address like a new address entry;
+// the inspection, its photos and its defects get new ids and start in progress.
+func ImportInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "export or import inspections") {
+			return
+		}
+		authorID := middleware.CallerID(r)
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxArchiveSize)
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, "Archive is missing or too large", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("archive")
+		if err != nil {
+			http.Error(w, "Archive is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		zr, err := zip.NewReader(file, header.Size)
+		if err != nil {
+			http.Error(w, "Archive is not a valid ZIP file", http.StatusBadRequest)
+			return
+		}
+		manifest, files, err := readManifest(zr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		urls, err := storePhotos(manifest, files)
+		if _, ok := err.(*archiveError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("[Archive] Error storing imported photos: %v", err)
+			http.Error(w, "Failed to store photos", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := importManifest(db, manifest, urls, authorID)
+		if err != nil {
+			removePhotos(urls)
+			if _, ok := err.(*archiveError); ok {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("[Archive] Error importing inspection: %v", err)
+			http.Error(w, "Failed to import inspection", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[Archive] Imported inspection %s as %s", manifest.Inspection.InspectionID, result.InspectionID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+)
+
+func validManifest() *Manifest {
+	date := "2026-03-14"
+	costMin, costMax := 200.0, 500.0
+	return &Manifest{
+		Format:        ArchiveFormat,
+		SchemaVersion: ArchiveSchemaVersion,
+		Property:      ManifestProperty{Street: "12 Elm St", City: "Springfield", State: "IL", PostalCode: "62701"},
+		Inspection:    ManifestInspection{SnapshotInspection: publishing.SnapshotInspection{InspectionDate: &date}},
+		Sections: []publishing.SnapshotSection{{Key: "roof", Items: []inspections.WorksheetItem{
+			{ItemName: "Flashing", InspectionStatus: "Inspected"},
+			{ItemName: "Gutters", InspectionStatus: ""},
+		}}},
+		Photos:  []ManifestPhoto{{PhotoID: 7, ItemName: "Flashing", File: "photos/7.jpg"}},
+		Defects: []inspections.Defect{{DefectID: 3, Section: "roof", Severity: "repair", Status: inspections.DefectOpen, CostMin: &costMin, CostMax: &costMax}},
+	}
+}
+
+// buildArchive zips the manifest (raw JSON when manifest is a []byte) with the given extra files
+func buildArchive(t *testing.T, manifest interface{}, files ...string) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if manifest != nil {
+		data, ok := manifest.([]byte)
+		if !ok {
+			var err error
+			if data, err = json.Marshal(manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f, err := zw.Create(manifestName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	for _, name := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte("jpeg"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(m *Manifest)
+		raw      []byte // sent instead of the manifest when set
+		noPhotos bool
+		wantErr  string // empty for a valid archive
+	}{
+		{name: "valid"},
+		{name: "wrong format", change: func(m *Manifest) { m.Format = "something.else" }, wantErr: "not an inspection archive"},
+		{name: "newer schema", change: func(m *Manifest) { m.SchemaVersion = ArchiveSchemaVersion + 1 }, wantErr: "unsupported schema version"},
+		{name: "missing schema", change: func(m *Manifest) { m.SchemaVersion = 0 }, wantErr: "unsupported schema version"},
+		{name: "not JSON", raw: []byte("{nope"), wantErr: "not valid JSON"},
+		{name: "missing address", change: func(m *Manifest) { m.Property.City = "" }, wantErr: "missing its address"},
+		{name: "bad date", change: func(m *Manifest) {
+			d := "14/03/2026"
+			m.Inspection.InspectionDate = &d
+		}, wantErr: "invalid inspection date"},
+		{name: "unknown section", change: func(m *Manifest) { m.Sections[0].Key = "garage" }, wantErr: `unknown section "garage"`},
+		{name: "unnamed item", change: func(m *Manifest) { m.Sections[0].Items[0].ItemName = "  " }, wantErr: "item without a name"},
+		{name: "bad item status", change: func(m *Manifest) { m.Sections[0].Items[0].InspectionStatus = "Fine" }, wantErr: "invalid inspection_status"},
+		{name: "defect section", change: func(m *Manifest) { m.Defects[0].Section = "garage" }, wantErr: "defect 3 is in unknown section"},
+		{name: "defect without severity", change: func(m *Manifest) { m.Defects[0].Severity = "none" }, wantErr: "defect 3 has invalid severity"},
+		{name: "defect status", change: func(m *Manifest) { m.Defects[0].Status = "fixed" }, wantErr: "defect 3 has invalid status"},
+		{name: "defect costs", change: func(m *Manifest) {
+			high := 1000.0
+			m.Defects[0].CostMin = &high
+		}, wantErr: "cost_min above cost_max"},
+		{name: "photo file missing", noPhotos: true, wantErr: "photos/7.jpg is not in the archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validManifest()
+			if tt.change != nil {
+				tt.change(m)
+			}
+			var manifest interface{} = m
+			if tt.raw != nil {
+				manifest = tt.raw
+			}
+			var files []string
+			if !tt.noPhotos {
+				files = append(files, "photos/7.jpg")
+			}
+
+			got, index, err := readManifest(buildArchive(t, manifest, files...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Property.Street != "12 Elm St" || index["photos/7.jpg"] == nil {
+					t.Errorf("manifest or file index not read back")
+				}
+				return
+			}
+			var archiveErr *archiveError
+			if !errors.As(err, &archiveErr) {
+				t.Fatalf("got %v, want an archive error containing %q", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadManifestMissing(t *testing.T) {
+	_, _, err := readManifest(buildArchive(t, nil, "photos/7.jpg"))
+	if err == nil || !strings.Contains(err.Error(), "archive has no manifest.json") {
+		t.Errorf("got %v, want a missing manifest error", err)
+	}
+}
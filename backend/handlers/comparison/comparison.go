@@ -0,0 +1,399 @@
+package comparison
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"home_solutions/backend/handlers/agreements"
+	"home_solutions/backend/handlers/inspections"
+
+	"github.com/gorilla/mux"
+)
+
+// Change classifications for a worksheet item between two inspections
+const (
+	ChangeNewDefect      = "new_defect"
+	ChangeResolved       = "resolved"
+	ChangeWorsened       = "worsened"
+	ChangeImproved       = "improved"
+	ChangeUnchanged      = "unchanged"
+	ChangeNotReinspected = "not_reinspected"
+)
+
+type InspectionRef struct {
+	InspectionID   string  `json:"inspection_id"`
+	ReportID       string  `json:"report_id"`
+	InspectionDate *string `json:"inspection_date"`
+}
+
+type ItemComparison struct {
+	Section          string   `json:"section"`
+	SectionTitle     string   `json:"section_title"`
+	ItemName         string   `json:"item_name"`
+	Change           string   `json:"change"`
+	FromSeverity     string   `json:"from_severity"`
+	ToSeverity       string   `json:"to_severity"`
+	NewDefects       []string `json:"new_defects"`
+	ResolvedDefects  []string `json:"resolved_defects"`
+	OngoingDefects   []string `json:"ongoing_defects"`
+	FromStatus       string   `json:"from_status"`
+	ToStatus         string   `json:"to_status"`
+	MaterialsChanged []string `json:"materials_changed"`
+	FromComments     string   `json:"from_comments,omitempty"`
+	ToComments       string   `json:"to_comments,omitempty"`
+}
+
+type CategoryDelta struct {
+	Category string   `json:"category"`
+	From     *float64 `json:"from"`
+	To       *float64 `json:"to"`
+	Delta    *float64 `json:"delta"`
+}
+
+type HealthScoreDelta struct {
+	From       *float64        `json:"from"`
+	To         *float64        `json:"to"`
+	Delta      *float64        `json:"delta"`
+	Categories []CategoryDelta `json:"categories"`
+}
+
+type Comparison struct {
+	PropertyID  string           `json:"property_id"`
+	From        InspectionRef    `json:"from"`
+	To          InspectionRef    `json:"to"`
+	Summary     map[string]int   `json:"summary"`
+	Items       []ItemComparison `json:"items"`
+	HealthScore HealthScoreDelta `json:"health_score"`
+}
+
+func loadInspectionRef(db *sql.DB, propertyID, inspectionID string) (InspectionRef, error) {
+	ref := InspectionRef{InspectionID: inspectionID}
+	var reportID, inspectionDate sql.NullString
+	err := db.QueryRow(`SELECT report_id, inspection_date FROM inspections WHERE inspection_id = ? AND property_id = ?`,
+		inspectionID, propertyID).Scan(&reportID, &inspectionDate)
+	if err != nil {
+		return ref, err
+	}
+	ref.ReportID = reportID.String
+	if inspectionDate.Valid {
+		ref.InspectionDate = &inspectionDate.String
+	}
+	return ref, nil
+}
+
+// latestTwoInspections picks the default pair to compare: the property's previous and most recent inspections
+func latestTwoInspections(db *sql.DB, propertyID string) (string, string, error) {
+	rows, err := db.Query(`
+		SELECT inspection_id FROM inspections
+		WHERE property_id = ? AND deleted_at IS NULL
+		ORDER BY COALESCE(inspection_date, '0001-01-01') DESC, created_at DESC
+		LIMIT 2`, propertyID)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return "", "", err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		return "", "", sql.ErrNoRows
+	}
+	return ids[1], ids[0], rows.Err()
+}
+
+func sortedKeys(m map[string]inspections.Severity) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareItem classifies how one item changed. Missing items, or items left "Not Inspected"
+// in the later inspection, can't be called resolved, so they are reported as not re-inspected.
+func compareItem(section inspections.Section, itemName string, from, to *inspections.WorksheetItem) ItemComparison {
+	c := ItemComparison{
+		Section:          section.Key,
+		SectionTitle:     section.Title,
+		ItemName:         itemName,
+		NewDefects:       []string{},
+		ResolvedDefects:  []string{},
+		OngoingDefects:   []string{},
+		MaterialsChanged: []string{},
+	}
+
+	fromDefects, toDefects := map[string]inspections.Severity{}, map[string]inspections.Severity{}
+	fromSeverity, toSeverity := inspections.SeverityNone, inspections.SeverityNone
+	if from != nil {
+		fromDefects, fromSeverity = inspections.ItemDefects(*from), inspections.ItemSeverity(*from)
+		c.FromStatus, c.FromComments = from.InspectionStatus, from.Comments
+	}
+	if to != nil {
+		toDefects, toSeverity = inspections.ItemDefects(*to), inspections.ItemSeverity(*to)
+		c.ToStatus, c.ToComments = to.InspectionStatus, to.Comments
+	}
+	c.FromSeverity, c.ToSeverity = fromSeverity.String(), toSeverity.String()
+
+	for _, d := range sortedKeys(toDefects) {
+		if _, ok := fromDefects[d]; ok {
+			c.OngoingDefects = append(c.OngoingDefects, d)
+		} else {
+			c.NewDefects = append(c.NewDefects, d)
+		}
+	}
+	for _, d := range sortedKeys(fromDefects) {
+		if _, ok := toDefects[d]; !ok {
+			c.ResolvedDefects = append(c.ResolvedDefects, d)
+		}
+	}
+
+	if from != nil && to != nil {
+		seen := map[string]bool{}
+		for k, v := range from.Materials {
+			seen[k] = true
+			if to.Materials[k] != v {
+				c.MaterialsChanged = append(c.MaterialsChanged, k)
+			}
+		}
+		for k := range to.Materials {
+			if !seen[k] {
+				c.MaterialsChanged = append(c.MaterialsChanged, k)
+			}
+		}
+		sort.Strings(c.MaterialsChanged)
+	}
+
+	reinspected := to != nil && to.InspectionStatus != "Not Inspected"
+	switch {
+	case len(fromDefects) > 0 && !reinspected:
+		c.Change = ChangeNotReinspected
+	case len(fromDefects) == 0 && len(toDefects) > 0:
+		c.Change = ChangeNewDefect
+	case len(fromDefects) > 0 && len(toDefects) == 0:
+		c.Change = ChangeResolved
+	case toSeverity > fromSeverity || len(c.NewDefects) > 0:
+		c.Change = ChangeWorsened
+	case toSeverity < fromSeverity || len(c.ResolvedDefects) > 0:
+		c.Change = ChangeImproved
+	default:
+		c.Change = ChangeUnchanged
+	}
+	return c
+}
+
+// compareWorksheets diffs every item of every section, in report order
+func compareWorksheets(db *sql.DB, fromID, toID string) ([]ItemComparison, error) {
+	var items []ItemComparison
+	for _, section := range inspections.Sections {
+		fromItems, err := inspections.LoadWorksheetItems(db, section, fromID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s items: %v", section.Key, err)
+		}
+		toItems, err := inspections.LoadWorksheetItems(db, section, toID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s items: %v", section.Key, err)
+		}
+
+		byName := map[string]*inspections.WorksheetItem{}
+		var names []string
+		for i := range fromItems {
+			byName[fromItems[i].ItemName] = &fromItems[i]
+			names = append(names, fromItems[i].ItemName)
+		}
+		toByName := map[string]*inspections.WorksheetItem{}
+		for i := range toItems {
+			toByName[toItems[i].ItemName] = &toItems[i]
+			if _, ok := byName[toItems[i].ItemName]; !ok {
+				names = append(names, toItems[i].ItemName)
+			}
+		}
+
+		for _, name := range names {
+			items = append(items, compareItem(section, name, byName[name], toByName[name]))
+		}
+	}
+	return items, nil
+}
+
+// loadHealthScore returns the most recent home health score saved for an inspection, if any
+func loadHealthScore(db *sql.DB, inspectionID string) (*float64, map[string]float64, error) {
+	var score float64
+	var breakdownJSON sql.NullString
+	err := db.QueryRow(`
+		SELECT score, breakdown FROM home_health_score
+		WHERE inspection_id = ?
+		ORDER BY updated_at DESC, id DESC LIMIT 1`, inspectionID).Scan(&score, &breakdownJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	breakdown := map[string]float64{}
+	if breakdownJSON.Valid && breakdownJSON.String != "" {
+		if err := json.Unmarshal([]byte(breakdownJSON.String), &breakdown); err != nil {
+			return nil, nil, fmt.Errorf("invalid health score breakdown: %v", err)
+		}
+	}
+	return &score, breakdown, nil
+}
+
+func delta(from, to *float64) *float64 {
+	if from == nil || to == nil {
+		return nil
+	}
+	d := *to - *from
+	return &d
+}
+
+func compareHealthScores(db *sql.DB, fromID, toID string) (HealthScoreDelta, error) {
+	fromScore, fromBreakdown, err := loadHealthScore(db, fromID)
+	if err != nil {
+		return HealthScoreDelta{}, err
+	}
+	toScore, toBreakdown, err := loadHealthScore(db, toID)
+	if err != nil {
+		return HealthScoreDelta{}, err
+	}
+
+	result := HealthScoreDelta{From: fromScore, To: toScore, Delta: delta(fromScore, toScore), Categories: []CategoryDelta{}}
+	categories := map[string]bool{}
+	for c := range fromBreakdown {
+		categories[c] = true
+	}
+	for c := range toBreakdown {
+		categories[c] = true
+	}
+	for c := range categories {
+		cd := CategoryDelta{Category: c}
+		if v, ok := fromBreakdown[c]; ok {
+			cd.From = &v
+		}
+		if v, ok := toBreakdown[c]; ok {
+			cd.To = &v
+		}
+		cd.Delta = delta(cd.From, cd.To)
+		result.Categories = append(result.Categories, cd)
+	}
+	sort.Slice(result.Categories, func(i, j int) bool { return result.Categories[i].Category < result.Categories[j].Category })
+	return result, nil
+}
+
+// Compare builds the change report between two inspections of a property
+func Compare(db *sql.DB, propertyID, fromID, toID string) (*Comparison, error) {
+	from, err := loadInspectionRef(db, propertyID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := loadInspectionRef(db, propertyID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := compareWorksheets(db, fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+	health, err := compareHealthScores(db, fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := map[string]int{
+		ChangeNewDefect: 0, ChangeResolved: 0, ChangeWorsened: 0,
+		ChangeImproved: 0, ChangeUnchanged: 0, ChangeNotReinspected: 0,
+	}
+	for _, item := range items {
+		summary[item.Change]++
+	}
+	if items == nil {
+		items = []ItemComparison{}
+	}
+
+	return &Comparison{PropertyID: propertyID, From: from, To: to, Summary: summary, Items: items, HealthScore: health}, nil
+}
+
+// GetComparison diffs two inspections of a property. It expects JWTAuthMiddleware to run first.
+//
+// Query parameters: from and to (inspection ids; default to the previous and most recent inspection),
+// format ("json" or "html") and download (serve as an attachment).
+func GetComparison(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		propertyID := mux.Vars(r)["property_id"]
+		q := r.URL.Query()
+		fromID, toID := q.Get("from"), q.Get("to")
+
+		if propertyID == "" {
+			http.Error(w, "property_id required", http.StatusBadRequest)
+			return
+		}
+		if (fromID == "") != (toID == "") {
+			http.Error(w, "Provide both from and to, or neither", http.StatusBadRequest)
+			return
+		}
+		if fromID == "" {
+			var err error
+			fromID, toID, err = latestTwoInspections(db, propertyID)
+			if err == sql.ErrNoRows {
+				http.Error(w, "The property needs at least two inspections to compare", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("[Comparison] Error finding inspections for %s: %v", propertyID, err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if fromID == toID {
+			http.Error(w, "from and to must be different inspections", http.StatusBadRequest)
+			return
+		}
+		if !agreements.CanViewReport(db, w, r, fromID, toID) {
+			return
+		}
+
+		comparison, err := Compare(db, propertyID, fromID, toID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found for this property", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Comparison] Error comparing %s and %s: %v", fromID, toID, err)
+			http.Error(w, "Failed to compare inspections", http.StatusInternalServerError)
+			return
+		}
+
+		filename := fmt.Sprintf("comparison-%s-%s", comparison.From.ReportID, comparison.To.ReportID)
+		switch q.Get("format") {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			if q.Get("download") == "true" {
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(comparison)
+		case "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if q.Get("download") == "true" {
+				w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.html"`, filename))
+			}
+			if err := RenderHTML(w, comparison); err != nil {
+				log.Printf("[Comparison] Error rendering report: %v", err)
+			}
+		default:
+			http.Error(w, "format must be json or html", http.StatusBadRequest)
+		}
+	}
+}
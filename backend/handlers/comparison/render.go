@@ -0,0 +1,140 @@
+package comparison
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var changeLabels = map[string]string{
+	ChangeNewDefect:      "New defect",
+	ChangeResolved:       "Resolved",
+	ChangeWorsened:       "Worsened",
+	ChangeImproved:       "Improved",
+	ChangeUnchanged:      "Unchanged",
+	ChangeNotReinspected: "Not re-inspected",
+}
+
+// Sections of the rendered report, most urgent first; unchanged items are left out
+var reportChangeOrder = []string{ChangeNewDefect, ChangeWorsened, ChangeNotReinspected, ChangeImproved, ChangeResolved}
+
+type reportGroup struct {
+	Change string
+	Label  string
+	Items  []ItemComparison
+}
+
+var reportTemplate = template.Must(template.New("comparison").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"date": func(d *string) string {
+		if d == nil {
+			return "—"
+		}
+		return *d
+	},
+	// Overall scores are stored as percentages, category scores as 0–1 fractions
+	"score": func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%.1f", *v)
+	},
+	"pct": func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%.0f%%", *v*100)
+	},
+	"signedScore": func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%+.1f", *v)
+	},
+	"signedPct": func(v *float64) string {
+		if v == nil {
+			return "—"
+		}
+		return fmt.Sprintf("%+.0f pts", *v*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Inspection comparison {{.C.From.ReportID}} → {{.C.To.ReportID}}</title>
+<style>
+  body { font-family: Arial, Helvetica, sans-serif; color: #222; margin: 2rem; }
+  h1 { font-size: 1.5rem; margin-bottom: 0.25rem; }
+  h2 { font-size: 1.15rem; border-bottom: 2px solid #ddd; padding-bottom: 0.25rem; margin-top: 2rem; }
+  table { border-collapse: collapse; width: 100%; margin-top: 0.5rem; }
+  th, td { border: 1px solid #ddd; padding: 0.4rem 0.6rem; text-align: left; vertical-align: top; font-size: 0.9rem; }
+  th { background: #f5f5f5; }
+  .muted { color: #777; }
+  .summary td { text-align: center; font-size: 1.1rem; }
+  .new_defect h2, .worsened h2 { color: #b00020; }
+  .resolved h2, .improved h2 { color: #1b7f3b; }
+  .not_reinspected h2 { color: #a86400; }
+</style>
+</head>
+<body>
+<h1>Inspection comparison</h1>
+<p class="muted">Property {{.C.PropertyID}} · Report {{.C.From.ReportID}} ({{date .C.From.InspectionDate}}) compared with report {{.C.To.ReportID}} ({{date .C.To.InspectionDate}})</p>
+
+<table class="summary">
+  <tr>{{range .Order}}<th>{{index $.Labels .}}</th>{{end}}<th>Unchanged</th></tr>
+  <tr>{{range .Order}}<td>{{index $.C.Summary .}}</td>{{end}}<td>{{index .C.Summary "unchanged"}}</td></tr>
+</table>
+
+<h2>Home health score</h2>
+<table>
+  <tr><th>Category</th><th>Previous</th><th>Current</th><th>Change</th></tr>
+  <tr><td><strong>Overall</strong></td><td>{{score .C.HealthScore.From}}</td><td>{{score .C.HealthScore.To}}</td><td>{{signedScore .C.HealthScore.Delta}}</td></tr>
+  {{range .C.HealthScore.Categories}}
+  <tr><td>{{.Category}}</td><td>{{pct .From}}</td><td>{{pct .To}}</td><td>{{signedPct .Delta}}</td></tr>
+  {{end}}
+</table>
+
+{{range .Groups}}
+<div class="{{.Change}}">
+<h2>{{.Label}} ({{len .Items}})</h2>
+<table>
+  <tr><th>Section</th><th>Item</th><th>Severity</th><th>New conditions</th><th>Resolved conditions</th><th>Ongoing conditions</th><th>Comments</th></tr>
+  {{range .Items}}
+  <tr>
+    <td>{{.SectionTitle}}</td>
+    <td>{{.ItemName}}{{if .MaterialsChanged}}<br><span class="muted">Materials changed: {{join .MaterialsChanged ", "}}</span>{{end}}</td>
+    <td>{{.FromSeverity}} → {{.ToSeverity}}</td>
+    <td>{{join .NewDefects ", "}}</td>
+    <td>{{join .ResolvedDefects ", "}}</td>
+    <td>{{join .OngoingDefects ", "}}</td>
+    <td>{{if .ToComments}}{{.ToComments}}{{else}}<span class="muted">{{.FromComments}}</span>{{end}}</td>
+  </tr>
+  {{end}}
+</table>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML writes the comparison as a standalone HTML report
+func RenderHTML(w io.Writer, c *Comparison) error {
+	grouped := map[string][]ItemComparison{}
+	for _, item := range c.Items {
+		grouped[item.Change] = append(grouped[item.Change], item)
+	}
+	var groups []reportGroup
+	for _, change := range reportChangeOrder {
+		if len(grouped[change]) > 0 {
+			groups = append(groups, reportGroup{Change: change, Label: changeLabels[change], Items: grouped[change]})
+		}
+	}
+
+	return reportTemplate.Execute(w, map[string]interface{}{
+		"C":      c,
+		"Order":  reportChangeOrder,
+		"Labels": changeLabels,
+		"Groups": groups,
+	})
+}
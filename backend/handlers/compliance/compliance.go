@@ -0,0 +1,413 @@
+package compliance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultStandard applies when an inspection doesn't name the standard it's performed under
+const DefaultStandard = "InterNACHI"
+
+// Rule requires a component to be reported. When ReasonAllowed is set the component may instead be
+// marked "Not Inspected" or "Not Present", as long as the comments give the reason.
+type Rule struct {
+	RuleID        int    `json:"rule_id"`
+	Section       string `json:"section"`
+	ItemName      string `json:"item_name"`
+	ReasonAllowed bool   `json:"reason_allowed"`
+	Description   string `json:"description"`
+}
+
+// RuleSet groups the rules of a standard. A set without a state is the standard's baseline;
+// state sets add that state's licensing requirements on top of it.
+type RuleSet struct {
+	RuleSetID int     `json:"rule_set_id"`
+	Standard  string  `json:"standard"`
+	State     *string `json:"state"`
+	Name      string  `json:"name"`
+	Rules     []Rule  `json:"rules"`
+}
+
+// Finding is the outcome of one rule for an inspection
+type Finding struct {
+	RuleSetID    int    `json:"rule_set_id"`
+	Section      string `json:"section"`
+	SectionTitle string `json:"section_title"`
+	ItemName     string `json:"item_name"`
+	Status       string `json:"inspection_status"`
+	Satisfied    bool   `json:"satisfied"`
+	Problem      string `json:"problem,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Report is the completeness report for an inspection against a standard
+type Report struct {
+	InspectionID string    `json:"inspection_id"`
+	Standard     string    `json:"standard"`
+	State        string    `json:"state"`
+	RuleSets     []string  `json:"rule_sets"`
+	Passed       bool      `json:"passed"`
+	Checked      int       `json:"checked"`
+	Satisfied    int       `json:"satisfied"`
+	Missing      []Finding `json:"missing"`
+	Findings     []Finding `json:"findings"`
+}
+
+// ErrNoRuleSet means no rules are configured for the requested standard
+var ErrNoRuleSet = errors.New("no rule set configured for this standard")
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so a check can run inside a publish transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func loadRules(db querier, ruleSetID int) ([]Rule, error) {
+	rows, err := db.Query(`SELECT rule_id, section, item_name, reason_allowed, COALESCE(description, '') FROM compliance_rules WHERE rule_set_id = ? ORDER BY rule_id`, ruleSetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []Rule{}
+	for rows.Next() {
+		var rule Rule
+		if err := rows.Scan(&rule.RuleID, &rule.Section, &rule.ItemName, &rule.ReasonAllowed, &rule.Description); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// LoadRuleSets returns rule sets with their rules. With a standard it returns the sets that apply
+// to an inspection in the given state: the baseline plus the state's own set, if any.
+func LoadRuleSets(db querier, standard, state string) ([]RuleSet, error) {
+	query := `SELECT rule_set_id, standard, state, name FROM compliance_rule_sets`
+	var args []interface{}
+	if standard != "" {
+		query += ` WHERE standard = ? AND (state IS NULL OR state = ?)`
+		args = append(args, standard, strings.ToUpper(state))
+	}
+	rows, err := db.Query(query+` ORDER BY standard, state IS NOT NULL, state`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []RuleSet
+	for rows.Next() {
+		var set RuleSet
+		var setState sql.NullString
+		if err := rows.Scan(&set.RuleSetID, &set.Standard, &setState, &set.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if setState.Valid {
+			set.State = &setState.String
+		}
+		sets = append(sets, set)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sets {
+		if sets[i].Rules, err = loadRules(db, sets[i].RuleSetID); err != nil {
+			return nil, err
+		}
+	}
+	return sets, nil
+}
+
+// inspectionStandard returns the standard an inspection is performed under and the property's state
+func inspectionStandard(db querier, inspectionID string) (string, string, error) {
+	var standard sql.NullString
+	var state string
+	err := db.QueryRow(`
+		SELECT i.sop_standard, p.state
+		FROM inspections i
+		JOIN properties p ON p.property_id = i.property_id
+		WHERE i.inspection_id = ?`, inspectionID).Scan(&standard, &state)
+	if err != nil {
+		return "", "", err
+	}
+	if !standard.Valid || standard.String == "" {
+		return DefaultStandard, state, nil
+	}
+	return standard.String, state, nil
+}
+
+// evaluate checks one rule against the item recorded for it, if any
+func evaluate(rule Rule, item *inspections.WorksheetItem) (bool, string) {
+	if item == nil || item.InspectionStatus == "" {
+		return false, "not reported"
+	}
+	// Items saved before statuses were normalized may differ from the canonical ones in case
+	status, _ := inspections.NormalizeInspectionStatus(item.InspectionStatus)
+	switch status {
+	case "Not Inspected", "Not Present":
+		if !rule.ReasonAllowed {
+			return false, "must be inspected under this standard"
+		}
+		if strings.TrimSpace(item.Comments) == "" {
+			return false, "marked " + strings.ToLower(status) + " without a reason in the comments"
+		}
+	}
+	return true, ""
+}
+
+// Check evaluates an inspection against the rules of a standard. An empty standard uses the one
+// recorded on the inspection.
+func Check(db querier, inspectionID, standard string) (*Report, error) {
+	recorded, state, err := inspectionStandard(db, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if standard == "" {
+		standard = recorded
+	}
+
+	sets, err := LoadRuleSets(db, standard, state)
+	if err != nil {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		return nil, ErrNoRuleSet
+	}
+
+	report := &Report{InspectionID: inspectionID, Standard: standard, State: state, Missing: []Finding{}, Findings: []Finding{}}
+	items := map[string]map[string]*inspections.WorksheetItem{}
+	for _, set := range sets {
+		report.RuleSets = append(report.RuleSets, set.Name)
+		for _, rule := range set.Rules {
+			section, ok := inspections.SectionByKey(rule.Section)
+			if !ok {
+				return nil, fmt.Errorf("rule %d refers to unknown section %q", rule.RuleID, rule.Section)
+			}
+			if _, loaded := items[section.Key]; !loaded {
+				sectionItems, err := inspections.LoadWorksheetItems(db, section, inspectionID)
+				if err != nil {
+					return nil, err
+				}
+				items[section.Key] = map[string]*inspections.WorksheetItem{}
+				for i := range sectionItems {
+					items[section.Key][sectionItems[i].ItemName] = &sectionItems[i]
+				}
+			}
+
+			item := items[section.Key][rule.ItemName]
+			finding := Finding{
+				RuleSetID:    set.RuleSetID,
+				Section:      section.Key,
+				SectionTitle: section.Title,
+				ItemName:     rule.ItemName,
+				Description:  rule.Description,
+			}
+			if item != nil {
+				finding.Status = item.InspectionStatus
+			}
+			finding.Satisfied, finding.Problem = evaluate(rule, item)
+
+			report.Checked++
+			if finding.Satisfied {
+				report.Satisfied++
+			} else {
+				report.Missing = append(report.Missing, finding)
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	report.Passed = len(report.Missing) == 0
+	return report, nil
+}
+
+// GetComplianceReport returns the completeness report for an inspection; ?standard= overrides the
+// standard recorded on the inspection
+func GetComplianceReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		report, err := Check(db, inspectionID, r.URL.Query().Get("standard"))
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrNoRuleSet):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			log.Printf("[Compliance] Error checking %s: %v", inspectionID, err)
+			http.Error(w, "Failed to check compliance", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// ListRuleSets returns every configured rule set, or those for ?standard= and ?state=
+func ListRuleSets(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		sets, err := LoadRuleSets(db, q.Get("standard"), q.Get("state"))
+		if err != nil {
+			log.Printf("[Compliance] Error loading rule sets: %v", err)
+			http.Error(w, "Failed to fetch rule sets", http.StatusInternalServerError)
+			return
+		}
+		if sets == nil {
+			sets = []RuleSet{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sets)
+	}
+}
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if userType, _ := r.Context().Value(middleware.UserTypeKey).(string); userType != "admin" {
+		http.Error(w, "Only admins can change compliance rules", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func validateRuleSet(set RuleSet) string {
+	if strings.TrimSpace(set.Standard) == "" || strings.TrimSpace(set.Name) == "" {
+		return "standard and name are required"
+	}
+	if set.State != nil && len(strings.TrimSpace(*set.State)) != 2 {
+		return "state must be a two-letter code"
+	}
+	for i, rule := range set.Rules {
+		if _, ok := inspections.SectionByKey(rule.Section); !ok {
+			return fmt.Sprintf("rule %d: unknown section %q", i, rule.Section)
+		}
+		if strings.TrimSpace(rule.ItemName) == "" {
+			return fmt.Sprintf("rule %d: item_name is required", i)
+		}
+	}
+	return ""
+}
+
+func replaceRules(tx *sql.Tx, ruleSetID int, rules []Rule) error {
+	if _, err := tx.Exec(`DELETE FROM compliance_rules WHERE rule_set_id = ?`, ruleSetID); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		_, err := tx.Exec(`INSERT INTO compliance_rules (rule_set_id, section, item_name, reason_allowed, description) VALUES (?, ?, ?, ?, ?)`,
+			ruleSetID, rule.Section, strings.TrimSpace(rule.ItemName), rule.ReasonAllowed, rule.Description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveRuleSet creates a rule set, or replaces one when the route has a rule_set_id (admin only)
+func SaveRuleSet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+
+		var set RuleSet
+		if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if msg := validateRuleSet(set); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		var state interface{}
+		if set.State != nil {
+			state = strings.ToUpper(strings.TrimSpace(*set.State))
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		status := http.StatusOK
+		if idParam, ok := mux.Vars(r)["rule_set_id"]; ok {
+			set.RuleSetID, err = strconv.Atoi(idParam)
+			if err != nil {
+				http.Error(w, "Invalid rule_set_id", http.StatusBadRequest)
+				return
+			}
+			var res sql.Result
+			res, err = tx.Exec(`UPDATE compliance_rule_sets SET standard = ?, state = ?, name = ? WHERE rule_set_id = ?`,
+				set.Standard, state, set.Name, set.RuleSetID)
+			if err == nil {
+				var exists bool
+				if n, _ := res.RowsAffected(); n == 0 {
+					err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM compliance_rule_sets WHERE rule_set_id = ?)`, set.RuleSetID).Scan(&exists)
+					if err == nil && !exists {
+						http.Error(w, "Rule set not found", http.StatusNotFound)
+						return
+					}
+				}
+			}
+		} else {
+			var res sql.Result
+			res, err = tx.Exec(`INSERT INTO compliance_rule_sets (standard, state, name) VALUES (?, ?, ?)`, set.Standard, state, set.Name)
+			if err == nil {
+				id, _ := res.LastInsertId()
+				set.RuleSetID = int(id)
+				status = http.StatusCreated
+			}
+		}
+		if err == nil {
+			err = replaceRules(tx, set.RuleSetID, set.Rules)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Compliance] Error saving rule set: %v", err)
+			http.Error(w, "Failed to save rule set (a set for this standard and state may already exist)", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Rule set saved", "rule_set_id": set.RuleSetID})
+	}
+}
+
+// DeleteRuleSet removes a rule set and its rules (admin only)
+func DeleteRuleSet(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["rule_set_id"])
+		if err != nil {
+			http.Error(w, "Invalid rule_set_id", http.StatusBadRequest)
+			return
+		}
+		if _, err := db.Exec(`DELETE FROM compliance_rule_sets WHERE rule_set_id = ?`, id); err != nil {
+			log.Printf("[Compliance] Error deleting rule set %d: %v", id, err)
+			http.Error(w, "Failed to delete rule set", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Rule set deleted"})
+	}
+}
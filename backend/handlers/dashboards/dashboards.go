@@ -3,6 +3,7 @@ package dashboards
 import (
 	"database/sql"
 	"encoding/json"
+	"home_solutions/backend/handlers/sitevisits"
 	"home_solutions/backend/middleware"
 	"log"
 	"net/http"
@@ -18,10 +19,15 @@ type DashboardResponse struct {
 }
 
 type Inspection struct {
-	InspectionID string `json:"inspection_id"`
-	Address      string `json:"address"`
-	Status       string `json:"status"`
-	Date         string `json:"date"`
+	InspectionID      string  `json:"inspection_id"`
+	PropertyID        string  `json:"property_id"`
+	Address           string  `json:"address"`
+	Status            string  `json:"status"`
+	Date              string  `json:"date"`
+	CheckedIn         bool    `json:"checked_in"`
+	TimeOnSiteMinutes float64 `json:"time_on_site_minutes"`
+	WorksheetMinutes  float64 `json:"worksheet_minutes"`
+	LocationVerified  *bool   `json:"location_verified"`
 }
 
 func GetInspectorDashboard(w http.ResponseWriter, r *http.Request) {
@@ -45,7 +51,7 @@ func GetInspectorDashboard(w http.ResponseWriter, r *http.Request) {
 
 	err := db.QueryRow(`
 		SELECT COUNT(*) FROM inspections
-		WHERE inspector_id = ? AND inspection_status = 'active'
+		WHERE inspector_id = ? AND COALESCE(status, '') <> 'published' AND deleted_at IS NULL
 	`, inspectorID).Scan(&activeCount)
 	if err != nil {
 		log.Printf("[GetInspectorDashboard] Error fetching active inspections: %v", err)
@@ -55,7 +61,7 @@ func GetInspectorDashboard(w http.ResponseWriter, r *http.Request) {
 
 	err = db.QueryRow(`
 		SELECT COUNT(*) FROM inspections
-		WHERE inspector_id = ? AND inspection_status = 'completed'
+		WHERE inspector_id = ? AND status = 'published' AND deleted_at IS NULL
 	`, inspectorID).Scan(&completedCount)
 	if err != nil {
 		log.Printf("[GetInspectorDashboard] Error fetching completed inspections: %v", err)
@@ -64,10 +70,12 @@ func GetInspectorDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := db.Query(`
-		SELECT inspection_id, address, inspection_status, inspection_date
-		FROM inspections
-		WHERE inspector_id = ?
-		ORDER BY inspection_date DESC
+		SELECT i.inspection_id, i.property_id, CONCAT(p.street, ', ', p.city, ', ', p.state), COALESCE(i.status, ''),
+		       COALESCE(DATE_FORMAT(i.inspection_date, '%Y-%m-%d'), '')
+		FROM inspections i
+		JOIN properties p ON p.property_id = i.property_id
+		WHERE i.inspector_id = ? AND i.deleted_at IS NULL AND p.deleted_at IS NULL
+		ORDER BY i.inspection_date DESC
 		LIMIT 5
 	`, inspectorID)
 	if err != nil {
@@ -80,13 +88,27 @@ func GetInspectorDashboard(w http.ResponseWriter, r *http.Request) {
 	recent := []Inspection{}
 	for rows.Next() {
 		var ins Inspection
-		err := rows.Scan(&ins.InspectionID, &ins.Address, &ins.Status, &ins.Date)
+		err := rows.Scan(&ins.InspectionID, &ins.PropertyID, &ins.Address, &ins.Status, &ins.Date)
 		if err != nil {
 			log.Printf("[GetInspectorDashboard] Error scanning row: %v", err)
 			continue
 		}
 		recent = append(recent, ins)
 	}
+	rows.Close()
+
+	// Time on site and check-in status for each recent inspection
+	for i := range recent {
+		summary, err := sitevisits.LoadSummary(db, recent[i].InspectionID)
+		if err != nil {
+			log.Printf("[GetInspectorDashboard] Error loading site visits for %s: %v", recent[i].InspectionID, err)
+			continue
+		}
+		recent[i].CheckedIn = summary.CheckedIn
+		recent[i].TimeOnSiteMinutes = summary.TimeOnSiteMinutes
+		recent[i].WorksheetMinutes = summary.WorksheetMinutes
+		recent[i].LocationVerified = summary.LocationVerified
+	}
 
 	res := DashboardResponse{
 		InspectorName:        "Inspector Evan", // In the future, fetch this from users table
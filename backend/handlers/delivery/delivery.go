@@ -0,0 +1,518 @@
+package delivery
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/agreements"
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/handlers/reports"
+	"home_solutions/backend/middleware"
+	"home_solutions/backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultExpiryDays = 30
+	maxExpiryDays     = 365
+	maxRecipients     = 20
+)
+
+var recipientRoles = map[string]bool{"client": true, "agent": true, "other": true}
+
+type RecipientInput struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"` // client, agent or other; default client
+}
+
+type DeliveryInput struct {
+	Recipients    []RecipientInput `json:"recipients"`
+	Subject       string           `json:"subject"` // empty uses the default subject
+	Message       string           `json:"message"`
+	ExpiresInDays *int             `json:"expires_in_days"`
+}
+
+// Event is something that happened to one recipient's copy
+type Event struct {
+	Event     string  `json:"event"`
+	IPAddress *string `json:"ip_address"`
+	UserAgent *string `json:"user_agent"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type Recipient struct {
+	RecipientID int     `json:"recipient_id"`
+	Email       string  `json:"email"`
+	Name        *string `json:"name"`
+	Role        string  `json:"role"`
+	Status      string  `json:"status"`
+	Error       *string `json:"error"`
+	Events      []Event `json:"events"`
+}
+
+// Delivery is one send of a published report. Link tokens are never returned; they only exist in
+// the emails.
+type Delivery struct {
+	DeliveryID    string      `json:"delivery_id"`
+	InspectionID  string      `json:"inspection_id"`
+	ReportVersion int         `json:"report_version"`
+	Subject       string      `json:"subject"`
+	Message       *string     `json:"message"`
+	SentBy        *int        `json:"sent_by"`
+	ExpiresAt     string      `json:"expires_at"`
+	CreatedAt     string      `json:"created_at"`
+	Recipients    []Recipient `json:"recipients"`
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordEvent logs an event for a recipient; r is nil for events the server causes itself
+func recordEvent(db execer, recipientID int, event string, r *http.Request) error {
+	var ip, agent interface{}
+	if r != nil {
+		ip = middleware.ClientIP(r)
+		if ua := r.UserAgent(); ua != "" {
+			if len(ua) > 255 {
+				ua = ua[:255]
+			}
+			agent = ua
+		}
+	}
+	_, err := db.Exec(`
+		INSERT INTO report_delivery_events (recipient_id, event, ip_address, user_agent, created_at)
+		VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`, recipientID, event, ip, agent)
+	return err
+}
+
+// validate normalizes the input and lists what's wrong with it
+func (in *DeliveryInput) validate() []string {
+	var problems []string
+	if len(in.Recipients) == 0 {
+		problems = append(problems, "at least one recipient is required")
+	}
+	if len(in.Recipients) > maxRecipients {
+		problems = append(problems, fmt.Sprintf("at most %d recipients can be sent to at once", maxRecipients))
+	}
+	seen := map[string]bool{}
+	for i := range in.Recipients {
+		rc := &in.Recipients[i]
+		rc.Name = strings.TrimSpace(rc.Name)
+		addr, err := mail.ParseAddress(strings.TrimSpace(rc.Email))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("recipient %d has an invalid email address", i+1))
+		} else {
+			rc.Email = addr.Address
+			if rc.Name == "" {
+				rc.Name = addr.Name
+			}
+			if seen[strings.ToLower(rc.Email)] {
+				problems = append(problems, fmt.Sprintf("%s is listed more than once", rc.Email))
+			}
+			seen[strings.ToLower(rc.Email)] = true
+		}
+		if rc.Role == "" {
+			rc.Role = "client"
+		}
+		if !recipientRoles[rc.Role] {
+			problems = append(problems, fmt.Sprintf("recipient %d has unknown role %q", i+1, rc.Role))
+		}
+	}
+	in.Subject = strings.TrimSpace(in.Subject)
+	if len(in.Subject) > 255 {
+		problems = append(problems, "subject must be at most 255 characters")
+	}
+	if strings.ContainsAny(in.Subject, "\r\n") {
+		problems = append(problems, "subject must be a single line")
+	}
+	in.Message = strings.TrimSpace(in.Message)
+	if in.ExpiresInDays == nil {
+		days := defaultExpiryDays
+		in.ExpiresInDays = &days
+	} else if *in.ExpiresInDays < 1 || *in.ExpiresInDays > maxExpiryDays {
+		problems = append(problems, fmt.Sprintf("expires_in_days must be between 1 and %d", maxExpiryDays))
+	}
+	return problems
+}
+
+func addressLine(p publishing.SnapshotProperty) string {
+	return fmt.Sprintf("%s, %s, %s %s", p.Street, p.City, p.State, p.PostalCode)
+}
+
+// CreateDelivery emails the latest published report of an inspection to each recipient, with a
+// personal link that opens that version until the delivery expires
+func CreateDelivery(db *sql.DB, cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "deliver reports") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+
+		var in DeliveryInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if problems := in.validate(); len(problems) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": "Invalid delivery", "errors": problems})
+			return
+		}
+
+		var exists int
+		err := db.QueryRow(`SELECT 1 FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		version, err := publishing.LatestVersion(db, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if version == 0 {
+			http.Error(w, "Publish the report before delivering it", http.StatusConflict)
+			return
+		}
+		// Report links skip the agreement gate, so the agreement has to be in place before sending
+		signed, err := agreements.HasSignedAgreement(db, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !signed {
+			http.Error(w, "The inspection agreement must be signed before the report is delivered", http.StatusConflict)
+			return
+		}
+		doc, err := reports.LoadDocument(db, inspectionID, version)
+		if err != nil {
+			log.Printf("[Delivery] Error loading report for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+
+		data := messageData{
+			Address:       addressLine(doc.Property),
+			Version:       version,
+			InspectorName: doc.InspectorName,
+			CompanyName:   doc.CompanyName,
+			Note:          in.Message,
+		}
+		if doc.Inspection.ReportID != nil {
+			data.ReportID = *doc.Inspection.ReportID
+		}
+		subject := in.Subject
+		if subject == "" {
+			// Rendered once so the stored subject is what recipients see
+			msg, err := data.render("")
+			if err != nil {
+				log.Printf("[Delivery] Error rendering subject for %s: %v", inspectionID, err)
+				http.Error(w, "Failed to render message", http.StatusInternalServerError)
+				return
+			}
+			subject = msg.Subject
+		}
+		expiresAt := time.Now().UTC().AddDate(0, 0, *in.ExpiresInDays)
+		data.ExpiresOn = expiresAt.Format("January 2, 2006")
+
+		// Recipients are saved before anything is sent, so every email has a row to report on
+		deliveryID := uuid.New().String()
+		tokens := make([]string, len(in.Recipients))
+		recipientIDs := make([]int, len(in.Recipients))
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		var note interface{}
+		if in.Message != "" {
+			note = in.Message
+		}
+		_, err = tx.Exec(`
+			INSERT INTO report_deliveries (delivery_id, inspection_id, report_version, subject, message, sent_by, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`,
+			deliveryID, inspectionID, version, subject, note, middleware.CallerID(r), expiresAt.Format("2006-01-02 15:04:05"))
+		for i, rc := range in.Recipients {
+			if err != nil {
+				break
+			}
+			var hash string
+			if tokens[i], hash, err = utils.NewLinkToken(); err != nil {
+				break
+			}
+			var name interface{}
+			if rc.Name != "" {
+				name = rc.Name
+			}
+			var res sql.Result
+			res, err = tx.Exec(`
+				INSERT INTO report_delivery_recipients (delivery_id, email, name, role, token_hash)
+				VALUES (?, ?, ?, ?, ?)`, deliveryID, rc.Email, name, rc.Role, hash)
+			if err == nil {
+				var id int64
+				id, err = res.LastInsertId()
+				recipientIDs[i] = int(id)
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Delivery] Error saving delivery for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to save delivery", http.StatusInternalServerError)
+			return
+		}
+
+		failed := 0
+		for i, rc := range in.Recipients {
+			data.RecipientName = rc.Name
+			data.Link = cfg.BaseURL + "/api/report-links/" + tokens[i]
+			data.PixelURL = data.Link + "/open.gif"
+			msg, err := data.render(subject)
+			if err == nil {
+				msg.From = cfg.From
+				msg.To = (&mail.Address{Name: rc.Name, Address: rc.Email}).String()
+				err = cfg.Transport.Send(msg)
+			}
+
+			status, event := "sent", "sent"
+			var sendError interface{}
+			if err != nil {
+				log.Printf("[Delivery] Error sending report %s to %s: %v", inspectionID, rc.Email, err)
+				failed++
+				status, event = "failed", "failed"
+				text := err.Error()
+				if len(text) > 500 {
+					text = text[:500]
+				}
+				sendError = text
+			}
+			_, err = db.Exec(`UPDATE report_delivery_recipients SET status = ?, error = ? WHERE recipient_id = ?`, status, sendError, recipientIDs[i])
+			if err == nil {
+				err = recordEvent(db, recipientIDs[i], event, nil)
+			}
+			if err != nil {
+				log.Printf("[Delivery] Error recording status for recipient %d: %v", recipientIDs[i], err)
+			}
+		}
+		log.Printf("[Delivery] Sent report %s v%d to %d recipients (%d failed)", inspectionID, version, len(in.Recipients)-failed, failed)
+
+		deliveries, err := loadDeliveries(db, `d.delivery_id = ?`, deliveryID)
+		if err != nil || len(deliveries) == 0 {
+			log.Printf("[Delivery] Error loading delivery %s: %v", deliveryID, err)
+			http.Error(w, "Failed to load delivery", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(deliveries[0])
+	}
+}
+
+// loadDeliveries reads deliveries matching the condition, newest first, with their recipients and events
+func loadDeliveries(db *sql.DB, where string, args ...interface{}) ([]Delivery, error) {
+	rows, err := db.Query(`
+		SELECT d.delivery_id, d.inspection_id, d.report_version, d.subject, d.message, d.sent_by, d.expires_at, d.created_at
+		FROM report_deliveries d WHERE `+where+` ORDER BY d.created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []Delivery{}
+	index := map[string]int{}
+	for rows.Next() {
+		var d Delivery
+		var sentBy sql.NullInt64
+		if err := rows.Scan(&d.DeliveryID, &d.InspectionID, &d.ReportVersion, &d.Subject, &d.Message, &sentBy, &d.ExpiresAt, &d.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if sentBy.Valid {
+			id := int(sentBy.Int64)
+			d.SentBy = &id
+		}
+		d.Recipients = []Recipient{}
+		index[d.DeliveryID] = len(deliveries)
+		deliveries = append(deliveries, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT r.delivery_id, r.recipient_id, r.email, r.name, r.role, r.status, r.error,
+		       e.event, e.ip_address, e.user_agent, e.created_at
+		FROM report_delivery_recipients r
+		JOIN report_deliveries d ON d.delivery_id = r.delivery_id
+		LEFT JOIN report_delivery_events e ON e.recipient_id = r.recipient_id
+		WHERE `+where+`
+		ORDER BY r.recipient_id, e.created_at, e.event_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var deliveryID string
+		var rc Recipient
+		var event, createdAt sql.NullString
+		var ev Event
+		if err := rows.Scan(&deliveryID, &rc.RecipientID, &rc.Email, &rc.Name, &rc.Role, &rc.Status, &rc.Error,
+			&event, &ev.IPAddress, &ev.UserAgent, &createdAt); err != nil {
+			return nil, err
+		}
+		d := &deliveries[index[deliveryID]]
+		n := len(d.Recipients)
+		if n == 0 || d.Recipients[n-1].RecipientID != rc.RecipientID {
+			rc.Events = []Event{}
+			d.Recipients = append(d.Recipients, rc)
+			n++
+		}
+		if event.Valid {
+			ev.Event = event.String
+			ev.CreatedAt = createdAt.String
+			d.Recipients[n-1].Events = append(d.Recipients[n-1].Events, ev)
+		}
+	}
+	return deliveries, rows.Err()
+}
+
+// ListDeliveries shows who an inspection's reports were sent to and what each recipient has opened
+func ListDeliveries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "deliver reports") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+		deliveries, err := loadDeliveries(db, `d.inspection_id = ?`, inspectionID)
+		if err != nil {
+			log.Printf("[Delivery] Error listing deliveries for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to load deliveries", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deliveries)
+	}
+}
+
+// linkTarget is what a report link token resolves to
+type linkTarget struct {
+	RecipientID   int
+	InspectionID  string
+	ReportVersion int
+	Expired       bool
+}
+
+// lookupLink resolves a token; sql.ErrNoRows covers unknown tokens and deleted inspections alike
+func lookupLink(db *sql.DB, token string) (*linkTarget, error) {
+	t := &linkTarget{}
+	err := db.QueryRow(`
+		SELECT r.recipient_id, d.inspection_id, d.report_version, d.expires_at <= UTC_TIMESTAMP()
+		FROM report_delivery_recipients r
+		JOIN report_deliveries d ON d.delivery_id = r.delivery_id
+		JOIN inspections i ON i.inspection_id = d.inspection_id
+		WHERE r.token_hash = ? AND i.deleted_at IS NULL`, utils.HashLinkToken(token)).Scan(&t.RecipientID, &t.InspectionID, &t.ReportVersion, &t.Expired)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// OpenReportLink serves the delivered report version to whoever holds the link, recording the view.
+// ?download=true asks the browser to save the file.
+func OpenReportLink(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, err := lookupLink(db, mux.Vars(r)["token"])
+		if err == sql.ErrNoRows {
+			http.Error(w, "Report link not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if target.Expired {
+			http.Error(w, "This report link has expired; ask your inspector for a new one", http.StatusGone)
+			return
+		}
+
+		doc, err := reports.LoadDocument(db, target.InspectionID, target.ReportVersion)
+		if err != nil {
+			log.Printf("[Delivery] Error loading report for %s: %v", target.InspectionID, err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+		theme, err := reports.LoadTheme(db, doc.OrganizationID)
+		if err != nil {
+			log.Printf("[Delivery] Error loading theme for %s: %v", target.InspectionID, err)
+			http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+			return
+		}
+		// Render fully before writing so a failure can still return an error status
+		var buf bytes.Buffer
+		if err := reports.RenderPDF(&buf, doc, theme); err != nil {
+			log.Printf("[Delivery] Error rendering PDF for %s: %v", target.InspectionID, err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		if err := recordEvent(db, target.RecipientID, "viewed", r); err != nil {
+			log.Printf("[Delivery] Error recording view for recipient %d: %v", target.RecipientID, err)
+		}
+
+		disposition := "inline"
+		if r.URL.Query().Get("download") == "true" {
+			disposition = "attachment"
+		}
+		name := target.InspectionID
+		if doc.Inspection.ReportID != nil && *doc.Inspection.ReportID != "" {
+			name = *doc.Inspection.ReportID
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, fmt.Sprintf("inspection-report-%s-v%d.pdf", name, target.ReportVersion)))
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Write(buf.Bytes())
+	}
+}
+
+// transparentGIF is a 1x1 transparent image
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// TrackOpen records that a delivery email was displayed. The image is returned whatever the token,
+// so the endpoint can't be used to probe for valid links.
+func TrackOpen(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, err := lookupLink(db, mux.Vars(r)["token"])
+		if err == nil {
+			err = recordEvent(db, target.RecipientID, "opened", r)
+		}
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("[Delivery] Error recording open: %v", err)
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+		w.Write(transparentGIF)
+	}
+}
@@ -0,0 +1,163 @@
+package delivery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is one email with plain text and HTML alternatives
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport hands messages to a mail system
+type Transport interface {
+	Send(msg Message) error
+}
+
+// bytes renders the message as RFC 5322 text
+func (m Message) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "localhost"
+	if at := strings.LastIndex(m.From, "@"); at >= 0 {
+		domain = strings.Trim(m.From[at+1:], "> ")
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// SMTPTransport sends through an SMTP server, upgrading to TLS when the server offers it
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+func (t SMTPTransport) Send(msg Message) error {
+	data, err := msg.bytes()
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, t.Host)
+	}
+	return smtp.SendMail(t.Host+":"+t.Port, auth, envelopeAddress(msg.From), []string{envelopeAddress(msg.To)}, data)
+}
+
+// envelopeAddress strips the display name from "Name <addr>"
+func envelopeAddress(addr string) string {
+	if i := strings.LastIndex(addr, "<"); i >= 0 {
+		return strings.TrimSuffix(addr[i+1:], ">")
+	}
+	return addr
+}
+
+// FileTransport writes each message to a .eml file instead of sending it, for development
+type FileTransport struct {
+	Dir string
+}
+
+func (t FileTransport) Send(msg Message) error {
+	data, err := msg.bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(t.Dir, name), data, 0644)
+}
+
+// Config is how delivered mail is sent and where its links point
+type Config struct {
+	Transport Transport
+	From      string
+	BaseURL   string // public origin of the API, used to build report links
+}
+
+// ConfigFromEnv reads MAIL_TRANSPORT ("smtp" or "file", the default), SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD, MAIL_DIR, MAIL_FROM and PUBLIC_BASE_URL
+func ConfigFromEnv() Config {
+	env := func(key, fallback string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return fallback
+	}
+
+	cfg := Config{
+		From:    env("MAIL_FROM", "Home Solutions <reports@localhost>"),
+		BaseURL: strings.TrimSuffix(env("PUBLIC_BASE_URL", "http://localhost:8080"), "/"),
+	}
+	switch env("MAIL_TRANSPORT", "file") {
+	case "smtp":
+		cfg.Transport = SMTPTransport{
+			Host:     env("SMTP_HOST", "localhost"),
+			Port:     env("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	default:
+		dir := env("MAIL_DIR", "./tmp/mail")
+		log.Printf("[Delivery] Writing outgoing mail to %s", dir)
+		cfg.Transport = FileTransport{Dir: dir}
+	}
+	return cfg
+}
@@ -0,0 +1,78 @@
+package delivery
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// messageData fills the delivery email templates
+type messageData struct {
+	RecipientName string
+	Address       string
+	ReportID      string
+	Version       int
+	InspectorName string
+	CompanyName   string
+	Note          string // the sender's personal message
+	Link          string
+	PixelURL      string
+	ExpiresOn     string
+}
+
+const defaultSubject = "Your home inspection report for {{.Address}}"
+
+var subjectTemplate = texttemplate.Must(texttemplate.New("subject").Parse(defaultSubject))
+
+var textTemplate = texttemplate.Must(texttemplate.New("text").Parse(`Hello{{if .RecipientName}} {{.RecipientName}}{{end}},
+
+The inspection report for {{.Address}} is ready{{if .CompanyName}} from {{.CompanyName}}{{end}}.
+{{if .Note}}
+{{.Note}}
+{{end}}
+View and download the report:
+{{.Link}}
+
+This link is personal to you and works until {{.ExpiresOn}}.
+{{if .InspectorName}}
+{{.InspectorName}}{{end}}{{if .CompanyName}}
+{{.CompanyName}}{{end}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{
+	"lines": func(s string) []string { return strings.Split(s, "\n") },
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family: Helvetica, Arial, sans-serif; color: #212121; line-height: 1.5;">
+<p>Hello{{if .RecipientName}} {{.RecipientName}}{{end}},</p>
+<p>The inspection report for <strong>{{.Address}}</strong> is ready{{if .CompanyName}} from {{.CompanyName}}{{end}}.</p>
+{{if .Note}}<p>{{range $i, $l := lines .Note}}{{if $i}}<br>{{end}}{{$l}}{{end}}</p>{{end}}
+<p><a href="{{.Link}}" style="display: inline-block; padding: 10px 18px; background: #1F406B; color: #ffffff; text-decoration: none; border-radius: 4px;">View the report</a></p>
+<p style="color: #757575; font-size: 13px;">Report {{.ReportID}}, version {{.Version}}. This link is personal to you and works until {{.ExpiresOn}}.</p>
+<p>{{if .InspectorName}}{{.InspectorName}}<br>{{end}}{{.CompanyName}}</p>
+<img src="{{.PixelURL}}" width="1" height="1" alt="" style="display: block; border: 0;">
+</body></html>
+`))
+
+// render fills the subject and both bodies for one recipient; a non-empty subject overrides the default
+func (d messageData) render(subject string) (Message, error) {
+	var msg Message
+	var b strings.Builder
+	if subject == "" {
+		if err := subjectTemplate.Execute(&b, d); err != nil {
+			return msg, err
+		}
+		subject = b.String()
+		b.Reset()
+	}
+	msg.Subject = subject
+	if err := textTemplate.Execute(&b, d); err != nil {
+		return msg, err
+	}
+	msg.Text = b.String()
+	b.Reset()
+	if err := htmlTemplate.Execute(&b, d); err != nil {
+		return msg, err
+	}
+	msg.HTML = b.String()
+	return msg, nil
+}
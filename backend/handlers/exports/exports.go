@@ -0,0 +1,322 @@
+package exports
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// inspectionFrom joins what every export row carries about its inspection: address and inspector
+const inspectionFrom = `
+	FROM inspections i
+	JOIN properties p ON p.property_id = i.property_id
+	LEFT JOIN inspectors ins ON ins.inspector_id = i.inspector_id
+	LEFT JOIN users u ON u.user_id = ins.user_id`
+
+const inspectionColumns = `i.inspection_id, COALESCE(i.report_id, ''), COALESCE(DATE_FORMAT(i.inspection_date, '%Y-%m-%d'), ''),
+	COALESCE(i.status, ''), COALESCE(CONCAT(u.first_name, ' ', u.last_name), ''),
+	p.street, p.city, p.state, p.postal_code`
+
+// inspectionRow is the leading part of every export row
+type inspectionRow struct {
+	InspectionID   string
+	ReportID       string
+	InspectionDate string
+	Status         string
+	Inspector      string
+	Street         string
+	City           string
+	State          string
+	PostalCode     string
+}
+
+var inspectionHeader = []string{"Report ID", "Inspection Date", "Inspector", "Street", "City", "State", "Postal Code"}
+
+func (r inspectionRow) cells() []string {
+	return []string{r.ReportID, r.InspectionDate, r.Inspector, r.Street, r.City, r.State, r.PostalCode}
+}
+
+func (r *inspectionRow) scanTargets() []interface{} {
+	return []interface{}{&r.InspectionID, &r.ReportID, &r.InspectionDate, &r.Status, &r.Inspector, &r.Street, &r.City, &r.State, &r.PostalCode}
+}
+
+// materialsText lists an item's materials as "Key: value" pairs in a stable order
+func materialsText(materials map[string]string) string {
+	keys := make([]string, 0, len(materials))
+	for k, v := range materials {
+		if strings.TrimSpace(v) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + ": " + materials[k]
+	}
+	return strings.Join(parts, "; ")
+}
+
+// checkedConditions returns the conditions ticked on an item, sorted
+func checkedConditions(item inspections.WorksheetItem) []string {
+	var checked []string
+	for name, on := range item.Conditions {
+		if on {
+			checked = append(checked, name)
+		}
+	}
+	sort.Strings(checked)
+	return checked
+}
+
+// exportFilter resolves the shared list filters, writing the error response itself when they fail
+func exportFilter(db *sql.DB, w http.ResponseWriter, r *http.Request) (string, []interface{}, bool) {
+	if !middleware.RequireStaff(w, r, "export inspections") {
+		return "", nil, false
+	}
+	where, args, err := inspections.ListFilter(db, r)
+	if _, ok := err.(*inspections.FilterError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", nil, false
+	}
+	if err != nil {
+		log.Printf("[Exports] Error resolving caller scope: %v", err)
+		http.Error(w, "Failed to resolve permissions", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	return " WHERE " + strings.Join(where, " AND "), args, true
+}
+
+// finish closes the table; once streaming has begun, errors can only be logged
+func finish(t table, name string, err error) {
+	if err != nil {
+		log.Printf("[Exports] Error writing %s export: %v", name, err)
+		return
+	}
+	if err := t.Close(); err != nil {
+		log.Printf("[Exports] Error finishing %s export: %v", name, err)
+	}
+}
+
+// ExportInspections streams the filtered inspection list with open defect counts by severity.
+// It takes the same filters as the inspection list; the format is csv or xlsx.
+func ExportInspections(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, args, ok := exportFilter(db, w, r)
+		if !ok {
+			return
+		}
+
+		counts := ""
+		for _, s := range []string{"safety", "major", "repair", "maintenance"} {
+			counts += fmt.Sprintf(`, (SELECT COUNT(*) FROM defects d WHERE d.inspection_id = i.inspection_id AND d.status = 'open' AND d.severity = '%s')`, s)
+		}
+		rows, err := db.Query(`SELECT `+inspectionColumns+counts+inspectionFrom+filter+
+			` ORDER BY i.inspection_date DESC, i.inspection_id`, args...)
+		if err != nil {
+			log.Printf("[Exports] Error querying inspections: %v", err)
+			http.Error(w, "Failed to export inspections", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		header := append(append([]string{}, inspectionHeader...), "Status", "Safety Hazards", "Major Defects", "Repairs", "Maintenance")
+		t, err := newTable(w, mux.Vars(r)["format"], "inspections", header)
+		if err != nil {
+			log.Printf("[Exports] Error starting inspections export: %v", err)
+			return
+		}
+		for rows.Next() {
+			var in inspectionRow
+			var safety, major, repair, maintenance string
+			if err = rows.Scan(append(in.scanTargets(), &safety, &major, &repair, &maintenance)...); err != nil {
+				break
+			}
+			if err = t.WriteRow(append(in.cells(), in.Status, safety, major, repair, maintenance)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		finish(t, "inspections", err)
+	}
+}
+
+// ExportConditions streams one row per worksheet item with flagged conditions across the
+// filtered inspections; ?all_items=true includes items with nothing flagged.
+func ExportConditions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, args, ok := exportFilter(db, w, r)
+		if !ok {
+			return
+		}
+		allItems := r.URL.Query().Get("all_items") == "true"
+
+		rows, err := db.Query(`SELECT `+inspectionColumns+inspectionFrom+filter+
+			` ORDER BY i.inspection_date DESC, i.inspection_id`, args...)
+		if err != nil {
+			log.Printf("[Exports] Error querying inspections: %v", err)
+			http.Error(w, "Failed to export conditions", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		header := append(append([]string{}, inspectionHeader...),
+			"Section", "Item", "Materials", "Flagged Conditions", "Severity", "Item Status", "Comments")
+		t, err := newTable(w, mux.Vars(r)["format"], "conditions", header)
+		if err != nil {
+			log.Printf("[Exports] Error starting conditions export: %v", err)
+			return
+		}
+
+		// Items are loaded one inspection at a time so a long date range never sits in memory
+	inspectionLoop:
+		for rows.Next() {
+			var in inspectionRow
+			if err = rows.Scan(in.scanTargets()...); err != nil {
+				break
+			}
+			for _, section := range inspections.Sections {
+				var items []inspections.WorksheetItem
+				items, err = inspections.LoadWorksheetItems(db, section, in.InspectionID)
+				if err != nil {
+					break inspectionLoop
+				}
+				for _, item := range items {
+					checked := checkedConditions(item)
+					if len(checked) == 0 && !allItems {
+						continue
+					}
+					severity := ""
+					if s := inspections.ItemSeverity(item); s != inspections.SeverityNone {
+						severity = s.String()
+					}
+					row := append(in.cells(), section.Title, item.ItemName, materialsText(item.Materials),
+						strings.Join(checked, "; "), severity, item.InspectionStatus, item.Comments)
+					if err = t.WriteRow(row); err != nil {
+						break inspectionLoop
+					}
+				}
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		finish(t, "conditions", err)
+	}
+}
+
+// ExportDefects streams one row per defect across the filtered inspections, with the materials,
+// flagged conditions and comments of its item. ?defect_status= takes a comma separated list or
+// "all" (default open); ?severity= limits the rows to one severity.
+func ExportDefects(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, args, ok := exportFilter(db, w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+
+		statuses := []string{inspections.DefectOpen}
+		if v := q.Get("defect_status"); v == "all" {
+			statuses = nil
+		} else if v != "" {
+			statuses = strings.Split(v, ",")
+		}
+		if len(statuses) > 0 {
+			filter += " AND d.status IN (?" + strings.Repeat(", ?", len(statuses)-1) + ")"
+			for _, s := range statuses {
+				args = append(args, strings.TrimSpace(s))
+			}
+		}
+		if v := q.Get("severity"); v != "" {
+			s, ok := inspections.ParseSeverity(v)
+			if !ok || s == inspections.SeverityNone {
+				http.Error(w, "Invalid severity", http.StatusBadRequest)
+				return
+			}
+			filter += " AND d.severity = ?"
+			args = append(args, s.String())
+		}
+
+		rows, err := db.Query(`
+			SELECT `+inspectionColumns+`, d.section, d.item_name, COALESCE(d.condition_key, ''), d.severity, d.status, d.source,
+				COALESCE(d.location, ''), COALESCE(d.recommendation, ''), COALESCE(d.responsible_trade, ''),
+				COALESCE(CAST(d.cost_min AS CHAR), ''), COALESCE(CAST(d.cost_max AS CHAR), '')`+
+			inspectionFrom+` JOIN defects d ON d.inspection_id = i.inspection_id`+filter+`
+			ORDER BY i.inspection_date DESC, i.inspection_id, d.section, d.item_name, d.defect_id`, args...)
+		if err != nil {
+			log.Printf("[Exports] Error querying defects: %v", err)
+			http.Error(w, "Failed to export defects", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		header := append(append([]string{}, inspectionHeader...),
+			"Section", "Item", "Materials", "Flagged Conditions", "Comments", "Condition", "Severity", "Defect Status",
+			"Source", "Location", "Recommendation", "Responsible Trade", "Cost Min", "Cost Max")
+		t, err := newTable(w, mux.Vars(r)["format"], "defects", header)
+		if err != nil {
+			log.Printf("[Exports] Error starting defects export: %v", err)
+			return
+		}
+
+		// Item details are cached for the inspection at hand only; rows arrive grouped by inspection
+		var cachedFor string
+		items := map[string]inspections.WorksheetItem{}
+		for rows.Next() {
+			var in inspectionRow
+			var sectionKey, itemName, condition, severity, status, source, location, recommendation, trade, costMin, costMax string
+			err = rows.Scan(append(in.scanTargets(), &sectionKey, &itemName, &condition, &severity, &status, &source,
+				&location, &recommendation, &trade, &costMin, &costMax)...)
+			if err != nil {
+				break
+			}
+			if in.InspectionID != cachedFor {
+				if items, err = loadItems(db, in.InspectionID); err != nil {
+					break
+				}
+				cachedFor = in.InspectionID
+			}
+
+			sectionTitle := sectionKey
+			if section, ok := inspections.SectionByKey(sectionKey); ok {
+				sectionTitle = section.Title
+			}
+			item := items[sectionKey+"\x00"+itemName]
+			row := append(in.cells(), sectionTitle, itemName, materialsText(item.Materials),
+				strings.Join(checkedConditions(item), "; "), item.Comments, condition, severity, status,
+				source, location, recommendation, trade, costMin, costMax)
+			if err = t.WriteRow(row); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		finish(t, "defects", err)
+	}
+}
+
+// loadItems indexes an inspection's worksheet items by section key and item name
+func loadItems(db *sql.DB, inspectionID string) (map[string]inspections.WorksheetItem, error) {
+	items := map[string]inspections.WorksheetItem{}
+	for _, section := range inspections.Sections {
+		sectionItems, err := inspections.LoadWorksheetItems(db, section, inspectionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range sectionItems {
+			items[section.Key+"\x00"+item.ItemName] = item
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,171 @@
+package exports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+		{16383, "XFD"}, // Excel's last column
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.index); got != tt.want {
+			t.Errorf("columnName(%d) = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestCSVFormulaPrefix(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"Roof", "Roof"},
+		{"=SUM(A1:A9)", "'=SUM(A1:A9)"},
+		{"+1 555 0100", "'+1 555 0100"},
+		{"-5", "'-5"},
+		{"@cmd", "'@cmd"},
+		{"\t=1", "'\t=1"},
+		{"a=b", "a=b"},
+		{"'quoted", "'quoted"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		table := &csvTable{w: csv.NewWriter(&buf)}
+		if err := table.WriteRow([]string{tt.cell, "x"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Close(); err != nil {
+			t.Fatal(err)
+		}
+		record, err := csv.NewReader(&buf).Read()
+		if err != nil {
+			t.Fatalf("reading back %q: %v", tt.cell, err)
+		}
+		if record[0] != tt.want {
+			t.Errorf("cell %q written as %q, want %q", tt.cell, record[0], tt.want)
+		}
+	}
+}
+
+// sheetXML is the part of a worksheet the tests read back
+type sheetXML struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref   string `xml:"r,attr"`
+			Style string `xml:"s,attr"`
+			Text  string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readPart(t *testing.T, data []byte, name string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return b
+		}
+	}
+	t.Fatalf("workbook has no %s", name)
+	return nil
+}
+
+func TestXLSXEscaping(t *testing.T) {
+	long := strings.Repeat("é", maxCellLength+10)
+	tests := []struct {
+		name string
+		cell string
+		want string
+	}{
+		{"markup", `<b>Tom & "Jerry"</b>`, `<b>Tom & "Jerry"</b>`},
+		{"formula stays text", "=HYPERLINK(\"x\")", "=HYPERLINK(\"x\")"},
+		{"control code", "bad\x01byte", "bad�byte"},
+		{"surrounding space", "  padded  ", "  padded  "},
+		{"too long", long, long[:len(strings.Repeat("é", maxCellLength))]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			table, err := newXLSXTable(&buf, "Defects & <Notes>")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := table.WriteRow([]string{"Item", "Comment"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := table.WriteRow([]string{"Roof", tt.cell}); err != nil {
+				t.Fatal(err)
+			}
+			if err := table.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var workbook struct {
+				Sheets []struct {
+					Name string `xml:"name,attr"`
+				} `xml:"sheets>sheet"`
+			}
+			if err := xml.Unmarshal(readPart(t, buf.Bytes(), "xl/workbook.xml"), &workbook); err != nil {
+				t.Fatalf("workbook.xml is not well-formed: %v", err)
+			}
+			if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != "Defects & <Notes>" {
+				t.Errorf("sheet name read back as %+v", workbook.Sheets)
+			}
+
+			var sheet sheetXML
+			if err := xml.Unmarshal(readPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml"), &sheet); err != nil {
+				t.Fatalf("sheet1.xml is not well-formed: %v", err)
+			}
+			if len(sheet.Rows) != 2 {
+				t.Fatalf("got %d rows, want 2", len(sheet.Rows))
+			}
+			header, row := sheet.Rows[0], sheet.Rows[1]
+			if header.Cells[0].Style != "1" || row.Cells[0].Style != "" {
+				t.Errorf("only the header row should be bold")
+			}
+			if row.R != 2 || row.Cells[1].Ref != "B2" {
+				t.Errorf("second cell of row %d is at %s, want B2", row.R, row.Cells[1].Ref)
+			}
+			if got := row.Cells[1].Text; got != tt.want {
+				if len(got) > 40 || len(tt.want) > 40 {
+					t.Errorf("cell read back with %d runes, want %d", len([]rune(got)), len([]rune(tt.want)))
+				} else {
+					t.Errorf("cell read back as %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package imports
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/properties"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+const maxImportSize = 20 << 20
+
+// SavedMapping is a named mapping kept for the next file from the same tool
+type SavedMapping struct {
+	MappingID int     `json:"mapping_id"`
+	Name      string  `json:"name"`
+	Format    string  `json:"format"`
+	Mapping   Mapping `json:"mapping"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+// ImportedRecord is an inspection created, or in a dry run validated, from the source file
+type ImportedRecord struct {
+	Rows            []int  `json:"rows"`
+	Street          string `json:"street"`
+	City            string `json:"city"`
+	InspectionDate  string `json:"inspection_date"`
+	Items           int    `json:"items"`
+	PropertyID      string `json:"property_id,omitempty"`
+	PropertyCreated bool   `json:"property_created,omitempty"`
+	InspectionID    string `json:"inspection_id,omitempty"`
+	ReportID        string `json:"report_id,omitempty"`
+}
+
+// ImportSummary is the outcome of an import; inspections with row errors are skipped and the
+// rest are imported
+type ImportSummary struct {
+	Format   string           `json:"format"`
+	DryRun   bool             `json:"dry_run"`
+	Records  int              `json:"records"`
+	Imported []ImportedRecord `json:"imported"`
+	Errors   []RowError       `json:"errors"`
+}
+
+// ListFormats returns the source formats an import can name
+func ListFormats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type format struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			UsesMapping bool   `json:"uses_mapping"`
+		}
+		formats := []format{}
+		for name, p := range parsers {
+			formats = append(formats, format{Name: name, Description: p.Description(), UsesMapping: p.UsesMapping()})
+		}
+		sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(formats)
+	}
+}
+
+func scanMapping(scanner interface{ Scan(...interface{}) error }) (SavedMapping, error) {
+	var m SavedMapping
+	var raw string
+	if err := scanner.Scan(&m.MappingID, &m.Name, &m.Format, &raw, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal([]byte(raw), &m.Mapping); err != nil {
+		return m, fmt.Errorf("invalid stored mapping %d: %v", m.MappingID, err)
+	}
+	return m, nil
+}
+
+const mappingSelect = `SELECT mapping_id, name, format, mapping, created_at, updated_at FROM import_mappings`
+
+// ListMappings returns the caller's saved mappings
+func ListMappings(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "import inspections") {
+			return
+		}
+		userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+		rows, err := db.Query(mappingSelect+` WHERE owner_user_id = ? ORDER BY name`, userID)
+		if err != nil {
+			log.Printf("[Imports] Error listing mappings: %v", err)
+			http.Error(w, "Failed to fetch mappings", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		mappings := []SavedMapping{}
+		for rows.Next() {
+			m, err := scanMapping(rows)
+			if err != nil {
+				log.Printf("[Imports] Error reading mapping: %v", err)
+				http.Error(w, "Failed to fetch mappings", http.StatusInternalServerError)
+				return
+			}
+			mappings = append(mappings, m)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mappings)
+	}
+}
+
+// SaveMapping stores a mapping under a name, replacing the caller's mapping of the same name
+func SaveMapping(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "import inspections") {
+			return
+		}
+		userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+
+		var in SavedMapping
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		in.Name = strings.TrimSpace(in.Name)
+		var problems []string
+		if in.Name == "" {
+			problems = append(problems, "name is required")
+		}
+		if p, ok := parsers[in.Format]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown format %q", in.Format))
+		} else if p.UsesMapping() {
+			problems = append(problems, in.Mapping.validate()...)
+		}
+		if len(problems) > 0 {
+			writeProblems(w, "Invalid mapping", problems)
+			return
+		}
+
+		raw, _ := json.Marshal(in.Mapping)
+		_, err := db.Exec(`
+			INSERT INTO import_mappings (owner_user_id, name, format, mapping, created_at, updated_at)
+			VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())
+			ON DUPLICATE KEY UPDATE format = VALUES(format), mapping = VALUES(mapping), updated_at = UTC_TIMESTAMP()`,
+			userID, in.Name, in.Format, string(raw))
+		if err == nil {
+			in, err = scanMapping(db.QueryRow(mappingSelect+` WHERE owner_user_id = ? AND name = ?`, userID, in.Name))
+		}
+		if err != nil {
+			log.Printf("[Imports] Error saving mapping: %v", err)
+			http.Error(w, "Failed to save mapping", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(in)
+	}
+}
+
+// DeleteMapping removes one of the caller's saved mappings
+func DeleteMapping(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "import inspections") {
+			return
+		}
+		userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+		id, err := strconv.Atoi(mux.Vars(r)["mapping_id"])
+		if err != nil {
+			http.Error(w, "Invalid mapping_id", http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`DELETE FROM import_mappings WHERE mapping_id = ? AND owner_user_id = ?`, id, userID)
+		if err != nil {
+			log.Printf("[Imports] Error deleting mapping %d: %v", id, err)
+			http.Error(w, "Failed to delete mapping", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Mapping not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeProblems(w http.ResponseWriter, message string, problems []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": message, "errors": problems})
+}
+
+// importRecord creates the property and inspection of one record in its own transaction, so a
+// failure only loses that inspection
+func importRecord(db *sql.DB, rec *Record, authorID *int) (ImportedRecord, error) {
+	out := ImportedRecord{Rows: rec.Rows, Street: rec.Address.Street, City: rec.Address.City, InspectionDate: rec.InspectionDate}
+	tx, err := db.Begin()
+	if err != nil {
+		return out, err
+	}
+	defer tx.Rollback()
+
+	out.PropertyID, out.PropertyCreated, err = properties.FindOrCreateProperty(tx, rec.Address)
+	if err != nil {
+		return out, err
+	}
+	result, err := inspections.ImportInspection(tx, inspections.ImportedInspection{
+		PropertyID:     out.PropertyID,
+		InspectionDate: rec.InspectionDate,
+		Weather:        rec.Weather,
+		Temperature:    rec.Temperature,
+		Items:          rec.Items,
+	}, authorID)
+	if err != nil {
+		return out, err
+	}
+	if err := tx.Commit(); err != nil {
+		return out, err
+	}
+	out.InspectionID, out.ReportID, out.Items = result.InspectionID, result.ReportID, result.Items
+	return out, nil
+}
+
+// ImportInspections creates properties, inspections and worksheet items from another tool's
+// export. Multipart fields: file, format (see ListFormats), and either mapping (JSON) or
+// mapping_id of a saved mapping; dry_run=true only validates. Properties are matched by address
+// like a new address entry. Each inspection imports on its own: rows with problems are reported
+// and their inspection skipped, the others still go in.
+func ImportInspections(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "import inspections") {
+			return
+		}
+		userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+		if err := r.ParseMultipartForm(maxImportSize); err != nil {
+			http.Error(w, "File is missing or too large", http.StatusBadRequest)
+			return
+		}
+		format := r.FormValue("format")
+		parser, ok := parsers[format]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown format %q", format), http.StatusBadRequest)
+			return
+		}
+
+		var mapping *Mapping
+		if parser.UsesMapping() {
+			mapping = &Mapping{}
+			if v := r.FormValue("mapping_id"); v != "" {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					http.Error(w, "Invalid mapping_id", http.StatusBadRequest)
+					return
+				}
+				saved, err := scanMapping(db.QueryRow(mappingSelect+` WHERE mapping_id = ? AND owner_user_id = ?`, id, userID))
+				if err == sql.ErrNoRows {
+					http.Error(w, "Mapping not found", http.StatusNotFound)
+					return
+				}
+				if err != nil {
+					log.Printf("[Imports] Error loading mapping %d: %v", id, err)
+					http.Error(w, "Failed to load mapping", http.StatusInternalServerError)
+					return
+				}
+				*mapping = saved.Mapping
+			} else if err := json.Unmarshal([]byte(r.FormValue("mapping")), mapping); err != nil {
+				http.Error(w, "A valid mapping or mapping_id is required", http.StatusBadRequest)
+				return
+			}
+			if problems := mapping.validate(); len(problems) > 0 {
+				writeProblems(w, "Invalid mapping", problems)
+				return
+			}
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "File is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		records, err := parser.Parse(file, mapping)
+		if _, ok := err.(*FileError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("[Imports] Error parsing %s file: %v", format, err)
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return
+		}
+
+		summary := ImportSummary{Format: format, DryRun: r.FormValue("dry_run") == "true", Records: len(records), Imported: []ImportedRecord{}, Errors: []RowError{}}
+		authorID := &userID
+		for _, rec := range records {
+			if len(rec.Errors) > 0 {
+				summary.Errors = append(summary.Errors, rec.Errors...)
+				continue
+			}
+			if summary.DryRun {
+				out := ImportedRecord{Rows: rec.Rows, Street: rec.Address.Street, City: rec.Address.City, InspectionDate: rec.InspectionDate}
+				for _, items := range rec.Items {
+					out.Items += len(items)
+				}
+				summary.Imported = append(summary.Imported, out)
+				continue
+			}
+			out, err := importRecord(db, rec, authorID)
+			if err != nil {
+				log.Printf("[Imports] Error importing rows %v: %v", rec.Rows, err)
+				summary.Errors = append(summary.Errors, RowError{Row: rec.Rows[0], Message: "the inspection starting on this row could not be saved"})
+				continue
+			}
+			summary.Imported = append(summary.Imported, out)
+		}
+		sort.SliceStable(summary.Errors, func(i, j int) bool { return summary.Errors[i].Row < summary.Errors[j].Row })
+
+		log.Printf("[Imports] %s import by user %d: %d of %d inspections (dry run: %v)", format, userID, len(summary.Imported), len(records), summary.DryRun)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
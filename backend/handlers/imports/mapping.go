@@ -0,0 +1,342 @@
+package imports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/properties"
+)
+
+// Mapping tells the generic parsers which source columns hold what. Every value names a column
+// (a CSV header, or a dotted key path in JSON) unless its name says otherwise.
+type Mapping struct {
+	Address    AddressMapping    `json:"address"`
+	Inspection InspectionMapping `json:"inspection"`
+	Items      []ItemMapping     `json:"items"`
+	// RowsPath expands a nested JSON array into one row per element, e.g. "items"; the parent
+	// entry's fields are repeated on every row and element fields are addressed as "items.name"
+	RowsPath string `json:"rows_path,omitempty"`
+}
+
+type AddressMapping struct {
+	Street           string `json:"street"`
+	City             string `json:"city"`
+	State            string `json:"state"`
+	PostalCode       string `json:"postal_code"`
+	PostalCodeSuffix string `json:"postal_code_suffix,omitempty"`
+	Country          string `json:"country,omitempty"`
+	DefaultCountry   string `json:"default_country,omitempty"` // used when the country column is missing or blank; "US" if unset
+}
+
+type InspectionMapping struct {
+	Date string `json:"date,omitempty"`
+	// DateFormat spells out the date column's layout with YYYY, YY, MM, M, DD and D, e.g. "MM/DD/YYYY".
+	// Defaults to YYYY-MM-DD.
+	DateFormat  string `json:"date_format,omitempty"`
+	Key         string `json:"key,omitempty"` // rows sharing a value form one inspection; default is address and date
+	Weather     string `json:"weather,omitempty"`
+	Temperature string `json:"temperature,omitempty"`
+}
+
+// ItemMapping produces one worksheet item per row. Section and item are either fixed, for
+// spreadsheets with a column group per item, or read from a column, for one row per item.
+type ItemMapping struct {
+	Section          string            `json:"section,omitempty"`        // worksheet key, e.g. "roof"
+	SectionColumn    string            `json:"section_column,omitempty"` // holds a worksheet key or title
+	Item             string            `json:"item,omitempty"`
+	ItemColumn       string            `json:"item_column,omitempty"`
+	Materials        map[string]string `json:"materials,omitempty"`         // material name → column
+	Conditions       string            `json:"conditions,omitempty"`        // column listing checked conditions
+	Separator        string            `json:"separator,omitempty"`         // between listed conditions; default ";"
+	ConditionColumns map[string]string `json:"condition_columns,omitempty"` // condition → yes/no column
+	Comments         string            `json:"comments,omitempty"`
+	Status           string            `json:"status,omitempty"`
+}
+
+// validate checks the mapping is complete enough to build inspections
+func (m *Mapping) validate() []string {
+	var problems []string
+	a := m.Address
+	if a.Street == "" || a.City == "" || a.State == "" || a.PostalCode == "" {
+		problems = append(problems, "address needs street, city, state and postal_code columns")
+	}
+	if _, err := dateLayout(m.Inspection.DateFormat); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(m.Items) == 0 {
+		problems = append(problems, "at least one item mapping is required")
+	}
+	for i, item := range m.Items {
+		if (item.Section == "") == (item.SectionColumn == "") {
+			problems = append(problems, fmt.Sprintf("item %d needs exactly one of section or section_column", i+1))
+		} else if item.Section != "" {
+			if _, ok := inspections.SectionByKey(item.Section); !ok {
+				problems = append(problems, fmt.Sprintf("item %d has unknown section %q", i+1, item.Section))
+			}
+		}
+		if (item.Item == "") == (item.ItemColumn == "") {
+			problems = append(problems, fmt.Sprintf("item %d needs exactly one of item or item_column", i+1))
+		}
+	}
+	return problems
+}
+
+// columns lists every source column the mapping reads
+func (m *Mapping) columns() []string {
+	a, in := m.Address, m.Inspection
+	cols := []string{a.Street, a.City, a.State, a.PostalCode, a.PostalCodeSuffix, a.Country, in.Date, in.Key, in.Weather, in.Temperature}
+	for _, item := range m.Items {
+		cols = append(cols, item.SectionColumn, item.ItemColumn, item.Conditions, item.Comments, item.Status)
+		for _, c := range item.Materials {
+			cols = append(cols, c)
+		}
+		for _, c := range item.ConditionColumns {
+			cols = append(cols, c)
+		}
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range cols {
+		if c != "" && !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// dateLayout turns a pattern like "MM/DD/YYYY" into a Go time layout
+func dateLayout(pattern string) (string, error) {
+	if pattern == "" {
+		return "2006-01-02", nil
+	}
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "M", "1", "D", "2").Replace(pattern)
+	if strings.ContainsAny(layout, "YMD") || !strings.Contains(layout, "1") || !strings.Contains(layout, "2") {
+		return "", fmt.Errorf("unsupported date_format %q", pattern)
+	}
+	return layout, nil
+}
+
+// sourceRow is one row of a source file, keyed by column
+type sourceRow struct {
+	Number int // spreadsheet row, or entry number in a JSON file
+	Values map[string]string
+}
+
+func (r sourceRow) get(column string) string {
+	if column == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Values[column])
+}
+
+// missingColumns reports mapped columns that the source doesn't have
+func missingColumns(m *Mapping, available map[string]bool) []string {
+	var missing []string
+	for _, c := range m.columns() {
+		if !available[c] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+var truthy = map[string]bool{"y": true, "yes": true, "true": true, "1": true, "x": true, "checked": true}
+
+// resolveSection accepts a worksheet key or title
+func resolveSection(value string) (inspections.Section, bool) {
+	if s, ok := inspections.SectionByKey(value); ok {
+		return s, true
+	}
+	for _, s := range inspections.Sections {
+		if strings.EqualFold(s.Key, value) || strings.EqualFold(s.Title, value) {
+			return s, true
+		}
+	}
+	return inspections.Section{}, false
+}
+
+// mapRows groups source rows into inspection records. Rows with problems are reported on their
+// record, which is then skipped as a whole so no inspection is imported half complete.
+func mapRows(rows []sourceRow, m *Mapping) []*Record {
+	layout, _ := dateLayout(m.Inspection.DateFormat)
+	var records []*Record
+	byKey := map[string]*Record{}
+
+	for _, row := range rows {
+		var problems []RowError
+		fail := func(column, format string, args ...interface{}) {
+			problems = append(problems, RowError{Row: row.Number, Column: column, Message: fmt.Sprintf(format, args...)})
+		}
+
+		address := properties.AddressDetails{
+			Street:           row.get(m.Address.Street),
+			City:             row.get(m.Address.City),
+			State:            strings.ToUpper(row.get(m.Address.State)),
+			PostalCode:       row.get(m.Address.PostalCode),
+			PostalCodeSuffix: row.get(m.Address.PostalCodeSuffix),
+			Country:          row.get(m.Address.Country),
+		}
+		if address.Country == "" {
+			address.Country = m.Address.DefaultCountry
+		}
+		if address.Country == "" {
+			// Same short code the address form stores
+			address.Country = "US"
+		}
+		for _, f := range []struct{ column, value string }{
+			{m.Address.Street, address.Street},
+			{m.Address.City, address.City},
+			{m.Address.PostalCode, address.PostalCode},
+		} {
+			if f.value == "" {
+				fail(f.column, "is required")
+			}
+		}
+		if len(address.State) != 2 {
+			fail(m.Address.State, "must be a two-letter state code")
+		}
+
+		date := ""
+		if v := row.get(m.Inspection.Date); v == "" && m.Inspection.Date != "" {
+			fail(m.Inspection.Date, "is required")
+		} else if v != "" {
+			t, err := time.Parse(layout, v)
+			if err != nil {
+				fail(m.Inspection.Date, "%q does not match the date format", v)
+			} else {
+				date = t.Format("2006-01-02")
+			}
+		}
+		var temperature *int
+		if v := strings.TrimRight(row.get(m.Inspection.Temperature), "°FfCc "); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fail(m.Inspection.Temperature, "%q is not a whole number", v)
+			} else {
+				temperature = &n
+			}
+		}
+
+		key := row.get(m.Inspection.Key)
+		if m.Inspection.Key == "" {
+			key = strings.ToLower(strings.Join([]string{address.Street, address.City, address.State, address.PostalCode, date}, "|"))
+		} else if key == "" {
+			fail(m.Inspection.Key, "is required to group rows into inspections")
+		}
+		record := byKey[key]
+		if record == nil {
+			record = &Record{Key: key, Address: address, InspectionDate: date, Items: map[string][]inspections.WorksheetItem{}}
+			byKey[key] = record
+			records = append(records, record)
+		}
+		if n := len(record.Rows); n == 0 || record.Rows[n-1] != row.Number {
+			record.Rows = append(record.Rows, row.Number)
+		}
+		if record.Weather == nil && row.get(m.Inspection.Weather) != "" {
+			weather := row.get(m.Inspection.Weather)
+			record.Weather = &weather
+		}
+		if record.Temperature == nil {
+			record.Temperature = temperature
+		}
+
+		for _, im := range m.Items {
+			section, ok := inspections.SectionByKey(im.Section)
+			if im.SectionColumn != "" {
+				v := row.get(im.SectionColumn)
+				if v == "" {
+					continue
+				}
+				if section, ok = resolveSection(v); !ok {
+					fail(im.SectionColumn, "%q is not a worksheet section", v)
+					continue
+				}
+			}
+			name := im.Item
+			if im.ItemColumn != "" {
+				if name = row.get(im.ItemColumn); name == "" {
+					continue
+				}
+			}
+
+			item := inspections.WorksheetItem{ItemName: name, Materials: map[string]string{}, Conditions: map[string]bool{}}
+			for material, column := range im.Materials {
+				if v := row.get(column); v != "" {
+					item.Materials[material] = v
+				}
+			}
+			if v := row.get(im.Conditions); v != "" {
+				separator := im.Separator
+				if separator == "" {
+					separator = ";"
+				}
+				for _, c := range strings.Split(v, separator) {
+					if c = strings.TrimSpace(c); c != "" {
+						item.Conditions[c] = true
+					}
+				}
+			}
+			for condition, column := range im.ConditionColumns {
+				if truthy[strings.ToLower(row.get(column))] {
+					item.Conditions[condition] = true
+				}
+			}
+			item.Comments = row.get(im.Comments)
+			status, ok := inspections.NormalizeInspectionStatus(row.get(im.Status))
+			if !ok {
+				fail(im.Status, "%q is not an inspection status", status)
+				continue
+			}
+			item.InspectionStatus = status
+			if len(item.Materials) == 0 && len(item.Conditions) == 0 && item.Comments == "" && row.get(im.Status) == "" {
+				// Nothing recorded for this item on this row
+				continue
+			}
+			record.addItem(section.Key, item)
+		}
+
+		record.Errors = append(record.Errors, problems...)
+	}
+
+	for _, record := range records {
+		if len(record.Items) == 0 && len(record.Errors) == 0 {
+			record.Errors = append(record.Errors, RowError{Row: record.Rows[0], Message: "no worksheet items were found for this inspection"})
+		}
+	}
+	return records
+}
+
+// addItem merges an item into the record: materials and conditions add up, comments are appended
+// and a status other than "Not Inspected" wins
+func (r *Record) addItem(sectionKey string, item inspections.WorksheetItem) {
+	items := r.Items[sectionKey]
+	for i := range items {
+		existing := &items[i]
+		if !strings.EqualFold(existing.ItemName, item.ItemName) {
+			continue
+		}
+		for k, v := range item.Materials {
+			existing.Materials[k] = v
+		}
+		for k := range item.Conditions {
+			existing.Conditions[k] = true
+		}
+		if item.Comments != "" {
+			if existing.Comments != "" {
+				existing.Comments += "\n"
+			}
+			existing.Comments += item.Comments
+		}
+		if item.InspectionStatus != "Not Inspected" {
+			existing.InspectionStatus = item.InspectionStatus
+		}
+		return
+	}
+	r.Items[sectionKey] = append(items, item)
+}
@@ -0,0 +1,254 @@
+package imports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/properties"
+)
+
+// Record is one inspection read from a source file
+type Record struct {
+	Rows           []int  // source rows the inspection was assembled from
+	Key            string // what grouped the rows
+	Address        properties.AddressDetails
+	InspectionDate string // YYYY-MM-DD; empty means today
+	Weather        *string
+	Temperature    *int
+	Items          map[string][]inspections.WorksheetItem // by section key
+	Errors         []RowError
+}
+
+// RowError is a problem with one row of the source file
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// Parser reads files exported by other inspection software. Parsers for a tool's own layout
+// can ignore the mapping; problems with single rows belong on the records, while an error means
+// the file as a whole can't be read.
+type Parser interface {
+	Description() string
+	UsesMapping() bool
+	Parse(src io.Reader, mapping *Mapping) ([]*Record, error)
+}
+
+// parsers are the formats an import can name
+var parsers = map[string]Parser{
+	"generic_csv":  csvParser{},
+	"generic_json": jsonParser{},
+}
+
+// Register adds a parser for another tool's export format
+func Register(name string, p Parser) {
+	parsers[name] = p
+}
+
+// FileError is a source file or mapping that can't be used at all
+type FileError struct{ msg string }
+
+func (e *FileError) Error() string { return e.msg }
+
+func fileError(format string, args ...interface{}) error {
+	return &FileError{msg: fmt.Sprintf(format, args...)}
+}
+
+// checkColumns fails when the mapping names columns the file doesn't have, which is almost
+// always a typo that would otherwise import every row without that data
+func checkColumns(m *Mapping, available map[string]bool) error {
+	if missing := missingColumns(m, available); len(missing) > 0 {
+		return fileError("columns not found in the file: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// csvParser reads a CSV file with a header row. Row numbers count the header as row 1, as a
+// spreadsheet shows them.
+type csvParser struct{}
+
+func (csvParser) Description() string {
+	return "CSV with a header row; columns are mapped to the property, the inspection and worksheet items"
+}
+
+func (csvParser) UsesMapping() bool { return true }
+
+func (csvParser) Parse(src io.Reader, m *Mapping) ([]*Record, error) {
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fileError("the file is empty")
+	}
+	if err != nil {
+		return nil, fileError("invalid CSV: %v", err)
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	available := map[string]bool{}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+		available[header[i]] = true
+	}
+	if err := checkColumns(m, available); err != nil {
+		return nil, err
+	}
+
+	var rows []sourceRow
+	for number := 2; ; number++ {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fileError("invalid CSV at row %d: %v", number, err)
+		}
+		row := sourceRow{Number: number, Values: map[string]string{}}
+		blank := true
+		for i, v := range fields {
+			if i < len(header) {
+				row.Values[header[i]] = v
+			}
+			if strings.TrimSpace(v) != "" {
+				blank = false
+			}
+		}
+		if !blank {
+			rows = append(rows, row)
+		}
+	}
+	return mapRows(rows, m), nil
+}
+
+// jsonParser reads a JSON array of objects, or an object holding one under "rows" or
+// "inspections". Nested objects are addressed with dotted paths, and arrays of plain values are
+// joined with the mapping's condition separator. Row numbers are entry positions, from 1.
+type jsonParser struct{}
+
+func (jsonParser) Description() string {
+	return "JSON array of objects; dotted key paths are mapped like columns, and rows_path expands nested item lists"
+}
+
+func (jsonParser) UsesMapping() bool { return true }
+
+func (jsonParser) Parse(src io.Reader, m *Mapping) ([]*Record, error) {
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fileError("invalid JSON: %v", err)
+	}
+	entries, ok := doc.([]interface{})
+	if obj, isObj := doc.(map[string]interface{}); isObj {
+		for _, k := range []string{"rows", "inspections"} {
+			if entries, ok = obj[k].([]interface{}); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fileError(`expected an array of objects, or an object with a "rows" or "inspections" array`)
+	}
+
+	separator := ";"
+	for _, im := range m.Items {
+		if im.Separator != "" {
+			separator = im.Separator
+			break
+		}
+	}
+
+	available := map[string]bool{}
+	var rows []sourceRow
+	for i, entry := range entries {
+		obj, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fileError("entry %d is not an object", i+1)
+		}
+		parent := map[string]string{}
+		var nested []interface{}
+		flatten(parent, "", obj, separator, m.RowsPath, &nested)
+
+		var entryRows []sourceRow
+		if m.RowsPath == "" {
+			entryRows = append(entryRows, sourceRow{Number: i + 1, Values: parent})
+		}
+		for _, child := range nested {
+			values := map[string]string{}
+			for k, v := range parent {
+				values[k] = v
+			}
+			if childObj, ok := child.(map[string]interface{}); ok {
+				flatten(values, m.RowsPath+".", childObj, separator, "", nil)
+			}
+			entryRows = append(entryRows, sourceRow{Number: i + 1, Values: values})
+		}
+		for _, row := range entryRows {
+			for k := range row.Values {
+				available[k] = true
+			}
+		}
+		rows = append(rows, entryRows...)
+	}
+	if len(entries) > 0 {
+		if err := checkColumns(m, available); err != nil {
+			return nil, err
+		}
+	}
+	return mapRows(rows, m), nil
+}
+
+// flatten writes obj's scalar values under dotted keys. The array at rowsPath is handed back
+// through nested instead of being flattened.
+func flatten(out map[string]string, prefix string, obj map[string]interface{}, separator, rowsPath string, nested *[]interface{}) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path := prefix + k
+		switch v := obj[k].(type) {
+		case map[string]interface{}:
+			flatten(out, path+".", v, separator, rowsPath, nested)
+		case []interface{}:
+			if path == rowsPath && nested != nil {
+				*nested = append(*nested, v...)
+				continue
+			}
+			var parts []string
+			for _, e := range v {
+				if s, ok := scalar(e); ok && s != "" {
+					parts = append(parts, s)
+				}
+			}
+			out[path] = strings.Join(parts, separator)
+		default:
+			if s, ok := scalar(v); ok {
+				out[path] = s
+			}
+		}
+	}
+}
+
+func scalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
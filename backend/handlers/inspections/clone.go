@@ -0,0 +1,122 @@
+package inspections
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrCloneSourceNotFound means the requested prior inspection doesn't exist for the property
+var ErrCloneSourceNotFound = errors.New("prior inspection not found for this property")
+
+// CloneOptions controls how a new inspection is seeded from a prior one of the same property
+type CloneOptions struct {
+	SourceInspectionID string
+	LinkPhotos         bool
+}
+
+// CloneSummary reports what was carried over into the new inspection
+type CloneSummary struct {
+	SourceInspectionID string         `json:"source_inspection_id"`
+	Items              map[string]int `json:"items"` // seeded items per section key
+	PhotosLinked       int            `json:"photos_linked"`
+}
+
+// createInspection inserts a new in-progress inspection with the next report number for the property
+func createInspection(db queryExecer, inspectionID, propertyID, inspectionDate string, clonedFrom *string, inspectorID *int) error {
+	// Get the next report number for this property
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM inspections WHERE property_id = ?`, propertyID).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count previous inspections: %v", err)
+	}
+	reportID := fmt.Sprintf("%s-%d", propertyID, count+1)
+
+	query := `INSERT INTO inspections (inspection_id, property_id, inspector_id, inspection_date, status, report_id, cloned_from)
+              VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = db.Exec(query, inspectionID, propertyID, inspectorID, inspectionDate, "in-progress", reportID, clonedFrom)
+	return err
+}
+
+// resolveCloneSource checks the source inspection belongs to the property. "latest" picks the
+// property's most recent inspection.
+func resolveCloneSource(db queryExecer, propertyID, source string) (string, error) {
+	var sourceID string
+	var err error
+	if source == "latest" {
+		err = db.QueryRow(`
+			SELECT inspection_id FROM inspections
+			WHERE property_id = ? AND deleted_at IS NULL
+			ORDER BY COALESCE(inspection_date, '0001-01-01') DESC, created_at DESC
+			LIMIT 1`, propertyID).Scan(&sourceID)
+	} else {
+		err = db.QueryRow(`SELECT inspection_id FROM inspections WHERE inspection_id = ? AND property_id = ? AND deleted_at IS NULL`,
+			source, propertyID).Scan(&sourceID)
+	}
+	if err == sql.ErrNoRows {
+		return "", ErrCloneSourceNotFound
+	}
+	return sourceID, err
+}
+
+// seedInspection copies the prior inspection's worksheet into the new one. Materials carry over,
+// since roof coverings, furnace fuel or panel types rarely change between visits; conditions,
+// statuses and comments start fresh so nothing is reported without being re-inspected.
+// Linked photos reference the original files rather than copying them.
+func seedInspection(db queryExecer, targetID string, opts CloneOptions, authorID *int) (CloneSummary, error) {
+	summary := CloneSummary{SourceInspectionID: opts.SourceInspectionID, Items: map[string]int{}}
+
+	for _, section := range Sections {
+		items, err := LoadWorksheetItems(db, section, opts.SourceInspectionID)
+		if err != nil {
+			return summary, fmt.Errorf("failed to load %s items: %v", section.Key, err)
+		}
+		for _, item := range items {
+			if len(item.Materials) == 0 {
+				continue
+			}
+			seeded := WorksheetItem{
+				InspectionID:     targetID,
+				ItemName:         item.ItemName,
+				Materials:        item.Materials,
+				Conditions:       map[string]bool{},
+				InspectionStatus: "Not Inspected",
+			}
+			if _, _, err := saveWorksheetItem(db, section, seeded, authorID, ""); err != nil {
+				return summary, fmt.Errorf("failed to seed %s item %q: %v", section.Key, item.ItemName, err)
+			}
+			summary.Items[section.Key]++
+		}
+	}
+
+	if !opts.LinkPhotos {
+		return summary, nil
+	}
+
+	rows, err := db.Query(`SELECT item_name, photo_url FROM inspection_photos WHERE inspection_id = ? AND deleted_at IS NULL ORDER BY photo_id`, opts.SourceInspectionID)
+	if err != nil {
+		return summary, fmt.Errorf("failed to load photos: %v", err)
+	}
+	type photo struct{ itemName, url string }
+	var photos []photo
+	for rows.Next() {
+		var p photo
+		if err := rows.Scan(&p.itemName, &p.url); err != nil {
+			rows.Close()
+			return summary, err
+		}
+		photos = append(photos, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+
+	for _, p := range photos {
+		if _, err := insertInspectionPhoto(db, targetID, p.itemName, p.url, ""); err != nil {
+			return summary, fmt.Errorf("failed to link photo: %v", err)
+		}
+		summary.PhotosLinked++
+	}
+	return summary, nil
+}
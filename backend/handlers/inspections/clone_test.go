@@ -0,0 +1,48 @@
+package inspections
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestResolveCloneSource(t *testing.T) {
+	// inspection id -> property id
+	inspections := map[string]string{"A": "house-1", "B": "house-2"}
+	db := openFakeDB(t, &fakeDB{
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+			if !strings.Contains(query, "WHERE inspection_id = ? AND property_id = ?") {
+				return nil, nil, fmt.Errorf("unexpected query %q", query)
+			}
+			id, propertyID := args[0].(string), args[1].(string)
+			if inspections[id] != propertyID {
+				return []string{"inspection_id"}, nil, nil
+			}
+			return []string{"inspection_id"}, [][]driver.Value{{id}}, nil
+		},
+	})
+
+	tests := []struct {
+		name       string
+		propertyID string
+		source     string
+		wantErr    error
+	}{
+		{"same property", "house-1", "A", nil},
+		{"other property", "house-1", "B", ErrCloneSourceNotFound},
+		{"unknown inspection", "house-1", "C", ErrCloneSourceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCloneSource(db, tt.propertyID, tt.source)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.source {
+				t.Errorf("source = %q, want %q", got, tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,571 @@
+package inspections
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// CannedComment is a reusable narrative comment. Section, ItemName and ConditionKey narrow where it
+// applies; empty means any. Comments belong to the inspector who wrote them and are visible to their
+// company once shared.
+type CannedComment struct {
+	CommentID    int    `json:"comment_id"`
+	OwnerID      int    `json:"owner_inspector_id"`
+	OwnerName    string `json:"owner_name,omitempty"`
+	Shared       bool   `json:"shared"`
+	Section      string `json:"section"`
+	ItemName     string `json:"item_name"`
+	ConditionKey string `json:"condition_key"`
+	Title        string `json:"title"`
+	Body         string `json:"body"`
+	IsDefault    bool   `json:"is_default"`
+	UsageCount   int    `json:"usage_count"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+// commentLibrary identifies whose comments a caller can use
+type commentLibrary struct {
+	InspectorID    int
+	OrganizationID *int
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// RenderComment fills the placeholders of a canned comment from a worksheet item:
+// {{material}} (the item's materials), {{location}} (the "location" material, or the item name),
+// {{item}}, {{condition}} and {{section}}. Unknown placeholders are left in place.
+func RenderComment(body string, section Section, item WorksheetItem, condition string) string {
+	var materials []string
+	for k, v := range item.Materials {
+		if v != "" && k != "location" {
+			materials = append(materials, v)
+		}
+	}
+	sort.Strings(materials)
+	location := item.Materials["location"]
+	if location == "" {
+		location = item.ItemName
+	}
+
+	values := map[string]string{
+		"material":  strings.Join(materials, ", "),
+		"location":  location,
+		"item":      item.ItemName,
+		"condition": condition,
+		"section":   section.Title,
+	}
+	return placeholderPattern.ReplaceAllStringFunc(body, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+func callerLibrary(db *sql.DB, r *http.Request) (*commentLibrary, error) {
+	userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+	return libraryForUser(db, userID)
+}
+
+func libraryForUser(db queryExecer, userID int) (*commentLibrary, error) {
+	var lib commentLibrary
+	var orgID sql.NullInt64
+	err := db.QueryRow(`SELECT inspector_id, organization_id FROM inspectors WHERE user_id = ?`, userID).Scan(&lib.InspectorID, &orgID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		lib.OrganizationID = &id
+	}
+	return &lib, nil
+}
+
+// libraryForInspection picks the library used for automatic comments: the saving inspector's,
+// or else the one of the inspector assigned to the inspection
+func libraryForInspection(db queryExecer, inspectionID string, authorID *int) (*commentLibrary, error) {
+	if authorID != nil {
+		lib, err := libraryForUser(db, *authorID)
+		if lib != nil || err != nil {
+			return lib, err
+		}
+	}
+	var userID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT ins.user_id FROM inspections i
+		JOIN inspectors ins ON ins.inspector_id = i.inspector_id
+		WHERE i.inspection_id = ?`, inspectionID).Scan(&userID)
+	if err == sql.ErrNoRows || (err == nil && !userID.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return libraryForUser(db, int(userID.Int64))
+}
+
+// visibility returns the SQL condition and args limiting comments to a library
+func (lib *commentLibrary) visibility() (string, []interface{}) {
+	if lib.OrganizationID == nil {
+		return "c.owner_inspector_id = ?", []interface{}{lib.InspectorID}
+	}
+	return "(c.owner_inspector_id = ? OR c.organization_id = ?)", []interface{}{lib.InspectorID, *lib.OrganizationID}
+}
+
+const commentSelect = `
+	SELECT c.comment_id, c.owner_inspector_id, CONCAT(u.first_name, ' ', u.last_name), c.organization_id IS NOT NULL,
+	       COALESCE(c.section, ''), COALESCE(c.item_name, ''), COALESCE(c.condition_key, ''),
+	       c.title, c.body, c.is_default, c.usage_count, c.created_at, c.updated_at
+	FROM canned_comments c
+	JOIN inspectors ins ON ins.inspector_id = c.owner_inspector_id
+	JOIN users u ON u.user_id = ins.user_id`
+
+func scanCannedComment(scanner interface{ Scan(...interface{}) error }) (CannedComment, error) {
+	var c CannedComment
+	var ownerName sql.NullString
+	err := scanner.Scan(&c.CommentID, &c.OwnerID, &ownerName, &c.Shared, &c.Section, &c.ItemName, &c.ConditionKey,
+		&c.Title, &c.Body, &c.IsDefault, &c.UsageCount, &c.CreatedAt, &c.UpdatedAt)
+	c.OwnerName = ownerName.String
+	return c, err
+}
+
+// defaultComment finds the default comment for a checked condition. The most specific match wins
+// (item over any item), and the inspector's own comments win over shared company ones.
+func defaultComment(db queryExecer, lib *commentLibrary, section Section, itemName, condition string) (*CannedComment, error) {
+	visible, args := lib.visibility()
+	args = append(args, section.Key, itemName, condition, lib.InspectorID)
+	c, err := scanCannedComment(db.QueryRow(commentSelect+`
+		WHERE `+visible+` AND c.is_default
+		  AND (c.section IS NULL OR c.section = ?)
+		  AND (c.item_name IS NULL OR c.item_name = ?)
+		  AND c.condition_key = ?
+		ORDER BY c.item_name IS NULL, c.section IS NULL, c.owner_inspector_id = ? DESC, c.comment_id
+		LIMIT 1`, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// applyDefaultComments appends the default comment of every condition newly checked in this save.
+// Conditions that were already checked are skipped so repeated autosaves don't duplicate text.
+func applyDefaultComments(db queryExecer, section Section, record WorksheetItem, authorID *int) (WorksheetItem, []int, error) {
+	lib, err := libraryForInspection(db, record.InspectionID, authorID)
+	if err != nil || lib == nil {
+		return record, nil, err
+	}
+	previous, err := loadWorksheetItem(db, section, record.InspectionID, record.ItemName)
+	if err != nil {
+		return record, nil, err
+	}
+
+	var conditions []string
+	for condition, checked := range record.Conditions {
+		if checked && (previous == nil || !previous.Conditions[condition]) {
+			conditions = append(conditions, condition)
+		}
+	}
+	sort.Strings(conditions)
+
+	var used []int
+	for _, condition := range conditions {
+		comment, err := defaultComment(db, lib, section, record.ItemName, condition)
+		if err != nil {
+			return record, nil, err
+		}
+		if comment == nil {
+			continue
+		}
+		text := RenderComment(comment.Body, section, record, condition)
+		if strings.Contains(record.Comments, text) {
+			continue
+		}
+		if strings.TrimSpace(record.Comments) != "" {
+			record.Comments += "\n"
+		}
+		record.Comments += text
+		used = append(used, comment.CommentID)
+	}
+	return record, used, nil
+}
+
+func countCommentUse(db queryExecer, commentIDs ...int) error {
+	for _, id := range commentIDs {
+		if _, err := db.Exec(`UPDATE canned_comments SET usage_count = usage_count + 1 WHERE comment_id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func requireLibrary(db *sql.DB, w http.ResponseWriter, r *http.Request) *commentLibrary {
+	lib, err := callerLibrary(db, r)
+	if err != nil {
+		log.Printf("[Comments] Error resolving inspector: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil
+	}
+	if lib == nil {
+		http.Error(w, "Only inspectors have a comment library", http.StatusForbidden)
+		return nil
+	}
+	return lib
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func validateCannedComment(c CannedComment) string {
+	if strings.TrimSpace(c.Title) == "" || strings.TrimSpace(c.Body) == "" {
+		return "title and body are required"
+	}
+	if c.Section != "" {
+		if _, ok := SectionByKey(c.Section); !ok {
+			return "section is not a known worksheet section"
+		}
+	}
+	if c.IsDefault && c.ConditionKey == "" {
+		return "a default comment needs a condition_key"
+	}
+	return ""
+}
+
+// ListCannedComments searches the caller's library. Query parameters: section, item_name and
+// condition_key (also matching comments that apply to any), q (title/body search),
+// scope ("mine", "shared" or all) and sort ("usage" or "title").
+func ListCannedComments(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+		q := r.URL.Query()
+
+		visible, args := lib.visibility()
+		where := []string{visible}
+		for _, f := range []struct{ param, column string }{
+			{"section", "c.section"},
+			{"item_name", "c.item_name"},
+			{"condition_key", "c.condition_key"},
+		} {
+			if v := q.Get(f.param); v != "" {
+				where = append(where, "("+f.column+" IS NULL OR "+f.column+" = ?)")
+				args = append(args, v)
+			}
+		}
+		if v := strings.TrimSpace(q.Get("q")); v != "" {
+			like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(v) + "%"
+			where = append(where, "(c.title LIKE ? OR c.body LIKE ?)")
+			args = append(args, like, like)
+		}
+		switch q.Get("scope") {
+		case "mine":
+			where = append(where, "c.owner_inspector_id = ?")
+			args = append(args, lib.InspectorID)
+		case "shared":
+			where = append(where, "c.organization_id IS NOT NULL")
+		}
+
+		order := "c.usage_count DESC, c.title"
+		if q.Get("sort") == "title" {
+			order = "c.title"
+		}
+
+		rows, err := db.Query(commentSelect+" WHERE "+strings.Join(where, " AND ")+" ORDER BY "+order, args...)
+		if err != nil {
+			log.Printf("[Comments] Error listing comments: %v", err)
+			http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		comments := []CannedComment{}
+		for rows.Next() {
+			c, err := scanCannedComment(rows)
+			if err != nil {
+				log.Printf("[Comments] Error scanning comment: %v", err)
+				http.Error(w, "Failed to read comments", http.StatusInternalServerError)
+				return
+			}
+			comments = append(comments, c)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comments)
+	}
+}
+
+// CreateCannedComment adds a comment to the caller's personal library; set shared to publish it to the company
+func CreateCannedComment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+
+		var c CannedComment
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if msg := validateCannedComment(c); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if c.Shared && lib.OrganizationID == nil {
+			http.Error(w, "You are not part of a company to share with", http.StatusBadRequest)
+			return
+		}
+
+		var orgID interface{}
+		if c.Shared {
+			orgID = *lib.OrganizationID
+		}
+		res, err := db.Exec(`
+			INSERT INTO canned_comments (owner_inspector_id, organization_id, section, item_name, condition_key, title, body, is_default)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			lib.InspectorID, orgID, nullIfEmpty(c.Section), nullIfEmpty(c.ItemName), nullIfEmpty(c.ConditionKey),
+			strings.TrimSpace(c.Title), c.Body, c.IsDefault)
+		if err != nil {
+			log.Printf("[Comments] Error creating comment: %v", err)
+			http.Error(w, "Failed to create comment", http.StatusInternalServerError)
+			return
+		}
+		id, _ := res.LastInsertId()
+
+		created, err := scanCannedComment(db.QueryRow(commentSelect+` WHERE c.comment_id = ?`, id))
+		if err != nil {
+			log.Printf("[Comments] Error loading comment %d: %v", id, err)
+			http.Error(w, "Failed to load comment", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// ownedComment loads a comment the caller may change: only its author can edit, share or delete it
+func ownedComment(db *sql.DB, w http.ResponseWriter, r *http.Request, lib *commentLibrary) *CannedComment {
+	id, err := strconv.Atoi(mux.Vars(r)["comment_id"])
+	if err != nil {
+		http.Error(w, "Invalid comment_id", http.StatusBadRequest)
+		return nil
+	}
+	c, err := scanCannedComment(db.QueryRow(commentSelect+` WHERE c.comment_id = ?`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Printf("[Comments] Error loading comment %d: %v", id, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil
+	}
+	if c.OwnerID != lib.InspectorID {
+		http.Error(w, "Only the author can change this comment", http.StatusForbidden)
+		return nil
+	}
+	return &c
+}
+
+// UpdateCannedComment replaces a comment's text, targeting and sharing
+func UpdateCannedComment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+		existing := ownedComment(db, w, r, lib)
+		if existing == nil {
+			return
+		}
+
+		var c CannedComment
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if msg := validateCannedComment(c); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if c.Shared && lib.OrganizationID == nil {
+			http.Error(w, "You are not part of a company to share with", http.StatusBadRequest)
+			return
+		}
+
+		var orgID interface{}
+		if c.Shared {
+			orgID = *lib.OrganizationID
+		}
+		_, err := db.Exec(`
+			UPDATE canned_comments
+			SET organization_id = ?, section = ?, item_name = ?, condition_key = ?, title = ?, body = ?, is_default = ?
+			WHERE comment_id = ?`,
+			orgID, nullIfEmpty(c.Section), nullIfEmpty(c.ItemName), nullIfEmpty(c.ConditionKey),
+			strings.TrimSpace(c.Title), c.Body, c.IsDefault, existing.CommentID)
+		if err == nil {
+			*existing, err = scanCannedComment(db.QueryRow(commentSelect+` WHERE c.comment_id = ?`, existing.CommentID))
+		}
+		if err != nil {
+			log.Printf("[Comments] Error updating comment %d: %v", existing.CommentID, err)
+			http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(existing)
+	}
+}
+
+// ShareCannedComment shares a comment with (or withdraws it from) the author's company
+func ShareCannedComment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+		existing := ownedComment(db, w, r, lib)
+		if existing == nil {
+			return
+		}
+
+		var req struct {
+			Shared bool `json:"shared"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if req.Shared && lib.OrganizationID == nil {
+			http.Error(w, "You are not part of a company to share with", http.StatusBadRequest)
+			return
+		}
+
+		var orgID interface{}
+		if req.Shared {
+			orgID = *lib.OrganizationID
+		}
+		if _, err := db.Exec(`UPDATE canned_comments SET organization_id = ? WHERE comment_id = ?`, orgID, existing.CommentID); err != nil {
+			log.Printf("[Comments] Error sharing comment %d: %v", existing.CommentID, err)
+			http.Error(w, "Failed to share comment", http.StatusInternalServerError)
+			return
+		}
+		existing.Shared = req.Shared
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(existing)
+	}
+}
+
+// DeleteCannedComment removes a comment from the library
+func DeleteCannedComment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+		existing := ownedComment(db, w, r, lib)
+		if existing == nil {
+			return
+		}
+		if _, err := db.Exec(`DELETE FROM canned_comments WHERE comment_id = ?`, existing.CommentID); err != nil {
+			log.Printf("[Comments] Error deleting comment %d: %v", existing.CommentID, err)
+			http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Comment deleted"})
+	}
+}
+
+// UseCannedComment renders a comment for a worksheet item and counts the use. The client inserts
+// the returned text; nothing is written to the worksheet here.
+func UseCannedComment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lib := requireLibrary(db, w, r)
+		if lib == nil {
+			return
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["comment_id"])
+		if err != nil {
+			http.Error(w, "Invalid comment_id", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			InspectionID string `json:"inspection_id"`
+			Section      string `json:"section"`
+			ItemName     string `json:"item_name"`
+			ConditionKey string `json:"condition_key"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		section, ok := SectionByKey(req.Section)
+		if !ok || req.InspectionID == "" || req.ItemName == "" {
+			http.Error(w, "inspection_id, section and item_name are required", http.StatusBadRequest)
+			return
+		}
+
+		visible, args := lib.visibility()
+		c, err := scanCannedComment(db.QueryRow(commentSelect+` WHERE c.comment_id = ? AND `+visible, append([]interface{}{id}, args...)...))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Comments] Error loading comment %d: %v", id, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		item, err := loadWorksheetItem(db, section, req.InspectionID, req.ItemName)
+		if err != nil {
+			log.Printf("[Comments] Error loading item %q: %v", req.ItemName, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if item == nil {
+			item = &WorksheetItem{InspectionID: req.InspectionID, ItemName: req.ItemName}
+		}
+		condition := req.ConditionKey
+		if condition == "" {
+			condition = c.ConditionKey
+		}
+
+		if err := countCommentUse(db, c.CommentID); err != nil {
+			log.Printf("[Comments] Error counting use of comment %d: %v", c.CommentID, err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"comment_id": c.CommentID,
+			"text":       RenderComment(c.Body, section, *item, condition),
+		})
+	}
+}
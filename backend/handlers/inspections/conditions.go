@@ -0,0 +1,147 @@
+package inspections
+
+import "strings"
+
+// Severity ranks a checked worksheet condition, from acceptable to a safety hazard
+type Severity int
+
+const (
+	SeverityNone Severity = iota
+	SeverityMaintenance
+	SeverityRepair
+	SeverityMajor
+	SeveritySafety
+)
+
+var severityNames = map[Severity]string{
+	SeverityNone:        "none",
+	SeverityMaintenance: "maintenance",
+	SeverityRepair:      "repair",
+	SeverityMajor:       "major",
+	SeveritySafety:      "safety",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// ParseSeverity is the inverse of String; unknown names rank as SeverityNone
+func ParseSeverity(name string) (Severity, bool) {
+	for s, n := range severityNames {
+		if n == strings.ToLower(strings.TrimSpace(name)) {
+			return s, true
+		}
+	}
+	return SeverityNone, false
+}
+
+// conditionSeverities covers the condition checkboxes offered by the worksheets.
+// Conditions not listed here are treated as needing repair.
+var conditionSeverities = map[string]Severity{
+	// Acceptable / informational
+	"normal":             SeverityNone,
+	"operational":        SeverityNone,
+	"properly installed": SeverityNone,
+	"proper":             SeverityNone,
+	"proper slope":       SeverityNone,
+	"properly vented":    SeverityNone,
+	"intact":             SeverityNone,
+	"secure":             SeverityNone,
+	"safe":               SeverityNone,
+	"adequate":           SeverityNone,
+	"evenly distributed": SeverityNone,
+	"dry":                SeverityNone,
+	"none":               SeverityNone,
+	"unknown":            SeverityNone,
+
+	// Wear and upkeep
+	"normal wear":    SeverityMaintenance,
+	"surface wear":   SeverityMaintenance,
+	"peeling":        SeverityMaintenance,
+	"rust":           SeverityMaintenance,
+	"rusty":          SeverityMaintenance,
+	"efflorescence":  SeverityMaintenance,
+	"granule loss":   SeverityMaintenance,
+	"compressed":     SeverityMaintenance,
+	"settled":        SeverityMaintenance,
+	"modified":       SeverityMaintenance,
+	"past":           SeverityMaintenance,
+	"noisy":          SeverityMaintenance,
+	"sticking":       SeverityMaintenance,
+	"misaligned":     SeverityMaintenance,
+	"remote missing": SeverityMaintenance,
+
+	// Major defects
+	"major defects":      SeverityMajor,
+	"leaking":            SeverityMajor,
+	"leaks":              SeverityMajor,
+	"active":             SeverityMajor,
+	"moisture":           SeverityMajor,
+	"wet":                SeverityMajor,
+	"rot":                SeverityMajor,
+	"non-operational":    SeverityMajor,
+	"not operational":    SeverityMajor,
+	"not responsive":     SeverityMajor,
+	"bulging":            SeverityMajor,
+	"sagging":            SeverityMajor,
+	"leaning":            SeverityMajor,
+	"heaved":             SeverityMajor,
+	"settling":           SeverityMajor,
+	"cracked masonry":    SeverityMajor,
+	"improper flashing":  SeverityMajor,
+	"failed seal":        SeverityMajor,
+	"damaged firebox":    SeverityMajor,
+	"insulation missing": SeverityMajor,
+
+	// Safety hazards
+	"unsafe":                      SeveritySafety,
+	"wired incorrectly":           SeveritySafety,
+	"improper wiring":             SeveritySafety,
+	"wiring issues":               SeveritySafety,
+	"reversed polarity":           SeveritySafety,
+	"double tapped":               SeveritySafety,
+	"ungrounded":                  SeveritySafety,
+	"missing covers":              SeveritySafety,
+	"no safety reversal":          SeveritySafety,
+	"auto-reverse not functional": SeveritySafety,
+	"improperly vented":           SeveritySafety,
+	"cracked flue":                SeveritySafety,
+	"creosote buildup":            SeveritySafety,
+	"loose railings":              SeveritySafety,
+	"no expansion tank":           SeveritySafety,
+	"expired":                     SeveritySafety,
+	"broken glass":                SeveritySafety,
+}
+
+// ConditionSeverity ranks a condition label as shown on the worksheets
+func ConditionSeverity(condition string) Severity {
+	if s, ok := conditionSeverities[strings.ToLower(strings.TrimSpace(condition))]; ok {
+		return s
+	}
+	return SeverityRepair
+}
+
+// ItemDefects returns the checked conditions of an item that call for action, with their severity
+func ItemDefects(item WorksheetItem) map[string]Severity {
+	defects := map[string]Severity{}
+	for condition, checked := range item.Conditions {
+		if !checked {
+			continue
+		}
+		if s := ConditionSeverity(condition); s > SeverityNone {
+			defects[condition] = s
+		}
+	}
+	return defects
+}
+
+// ItemSeverity is the worst severity among an item's checked conditions
+func ItemSeverity(item WorksheetItem) Severity {
+	worst := SeverityNone
+	for _, s := range ItemDefects(item) {
+		if s > worst {
+			worst = s
+		}
+	}
+	return worst
+}
@@ -0,0 +1,644 @@
+package inspections
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/agreements"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// Defect statuses. Derived defects are cleared when their condition is unchecked and reopened when
+// it is checked again; dismissed ones stay dismissed so a deleted derived defect doesn't come back.
+const (
+	DefectOpen      = "open"
+	DefectCleared   = "cleared"
+	DefectDismissed = "dismissed"
+)
+
+// Defect is a tracked finding on a worksheet item, either derived from a checked condition or added by hand
+type Defect struct {
+	DefectID         int      `json:"defect_id"`
+	InspectionID     string   `json:"inspection_id"`
+	Section          string   `json:"section"`
+	ItemName         string   `json:"item_name"`
+	ConditionKey     *string  `json:"condition_key"`
+	Source           string   `json:"source"`
+	Status           string   `json:"status"`
+	Severity         string   `json:"severity"`
+	Location         string   `json:"location"`
+	Recommendation   string   `json:"recommendation"`
+	ResponsibleTrade string   `json:"responsible_trade"`
+	CostMin          *float64 `json:"cost_min"`
+	CostMax          *float64 `json:"cost_max"`
+	PhotoIDs         []int    `json:"photo_ids"`
+	CreatedAt        string   `json:"created_at"`
+	UpdatedAt        string   `json:"updated_at"`
+}
+
+// DefectInput carries the editable fields of a defect; nil fields are left as they are on update
+type DefectInput struct {
+	Section          *string  `json:"section"`
+	ItemName         *string  `json:"item_name"`
+	Status           *string  `json:"status"`
+	Severity         *string  `json:"severity"`
+	Location         *string  `json:"location"`
+	Recommendation   *string  `json:"recommendation"`
+	ResponsibleTrade *string  `json:"responsible_trade"`
+	CostMin          *float64 `json:"cost_min"`
+	CostMax          *float64 `json:"cost_max"`
+	PhotoIDs         *[]int   `json:"photo_ids"`
+}
+
+// sectionTrades is the default trade suggested for defects derived in each section
+var sectionTrades = map[string]string{
+	"exterior":           "General contractor",
+	"roof":               "Roofing contractor",
+	"basementFoundation": "Foundation contractor",
+	"heating":            "HVAC contractor",
+	"cooling":            "HVAC contractor",
+	"plumbing":           "Plumber",
+	"electrical":         "Electrician",
+	"attic":              "Insulation contractor",
+	"doorsWindows":       "Carpenter",
+	"fireplace":          "Chimney sweep",
+	"systemsComponents":  "General contractor",
+}
+
+const defectSelect = `
+	SELECT defect_id, inspection_id, section, item_name, condition_key, source, status, severity,
+	       COALESCE(location, ''), COALESCE(recommendation, ''), COALESCE(responsible_trade, ''),
+	       cost_min, cost_max, created_at, updated_at
+	FROM defects`
+
+func scanDefect(scanner interface{ Scan(...interface{}) error }) (Defect, error) {
+	var d Defect
+	var conditionKey sql.NullString
+	var costMin, costMax sql.NullFloat64
+	err := scanner.Scan(&d.DefectID, &d.InspectionID, &d.Section, &d.ItemName, &conditionKey, &d.Source, &d.Status, &d.Severity,
+		&d.Location, &d.Recommendation, &d.ResponsibleTrade, &costMin, &costMax, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return d, err
+	}
+	if conditionKey.Valid {
+		d.ConditionKey = &conditionKey.String
+	}
+	if costMin.Valid {
+		d.CostMin = &costMin.Float64
+	}
+	if costMax.Valid {
+		d.CostMax = &costMax.Float64
+	}
+	d.PhotoIDs = []int{}
+	return d, nil
+}
+
+// attachDefectPhotos fills in the linked photo ids of the given defects
+func attachDefectPhotos(db queryExecer, defects []Defect) error {
+	if len(defects) == 0 {
+		return nil
+	}
+	index := map[int]*Defect{}
+	placeholders := make([]string, len(defects))
+	args := make([]interface{}, len(defects))
+	for i := range defects {
+		index[defects[i].DefectID] = &defects[i]
+		placeholders[i] = "?"
+		args[i] = defects[i].DefectID
+	}
+
+	rows, err := db.Query(`SELECT defect_id, photo_id FROM defect_photos WHERE defect_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY photo_id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var defectID, photoID int
+		if err := rows.Scan(&defectID, &photoID); err != nil {
+			return err
+		}
+		index[defectID].PhotoIDs = append(index[defectID].PhotoIDs, photoID)
+	}
+	return rows.Err()
+}
+
+// LoadDefects returns an inspection's defects in report order, optionally limited to some statuses
+func LoadDefects(db queryExecer, inspectionID string, statuses ...string) ([]Defect, error) {
+	query := defectSelect + ` WHERE inspection_id = ?`
+	args := []interface{}{inspectionID}
+	if len(statuses) > 0 {
+		placeholders := make([]string, len(statuses))
+		for i, s := range statuses {
+			placeholders[i] = "?"
+			args = append(args, s)
+		}
+		query += ` AND status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+
+	rows, err := db.Query(query+` ORDER BY defect_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defects := []Defect{}
+	for rows.Next() {
+		d, err := scanDefect(rows)
+		if err != nil {
+			return nil, err
+		}
+		defects = append(defects, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := attachDefectPhotos(db, defects); err != nil {
+		return nil, err
+	}
+
+	order := map[string]int{}
+	for i, s := range Sections {
+		order[s.Key] = i
+	}
+	sort.SliceStable(defects, func(i, j int) bool { return order[defects[i].Section] < order[defects[j].Section] })
+	return defects, nil
+}
+
+func loadDefect(db queryExecer, defectID int) (*Defect, error) {
+	d, err := scanDefect(db.QueryRow(defectSelect+` WHERE defect_id = ?`, defectID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defects := []Defect{d}
+	if err := attachDefectPhotos(db, defects); err != nil {
+		return nil, err
+	}
+	return &defects[0], nil
+}
+
+// syncDerivedDefects keeps the derived defects of an item in line with its checked conditions.
+// New defect conditions get a record with default severity and trade; details entered on
+// existing records are kept.
+func syncDerivedDefects(db queryExecer, section Section, item WorksheetItem) error {
+	defects := ItemDefects(item)
+
+	rows, err := db.Query(`SELECT defect_id, condition_key, status FROM defects WHERE inspection_id = ? AND section = ? AND item_name = ? AND source = 'derived'`,
+		item.InspectionID, section.Key, item.ItemName)
+	if err != nil {
+		return fmt.Errorf("failed to load derived defects: %v", err)
+	}
+	type existing struct {
+		id     int
+		status string
+	}
+	current := map[string]existing{}
+	for rows.Next() {
+		var e existing
+		var key string
+		if err := rows.Scan(&e.id, &key, &e.status); err != nil {
+			rows.Close()
+			return err
+		}
+		current[key] = e
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for key, e := range current {
+		_, checked := defects[key]
+		switch {
+		case checked && e.status == DefectCleared:
+			_, err = db.Exec(`UPDATE defects SET status = ? WHERE defect_id = ?`, DefectOpen, e.id)
+		case !checked && e.status == DefectOpen:
+			_, err = db.Exec(`UPDATE defects SET status = ? WHERE defect_id = ?`, DefectCleared, e.id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update derived defect: %v", err)
+		}
+	}
+
+	for key, severity := range defects {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		_, err := db.Exec(`
+			INSERT INTO defects (inspection_id, section, item_name, condition_key, source, status, severity, responsible_trade)
+			VALUES (?, ?, ?, ?, 'derived', ?, ?, ?)`,
+			item.InspectionID, section.Key, item.ItemName, key, DefectOpen, severity.String(), sectionTrades[section.Key])
+		if err != nil {
+			return fmt.Errorf("failed to insert derived defect: %v", err)
+		}
+	}
+	return nil
+}
+
+// validateDefectInput checks the fields present in the input; photos must belong to the inspection
+func validateDefectInput(db queryExecer, inspectionID string, in DefectInput) ([]string, error) {
+	var problems []string
+	if in.Section != nil {
+		if _, ok := SectionByKey(*in.Section); !ok {
+			problems = append(problems, "section is not a known worksheet section")
+		}
+	}
+	if in.ItemName != nil && strings.TrimSpace(*in.ItemName) == "" {
+		problems = append(problems, "item_name must not be empty")
+	}
+	if in.Severity != nil {
+		if s, ok := ParseSeverity(*in.Severity); !ok || s == SeverityNone {
+			problems = append(problems, "severity must be maintenance, repair, major or safety")
+		}
+	}
+	if in.Status != nil && *in.Status != DefectOpen && *in.Status != DefectCleared && *in.Status != DefectDismissed {
+		problems = append(problems, "status must be open, cleared or dismissed")
+	}
+	if (in.CostMin != nil && *in.CostMin < 0) || (in.CostMax != nil && *in.CostMax < 0) {
+		problems = append(problems, "cost estimates must not be negative")
+	}
+	if in.CostMin != nil && in.CostMax != nil && *in.CostMin > *in.CostMax {
+		problems = append(problems, "cost_min must not exceed cost_max")
+	}
+	if in.PhotoIDs != nil {
+		for _, id := range *in.PhotoIDs {
+			var owner string
+			err := db.QueryRow(`SELECT inspection_id FROM inspection_photos WHERE photo_id = ? AND deleted_at IS NULL`, id).Scan(&owner)
+			if err == sql.ErrNoRows || (err == nil && owner != inspectionID) {
+				problems = append(problems, fmt.Sprintf("photo %d does not belong to this inspection", id))
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return problems, nil
+}
+
+func setDefectPhotos(db queryExecer, defectID int, photoIDs []int) error {
+	if _, err := db.Exec(`DELETE FROM defect_photos WHERE defect_id = ?`, defectID); err != nil {
+		return err
+	}
+	seen := map[int]bool{}
+	for _, id := range photoIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := db.Exec(`INSERT INTO defect_photos (defect_id, photo_id) VALUES (?, ?)`, defectID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDefectInput writes the provided fields of a defect
+func applyDefectInput(db queryExecer, defectID int, in DefectInput) error {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	if in.Status != nil {
+		set("status", *in.Status)
+	}
+	if in.Severity != nil {
+		s, _ := ParseSeverity(*in.Severity)
+		set("severity", s.String())
+	}
+	if in.Location != nil {
+		set("location", *in.Location)
+	}
+	if in.Recommendation != nil {
+		set("recommendation", *in.Recommendation)
+	}
+	if in.ResponsibleTrade != nil {
+		set("responsible_trade", *in.ResponsibleTrade)
+	}
+	if in.CostMin != nil {
+		set("cost_min", *in.CostMin)
+	}
+	if in.CostMax != nil {
+		set("cost_max", *in.CostMax)
+	}
+	if len(sets) > 0 {
+		args = append(args, defectID)
+		if _, err := db.Exec(`UPDATE defects SET `+strings.Join(sets, ", ")+` WHERE defect_id = ?`, args...); err != nil {
+			return err
+		}
+	}
+	if in.PhotoIDs != nil {
+		return setDefectPhotos(db, defectID, *in.PhotoIDs)
+	}
+	return nil
+}
+
+func writeDefectError(w http.ResponseWriter, problems []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Invalid defect", "errors": problems})
+}
+
+// ListDefects returns an inspection's defects. Query parameters: status (comma separated, default
+// "open", or "all"), section and severity.
+func ListDefects(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		q := r.URL.Query()
+
+		statuses := []string{DefectOpen}
+		if v := q.Get("status"); v == "all" {
+			statuses = nil
+		} else if v != "" {
+			statuses = strings.Split(v, ",")
+		}
+
+		defects, err := LoadDefects(db, inspectionID, statuses...)
+		if err != nil {
+			log.Printf("[Defects] Error loading defects for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to fetch defects", http.StatusInternalServerError)
+			return
+		}
+
+		section, severity := q.Get("section"), q.Get("severity")
+		filtered := []Defect{}
+		for _, d := range defects {
+			if (section == "" || d.Section == section) && (severity == "" || d.Severity == severity) {
+				filtered = append(filtered, d)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(filtered)
+	}
+}
+
+// CreateDefect records a defect by hand, for findings not covered by a condition checkbox
+func CreateDefect(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "change defects") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+
+		var in DefectInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspections WHERE inspection_id = ?)`, inspectionID).Scan(&exists); err != nil {
+			log.Printf("[Defects] Error checking inspection %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+
+		problems, err := validateDefectInput(db, inspectionID, in)
+		if err != nil {
+			log.Printf("[Defects] Error validating defect: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if in.Section == nil || in.ItemName == nil {
+			problems = append(problems, "section and item_name are required")
+		}
+		if in.Severity == nil {
+			problems = append(problems, "severity is required")
+		}
+		if len(problems) > 0 {
+			writeDefectError(w, problems)
+			return
+		}
+
+		trade := sectionTrades[*in.Section]
+		if in.ResponsibleTrade != nil {
+			trade = *in.ResponsibleTrade
+		}
+		in.ResponsibleTrade = &trade
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		if rejectIfLocked(w, tx, inspectionID) {
+			return
+		}
+
+		res, err := tx.Exec(`INSERT INTO defects (inspection_id, section, item_name, source, status, severity) VALUES (?, ?, ?, 'manual', ?, ?)`,
+			inspectionID, *in.Section, strings.TrimSpace(*in.ItemName), DefectOpen, *in.Severity)
+		var defect *Defect
+		if err == nil {
+			id, _ := res.LastInsertId()
+			if err = applyDefectInput(tx, int(id), in); err == nil {
+				defect, err = loadDefect(tx, int(id))
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Defects] Error creating defect: %v", err)
+			http.Error(w, "Failed to create defect", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(defect)
+	}
+}
+
+func defectIDFromRequest(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["defect_id"])
+	if err != nil {
+		http.Error(w, "Invalid defect_id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// GetDefect returns one defect with its linked photos. The path has no inspection id for the report
+// gate, so the same check runs here; it expects JWTAuthMiddleware to run first.
+func GetDefect(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defectID, ok := defectIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+		defect, err := loadDefect(db, defectID)
+		if err != nil {
+			log.Printf("[Defects] Error loading defect %d: %v", defectID, err)
+			http.Error(w, "Failed to fetch defect", http.StatusInternalServerError)
+			return
+		}
+		if defect == nil {
+			http.Error(w, "Defect not found", http.StatusNotFound)
+			return
+		}
+		if !agreements.CanViewReport(db, w, r, defect.InspectionID) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(defect)
+	}
+}
+
+// UpdateDefect changes the fields present in the body. The section, item and condition of a
+// derived defect follow the worksheet and can't be edited here.
+func UpdateDefect(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "change defects") {
+			return
+		}
+		defectID, ok := defectIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		var in DefectInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		defect, err := loadDefect(tx, defectID)
+		if err != nil {
+			log.Printf("[Defects] Error loading defect %d: %v", defectID, err)
+			http.Error(w, "Failed to fetch defect", http.StatusInternalServerError)
+			return
+		}
+		if defect == nil {
+			http.Error(w, "Defect not found", http.StatusNotFound)
+			return
+		}
+		if rejectIfLocked(w, tx, defect.InspectionID) {
+			return
+		}
+
+		problems, err := validateDefectInput(tx, defect.InspectionID, in)
+		if err != nil {
+			log.Printf("[Defects] Error validating defect: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if defect.Source == "derived" && (in.Section != nil || in.ItemName != nil) {
+			problems = append(problems, "section and item_name of a derived defect follow the worksheet")
+		}
+		costMin, costMax := defect.CostMin, defect.CostMax
+		if in.CostMin != nil {
+			costMin = in.CostMin
+		}
+		if in.CostMax != nil {
+			costMax = in.CostMax
+		}
+		if costMin != nil && costMax != nil && *costMin > *costMax {
+			problems = append(problems, "cost_min must not exceed cost_max")
+		}
+		if len(problems) > 0 {
+			writeDefectError(w, problems)
+			return
+		}
+
+		if defect.Source == "manual" && (in.Section != nil || in.ItemName != nil) {
+			if in.Section != nil {
+				defect.Section = *in.Section
+			}
+			if in.ItemName != nil {
+				defect.ItemName = strings.TrimSpace(*in.ItemName)
+			}
+			_, err = tx.Exec(`UPDATE defects SET section = ?, item_name = ? WHERE defect_id = ?`, defect.Section, defect.ItemName, defectID)
+		}
+		if err == nil {
+			err = applyDefectInput(tx, defectID, in)
+		}
+		if err == nil {
+			defect, err = loadDefect(tx, defectID)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Defects] Error updating defect %d: %v", defectID, err)
+			http.Error(w, "Failed to update defect", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(defect)
+	}
+}
+
+// DeleteDefect removes a manual defect. Derived defects are dismissed instead, so saving the
+// worksheet again doesn't bring them back.
+func DeleteDefect(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "change defects") {
+			return
+		}
+		defectID, ok := defectIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		var source, inspectionID string
+		err = tx.QueryRow(`SELECT source, inspection_id FROM defects WHERE defect_id = ?`, defectID).Scan(&source, &inspectionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Defect not found", http.StatusNotFound)
+			return
+		}
+		if err == nil && rejectIfLocked(w, tx, inspectionID) {
+			return
+		}
+		if err == nil {
+			if source == "derived" {
+				_, err = tx.Exec(`UPDATE defects SET status = ? WHERE defect_id = ?`, DefectDismissed, defectID)
+			} else {
+				_, err = tx.Exec(`DELETE FROM defects WHERE defect_id = ?`, defectID)
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Defects] Error deleting defect %d: %v", defectID, err)
+			http.Error(w, "Failed to delete defect", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Defect deleted"})
+	}
+}
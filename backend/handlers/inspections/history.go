@@ -0,0 +1,192 @@
+package inspections
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+type ItemRevision struct {
+	RevisionID   int           `json:"revision_id"`
+	InspectionID string        `json:"inspection_id"`
+	Section      string        `json:"section"`
+	ItemName     string        `json:"item_name"`
+	Revision     int           `json:"revision"`
+	Action       string        `json:"action"`
+	AuthorID     *int          `json:"author_id"`
+	AuthorName   string        `json:"author_name,omitempty"`
+	Changes      []FieldChange `json:"changes"`
+	Snapshot     WorksheetItem `json:"snapshot"`
+	CreatedAt    string        `json:"created_at"`
+}
+
+// insertItemRevision appends the item's new state to its history with the next revision number
+func insertItemRevision(db queryExecer, section Section, item WorksheetItem, authorID *int, action string, changes []FieldChange) error {
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision diff: %v", err)
+	}
+	snapshotJSON, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revision snapshot: %v", err)
+	}
+
+	var next int
+	err = db.QueryRow(`SELECT COALESCE(MAX(revision), 0) + 1 FROM inspection_item_revisions WHERE inspection_id = ? AND section = ? AND item_name = ?`,
+		item.InspectionID, section.Key, item.ItemName).Scan(&next)
+	if err != nil {
+		return fmt.Errorf("failed to determine next revision: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO inspection_item_revisions (inspection_id, section, item_name, revision, action, author_id, changes, snapshot)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		item.InspectionID, section.Key, item.ItemName, next, action, authorID, changesJSON, snapshotJSON)
+	if err != nil {
+		return fmt.Errorf("failed to insert item revision: %v", err)
+	}
+	return nil
+}
+
+func scanItemRevision(scanner interface{ Scan(...interface{}) error }) (ItemRevision, error) {
+	var rev ItemRevision
+	var authorID sql.NullInt64
+	var authorName sql.NullString
+	var changesJSON, snapshotJSON string
+	err := scanner.Scan(&rev.RevisionID, &rev.InspectionID, &rev.Section, &rev.ItemName, &rev.Revision, &rev.Action,
+		&authorID, &authorName, &changesJSON, &snapshotJSON, &rev.CreatedAt)
+	if err != nil {
+		return rev, err
+	}
+	if authorID.Valid {
+		id := int(authorID.Int64)
+		rev.AuthorID = &id
+	}
+	rev.AuthorName = authorName.String
+	if err := json.Unmarshal([]byte(changesJSON), &rev.Changes); err != nil {
+		return rev, fmt.Errorf("invalid revision diff: %v", err)
+	}
+	if err := json.Unmarshal([]byte(snapshotJSON), &rev.Snapshot); err != nil {
+		return rev, fmt.Errorf("invalid revision snapshot: %v", err)
+	}
+	return rev, nil
+}
+
+const revisionSelect = `
+	SELECT r.revision_id, r.inspection_id, r.section, r.item_name, r.revision, r.action,
+	       r.author_id, CONCAT(u.first_name, ' ', u.last_name), r.changes, r.snapshot, r.created_at
+	FROM inspection_item_revisions r
+	LEFT JOIN users u ON u.user_id = r.author_id`
+
+// GetItemHistory lists every revision of a worksheet item, newest first
+func GetItemHistory(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		inspectionID := vars["inspection_id"]
+		itemName := vars["item_name"]
+		section, ok := SectionByKey(vars["section"])
+		if inspectionID == "" || itemName == "" || !ok {
+			http.Error(w, "Valid inspection_id, section and item_name are required", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(revisionSelect+`
+			WHERE r.inspection_id = ? AND r.section = ? AND r.item_name = ?
+			ORDER BY r.revision DESC`, inspectionID, section.Key, itemName)
+		if err != nil {
+			log.Printf("Error querying item history: %v", err)
+			http.Error(w, "Failed to fetch item history", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		history := []ItemRevision{}
+		for rows.Next() {
+			rev, err := scanItemRevision(rows)
+			if err != nil {
+				log.Printf("Error scanning item revision: %v", err)
+				http.Error(w, "Failed to read item history", http.StatusInternalServerError)
+				return
+			}
+			history = append(history, rev)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+// RestoreItemRevision puts a worksheet item back to the state captured in a prior revision.
+// The restore itself is recorded as a new revision, so it can be undone the same way.
+func RestoreItemRevision(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "restore revisions") {
+			return
+		}
+		revisionID, err := strconv.Atoi(mux.Vars(r)["revision_id"])
+		if err != nil {
+			http.Error(w, "Invalid revision_id", http.StatusBadRequest)
+			return
+		}
+
+		rev, err := scanItemRevision(db.QueryRow(revisionSelect+` WHERE r.revision_id = ?`, revisionID))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Revision not found", http.StatusNotFound)
+			} else {
+				log.Printf("Error loading revision %d: %v", revisionID, err)
+				http.Error(w, "Failed to load revision", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		section, ok := SectionByKey(rev.Section)
+		if !ok {
+			http.Error(w, "Revision belongs to an unknown section", http.StatusInternalServerError)
+			return
+		}
+
+		restored := rev.Snapshot
+		restored.InspectionID = rev.InspectionID
+		restored.ItemName = rev.ItemName
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Error starting transaction: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		restored, _, err = saveWorksheetItem(tx, section, restored, middleware.CallerID(r), "restore")
+		if err == nil {
+			err = tx.Commit()
+		}
+		if errors.Is(err, ErrInspectionLocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Error restoring revision %d: %v", revisionID, err)
+			http.Error(w, "Failed to restore revision", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":           "Item restored",
+			"restored_revision": rev.Revision,
+			"item":              restored,
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package inspections
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportedInspection is a complete inspection to recreate under new ids, e.g. from an exported archive
+type ImportedInspection struct {
+	PropertyID        string
+	InspectionDate    string // YYYY-MM-DD; empty means today
+	Temperature       *int
+	Weather           *string
+	GroundCondition   *string
+	RainLastThreeDays *bool
+	RadonTest         *bool
+	MoldTest          *bool
+	SOPStandard       string
+	Items             map[string][]WorksheetItem // by section key
+	Photos            []ImportedPhoto
+	Defects           []Defect // DefectID and PhotoIDs refer to the source inspection
+}
+
+// ImportedPhoto is a photo file already stored under /uploads; SourceID is its id in the source inspection
+type ImportedPhoto struct {
+	SourceID int
+	ItemName string
+	URL      string
+}
+
+// ImportResult maps the source ids of an imported inspection to the ones created for it
+type ImportResult struct {
+	InspectionID string      `json:"inspection_id"`
+	ReportID     string      `json:"report_id"`
+	Items        int         `json:"items"`
+	PhotoIDs     map[int]int `json:"photo_ids"`
+	DefectIDs    map[int]int `json:"defect_ids"`
+}
+
+// ImportInspection creates a new in-progress inspection of the property from imported contents.
+// Items go through the normal save path, so their revisions, change feed entries and derived
+// defects are recorded as for any other edit; the details of derived defects are then copied
+// onto the records that saving created. Run it inside a transaction so a bad record leaves nothing behind.
+func ImportInspection(db queryExecer, in ImportedInspection, authorID *int) (*ImportResult, error) {
+	if in.InspectionDate == "" {
+		in.InspectionDate = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", in.InspectionDate); err != nil {
+		return nil, fmt.Errorf("invalid inspection date %q", in.InspectionDate)
+	}
+
+	result := &ImportResult{InspectionID: uuid.New().String(), PhotoIDs: map[int]int{}, DefectIDs: map[int]int{}}
+	if err := createInspection(db, result.InspectionID, in.PropertyID, in.InspectionDate, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to create inspection: %v", err)
+	}
+	var standard interface{}
+	if in.SOPStandard != "" {
+		standard = in.SOPStandard
+	}
+	_, err := db.Exec(`UPDATE inspections SET temperature = ?, weather = ?, ground_condition = ?, rain_last_three_days = ?,
+		radon_test = ?, mold_test = ?, sop_standard = ? WHERE inspection_id = ?`,
+		in.Temperature, in.Weather, in.GroundCondition, in.RainLastThreeDays, in.RadonTest, in.MoldTest, standard, result.InspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set inspection details: %v", err)
+	}
+	if err := db.QueryRow(`SELECT report_id FROM inspections WHERE inspection_id = ?`, result.InspectionID).Scan(&result.ReportID); err != nil {
+		return nil, err
+	}
+
+	for key := range in.Items {
+		if _, ok := SectionByKey(key); !ok {
+			return nil, fmt.Errorf("unknown section %q", key)
+		}
+	}
+	for _, section := range Sections {
+		key := section.Key
+		for _, item := range in.Items[key] {
+			if strings.TrimSpace(item.ItemName) == "" {
+				return nil, fmt.Errorf("%s item without a name", key)
+			}
+			status, ok := NormalizeInspectionStatus(item.InspectionStatus)
+			if !ok {
+				return nil, fmt.Errorf("%s item %q has invalid inspection_status %q", key, item.ItemName, item.InspectionStatus)
+			}
+			item.InspectionStatus = status
+			item.InspectionID = result.InspectionID
+			item.Version = 0
+			if item.Materials == nil {
+				item.Materials = map[string]string{}
+			}
+			if item.Conditions == nil {
+				item.Conditions = map[string]bool{}
+			}
+			if _, _, err := saveWorksheetItem(db, section, item, authorID, "create"); err != nil {
+				return nil, fmt.Errorf("failed to import %s item %q: %v", key, item.ItemName, err)
+			}
+			result.Items++
+		}
+	}
+
+	for _, p := range in.Photos {
+		id, err := insertInspectionPhoto(db, result.InspectionID, p.ItemName, p.URL, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to import photo: %v", err)
+		}
+		result.PhotoIDs[p.SourceID] = id
+	}
+
+	for _, d := range in.Defects {
+		defectID, err := importDefect(db, result.InspectionID, d)
+		if err != nil {
+			return nil, err
+		}
+		if defectID == 0 {
+			continue
+		}
+		result.DefectIDs[d.DefectID] = defectID
+
+		photoIDs := []int{}
+		for _, id := range d.PhotoIDs {
+			if mapped, ok := result.PhotoIDs[id]; ok {
+				photoIDs = append(photoIDs, mapped)
+			}
+		}
+		status, severity := d.Status, d.Severity
+		input := DefectInput{
+			Status:           &status,
+			Severity:         &severity,
+			Location:         &d.Location,
+			Recommendation:   &d.Recommendation,
+			ResponsibleTrade: &d.ResponsibleTrade,
+			CostMin:          d.CostMin,
+			CostMax:          d.CostMax,
+			PhotoIDs:         &photoIDs,
+		}
+		problems, err := validateDefectInput(db, result.InspectionID, input)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) > 0 {
+			return nil, fmt.Errorf("invalid defect on %s item %q: %s", d.Section, d.ItemName, strings.Join(problems, "; "))
+		}
+		if err := applyDefectInput(db, defectID, input); err != nil {
+			return nil, fmt.Errorf("failed to import defect: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// importDefect finds the derived record that saving the items created for a defect, or inserts a
+// manual one. It returns 0 for a derived defect whose condition no longer yields one.
+func importDefect(db queryExecer, inspectionID string, d Defect) (int, error) {
+	if _, ok := SectionByKey(d.Section); !ok {
+		return 0, fmt.Errorf("defect in unknown section %q", d.Section)
+	}
+	if d.Source == "derived" && d.ConditionKey != nil {
+		var id int
+		err := db.QueryRow(`SELECT defect_id FROM defects WHERE inspection_id = ? AND section = ? AND item_name = ? AND condition_key = ? AND source = 'derived'`,
+			inspectionID, d.Section, d.ItemName, *d.ConditionKey).Scan(&id)
+		if err == sql.ErrNoRows {
+			// The condition was unchecked in the source or is no longer a defect condition
+			return 0, nil
+		}
+		return id, err
+	}
+
+	severity, ok := ParseSeverity(d.Severity)
+	if !ok || severity == SeverityNone {
+		return 0, fmt.Errorf("invalid severity %q on %s item %q", d.Severity, d.Section, d.ItemName)
+	}
+	res, err := db.Exec(`INSERT INTO defects (inspection_id, section, item_name, source, status, severity) VALUES (?, ?, ?, 'manual', ?, ?)`,
+		inspectionID, d.Section, strings.TrimSpace(d.ItemName), DefectOpen, severity.String())
+	if err != nil {
+		return 0, fmt.Errorf("failed to import defect: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id), nil
+}
@@ -3,14 +3,18 @@ package inspections
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
+	"home_solutions/backend/middleware"
+
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
@@ -18,10 +22,14 @@ import (
 type CreateInspectionRequest struct {
 	PropertyID     string `json:"property_id"`
 	InspectionDate string `json:"inspection_date,omitempty"`
+	// CloneFrom seeds the worksheet from a prior inspection of the property ("latest" for the most recent)
+	CloneFrom  string `json:"clone_from,omitempty"`
+	LinkPhotos bool   `json:"link_photos,omitempty"`
 }
 
 type CreateInspectionResponse struct {
-	InspectionID string `json:"inspection_id"`
+	InspectionID string        `json:"inspection_id"`
+	ClonedFrom   *CloneSummary `json:"cloned_from,omitempty"`
 }
 
 type NullableInt struct {
@@ -51,8 +59,9 @@ func getDBConnection() (*sql.DB, error) {
 }
 
 // COVERPAGE WORKSHEET -------------------------------------------------------------------------------------------
-// CreateInspectionHelper creates a new inspection form and returns the form ID
-func CreateInspectionHelper(db *sql.DB, propertyID string, inspectionDate string) (string, error) {
+// CreateInspectionHelper creates a new inspection form and returns the form ID. inspectorID assigns
+// the inspection to the inspector creating it, so it shows in their list; nil leaves it unassigned.
+func CreateInspectionHelper(db *sql.DB, propertyID string, inspectionDate string, inspectorID *int) (string, error) {
 	inspectionID := uuid.New().String()
 
 	if inspectionDate == "" {
@@ -64,24 +73,48 @@ func CreateInspectionHelper(db *sql.DB, propertyID string, inspectionDate string
 		}
 	}
 
-	// Get the next report number for this property
-	var count int
-	err := db.QueryRow(`SELECT COUNT(*) FROM inspections WHERE property_id = ?`, propertyID).Scan(&count)
-	if err != nil {
-		return "", fmt.Errorf("failed to count previous inspections: %v", err)
+	if err := createInspection(db, inspectionID, propertyID, inspectionDate, nil, inspectorID); err != nil {
+		log.Printf("Error inserting inspection: %v", err)
+		return "", err
+	}
+
+	return inspectionID, nil
+}
+
+// CreateInspectionFromPrior creates a new inspection seeded from a prior inspection of the same
+// property, in one transaction so a failed copy leaves nothing behind. It is assigned to inspectorID
+// like CreateInspectionHelper.
+func CreateInspectionFromPrior(db *sql.DB, propertyID, inspectionDate string, opts CloneOptions, authorID, inspectorID *int) (string, CloneSummary, error) {
+	if inspectionDate == "" {
+		inspectionDate = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", inspectionDate); err != nil {
+		return "", CloneSummary{}, fmt.Errorf("invalid date format")
 	}
-	reportID := fmt.Sprintf("%s-%d", propertyID, count+1)
 
-	query := `INSERT INTO inspections (inspection_id, property_id, inspection_date, status, report_id)
-              VALUES (?, ?, ?, ?, ?)`
+	tx, err := db.Begin()
+	if err != nil {
+		return "", CloneSummary{}, err
+	}
+	defer tx.Rollback()
 
-	_, err = db.Exec(query, inspectionID, propertyID, inspectionDate, "in-progress", reportID)
+	sourceID, err := resolveCloneSource(tx, propertyID, opts.SourceInspectionID)
 	if err != nil {
-		log.Printf("Error inserting inspection: %v", err)
-		return "", err
+		return "", CloneSummary{}, err
 	}
+	opts.SourceInspectionID = sourceID
 
-	return inspectionID, nil
+	inspectionID := uuid.New().String()
+	if err := createInspection(tx, inspectionID, propertyID, inspectionDate, &sourceID, inspectorID); err != nil {
+		return "", CloneSummary{}, err
+	}
+	summary, err := seedInspection(tx, inspectionID, opts, authorID)
+	if err != nil {
+		return "", CloneSummary{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", CloneSummary{}, err
+	}
+	return inspectionID, summary, nil
 }
 
 // CreateInspection handles HTTP requests to create a new inspection form
@@ -111,6 +144,17 @@ func CreateInspection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Cloning copies another inspection's findings, so only staff may seed from one
+	if req.CloneFrom != "" {
+		if middleware.CallerID(r) == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !middleware.RequireStaff(w, r, "clone inspections") {
+			return
+		}
+	}
+
 	// Get environment variables for database connection
 	db, err := getDBConnection()
 	if err != nil {
@@ -120,8 +164,32 @@ func CreateInspection(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
+	inspectorID, err := CallerInspectorID(db, r)
+	if err != nil {
+		log.Printf("Error looking up inspector: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if req.CloneFrom != "" {
+		opts := CloneOptions{SourceInspectionID: req.CloneFrom, LinkPhotos: req.LinkPhotos}
+		inspectionID, summary, err := CreateInspectionFromPrior(db, req.PropertyID, req.InspectionDate, opts, middleware.CallerID(r), inspectorID)
+		if err != nil {
+			if errors.Is(err, ErrCloneSourceNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Printf("Error cloning inspection %s: %v", req.CloneFrom, err)
+			http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CreateInspectionResponse{InspectionID: inspectionID, ClonedFrom: &summary})
+		return
+	}
+
 	// Use CreateInspectionHelper to insert into the database
-	inspectionID, err := CreateInspectionHelper(db, req.PropertyID, req.InspectionDate)
+	inspectionID, err := CreateInspectionHelper(db, req.PropertyID, req.InspectionDate, inspectorID)
 	if err != nil {
 		http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
 		return
@@ -152,11 +220,13 @@ func GetInspectionForm(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	query := `
-        SELECT inspection_id, property_id, report_id, inspection_date, status, temperature, weather, ground_condition, rain_last_three_days, radon_test, mold_test
+        SELECT inspection_id, property_id, report_id, inspection_date, status, temperature, weather, ground_condition, rain_last_three_days, radon_test, mold_test,
+               scheduled_start, scheduled_end
         FROM inspections 
-        WHERE inspection_id = ? AND property_id = ?
+        WHERE inspection_id = ? AND property_id = ? AND deleted_at IS NULL
     `
 	var inspectionDateStr string
+	var scheduledStart, scheduledEnd sql.NullString
 	var inspectionData struct {
 		InspectionID    string  `json:"inspection_id"`
 		PropertyID      string  `json:"property_id"`
@@ -169,6 +239,9 @@ func GetInspectionForm(w http.ResponseWriter, r *http.Request) {
 		RainLast3Days   *bool   `json:"rain_last_three_days"`
 		RadonTest       *bool   `json:"radon_test"`
 		MoldTest        *bool   `json:"mold_test"`
+		ScheduledStart  *string `json:"scheduled_start"`
+		ScheduledEnd    *string `json:"scheduled_end"`
+		InspectionTime  string  `json:"inspection_time"`
 	}
 
 	err = db.QueryRow(query, inspectionId, propertyId).Scan(
@@ -183,6 +256,8 @@ func GetInspectionForm(w http.ResponseWriter, r *http.Request) {
 		&inspectionData.RainLast3Days,
 		&inspectionData.RadonTest,
 		&inspectionData.MoldTest,
+		&scheduledStart,
+		&scheduledEnd,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -208,6 +283,21 @@ func GetInspectionForm(w http.ResponseWriter, r *http.Request) {
 		inspectionData.InspectionDate = ""
 	}
 
+	// Appointment times are stored in UTC; expose them as RFC 3339 plus a display time for the cover page
+	if scheduledStart.Valid {
+		if t, err := time.Parse("2006-01-02 15:04:05", scheduledStart.String); err == nil {
+			start := t.Format(time.RFC3339)
+			inspectionData.ScheduledStart = &start
+			inspectionData.InspectionTime = t.Format("3:04 PM")
+		}
+	}
+	if scheduledEnd.Valid {
+		if t, err := time.Parse("2006-01-02 15:04:05", scheduledEnd.String); err == nil {
+			end := t.Format(time.RFC3339)
+			inspectionData.ScheduledEnd = &end
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(inspectionData); err != nil {
 		log.Printf("Error encoding response: %v", err)
@@ -241,1480 +331,167 @@ func UpdateInspection(w http.ResponseWriter, r *http.Request) {
 		Weather         *string     `json:"weather"`
 		GroundCondition *string     `json:"ground_condition"`
 		RainLast3Days   *bool       `json:"rain_last_three_days"`
-		RadonTest       *bool       `json:"radon_test"`
-		MoldTest        *bool       `json:"mold_test"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&inspection); err != nil {
-		log.Printf("❌ Failed to decode inspection update: %v", err)
-		http.Error(w, "Invalid payload", http.StatusBadRequest)
-		return
-	}
-
-	query := `
-        UPDATE inspections
-        SET inspection_date = ?, temperature = ?, weather = ?, ground_condition = ?, 
-            rain_last_three_days = ?, radon_test = ?, mold_test = ?
-        WHERE inspection_id = ?
-    `
-
-	_, err = db.Exec(query, inspection.InspectionDate, inspection.Temperature.Value, inspection.Weather,
-		inspection.GroundCondition, inspection.RainLast3Days, inspection.RadonTest, inspection.MoldTest, inspection.InspectionID)
-
-	if err != nil {
-		log.Printf("Error updating inspection: %v", err)
-		http.Error(w, "Failed to update inspection", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"message": "Inspection updated successfully"}`))
-}
-
-// EXTERIOR WORKSHEET -------------------------------------------------------------------------------------------
-type ExteriorData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
-}
-
-func SaveExteriorData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []ExteriorData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		// log.Printf("Payload received: %+v", data)
-
-		query := `
-			INSERT INTO inspection_exterior (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-			materials = VALUES(materials),
-			conditions = VALUES(conditions),
-			comments = VALUES(comments),
-			inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Exterior data saved successfully"))
-	}
-}
-
-func GetExteriorData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_exterior WHERE inspection_id = ?`
-
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error fetching exterior data: %v", err)
-			http.Error(w, "Failed to fetch data", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []ExteriorData
-		for rows.Next() {
-			var record ExteriorData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Error scanning row: %v", err)
-				http.Error(w, "Failed to parse data", http.StatusInternalServerError)
-				return
-			}
-
-			if materialsJSON == "" {
-				materialsJSON = "{}"
-			}
-			if err := json.Unmarshal([]byte(materialsJSON), &record.Materials); err != nil {
-				log.Printf("Error unmarshalling materials: %v", err)
-				record.Materials = make(map[string]string) // fallback
-			}
-
-			if conditionsJSON == "" {
-				conditionsJSON = "{}"
-			}
-			if err := json.Unmarshal([]byte(conditionsJSON), &record.Conditions); err != nil {
-				log.Printf("Error unmarshalling conditions: %v", err)
-				record.Conditions = make(map[string]bool) // fallback
-			}
-
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// ROOF WORKSHEET -------------------------------------------------------------------------------------------
-type RoofData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
-}
-
-func SaveRoofData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []RoofData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_roof (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Roof data saved successfully"))
-	}
-}
-
-func GetRoofData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_roof WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying roof data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []RoofData
-		for rows.Next() {
-			var record RoofData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// BASEMENT FOUNDATION WORKSHEET -------------------------------------------------------------------------------------------
-type BasementData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
-}
-
-func SaveBasementData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []BasementData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_basementFoundation (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-			`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Basement/Foundation data saved successfully"))
-	}
-}
-
-func GetBasementData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_basementFoundation WHERE inspection_id = ?`
-
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying basement data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []BasementData
-		for rows.Next() {
-			var record BasementData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// HEATING WORKSHEET -------------------------------------------------------------------------------------------
-type HeatingData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"` // ✅ add this
-}
-
-func SaveHeatingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []HeatingData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_heating (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Heating data saved successfully"))
-	}
-}
-
-func GetHeatingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_heating WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying heating data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []HeatingData
-		for rows.Next() {
-			var record HeatingData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		if len(data) == 0 {
-			data = []HeatingData{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// COOLING WORKSHEET -------------------------------------------------------------------------------------------
-type CoolingData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"` // ✅ Add this line
-}
-
-func SaveCoolingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []CoolingData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_cooling (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-			`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Cooling data saved successfully"))
-	}
-}
-
-func GetCoolingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_cooling WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying cooling data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []CoolingData
-		for rows.Next() {
-			var record CoolingData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		if len(data) == 0 {
-			data = []CoolingData{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// PLUMBING WORKSHEET -------------------------------------------------------------------------------------------
-type PlumbingData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"` // ✅ Add this line
-}
-
-func SavePlumbingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []PlumbingData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_plumbing (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-			`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Plumbing data saved successfully"))
-	}
-}
-
-func GetPlumbingData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_plumbing WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying plumbing data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []PlumbingData
-		for rows.Next() {
-			var record PlumbingData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		if len(data) == 0 {
-			data = []PlumbingData{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// ELECTRICAL WORKSHEET -------------------------------------------------------------------------------------------
-type ElectricalData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
-}
-
-func SaveElectricalData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []ElectricalData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-		INSERT INTO inspection_electrical (inspection_id, item_name, materials, conditions, comments, inspection_status)
-		VALUES (?, ?, ?, ?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-			materials = VALUES(materials),
-			conditions = VALUES(conditions),
-			comments = VALUES(comments),
-			inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Electrical data saved successfully"))
-	}
-}
-
-func GetElectricalData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_electrical WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying electrical data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []ElectricalData
-		for rows.Next() {
-			var record ElectricalData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		if len(data) == 0 {
-			data = []ElectricalData{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// ATTIC WORKSHEET -------------------------------------------------------------------------------------------
-type AtticData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
-}
-
-func SaveAtticData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []AtticData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_attic (inspection_id, item_name, materials, conditions, comments, inspection_status)
-				VALUES (?, ?, ?, ?, ?, ?)
-				ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-			`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Attic data saved successfully"))
-	}
-}
-
-func GetAtticData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_attic WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying attic data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []AtticData
-		for rows.Next() {
-			var record AtticData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
-
-			data = append(data, record)
-		}
-
-		if len(data) == 0 {
-			data = []AtticData{}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
-}
-
-// DOORS WINDOWS WORKSHEET -------------------------------------------------------------------------------------------
-type DoorsWindowsData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"` // ✅ Add this line
-}
-
-func SaveDoorsWindowsData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		var data []DoorsWindowsData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		query := `
-			INSERT INTO inspection_doorsWindows (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
-
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
-
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
-
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Doors & Windows data saved successfully"))
-	}
-}
-
-func GetDoorsWindowsData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
-
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_doorsWindows WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying doors/windows data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []DoorsWindowsData
-		for rows.Next() {
-			var record DoorsWindowsData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
-
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
+		RadonTest       *bool       `json:"radon_test"`
+		MoldTest        *bool       `json:"mold_test"`
+	}
 
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
+	if err := json.NewDecoder(r.Body).Decode(&inspection); err != nil {
+		log.Printf("❌ Failed to decode inspection update: %v", err)
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
 
-			data = append(data, record)
-		}
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if rejectIfLocked(w, tx, inspection.InspectionID) {
+		return
+	}
 
-		if len(data) == 0 {
-			data = []DoorsWindowsData{}
-		}
+	query := `
+        UPDATE inspections
+        SET inspection_date = ?, temperature = ?, weather = ?, ground_condition = ?, 
+            rain_last_three_days = ?, radon_test = ?, mold_test = ?
+        WHERE inspection_id = ?
+    `
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
+	_, err = tx.Exec(query, inspection.InspectionDate, inspection.Temperature.Value, inspection.Weather,
+		inspection.GroundCondition, inspection.RainLast3Days, inspection.RadonTest, inspection.MoldTest, inspection.InspectionID)
+	if err == nil {
+		err = tx.Commit()
 	}
+	if err != nil {
+		log.Printf("Error updating inspection: %v", err)
+		http.Error(w, "Failed to update inspection", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message": "Inspection updated successfully"}`))
 }
 
-// FIREPLACE WORKSHEET -------------------------------------------------------------------------------------------
-type FireplaceData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"`
+// EXTERIOR WORKSHEET -------------------------------------------------------------------------------------------
+type ExteriorData = WorksheetItem
+
+func SaveExteriorData() http.HandlerFunc {
+	return saveWorksheetHandler("exterior", "Exterior data saved successfully")
 }
 
-func SaveFireplaceData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
+func GetExteriorData() http.HandlerFunc {
+	return getWorksheetHandler("exterior")
+}
 
-		var data []FireplaceData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
+// ROOF WORKSHEET -------------------------------------------------------------------------------------------
+type RoofData = WorksheetItem
 
-		query := `
-			INSERT INTO inspection_fireplace (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
+func SaveRoofData() http.HandlerFunc {
+	return saveWorksheetHandler("roof", "Roof data saved successfully")
+}
 
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
+func GetRoofData() http.HandlerFunc {
+	return getWorksheetHandler("roof")
+}
 
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
+// BASEMENT FOUNDATION WORKSHEET -------------------------------------------------------------------------------------------
+type BasementData = WorksheetItem
 
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
+func SaveBasementData() http.HandlerFunc {
+	return saveWorksheetHandler("basementFoundation", "Basement/Foundation data saved successfully")
+}
 
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Fireplace data saved successfully"))
-	}
+func GetBasementData() http.HandlerFunc {
+	return getWorksheetHandler("basementFoundation")
 }
 
-func GetFireplaceData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
+// HEATING WORKSHEET -------------------------------------------------------------------------------------------
+type HeatingData = WorksheetItem
 
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
+func SaveHeatingData() http.HandlerFunc {
+	return saveWorksheetHandler("heating", "Heating data saved successfully")
+}
 
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_fireplace WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying fireplace data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []FireplaceData
-		for rows.Next() {
-			var record FireplaceData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
+func GetHeatingData() http.HandlerFunc {
+	return getWorksheetHandler("heating")
+}
 
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
+// COOLING WORKSHEET -------------------------------------------------------------------------------------------
+type CoolingData = WorksheetItem
 
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
+func SaveCoolingData() http.HandlerFunc {
+	return saveWorksheetHandler("cooling", "Cooling data saved successfully")
+}
 
-			data = append(data, record)
-		}
+func GetCoolingData() http.HandlerFunc {
+	return getWorksheetHandler("cooling")
+}
 
-		if len(data) == 0 {
-			data = []FireplaceData{}
-		}
+// PLUMBING WORKSHEET -------------------------------------------------------------------------------------------
+type PlumbingData = WorksheetItem
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+func SavePlumbingData() http.HandlerFunc {
+	return saveWorksheetHandler("plumbing", "Plumbing data saved successfully")
 }
 
-// SYSTEMS COMPONENTS WORKSHEET -------------------------------------------------------------------------------------------
-type SystemsComponentsData struct {
-	InspectionID     string            `json:"inspection_id"`
-	ItemName         string            `json:"item_name"`
-	Materials        map[string]string `json:"materials"`
-	Conditions       map[string]bool   `json:"conditions"`
-	Comments         string            `json:"comments"`
-	InspectionStatus string            `json:"inspection_status"` // ✅ Add this
+func GetPlumbingData() http.HandlerFunc {
+	return getWorksheetHandler("plumbing")
 }
 
-func SaveSystemsComponentsData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
+// ELECTRICAL WORKSHEET -------------------------------------------------------------------------------------------
+type ElectricalData = WorksheetItem
 
-		var data []SystemsComponentsData
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-			log.Printf("Error decoding request body: %v", err)
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
+func SaveElectricalData() http.HandlerFunc {
+	return saveWorksheetHandler("electrical", "Electrical data saved successfully")
+}
 
-		query := `
-			INSERT INTO inspection_systemsComponents (inspection_id, item_name, materials, conditions, comments, inspection_status)
-			VALUES (?, ?, ?, ?, ?, ?)
-			ON DUPLICATE KEY UPDATE
-				materials = VALUES(materials),
-				conditions = VALUES(conditions),
-				comments = VALUES(comments),
-				inspection_status = VALUES(inspection_status)
-		`
-
-		for _, record := range data {
-			if record.ItemName == "" || record.InspectionID == "" {
-				continue
-			}
+func GetElectricalData() http.HandlerFunc {
+	return getWorksheetHandler("electrical")
+}
 
-			materialsJSON, err := json.Marshal(record.Materials)
-			if err != nil {
-				log.Printf("Error marshalling materials: %v", err)
-				materialsJSON = []byte("{}")
-			}
+// ATTIC WORKSHEET -------------------------------------------------------------------------------------------
+type AtticData = WorksheetItem
 
-			conditionsJSON, err := json.Marshal(record.Conditions)
-			if err != nil {
-				log.Printf("Error marshalling conditions: %v", err)
-				conditionsJSON = []byte("{}")
-			}
+func SaveAtticData() http.HandlerFunc {
+	return saveWorksheetHandler("attic", "Attic data saved successfully")
+}
 
-			_, err = db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
-			if err != nil {
-				log.Printf("Error executing insert: %v", err)
-				http.Error(w, "Database error", http.StatusInternalServerError)
-				return
-			}
-		}
+func GetAtticData() http.HandlerFunc {
+	return getWorksheetHandler("attic")
+}
 
-		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte("Systems & Components data saved successfully"))
-	}
+// DOORS WINDOWS WORKSHEET -------------------------------------------------------------------------------------------
+type DoorsWindowsData = WorksheetItem
+
+func SaveDoorsWindowsData() http.HandlerFunc {
+	return saveWorksheetHandler("doorsWindows", "Doors & Windows data saved successfully")
 }
 
-func GetSystemsComponentsData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		inspectionId := vars["inspection_id"]
-		if inspectionId == "" {
-			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
-			return
-		}
+func GetDoorsWindowsData() http.HandlerFunc {
+	return getWorksheetHandler("doorsWindows")
+}
 
-		db, err := getDBConnection()
-		if err != nil {
-			log.Printf("DB connection error: %v", err)
-			http.Error(w, "DB connection error", http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
+// FIREPLACE WORKSHEET -------------------------------------------------------------------------------------------
+type FireplaceData = WorksheetItem
 
-		query := `SELECT item_name, materials, conditions, comments, inspection_status FROM inspection_systemsComponents WHERE inspection_id = ?`
-		rows, err := db.Query(query, inspectionId)
-		if err != nil {
-			log.Printf("Error querying systems & components data: %v", err)
-			http.Error(w, "Query error", http.StatusInternalServerError)
-			return
-		}
-		defer rows.Close()
-
-		var data []SystemsComponentsData
-		for rows.Next() {
-			var record SystemsComponentsData
-			var materialsJSON, conditionsJSON string
-			var comments sql.NullString
-			var status sql.NullString
-
-			if err := rows.Scan(&record.ItemName, &materialsJSON, &conditionsJSON, &comments, &status); err != nil {
-				log.Printf("Row scan error: %v", err)
-				http.Error(w, "Row scan failed", http.StatusInternalServerError)
-				return
-			}
+func SaveFireplaceData() http.HandlerFunc {
+	return saveWorksheetHandler("fireplace", "Fireplace data saved successfully")
+}
 
-			json.Unmarshal([]byte(materialsJSON), &record.Materials)
-			json.Unmarshal([]byte(conditionsJSON), &record.Conditions)
-			record.InspectionID = inspectionId
-			record.Comments = ""
-			if comments.Valid {
-				record.Comments = comments.String
-			}
-			record.InspectionStatus = ""
-			if status.Valid {
-				record.InspectionStatus = status.String
-			}
+func GetFireplaceData() http.HandlerFunc {
+	return getWorksheetHandler("fireplace")
+}
 
-			data = append(data, record)
-		}
+// SYSTEMS COMPONENTS WORKSHEET -------------------------------------------------------------------------------------------
+type SystemsComponentsData = WorksheetItem
 
-		if len(data) == 0 {
-			data = []SystemsComponentsData{}
-		}
+func SaveSystemsComponentsData() http.HandlerFunc {
+	return saveWorksheetHandler("systemsComponents", "Systems & Components data saved successfully")
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
-	}
+func GetSystemsComponentsData() http.HandlerFunc {
+	return getWorksheetHandler("systemsComponents")
 }
 
 // STORING PHOTOS -------------------------------------------------------------------------------------------
@@ -1748,49 +525,57 @@ func UploadInspectionPhoto(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 	log.Printf("Received file: %s, size: %d", handler.Filename, handler.Size)
 
-	// Ensure the uploads directory exists
-	uploadDir := "./uploads/inspection_photos/"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		log.Printf("Error creating upload directory: %v", err)
-		http.Error(w, "Failed to create upload directory", http.StatusInternalServerError)
+	// Get a DB connection
+	db, err := getDBConnection()
+	if err != nil {
+		log.Printf("DB connection error: %v", err)
+		http.Error(w, "DB connection error", http.StatusInternalServerError)
 		return
 	}
+	defer db.Close()
 
-	// Simplify filename: generate a UUID and preserve the file extension.
-	// Import "path" at the top of the file if not already imported.
-	extension := path.Ext(handler.Filename)
-	filename := uuid.New().String() + extension
-	filePath := uploadDir + filename
+	// Offline clients retry uploads with the same client_id; hand back the photo stored the first time
+	clientID := r.FormValue("client_id")
+	if clientID != "" {
+		var existingURL string
+		err := db.QueryRow("SELECT photo_url FROM inspection_photos WHERE client_id = ? AND inspection_id = ?", clientID, inspectionId).Scan(&existingURL)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{
+				"message":   "Photo already uploaded",
+				"photo_url": existingURL,
+			})
+			return
+		} else if err != sql.ErrNoRows {
+			log.Printf("Error checking photo client_id: %v", err)
+			http.Error(w, "Failed to save photo record", http.StatusInternalServerError)
+			return
+		}
+	}
 
-	// Save the file to disk
-	dst, err := os.Create(filePath)
+	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Error creating file on disk: %v", err)
-		http.Error(w, "Error saving the file", http.StatusInternalServerError)
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		log.Printf("Error copying file to disk: %v", err)
-		http.Error(w, "Error saving the file", http.StatusInternalServerError)
+	defer tx.Rollback()
+	if rejectIfLocked(w, tx, inspectionId) {
 		return
 	}
 
-	// Construct the relative URL to store in the database
-	photoUrl := "/uploads/inspection_photos/" + filename
-
-	// Get a DB connection and insert the photo record
-	db, err := getDBConnection()
+	photoUrl, err := StoreInspectionPhotoFile(file, handler.Filename)
 	if err != nil {
-		log.Printf("DB connection error: %v", err)
-		http.Error(w, "DB connection error", http.StatusInternalServerError)
+		log.Printf("Error saving photo file: %v", err)
+		http.Error(w, "Error saving the file", http.StatusInternalServerError)
 		return
 	}
-	defer db.Close()
 
-	query := "INSERT INTO inspection_photos (inspection_id, item_name, photo_url) VALUES (?, ?, ?)"
-	if _, err = db.Exec(query, inspectionId, itemName, photoUrl); err != nil {
+	_, err = insertInspectionPhoto(tx, inspectionId, itemName, photoUrl, clientID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		os.Remove("." + photoUrl)
 		log.Printf("Error inserting photo record: %v", err)
 		http.Error(w, "Failed to save photo record", http.StatusInternalServerError)
 		return
@@ -1803,6 +588,53 @@ func UploadInspectionPhoto(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// StoreInspectionPhotoFile writes an uploaded photo under a UUID filename and returns its public URL
+func StoreInspectionPhotoFile(src io.Reader, originalName string) (string, error) {
+	// Ensure the uploads directory exists
+	uploadDir := "./uploads/inspection_photos/"
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %v", err)
+	}
+
+	// Simplify filename: generate a UUID and preserve the file extension.
+	filename := uuid.New().String() + path.Ext(originalName)
+
+	dst, err := os.Create(uploadDir + filename)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return "", err
+	}
+
+	// Relative URL stored in the database
+	return "/uploads/inspection_photos/" + filename, nil
+}
+
+// insertInspectionPhoto stores the photo record and publishes it to the sync change feed
+func insertInspectionPhoto(db queryExecer, inspectionID, itemName, photoURL, clientID string) (int, error) {
+	if err := EnsureEditable(db, inspectionID); err != nil {
+		return 0, err
+	}
+	var client interface{}
+	if clientID != "" {
+		client = clientID
+	}
+	res, err := db.Exec("INSERT INTO inspection_photos (inspection_id, item_name, photo_url, client_id) VALUES (?, ?, ?, ?)",
+		inspectionID, itemName, photoURL, client)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	photoID := int(id)
+	if err := recordChange(db, inspectionID, change{Entity: "photo", PhotoID: photoID, Action: "upsert"}); err != nil {
+		return 0, err
+	}
+	return photoID, nil
+}
+
 // GetInspectionPhotos fetches photos for a given inspection and item.
 func GetInspectionPhotos(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -1823,7 +655,7 @@ func GetInspectionPhotos(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT photo_id, photo_url, uploaded_at FROM inspection_photos WHERE inspection_id = ? AND item_name = ?"
+	query := "SELECT photo_id, photo_url, uploaded_at FROM inspection_photos WHERE inspection_id = ? AND item_name = ? AND deleted_at IS NULL"
 	rows, err := db.Query(query, inspectionId, itemName)
 	if err != nil {
 		log.Printf("Error executing query: %v", err)
@@ -1891,31 +723,35 @@ func DeleteInspectionPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Fetch the photo URL before deleting it
-	var photoUrl string
-	err = db.QueryRow("SELECT photo_url FROM inspection_photos WHERE photo_id = ?", photoID).Scan(&photoUrl)
+	var inspectionID string
+	err = db.QueryRow("SELECT inspection_id FROM inspection_photos WHERE photo_id = ? AND deleted_at IS NULL", photoID).Scan(&inspectionID)
 	if err != nil {
 		log.Printf("Failed to fetch photo record: %v", err)
 		http.Error(w, "Photo not found", http.StatusNotFound)
 		return
 	}
 
-	// Delete photo record from DB
-	_, err = db.Exec("DELETE FROM inspection_photos WHERE photo_id = ?", photoID)
+	// Move the photo to the trash; the file stays until the retention job purges it
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	err = deleteInspectionPhotoRecord(tx, inspectionID, photoID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if errors.Is(err, ErrInspectionLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Printf("Error deleting photo from DB: %v", err)
 		http.Error(w, "Failed to delete photo record", http.StatusInternalServerError)
 		return
 	}
 
-	// Delete the file from disk
-	// Remove leading slash so it's a relative path from the current dir
-	filePath := "." + photoUrl
-	if err := os.Remove(filePath); err != nil {
-		log.Printf("Failed to delete file %s: %v", filePath, err)
-		// Don't return 500 here — the DB record is already gone, and this isn't critical
-	}
-
 	// Respond success
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -1923,6 +759,47 @@ func DeleteInspectionPhoto(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// deleteInspectionPhotoRecord soft-deletes the photo and publishes the deletion to the sync change feed
+func deleteInspectionPhotoRecord(db queryExecer, inspectionID, photoID string) error {
+	if err := EnsureEditable(db, inspectionID); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE inspection_photos SET deleted_at = UTC_TIMESTAMP() WHERE photo_id = ? AND deleted_at IS NULL", photoID); err != nil {
+		return err
+	}
+	id, _ := strconv.Atoi(photoID)
+	return recordChange(db, inspectionID, change{Entity: "photo", PhotoID: id, Action: "delete"})
+}
+
+// RestoreInspectionPhotoRecord brings a trashed photo back and republishes it to the sync change feed
+func RestoreInspectionPhotoRecord(db queryExecer, inspectionID string, photoID int) error {
+	if err := EnsureEditable(db, inspectionID); err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE inspection_photos SET deleted_at = NULL WHERE photo_id = ?", photoID); err != nil {
+		return err
+	}
+	return recordChange(db, inspectionID, change{Entity: "photo", PhotoID: photoID, Action: "upsert"})
+}
+
+// RemoveUnreferencedPhotoFile deletes a photo file once no inspection_photos row points at it any more.
+// Photos linked into cloned inspections share the original file, and trashed rows still hold theirs.
+func RemoveUnreferencedPhotoFile(db queryExecer, photoURL string) {
+	var refs int
+	if err := db.QueryRow("SELECT COUNT(*) FROM inspection_photos WHERE photo_url = ?", photoURL).Scan(&refs); err != nil {
+		log.Printf("Failed to check references to %s: %v", photoURL, err)
+		return
+	}
+	if refs > 0 {
+		return
+	}
+	// Remove leading slash so it's a relative path from the current dir
+	filePath := "." + photoURL
+	if err := os.Remove(filePath); err != nil {
+		log.Printf("Failed to delete file %s: %v", filePath, err)
+	}
+}
+
 // GetAllInspectionPhotos returns all photos for a given inspection
 func GetAllInspectionPhotos(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -1941,7 +818,7 @@ func GetAllInspectionPhotos(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := `SELECT photo_id, inspection_id, item_name, photo_url FROM inspection_photos WHERE inspection_id = ?`
+	query := `SELECT photo_id, inspection_id, item_name, photo_url FROM inspection_photos WHERE inspection_id = ? AND deleted_at IS NULL`
 	rows, err := db.Query(query, inspectionId)
 	if err != nil {
 		http.Error(w, "Query error", http.StatusInternalServerError)
@@ -2002,6 +879,24 @@ func UploadPropertyPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	db, err := getDBConnection()
+	if err != nil {
+		log.Printf("DB connection error: %v", err)
+		http.Error(w, "DB connection error", http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if rejectIfLocked(w, tx, inspectionId) {
+		return
+	}
+
 	filename := uuid.New().String() + path.Ext(header.Filename)
 	filePath := path.Join("uploads", "property_photos", filename)
 
@@ -2013,6 +908,7 @@ func UploadPropertyPhoto(w http.ResponseWriter, r *http.Request) {
 	defer out.Close()
 
 	if _, err := io.Copy(out, file); err != nil {
+		os.Remove(filePath)
 		http.Error(w, "Failed to write file", http.StatusInternalServerError)
 		return
 	}
@@ -2020,23 +916,18 @@ func UploadPropertyPhoto(w http.ResponseWriter, r *http.Request) {
 	photoID := uuid.New().String()
 	photoURL := "/uploads/property_photos/" + filename
 
-	db, err := getDBConnection()
-	if err != nil {
-		log.Printf("DB connection error: %v", err)
-		http.Error(w, "DB connection error", http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	query := `INSERT INTO property_photos (photo_id, inspection_id, photo_url, uploaded_at) VALUES (?, ?, ?, ?)`
-	_, err = db.Exec(query,
+	_, err = tx.Exec(query,
 		photoID,
 		inspectionId,
 		photoURL,
 		time.Now().Format("2006-01-02 15:04:05"),
 	)
-
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
+		os.Remove(filePath)
 		log.Printf("❌ DB insert error in UploadPropertyPhoto: %v", err)
 		http.Error(w, "DB insert failed", http.StatusInternalServerError)
 		return
@@ -2077,7 +968,7 @@ func GetPropertyPhoto(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	// ✅ Use the correct table: property_photos
-	query := `SELECT photo_id, photo_url FROM property_photos WHERE inspection_id = ?`
+	query := `SELECT photo_id, photo_url FROM property_photos WHERE inspection_id = ? AND deleted_at IS NULL`
 	rows, err := db.Query(query, inspectionID)
 	if err != nil {
 		log.Printf("❌ Query failed in GetPropertyPhoto (inspection_id=%s): %v", inspectionID, err)
@@ -2142,29 +1033,21 @@ func DeletePropertyPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	// Fetch photo URL to delete file
-	var fileURL string
-	err = db.QueryRow(`SELECT photo_url FROM property_photos WHERE inspection_id = ?`, inspectionId).Scan(&fileURL)
-	if err == sql.ErrNoRows {
-		w.WriteHeader(http.StatusOK)
-		return
-	} else if err != nil {
-		log.Println("Query error:", err)
-		http.Error(w, "Failed to fetch photo record", http.StatusInternalServerError)
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-
-	// Remove file from disk
-	filename := path.Base(fileURL)
-	filePath := path.Join("./uploads/property_photos", filename)
-	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-		log.Println("File deletion error:", err)
-		http.Error(w, "Failed to delete photo file", http.StatusInternalServerError)
+	defer tx.Rollback()
+	if rejectIfLocked(w, tx, inspectionId) {
 		return
 	}
 
-	// Remove DB record
-	_, err = db.Exec(`DELETE FROM property_photos WHERE inspection_id = ?`, inspectionId)
+	// Move the photo to the trash; the file stays until the retention job purges it
+	_, err = tx.Exec(`UPDATE property_photos SET deleted_at = UTC_TIMESTAMP() WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionId)
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Println("DB delete error:", err)
 		http.Error(w, "Failed to delete photo record", http.StatusInternalServerError)
@@ -0,0 +1,334 @@
+package inspections
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/middleware"
+	users "home_solutions/backend/models/users"
+)
+
+const (
+	defaultListLimit = 25
+	maxListLimit     = 100
+)
+
+// Sortable columns; NULLs are coalesced so keyset pagination can compare them
+var listSortColumns = map[string]string{
+	"inspection_date": "COALESCE(i.inspection_date, '0001-01-01')",
+	"scheduled_start": "COALESCE(i.scheduled_start, '0001-01-01 00:00:00')",
+	"created_at":      "i.created_at",
+	"report_id":       "COALESCE(i.report_id, '')",
+	"status":          "COALESCE(i.status, '')",
+	"city":            "p.city",
+}
+
+type InspectionListItem struct {
+	InspectionID   string  `json:"inspection_id"`
+	PropertyID     string  `json:"property_id"`
+	ReportID       string  `json:"report_id"`
+	Status         string  `json:"status"`
+	InspectionDate *string `json:"inspection_date"`
+	ScheduledStart *string `json:"scheduled_start"`
+	InspectorID    *int    `json:"inspector_id"`
+	CustomerID     *int    `json:"customer_id"`
+	Street         string  `json:"street"`
+	City           string  `json:"city"`
+	State          string  `json:"state"`
+	PostalCode     string  `json:"postal_code"`
+}
+
+type InspectionListResponse struct {
+	Inspections []InspectionListItem `json:"inspections"`
+	Total       int                  `json:"total"`
+	NextCursor  string               `json:"next_cursor,omitempty"`
+}
+
+// listCursor is the opaque keyset position handed back to clients as base64 JSON
+type listCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeListCursor(c listCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeListCursor(value string) (listCursor, error) {
+	var c listCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// inspectionScope restricts the listing to what the caller may see:
+// admins see everything, inspectors their assignments, homeowners their own inspections and properties.
+func inspectionScope(db *sql.DB, r *http.Request) (string, []interface{}, error) {
+	userType, _ := r.Context().Value(middleware.UserTypeKey).(string)
+	userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+
+	switch userType {
+	case "admin":
+		return "", nil, nil
+	case "inspector":
+		inspectorID, err := users.GetInspectorIDByUserID(db, userID)
+		if err == sql.ErrNoRows {
+			return "1 = 0", nil, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		return "i.inspector_id = ?", []interface{}{inspectorID}, nil
+	case "homeowner":
+		return `(i.customer_id = ? OR i.property_id IN (SELECT property_id FROM user_properties WHERE user_id = ?))`,
+			[]interface{}{userID, userID}, nil
+	default:
+		return "1 = 0", nil, nil
+	}
+}
+
+// CallerInspectorID returns the inspector profile of an inspector caller, or nil for anyone else
+func CallerInspectorID(db *sql.DB, r *http.Request) (*int, error) {
+	userType, _ := r.Context().Value(middleware.UserTypeKey).(string)
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if userType != "inspector" || !ok {
+		return nil, nil
+	}
+	inspectorID, err := users.GetInspectorIDByUserID(db, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inspectorID, nil
+}
+
+// FilterError is a malformed list filter parameter
+type FilterError struct{ msg string }
+
+func (e *FilterError) Error() string { return e.msg }
+
+// ListFilter builds the WHERE conditions shared by the inspection list and exports, over
+// "inspections i JOIN properties p". It applies the caller's scope and the query parameters
+// status (comma separated), from, to (YYYY-MM-DD), inspector_id, customer_id, city, postal_code
+// and q (address search). Bad parameters are reported as a *FilterError.
+func ListFilter(db *sql.DB, r *http.Request) ([]string, []interface{}, error) {
+	q := r.URL.Query()
+
+	scope, scopeArgs, err := inspectionScope(db, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	where := []string{"i.deleted_at IS NULL", "p.deleted_at IS NULL"}
+	var args []interface{}
+	if scope != "" {
+		where = append(where, scope)
+		args = append(args, scopeArgs...)
+	}
+
+	if v := q.Get("status"); v != "" {
+		statuses := strings.Split(v, ",")
+		placeholders := make([]string, len(statuses))
+		for i, s := range statuses {
+			placeholders[i] = "?"
+			args = append(args, strings.TrimSpace(s))
+		}
+		where = append(where, "i.status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	for _, param := range []struct{ name, clause string }{
+		{"from", "i.inspection_date >= ?"},
+		{"to", "i.inspection_date <= ?"},
+	} {
+		if v := q.Get(param.name); v != "" {
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				return nil, nil, &FilterError{"Invalid " + param.name + " date, expected YYYY-MM-DD"}
+			}
+			where = append(where, param.clause)
+			args = append(args, v)
+		}
+	}
+	for _, param := range []struct{ name, clause string }{
+		{"inspector_id", "i.inspector_id = ?"},
+		{"customer_id", "i.customer_id = ?"},
+	} {
+		if v := q.Get(param.name); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, nil, &FilterError{"Invalid " + param.name}
+			}
+			where = append(where, param.clause)
+			args = append(args, id)
+		}
+	}
+	if v := q.Get("city"); v != "" {
+		where = append(where, "p.city = ?")
+		args = append(args, v)
+	}
+	if v := q.Get("postal_code"); v != "" {
+		where = append(where, "p.postal_code = ?")
+		args = append(args, v)
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(v) + "%"
+		where = append(where, "(CONCAT_WS(' ', p.street, p.city, p.state, p.postal_code) LIKE ? OR i.report_id LIKE ?)")
+		args = append(args, like, like)
+	}
+	return where, args, nil
+}
+
+// ListInspections returns a filtered, sorted and cursor-paginated list of inspections visible to the caller.
+//
+// Query parameters: status (comma separated), from, to (YYYY-MM-DD), inspector_id, customer_id,
+// city, postal_code, q (address search), sort (column, prefix "-" for descending), limit, cursor.
+func ListInspections(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		where, args, err := ListFilter(db, r)
+		if _, ok := err.(*FilterError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("[ListInspections] Error resolving caller scope: %v", err)
+			http.Error(w, "Failed to resolve permissions", http.StatusInternalServerError)
+			return
+		}
+
+		sortKey := q.Get("sort")
+		if sortKey == "" {
+			sortKey = "-inspection_date"
+		}
+		desc := strings.HasPrefix(sortKey, "-")
+		sortKey = strings.TrimPrefix(sortKey, "-")
+		sortExpr, ok := listSortColumns[sortKey]
+		if !ok {
+			http.Error(w, "Invalid sort column", http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultListLimit
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			if n > maxListLimit {
+				n = maxListLimit
+			}
+			limit = n
+		}
+
+		const from = ` FROM inspections i JOIN properties p ON p.property_id = i.property_id`
+		filter := ""
+		if len(where) > 0 {
+			filter = " WHERE " + strings.Join(where, " AND ")
+		}
+
+		var total int
+		if err := db.QueryRow("SELECT COUNT(*)"+from+filter, args...).Scan(&total); err != nil {
+			log.Printf("[ListInspections] Count error: %v", err)
+			http.Error(w, "Failed to count inspections", http.StatusInternalServerError)
+			return
+		}
+
+		pageWhere := append([]string{}, where...)
+		pageArgs := append([]interface{}{}, args...)
+		if v := q.Get("cursor"); v != "" {
+			cursor, err := decodeListCursor(v)
+			if err != nil || cursor.Sort != sortKey || cursor.Desc != desc {
+				http.Error(w, "Invalid cursor", http.StatusBadRequest)
+				return
+			}
+			op := ">"
+			if desc {
+				op = "<"
+			}
+			pageWhere = append(pageWhere, fmt.Sprintf("(%s, i.inspection_id) %s (?, ?)", sortExpr, op))
+			pageArgs = append(pageArgs, cursor.Value, cursor.ID)
+		}
+		pageFilter := ""
+		if len(pageWhere) > 0 {
+			pageFilter = " WHERE " + strings.Join(pageWhere, " AND ")
+		}
+
+		direction := "ASC"
+		if desc {
+			direction = "DESC"
+		}
+		query := fmt.Sprintf(`
+			SELECT i.inspection_id, i.property_id, COALESCE(i.report_id, ''), COALESCE(i.status, ''),
+			       i.inspection_date, i.scheduled_start, i.inspector_id, i.customer_id,
+			       p.street, p.city, p.state, p.postal_code, CAST(%s AS CHAR)
+			%s%s
+			ORDER BY %s %s, i.inspection_id %s
+			LIMIT ?`, sortExpr, from, pageFilter, sortExpr, direction, direction)
+		pageArgs = append(pageArgs, limit+1)
+
+		rows, err := db.Query(query, pageArgs...)
+		if err != nil {
+			log.Printf("[ListInspections] Query error: %v", err)
+			http.Error(w, "Failed to list inspections", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		resp := InspectionListResponse{Inspections: []InspectionListItem{}, Total: total}
+		var lastSortValue string
+		for rows.Next() {
+			var item InspectionListItem
+			var inspectionDate, scheduledStart sql.NullString
+			var inspectorID, customerID sql.NullInt64
+			var sortValue string
+			if err := rows.Scan(&item.InspectionID, &item.PropertyID, &item.ReportID, &item.Status,
+				&inspectionDate, &scheduledStart, &inspectorID, &customerID,
+				&item.Street, &item.City, &item.State, &item.PostalCode, &sortValue); err != nil {
+				log.Printf("[ListInspections] Scan error: %v", err)
+				http.Error(w, "Failed to read inspections", http.StatusInternalServerError)
+				return
+			}
+			if len(resp.Inspections) == limit {
+				// The extra row only signals that another page exists
+				resp.NextCursor = encodeListCursor(listCursor{Sort: sortKey, Desc: desc, Value: lastSortValue, ID: resp.Inspections[limit-1].InspectionID})
+				break
+			}
+			if inspectionDate.Valid {
+				item.InspectionDate = &inspectionDate.String
+			}
+			if scheduledStart.Valid {
+				item.ScheduledStart = &scheduledStart.String
+			}
+			if inspectorID.Valid {
+				id := int(inspectorID.Int64)
+				item.InspectorID = &id
+			}
+			if customerID.Valid {
+				id := int(customerID.Int64)
+				item.CustomerID = &id
+			}
+			lastSortValue = sortValue
+			resp.Inspections = append(resp.Inspections, item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,65 @@
+package inspections
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ErrInspectionLocked rejects changes to a published report; an amendment must be started first
+var ErrInspectionLocked = errors.New("inspection is published; start an amendment to change it")
+
+// EnsureEditable returns ErrInspectionLocked while the inspection is published.
+// Missing inspections pass; callers validate existence themselves. Inside a transaction the
+// inspection row stays locked until commit, so publishing can't slip in between the check and
+// the write; callers should check and write in the same transaction.
+func EnsureEditable(db queryExecer, inspectionID string) error {
+	var status sql.NullString
+	err := db.QueryRow(`SELECT status FROM inspections WHERE inspection_id = ? FOR UPDATE`, inspectionID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status.String == "published" {
+		return ErrInspectionLocked
+	}
+	return nil
+}
+
+// EnsurePropertyEditable returns ErrInspectionLocked while any of the property's inspections is
+// published, since published reports include the property details
+func EnsurePropertyEditable(db queryExecer, propertyID string) error {
+	rows, err := db.Query(`SELECT status FROM inspections WHERE property_id = ? AND deleted_at IS NULL FOR UPDATE`, propertyID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status sql.NullString
+		if err := rows.Scan(&status); err != nil {
+			return err
+		}
+		if status.String == "published" {
+			return ErrInspectionLocked
+		}
+	}
+	return rows.Err()
+}
+
+// rejectIfLocked writes the response for a locked or unreadable inspection and reports whether it did
+func rejectIfLocked(w http.ResponseWriter, db queryExecer, inspectionID string) bool {
+	err := EnsureEditable(db, inspectionID)
+	if errors.Is(err, ErrInspectionLocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return true
+	}
+	if err != nil {
+		log.Printf("Error checking inspection %s status: %v", inspectionID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,664 @@
+package inspections
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxSyncOperations = 500
+	maxSyncBodyBytes  = 50 << 20
+
+	// A change id is handed out when its transaction inserts the row, not when it commits, so a
+	// lower id can become visible after a higher one. The cursor only moves past changes older than
+	// this window; newer ones are sent again on the next sync, which is harmless because every change
+	// carries the current state. It must outlast the longest sync transaction.
+	syncCursorSettleSeconds = 300
+)
+
+// change is one entry of the per-inspection change feed that offline clients pull from
+type change struct {
+	Entity   string // "item" or "photo"
+	Section  string
+	ItemName string
+	PhotoID  int
+	Action   string // "upsert" or "delete"
+}
+
+// recordChange appends to the change feed; its auto-increment id is the clients' sync cursor
+func recordChange(db queryExecer, inspectionID string, c change) error {
+	var section, itemName, photoID interface{}
+	if c.Section != "" {
+		section = c.Section
+	}
+	if c.ItemName != "" {
+		itemName = c.ItemName
+	}
+	if c.PhotoID != 0 {
+		photoID = c.PhotoID
+	}
+	_, err := db.Exec(`INSERT INTO sync_changes (inspection_id, entity, section, item_name, photo_id, action) VALUES (?, ?, ?, ?, ?, ?)`,
+		inspectionID, c.Entity, section, itemName, photoID, c.Action)
+	if err != nil {
+		return fmt.Errorf("failed to record change: %v", err)
+	}
+	return nil
+}
+
+// deleteWorksheetItem removes an item if the client saw its current version. The deleted state is
+// kept as a "delete" revision so it can be restored from history. Deleting a missing item is a no-op.
+func deleteWorksheetItem(db queryExecer, section Section, inspectionID, itemName string, expectedVersion int, authorID *int) (string, error) {
+	if err := EnsureEditable(db, inspectionID); err != nil {
+		return "", err
+	}
+	previous, err := loadWorksheetItem(db, section, inspectionID, itemName)
+	if err != nil {
+		return "", err
+	}
+	if previous == nil {
+		return outcomeUnchanged, nil
+	}
+	if previous.Version != expectedVersion {
+		return "", &VersionConflictError{ItemName: itemName, ClientVersion: expectedVersion, Current: previous}
+	}
+
+	res, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE inspection_id = ? AND item_name = ? AND version = ?`, section.Table),
+		inspectionID, itemName, expectedVersion)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return "", conflictFor(db, section, WorksheetItem{InspectionID: inspectionID, ItemName: itemName, Version: expectedVersion})
+	}
+
+	changes := diffWorksheetItems(previous, &WorksheetItem{})
+	if err := insertItemRevision(db, section, *previous, authorID, "delete", changes); err != nil {
+		return "", err
+	}
+	if err := recordChange(db, inspectionID, change{Entity: "item", Section: section.Key, ItemName: itemName, Action: "delete"}); err != nil {
+		return "", err
+	}
+	if err := syncDerivedDefects(db, section, WorksheetItem{InspectionID: inspectionID, ItemName: itemName}); err != nil {
+		return "", err
+	}
+	return "deleted", nil
+}
+
+// SyncOperation is one edit captured on a device, possibly while offline.
+//
+// Types: "item.upsert" (Section, Item; Item.Version is the version the edit was based on),
+// "item.delete" (Section, ItemName, BaseVersion), "photo.upload" (Photo with client_id, item_name,
+// filename and base64 data) and "photo.delete" (Photo.ClientID or PhotoID).
+type SyncOperation struct {
+	OpID            string         `json:"op_id"`
+	Type            string         `json:"type"`
+	ClientTimestamp string         `json:"client_timestamp"`
+	Section         string         `json:"section,omitempty"`
+	Item            *WorksheetItem `json:"item,omitempty"`
+	ItemName        string         `json:"item_name,omitempty"`
+	BaseVersion     int            `json:"base_version,omitempty"`
+	PhotoID         int            `json:"photo_id,omitempty"`
+	Photo           *SyncPhoto     `json:"photo,omitempty"`
+
+	timestamp time.Time
+}
+
+type SyncPhoto struct {
+	ClientID string `json:"client_id"`
+	ItemName string `json:"item_name,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Data     string `json:"data,omitempty"`
+}
+
+type SyncRequest struct {
+	DeviceID   string          `json:"device_id"`
+	Cursor     int64           `json:"cursor"`
+	Operations []SyncOperation `json:"operations"`
+}
+
+// SyncResult reports what happened to one operation.
+// Status is "applied", "merged" (combined with server edits made since the client's version), "server_won", "deleted",
+// "unchanged" or "rejected". Duplicate is set when the op_id had already been applied earlier.
+type SyncResult struct {
+	OpID      string         `json:"op_id"`
+	Status    string         `json:"status"`
+	Duplicate bool           `json:"duplicate,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Item      *WorksheetItem `json:"item,omitempty"`
+	Photo     *SyncedPhoto   `json:"photo,omitempty"`
+}
+
+type SyncedPhoto struct {
+	PhotoID  int    `json:"photo_id"`
+	ClientID string `json:"client_id,omitempty"`
+	ItemName string `json:"item_name"`
+	PhotoURL string `json:"photo_url"`
+}
+
+// SyncChange is the current server state of something that changed after the client's cursor.
+// Item or Photo is nil when the change was a deletion.
+type SyncChange struct {
+	ChangeID int64          `json:"change_id"`
+	Entity   string         `json:"entity"`
+	Action   string         `json:"action"`
+	Section  string         `json:"section,omitempty"`
+	ItemName string         `json:"item_name,omitempty"`
+	PhotoID  int            `json:"photo_id,omitempty"`
+	Item     *WorksheetItem `json:"item,omitempty"`
+	Photo    *SyncedPhoto   `json:"photo,omitempty"`
+}
+
+type SyncResponse struct {
+	Results []SyncResult `json:"results"`
+	Changes []SyncChange `json:"changes"`
+	Cursor  int64        `json:"cursor"`
+}
+
+// flattenItem turns an item into comparable fields for three-way merging
+func flattenItem(item WorksheetItem) map[string]interface{} {
+	fields := map[string]interface{}{
+		"inspection_status": item.InspectionStatus,
+		"comments":          item.Comments,
+	}
+	for k, v := range item.Materials {
+		fields["materials."+k] = v
+	}
+	for k, v := range item.Conditions {
+		fields["conditions."+k] = v
+	}
+	return fields
+}
+
+func unflattenItem(template WorksheetItem, fields map[string]interface{}) WorksheetItem {
+	item := WorksheetItem{
+		InspectionID: template.InspectionID,
+		ItemName:     template.ItemName,
+		Version:      template.Version,
+		Materials:    map[string]string{},
+		Conditions:   map[string]bool{},
+	}
+	for k, v := range fields {
+		switch {
+		case k == "inspection_status":
+			item.InspectionStatus, _ = v.(string)
+		case k == "comments":
+			item.Comments, _ = v.(string)
+		case strings.HasPrefix(k, "materials."):
+			item.Materials[strings.TrimPrefix(k, "materials.")], _ = v.(string)
+		case strings.HasPrefix(k, "conditions."):
+			item.Conditions[strings.TrimPrefix(k, "conditions.")], _ = v.(bool)
+		}
+	}
+	return item
+}
+
+// mergeItems resolves an offline edit against a server copy that moved on.
+//
+// Conflicts are decided by item version, never by device clocks. With the base version the client
+// edited from, fields are merged individually: a field only the client changed takes the client
+// value, and a field the server changed since the base keeps the committed server value. Without a
+// base there is nothing to merge against, so the server copy stands.
+func mergeItems(base *WorksheetItem, server, client WorksheetItem) WorksheetItem {
+	if base == nil {
+		return server
+	}
+
+	baseFields, serverFields, clientFields := flattenItem(*base), flattenItem(server), flattenItem(client)
+	keys := map[string]bool{}
+	for _, fields := range []map[string]interface{}{baseFields, serverFields, clientFields} {
+		for k := range fields {
+			keys[k] = true
+		}
+	}
+
+	merged := map[string]interface{}{}
+	for k := range keys {
+		baseValue, inBase := baseFields[k]
+		serverValue, inServer := serverFields[k]
+		clientValue, inClient := clientFields[k]
+
+		clientChanged := inBase != inClient || baseValue != clientValue
+		serverChanged := inBase != inServer || baseValue != serverValue
+
+		if clientChanged && !serverChanged {
+			if inClient {
+				merged[k] = clientValue
+			}
+		} else if inServer {
+			merged[k] = serverValue
+		}
+	}
+	return unflattenItem(server, merged)
+}
+
+// loadItemAtVersion finds the item state a client based its edit on, from the revision history
+func loadItemAtVersion(db queryExecer, section Section, inspectionID, itemName string, version int) (*WorksheetItem, error) {
+	var snapshotJSON string
+	err := db.QueryRow(`
+		SELECT snapshot FROM inspection_item_revisions
+		WHERE inspection_id = ? AND section = ? AND item_name = ? AND action <> 'delete'
+		  AND JSON_EXTRACT(snapshot, '$.version') = ?
+		ORDER BY revision DESC LIMIT 1`, inspectionID, section.Key, itemName, version).Scan(&snapshotJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item WorksheetItem
+	if err := json.Unmarshal([]byte(snapshotJSON), &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func applyItemUpsert(tx queryExecer, inspectionID string, op SyncOperation, authorID *int) (SyncResult, error) {
+	result := SyncResult{OpID: op.OpID}
+	section, ok := SectionByKey(op.Section)
+	if !ok || op.Item == nil {
+		return result, fmt.Errorf("item.upsert requires a valid section and item")
+	}
+
+	client := *op.Item
+	client.InspectionID = inspectionID
+	records := []WorksheetItem{client}
+	recordErrors, err := validateWorksheetItems(tx, records)
+	if err != nil {
+		return result, err
+	}
+	if len(recordErrors) > 0 {
+		return result, fmt.Errorf("%v", recordErrors[0].Errors)
+	}
+	client = records[0]
+
+	current, err := loadWorksheetItem(tx, section, inspectionID, client.ItemName)
+	if err != nil {
+		return result, err
+	}
+
+	status := "applied"
+	toStore := client
+	switch {
+	case current == nil:
+		// New on the server, or deleted there since: the offline edit recreates it
+		toStore.Version = 0
+	case current.Version == client.Version:
+		// Nobody else touched it
+	default:
+		base, err := loadItemAtVersion(tx, section, inspectionID, client.ItemName, client.Version)
+		if err != nil {
+			return result, err
+		}
+		toStore = mergeItems(base, *current, client)
+		toStore.Version = current.Version
+
+		switch {
+		case len(diffWorksheetItems(current, &toStore)) == 0:
+			status = "server_won"
+		case len(diffWorksheetItems(&client, &toStore)) == 0:
+			status = "applied"
+		default:
+			status = "merged"
+		}
+	}
+
+	stored, outcome, err := saveWorksheetItem(tx, section, toStore, authorID, "")
+	if err != nil {
+		return result, err
+	}
+	if outcome == outcomeUnchanged && status == "applied" {
+		status = outcomeUnchanged
+	}
+	result.Status = status
+	result.Item = &stored
+	return result, nil
+}
+
+func applyItemDelete(tx queryExecer, inspectionID string, op SyncOperation, authorID *int) (SyncResult, error) {
+	result := SyncResult{OpID: op.OpID}
+	section, ok := SectionByKey(op.Section)
+	if !ok || op.ItemName == "" {
+		return result, fmt.Errorf("item.delete requires a valid section and item_name")
+	}
+
+	outcome, err := deleteWorksheetItem(tx, section, inspectionID, op.ItemName, op.BaseVersion, authorID)
+	var conflict *VersionConflictError
+	if errors.As(err, &conflict) {
+		// The item was edited after the version the client deleted; the edit stands
+		result.Status = "server_won"
+		result.Item = conflict.Current
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Status = outcome
+	return result, nil
+}
+
+func findPhotoByClientID(db queryExecer, inspectionID, clientID string) (*SyncedPhoto, error) {
+	var p SyncedPhoto
+	var client sql.NullString
+	err := db.QueryRow(`SELECT photo_id, client_id, item_name, photo_url FROM inspection_photos WHERE client_id = ? AND inspection_id = ?`, clientID, inspectionID).
+		Scan(&p.PhotoID, &client, &p.ItemName, &p.PhotoURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.ClientID = client.String
+	return &p, nil
+}
+
+func applyPhotoUpload(db *sql.DB, inspectionID string, op SyncOperation) (SyncResult, error) {
+	result := SyncResult{OpID: op.OpID}
+	if op.Photo == nil || op.Photo.ClientID == "" || op.Photo.ItemName == "" || op.Photo.Data == "" {
+		return result, fmt.Errorf("photo.upload requires photo.client_id, item_name and data")
+	}
+
+	existing, err := findPhotoByClientID(db, inspectionID, op.Photo.ClientID)
+	if err != nil {
+		return result, err
+	}
+	if existing != nil {
+		result.Status = outcomeUnchanged
+		result.Photo = existing
+		return result, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+	if err := EnsureEditable(tx, inspectionID); err != nil {
+		return result, err
+	}
+	data, err := base64.StdEncoding.DecodeString(op.Photo.Data)
+	if err != nil {
+		return result, fmt.Errorf("photo.data is not valid base64")
+	}
+	photoURL, err := StoreInspectionPhotoFile(bytes.NewReader(data), op.Photo.Filename)
+	if err != nil {
+		return result, err
+	}
+
+	photoID, err := insertInspectionPhoto(tx, inspectionID, op.Photo.ItemName, photoURL, op.Photo.ClientID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		os.Remove("." + photoURL)
+		return result, err
+	}
+
+	result.Status = "applied"
+	result.Photo = &SyncedPhoto{PhotoID: photoID, ClientID: op.Photo.ClientID, ItemName: op.Photo.ItemName, PhotoURL: photoURL}
+	return result, nil
+}
+
+func applyPhotoDelete(tx queryExecer, inspectionID string, op SyncOperation) (SyncResult, error) {
+	result := SyncResult{OpID: op.OpID}
+
+	var photoID int
+	var err error
+	switch {
+	case op.Photo != nil && op.Photo.ClientID != "":
+		err = tx.QueryRow(`SELECT photo_id FROM inspection_photos WHERE client_id = ? AND inspection_id = ? AND deleted_at IS NULL`,
+			op.Photo.ClientID, inspectionID).Scan(&photoID)
+	case op.PhotoID != 0:
+		err = tx.QueryRow(`SELECT photo_id FROM inspection_photos WHERE photo_id = ? AND inspection_id = ? AND deleted_at IS NULL`,
+			op.PhotoID, inspectionID).Scan(&photoID)
+	default:
+		return result, fmt.Errorf("photo.delete requires photo.client_id or photo_id")
+	}
+	if err == sql.ErrNoRows {
+		result.Status = outcomeUnchanged
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if err := deleteInspectionPhotoRecord(tx, inspectionID, strconv.Itoa(photoID)); err != nil {
+		return result, err
+	}
+	result.Status = "deleted"
+	return result, nil
+}
+
+// applySyncOperation applies one operation and remembers its result under op_id, all in one
+// transaction, so a retried batch never applies an operation twice.
+func applySyncOperation(db *sql.DB, inspectionID, deviceID string, op SyncOperation, authorID *int) SyncResult {
+	previous, err := findSyncOperation(db, inspectionID, op.OpID)
+	if err != nil {
+		log.Printf("[Sync] Error checking op %s: %v", op.OpID, err)
+		return SyncResult{OpID: op.OpID, Status: "rejected", Error: "database error"}
+	}
+	if previous != nil {
+		previous.Duplicate = true
+		return *previous
+	}
+
+	var result SyncResult
+	if op.Type == "photo.upload" {
+		// The file write can't join the transaction; photo client_ids make the upload itself idempotent
+		result, err = applyPhotoUpload(db, inspectionID, op)
+		if err == nil {
+			err = saveSyncOperation(db, inspectionID, deviceID, op, result)
+		}
+	} else {
+		var tx *sql.Tx
+		tx, err = db.Begin()
+		if err == nil {
+			switch op.Type {
+			case "item.upsert":
+				result, err = applyItemUpsert(tx, inspectionID, op, authorID)
+			case "item.delete":
+				result, err = applyItemDelete(tx, inspectionID, op, authorID)
+			case "photo.delete":
+				result, err = applyPhotoDelete(tx, inspectionID, op)
+			default:
+				err = fmt.Errorf("unknown operation type %q", op.Type)
+			}
+			if err == nil {
+				err = saveSyncOperation(tx, inspectionID, deviceID, op, result)
+			}
+			if err == nil {
+				err = tx.Commit()
+			} else {
+				tx.Rollback()
+			}
+		}
+	}
+
+	if err != nil {
+		log.Printf("[Sync] Rejected op %s (%s): %v", op.OpID, op.Type, err)
+		return SyncResult{OpID: op.OpID, Status: "rejected", Error: err.Error()}
+	}
+	return result
+}
+
+// findSyncOperation returns the stored result of an operation already applied to the inspection.
+// Op ids are only unique per inspection, so a device reusing one elsewhere isn't mistaken for a retry.
+func findSyncOperation(db queryExecer, inspectionID, opID string) (*SyncResult, error) {
+	var stored string
+	err := db.QueryRow(`SELECT result FROM sync_operations WHERE inspection_id = ? AND op_id = ?`, inspectionID, opID).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result SyncResult
+	if err := json.Unmarshal([]byte(stored), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func saveSyncOperation(db queryExecer, inspectionID, deviceID string, op SyncOperation, result SyncResult) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO sync_operations (op_id, inspection_id, device_id, op_type, status, result) VALUES (?, ?, ?, ?, ?, ?)`,
+		op.OpID, inspectionID, deviceID, op.Type, result.Status, resultJSON)
+	return err
+}
+
+// changesSince returns the latest state of everything that changed after the cursor, one entry per item or photo.
+// The returned cursor stays behind changes recorded within the settle window, see syncCursorSettleSeconds.
+func changesSince(db *sql.DB, inspectionID string, cursor int64) ([]SyncChange, int64, error) {
+	rows, err := db.Query(`
+		SELECT change_id, entity, action, COALESCE(section, ''), COALESCE(item_name, ''), COALESCE(photo_id, 0),
+		       created_at <= CURRENT_TIMESTAMP - INTERVAL ? SECOND
+		FROM sync_changes
+		WHERE inspection_id = ? AND change_id > ?
+		ORDER BY change_id`, syncCursorSettleSeconds, inspectionID, cursor)
+	if err != nil {
+		return nil, cursor, err
+	}
+	defer rows.Close()
+
+	latest := map[string]SyncChange{}
+	for rows.Next() {
+		var c SyncChange
+		var settled bool
+		if err := rows.Scan(&c.ChangeID, &c.Entity, &c.Action, &c.Section, &c.ItemName, &c.PhotoID, &settled); err != nil {
+			return nil, cursor, err
+		}
+		key := c.Entity + "|" + c.Section + "|" + c.ItemName + "|" + strconv.Itoa(c.PhotoID)
+		latest[key] = c
+		if settled && c.ChangeID > cursor {
+			cursor = c.ChangeID
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, cursor, err
+	}
+
+	changes := make([]SyncChange, 0, len(latest))
+	for _, c := range latest {
+		switch c.Entity {
+		case "item":
+			if section, ok := SectionByKey(c.Section); ok {
+				item, err := loadWorksheetItem(db, section, inspectionID, c.ItemName)
+				if err != nil {
+					return nil, cursor, err
+				}
+				c.Item = item
+			}
+		case "photo":
+			var p SyncedPhoto
+			var client sql.NullString
+			err := db.QueryRow(`SELECT photo_id, client_id, item_name, photo_url FROM inspection_photos WHERE photo_id = ?`, c.PhotoID).
+				Scan(&p.PhotoID, &client, &p.ItemName, &p.PhotoURL)
+			if err == nil {
+				p.ClientID = client.String
+				c.Photo = &p
+			} else if err != sql.ErrNoRows {
+				return nil, cursor, err
+			}
+		}
+		if c.Item == nil && c.Photo == nil {
+			c.Action = "delete"
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ChangeID < changes[j].ChangeID })
+	return changes, cursor, nil
+}
+
+// SyncInspection applies a batch of offline operations and returns the server changes since the client's cursor.
+//
+// Operations are replayed in client timestamp order (op_id breaks ties), each in its own transaction, and
+// are idempotent by op_id. Conflicts with other devices are settled by item version, not by timestamps.
+// The returned changes include the effects of this batch; clients store the returned cursor and send it
+// with their next sync, and may see recent changes again.
+func SyncInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if inspectionID == "" {
+			http.Error(w, "inspection_id required", http.StatusBadRequest)
+			return
+		}
+		if !middleware.RequireStaff(w, r, "sync inspections") {
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSyncBodyBytes)
+		var req SyncRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if len(req.Operations) > maxSyncOperations {
+			http.Error(w, fmt.Sprintf("At most %d operations per sync", maxSyncOperations), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspections WHERE inspection_id = ?)`, inspectionID).Scan(&exists); err != nil {
+			log.Printf("[Sync] Error checking inspection %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+
+		results := make([]SyncResult, 0, len(req.Operations))
+		var valid []SyncOperation
+		for _, op := range req.Operations {
+			t, err := time.Parse(time.RFC3339, op.ClientTimestamp)
+			switch {
+			case op.OpID == "":
+				results = append(results, SyncResult{Status: "rejected", Error: "op_id is required"})
+			case err != nil:
+				results = append(results, SyncResult{OpID: op.OpID, Status: "rejected", Error: "client_timestamp must be RFC 3339"})
+			default:
+				op.timestamp = t.UTC()
+				valid = append(valid, op)
+			}
+		}
+		sort.SliceStable(valid, func(i, j int) bool {
+			if !valid[i].timestamp.Equal(valid[j].timestamp) {
+				return valid[i].timestamp.Before(valid[j].timestamp)
+			}
+			return valid[i].OpID < valid[j].OpID
+		})
+
+		authorID := middleware.CallerID(r)
+		for _, op := range valid {
+			results = append(results, applySyncOperation(db, inspectionID, req.DeviceID, op, authorID))
+		}
+
+		changes, cursor, err := changesSince(db, inspectionID, req.Cursor)
+		if err != nil {
+			log.Printf("[Sync] Error collecting changes for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to collect server changes", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SyncResponse{Results: results, Changes: changes, Cursor: cursor})
+	}
+}
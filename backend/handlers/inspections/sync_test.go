@@ -0,0 +1,157 @@
+package inspections
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeItems(t *testing.T) {
+	base := WorksheetItem{
+		ItemName:         "Furnace",
+		Materials:        map[string]string{"fuel": "Gas"},
+		Conditions:       map[string]bool{"no heat": false},
+		Comments:         "Serviced 2024",
+		InspectionStatus: "Inspected",
+		Version:          3,
+	}
+	with := func(change func(*WorksheetItem)) WorksheetItem {
+		item := base
+		item.Materials = map[string]string{}
+		for k, v := range base.Materials {
+			item.Materials[k] = v
+		}
+		item.Conditions = map[string]bool{}
+		for k, v := range base.Conditions {
+			item.Conditions[k] = v
+		}
+		change(&item)
+		return item
+	}
+
+	tests := []struct {
+		name   string
+		base   *WorksheetItem
+		server WorksheetItem
+		client WorksheetItem
+		want   WorksheetItem
+	}{
+		{
+			name:   "separate fields merge",
+			base:   &base,
+			server: with(func(i *WorksheetItem) { i.Comments = "Filter dirty"; i.Version = 5 }),
+			client: with(func(i *WorksheetItem) { i.Conditions["no heat"] = true }),
+			want: with(func(i *WorksheetItem) {
+				i.Comments = "Filter dirty"
+				i.Conditions["no heat"] = true
+				i.Version = 5
+			}),
+		},
+		{
+			name:   "server keeps a field both changed",
+			base:   &base,
+			server: with(func(i *WorksheetItem) { i.Comments = "Server text"; i.Version = 4 }),
+			client: with(func(i *WorksheetItem) { i.Comments = "Client text"; i.InspectionStatus = "Repair or Replace" }),
+			want: with(func(i *WorksheetItem) {
+				i.Comments = "Server text"
+				i.InspectionStatus = "Repair or Replace"
+				i.Version = 4
+			}),
+		},
+		{
+			name:   "client adds a material",
+			base:   &base,
+			server: with(func(i *WorksheetItem) { i.Version = 4 }),
+			client: with(func(i *WorksheetItem) { i.Materials["age"] = "10 years" }),
+			want: with(func(i *WorksheetItem) {
+				i.Materials["age"] = "10 years"
+				i.Version = 4
+			}),
+		},
+		{
+			name:   "client removes a material the server left alone",
+			base:   &base,
+			server: with(func(i *WorksheetItem) { i.Version = 4 }),
+			client: with(func(i *WorksheetItem) { delete(i.Materials, "fuel") }),
+			want: with(func(i *WorksheetItem) {
+				delete(i.Materials, "fuel")
+				i.Version = 4
+			}),
+		},
+		{
+			name:   "no base keeps the server copy",
+			server: with(func(i *WorksheetItem) { i.Comments = "Server"; i.Version = 9 }),
+			client: with(func(i *WorksheetItem) { i.Comments = "Client" }),
+			want:   with(func(i *WorksheetItem) { i.Comments = "Server"; i.Version = 9 }),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeItems(tt.base, tt.server, tt.client)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncOperationReusedAcrossInspections(t *testing.T) {
+	// sync_operations, keyed like the table by (inspection_id, op_id)
+	stored := map[[2]string]string{}
+	db := openFakeDB(t, &fakeDB{
+		exec: func(query string, args []driver.Value) error {
+			if !strings.HasPrefix(query, "INSERT INTO sync_operations (op_id, inspection_id,") {
+				return fmt.Errorf("unexpected exec %q", query)
+			}
+			key := [2]string{args[1].(string), args[0].(string)}
+			if _, ok := stored[key]; ok {
+				return fmt.Errorf("duplicate key %v", key)
+			}
+			stored[key] = string(args[5].([]byte))
+			return nil
+		},
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+			if !strings.Contains(query, "FROM sync_operations WHERE inspection_id = ? AND op_id = ?") {
+				return nil, nil, fmt.Errorf("unexpected query %q", query)
+			}
+			result, ok := stored[[2]string{args[0].(string), args[1].(string)}]
+			if !ok {
+				return []string{"result"}, nil, nil
+			}
+			return []string{"result"}, [][]driver.Value{{result}}, nil
+		},
+	})
+
+	op := SyncOperation{OpID: "device-1:42", Type: "item.upsert"}
+	applied := SyncResult{OpID: op.OpID, Status: "applied", Item: &WorksheetItem{InspectionID: "A", ItemName: "Roof", Comments: "A's comment"}}
+	if err := saveSyncOperation(db, "A", "device-1", op, applied); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		inspectionID string
+		wantStored   bool
+	}{
+		{"A", true},
+		{"B", false},
+	}
+	for _, tt := range tests {
+		got, err := findSyncOperation(db, tt.inspectionID, op.OpID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != nil) != tt.wantStored {
+			t.Fatalf("inspection %s: stored result found = %v, want %v", tt.inspectionID, got != nil, tt.wantStored)
+		}
+		if got != nil && !reflect.DeepEqual(*got, applied) {
+			t.Errorf("inspection %s: got %+v, want %+v", tt.inspectionID, *got, applied)
+		}
+	}
+
+	// The same op id on the other inspection is stored on its own rather than clashing
+	if err := saveSyncOperation(db, "B", "device-1", op, SyncResult{OpID: op.OpID, Status: "applied"}); err != nil {
+		t.Errorf("saving the reused op id for B: %v", err)
+	}
+}
@@ -0,0 +1,617 @@
+package inspections
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"home_solutions/backend/middleware"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+)
+
+// Section describes one worksheet and the table its items are stored in
+type Section struct {
+	Key   string `json:"key"`
+	Table string `json:"table"`
+	Title string `json:"title"`
+}
+
+// Sections lists the worksheets in report order
+var Sections = []Section{
+	{"exterior", "inspection_exterior", "Exterior"},
+	{"roof", "inspection_roof", "Roof"},
+	{"basementFoundation", "inspection_basementFoundation", "Basement & Foundation"},
+	{"heating", "inspection_heating", "Heating"},
+	{"cooling", "inspection_cooling", "Cooling"},
+	{"plumbing", "inspection_plumbing", "Plumbing"},
+	{"electrical", "inspection_electrical", "Electrical"},
+	{"attic", "inspection_attic", "Attic, Insulation & Ventilation"},
+	{"doorsWindows", "inspection_doorsWindows", "Doors, Windows & Interior"},
+	{"fireplace", "inspection_fireplace", "Fireplace"},
+	{"systemsComponents", "inspection_systemsComponents", "Systems & Components"},
+}
+
+// SectionByKey looks up a worksheet by its route key (e.g. "basementFoundation")
+func SectionByKey(key string) (Section, bool) {
+	for _, s := range Sections {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Section{}, false
+}
+
+func mustSection(key string) Section {
+	s, ok := SectionByKey(key)
+	if !ok {
+		panic("unknown worksheet section: " + key)
+	}
+	return s
+}
+
+// WorksheetItem is the row shape shared by every worksheet table.
+// Version increases on every stored change and must be echoed back on save.
+type WorksheetItem struct {
+	InspectionID     string            `json:"inspection_id"`
+	ItemName         string            `json:"item_name"`
+	Materials        map[string]string `json:"materials"`
+	Conditions       map[string]bool   `json:"conditions"`
+	Comments         string            `json:"comments"`
+	InspectionStatus string            `json:"inspection_status"`
+	Version          int               `json:"version"`
+}
+
+// queryExecer is satisfied by both *sql.DB and *sql.Tx
+type queryExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// VersionConflictError reports a save based on a stale version of an item
+type VersionConflictError struct {
+	ItemName      string         `json:"item_name"`
+	ClientVersion int            `json:"client_version"`
+	Current       *WorksheetItem `json:"current"`
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("item %q was modified (client version %d)", e.ItemName, e.ClientVersion)
+}
+
+const worksheetColumns = `item_name, materials, conditions, comments, inspection_status, version`
+
+func scanWorksheetItem(scanner interface{ Scan(...interface{}) error }, inspectionID string) (WorksheetItem, error) {
+	var item WorksheetItem
+	var materialsJSON, conditionsJSON, comments, status sql.NullString
+	if err := scanner.Scan(&item.ItemName, &materialsJSON, &conditionsJSON, &comments, &status, &item.Version); err != nil {
+		return item, err
+	}
+
+	item.InspectionID = inspectionID
+	item.Comments = comments.String
+	item.InspectionStatus = status.String
+	if materialsJSON.Valid && materialsJSON.String != "" {
+		if err := json.Unmarshal([]byte(materialsJSON.String), &item.Materials); err != nil {
+			log.Printf("Error unmarshalling materials: %v", err)
+		}
+	}
+	if conditionsJSON.Valid && conditionsJSON.String != "" {
+		if err := json.Unmarshal([]byte(conditionsJSON.String), &item.Conditions); err != nil {
+			log.Printf("Error unmarshalling conditions: %v", err)
+		}
+	}
+	if item.Materials == nil {
+		item.Materials = map[string]string{}
+	}
+	if item.Conditions == nil {
+		item.Conditions = map[string]bool{}
+	}
+	return item, nil
+}
+
+// loadWorksheetItem returns the stored item, or nil when it has never been saved
+func loadWorksheetItem(db queryExecer, section Section, inspectionID, itemName string) (*WorksheetItem, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE inspection_id = ? AND item_name = ?`, worksheetColumns, section.Table)
+	item, err := scanWorksheetItem(db.QueryRow(query, inspectionID, itemName), inspectionID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// LoadWorksheetItems returns every stored item of one section for an inspection
+func LoadWorksheetItems(db queryExecer, section Section, inspectionID string) ([]WorksheetItem, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE inspection_id = ? ORDER BY created_at, item_name`, worksheetColumns, section.Table)
+	rows, err := db.Query(query, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []WorksheetItem{}
+	for rows.Next() {
+		item, err := scanWorksheetItem(rows, inspectionID)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func marshalItemMaps(record WorksheetItem) ([]byte, []byte) {
+	materialsJSON, err := json.Marshal(record.Materials)
+	if err != nil {
+		log.Printf("Error marshalling materials: %v", err)
+		materialsJSON = []byte("{}")
+	}
+
+	conditionsJSON, err := json.Marshal(record.Conditions)
+	if err != nil {
+		log.Printf("Error marshalling conditions: %v", err)
+		conditionsJSON = []byte("{}")
+	}
+	return materialsJSON, conditionsJSON
+}
+
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// writeWorksheetItem inserts a new item at version 1, or updates an existing one only if it is
+// still at expectedVersion. It returns the stored version, or a *VersionConflictError.
+func writeWorksheetItem(db queryExecer, section Section, record WorksheetItem, exists bool, expectedVersion int) (int, error) {
+	materialsJSON, conditionsJSON := marshalItemMaps(record)
+
+	if !exists {
+		query := fmt.Sprintf(`
+			INSERT INTO %s (inspection_id, item_name, materials, conditions, comments, inspection_status, version)
+			VALUES (?, ?, ?, ?, ?, ?, 1)
+		`, section.Table)
+		_, err := db.Exec(query, record.InspectionID, record.ItemName, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus)
+		if isDuplicateKey(err) {
+			// Created concurrently by another client
+			return 0, conflictFor(db, section, record)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET materials = ?, conditions = ?, comments = ?, inspection_status = ?, version = version + 1
+		WHERE inspection_id = ? AND item_name = ? AND version = ?
+	`, section.Table)
+	res, err := db.Exec(query, materialsJSON, conditionsJSON, record.Comments, record.InspectionStatus, record.InspectionID, record.ItemName, expectedVersion)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, conflictFor(db, section, record)
+	}
+	return expectedVersion + 1, nil
+}
+
+func conflictFor(db queryExecer, section Section, record WorksheetItem) error {
+	current, err := loadWorksheetItem(db, section, record.InspectionID, record.ItemName)
+	if err != nil {
+		return err
+	}
+	return &VersionConflictError{ItemName: record.ItemName, ClientVersion: record.Version, Current: current}
+}
+
+// Outcomes reported for each saved record
+const (
+	outcomeCreated   = "created"
+	outcomeUpdated   = "updated"
+	outcomeUnchanged = "unchanged"
+)
+
+// saveWorksheetItem stores an item if the client saw the current version, and records a revision
+// when its content changed. action is "restore" for restores, which overwrite whatever version is
+// current; otherwise it is derived as "create" or "update". It returns the item as stored and
+// whether it was created, updated or left unchanged.
+func saveWorksheetItem(db queryExecer, section Section, record WorksheetItem, authorID *int, action string) (WorksheetItem, string, error) {
+	if err := EnsureEditable(db, record.InspectionID); err != nil {
+		return record, "", err
+	}
+	previous, err := loadWorksheetItem(db, section, record.InspectionID, record.ItemName)
+	if err != nil {
+		return record, "", fmt.Errorf("failed to load current %s item: %v", section.Key, err)
+	}
+
+	if action == "restore" && previous != nil {
+		record.Version = previous.Version
+	}
+	if previous != nil && record.Version != previous.Version {
+		return record, "", &VersionConflictError{ItemName: record.ItemName, ClientVersion: record.Version, Current: previous}
+	}
+
+	changes := diffWorksheetItems(previous, &record)
+	if len(changes) == 0 {
+		// Nothing to store; repeated autosaves of the same content keep the version stable, and an
+		// empty item that was never saved stays unsaved
+		if previous == nil {
+			return record, outcomeUnchanged, nil
+		}
+		return *previous, outcomeUnchanged, nil
+	}
+
+	expectedVersion := 0
+	if previous != nil {
+		expectedVersion = previous.Version
+	}
+	record.Version, err = writeWorksheetItem(db, section, record, previous != nil, expectedVersion)
+	if err != nil {
+		return record, "", err
+	}
+
+	outcome := outcomeUpdated
+	if previous == nil {
+		outcome = outcomeCreated
+	}
+
+	if action == "" {
+		action = "update"
+		if previous == nil {
+			action = "create"
+		}
+	}
+
+	// Items saved before history existed get their prior state captured once, so it can still be restored
+	if previous != nil {
+		var hasHistory bool
+		err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspection_item_revisions WHERE inspection_id = ? AND section = ? AND item_name = ?)`,
+			record.InspectionID, section.Key, record.ItemName).Scan(&hasHistory)
+		if err != nil {
+			return record, "", fmt.Errorf("failed to check item history: %v", err)
+		}
+		if !hasHistory {
+			if err := insertItemRevision(db, section, *previous, nil, "baseline", nil); err != nil {
+				return record, "", err
+			}
+		}
+	}
+
+	if err := insertItemRevision(db, section, record, authorID, action, changes); err != nil {
+		return record, "", err
+	}
+	if err := recordChange(db, record.InspectionID, change{Entity: "item", Section: section.Key, ItemName: record.ItemName, Action: "upsert"}); err != nil {
+		return record, "", err
+	}
+	if err := syncDerivedDefects(db, section, record); err != nil {
+		return record, "", err
+	}
+	return record, outcome, nil
+}
+
+// sectionETag fingerprints the item versions of a section so clients can revalidate cheaply
+func sectionETag(items []WorksheetItem) string {
+	h := sha256.New()
+	for _, item := range items {
+		fmt.Fprintf(h, "%s\x00%d\x00", item.ItemName, item.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum(nil)[:12])
+}
+
+// getWorksheetHandler is the shared GET handler behind every Get*Data endpoint
+func getWorksheetHandler(sectionKey string) http.HandlerFunc {
+	section := mustSection(sectionKey)
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		inspectionId := vars["inspection_id"]
+		if inspectionId == "" {
+			http.Error(w, "Inspection ID is required", http.StatusBadRequest)
+			return
+		}
+
+		db, err := getDBConnection()
+		if err != nil {
+			log.Printf("DB connection error: %v", err)
+			http.Error(w, "DB connection error", http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		data, err := LoadWorksheetItems(db, section, inspectionId)
+		if err != nil {
+			log.Printf("Error fetching %s data: %v", section.Key, err)
+			http.Error(w, "Failed to fetch data", http.StatusInternalServerError)
+			return
+		}
+
+		etag := sectionETag(data)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+	}
+}
+
+// Statuses an item can be saved with; an empty status is stored as "Not Inspected"
+var inspectionStatuses = map[string]bool{
+	"Inspected":         true,
+	"Not Inspected":     true,
+	"Not Present":       true,
+	"Repair or Replace": true,
+}
+
+// NormalizeInspectionStatus matches a status case-insensitively against the ones an item can be
+// saved with; an empty status is "Not Inspected"
+func NormalizeInspectionStatus(status string) (string, bool) {
+	status = strings.TrimSpace(status)
+	if status == "" {
+		return "Not Inspected", true
+	}
+	for known := range inspectionStatuses {
+		if strings.EqualFold(known, status) {
+			return known, true
+		}
+	}
+	return status, false
+}
+
+type savedItem struct {
+	ItemName string `json:"item_name"`
+	Version  int    `json:"version"`
+	Outcome  string `json:"outcome"`
+	// Comments is returned when default library comments were added, so the client can show them
+	Comments *string `json:"comments,omitempty"`
+}
+
+// RecordError lists the validation problems of one record in a save payload
+type RecordError struct {
+	Index    int      `json:"index"`
+	ItemName string   `json:"item_name"`
+	Errors   []string `json:"errors"`
+}
+
+// validateWorksheetItems checks a whole payload before anything is written, normalizing statuses in place
+func validateWorksheetItems(db queryExecer, data []WorksheetItem) ([]RecordError, error) {
+	recordErrors := []RecordError{}
+	seen := map[string]int{}
+	inspectionExists := map[string]bool{}
+
+	for i := range data {
+		record := &data[i]
+		var problems []string
+
+		record.ItemName = strings.TrimSpace(record.ItemName)
+		if record.ItemName == "" {
+			problems = append(problems, "item_name is required")
+		}
+		if record.InspectionID == "" {
+			problems = append(problems, "inspection_id is required")
+		} else {
+			exists, checked := inspectionExists[record.InspectionID]
+			if !checked {
+				if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspections WHERE inspection_id = ?)`, record.InspectionID).Scan(&exists); err != nil {
+					return nil, err
+				}
+				inspectionExists[record.InspectionID] = exists
+			}
+			if !exists {
+				problems = append(problems, "inspection not found")
+			}
+		}
+		if status, ok := NormalizeInspectionStatus(record.InspectionStatus); ok {
+			record.InspectionStatus = status
+		} else {
+			problems = append(problems, fmt.Sprintf("invalid inspection_status %q", record.InspectionStatus))
+		}
+		if record.Version < 0 {
+			problems = append(problems, "version must not be negative")
+		}
+
+		key := record.InspectionID + "\x00" + record.ItemName
+		if first, dup := seen[key]; dup && record.ItemName != "" {
+			problems = append(problems, fmt.Sprintf("duplicate of record %d", first))
+		} else {
+			seen[key] = i
+		}
+
+		if len(problems) > 0 {
+			recordErrors = append(recordErrors, RecordError{Index: i, ItemName: record.ItemName, Errors: problems})
+		}
+	}
+	return recordErrors, nil
+}
+
+// saveWorksheetHandler is the shared POST handler behind every Save*Data endpoint.
+//
+// The payload is validated up front and written in a single transaction: either every record is
+// persisted or none is. Each record must carry the version returned by the GET endpoint (0 for new
+// items); stale records roll back the batch with 409 and the current server values.
+func saveWorksheetHandler(sectionKey, successMessage string) http.HandlerFunc {
+	section := mustSection(sectionKey)
+	return func(w http.ResponseWriter, r *http.Request) {
+		db, err := getDBConnection()
+		if err != nil {
+			log.Printf("DB connection error: %v", err)
+			http.Error(w, "DB connection error", http.StatusInternalServerError)
+			return
+		}
+		defer db.Close()
+
+		var data []WorksheetItem
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			log.Printf("Error decoding request body: %v", err)
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		recordErrors, err := validateWorksheetItems(db, data)
+		if err != nil {
+			log.Printf("Error validating %s payload: %v", section.Key, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if len(recordErrors) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":   "Payload failed validation; nothing was saved",
+				"records": recordErrors,
+				"saved":   []savedItem{},
+			})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			log.Printf("Error starting transaction: %v", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		authorID := middleware.CallerID(r)
+		autoComments := r.URL.Query().Get("auto_comments") == "true"
+		saved := []savedItem{}
+		conflicts := []*VersionConflictError{}
+		for _, record := range data {
+			var usedComments []int
+			if autoComments {
+				if record, usedComments, err = applyDefaultComments(tx, section, record, authorID); err != nil {
+					log.Printf("Error applying default comments to %s item %q: %v", section.Key, record.ItemName, err)
+					http.Error(w, "Database error", http.StatusInternalServerError)
+					return
+				}
+			}
+
+			stored, outcome, err := saveWorksheetItem(tx, section, record, authorID, "")
+			var conflict *VersionConflictError
+			if errors.As(err, &conflict) {
+				conflicts = append(conflicts, conflict)
+				continue
+			}
+			if errors.Is(err, ErrInspectionLocked) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				log.Printf("Error saving %s item %q: %v", section.Key, record.ItemName, err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			item := savedItem{ItemName: stored.ItemName, Version: stored.Version, Outcome: outcome}
+			if len(usedComments) > 0 {
+				if err := countCommentUse(tx, usedComments...); err != nil {
+					log.Printf("Error counting comment use: %v", err)
+					http.Error(w, "Database error", http.StatusInternalServerError)
+					return
+				}
+				item.Comments = &stored.Comments
+			}
+			saved = append(saved, item)
+		}
+
+		if len(conflicts) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     "Some items were changed by someone else; nothing was saved. Merge with the current values and retry",
+				"conflicts": conflicts,
+				"saved":     []savedItem{},
+			})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Error committing %s save: %v", section.Key, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": successMessage,
+			"saved":   saved,
+		})
+	}
+}
+
+// FieldChange is one entry of a revision diff. Field is "comments", "inspection_status",
+// "materials.<name>" or "conditions.<name>"; a nil From or To means the key was added or removed.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+func diffWorksheetItems(before, after *WorksheetItem) []FieldChange {
+	if before == nil {
+		before = &WorksheetItem{}
+	}
+	var changes []FieldChange
+
+	if before.InspectionStatus != after.InspectionStatus {
+		changes = append(changes, FieldChange{"inspection_status", before.InspectionStatus, after.InspectionStatus})
+	}
+	materialsBefore, materialsAfter := map[string]interface{}{}, map[string]interface{}{}
+	for k, v := range before.Materials {
+		materialsBefore[k] = v
+	}
+	for k, v := range after.Materials {
+		materialsAfter[k] = v
+	}
+	conditionsBefore, conditionsAfter := map[string]interface{}{}, map[string]interface{}{}
+	for k, v := range before.Conditions {
+		conditionsBefore[k] = v
+	}
+	for k, v := range after.Conditions {
+		conditionsAfter[k] = v
+	}
+
+	changes = append(changes, diffMaps("materials", materialsBefore, materialsAfter)...)
+	changes = append(changes, diffMaps("conditions", conditionsBefore, conditionsAfter)...)
+	if before.Comments != after.Comments {
+		changes = append(changes, FieldChange{"comments", before.Comments, after.Comments})
+	}
+	return changes
+}
+
+func diffMaps(prefix string, before, after map[string]interface{}) []FieldChange {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, k := range sorted {
+		from, inBefore := before[k]
+		to, inAfter := after[k]
+		if inBefore && inAfter && from == to {
+			continue
+		}
+		changes = append(changes, FieldChange{prefix + "." + k, from, to})
+	}
+	return changes
+}
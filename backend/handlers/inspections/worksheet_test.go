@@ -0,0 +1,116 @@
+package inspections
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWorksheetItems(t *testing.T) {
+	base := WorksheetItem{
+		ItemName:         "Flashing",
+		Materials:        map[string]string{"type": "Metal"},
+		Conditions:       map[string]bool{"rusting": true},
+		Comments:         "Surface rust",
+		InspectionStatus: "Inspected",
+	}
+	tests := []struct {
+		name   string
+		before *WorksheetItem
+		after  WorksheetItem
+		want   []FieldChange
+	}{
+		{"unchanged", &base, base, nil},
+		{"new item", nil, WorksheetItem{InspectionStatus: "Inspected", Materials: map[string]string{"type": "Metal"}}, []FieldChange{
+			{"inspection_status", "", "Inspected"},
+			{"materials.type", nil, "Metal"},
+		}},
+		{"status and comments", &base, WorksheetItem{
+			Materials: base.Materials, Conditions: base.Conditions, Comments: "Replaced", InspectionStatus: "Repair or Replace",
+		}, []FieldChange{
+			{"inspection_status", "Inspected", "Repair or Replace"},
+			{"comments", "Surface rust", "Replaced"},
+		}},
+		{"conditions sorted", &base, WorksheetItem{
+			Materials: base.Materials, Conditions: map[string]bool{"separated": true, "rusting": false, "improper install": true},
+			Comments: base.Comments, InspectionStatus: base.InspectionStatus,
+		}, []FieldChange{
+			{"conditions.improper install", nil, true},
+			{"conditions.rusting", true, false},
+			{"conditions.separated", nil, true},
+		}},
+		{"material removed", &base, WorksheetItem{
+			Materials: map[string]string{}, Conditions: base.Conditions, Comments: base.Comments, InspectionStatus: base.InspectionStatus,
+		}, []FieldChange{
+			{"materials.type", "Metal", nil},
+		}},
+		{"deleted", &base, WorksheetItem{}, []FieldChange{
+			{"inspection_status", "Inspected", ""},
+			{"materials.type", "Metal", nil},
+			{"conditions.rusting", true, nil},
+			{"comments", "Surface rust", ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := tt.after
+			if got := diffWorksheetItems(tt.before, &after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveEmptyWorksheetItemNeverStored(t *testing.T) {
+	// The inspection and item lookups both come back empty, and nothing may be written
+	db := openFakeDB(t, &fakeDB{
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+			return nil, nil, nil
+		},
+	})
+
+	record := WorksheetItem{InspectionID: "A", ItemName: "Flashing"}
+	got, outcome, err := saveWorksheetItem(db, Sections[0], record, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != outcomeUnchanged {
+		t.Errorf("outcome = %q, want %q", outcome, outcomeUnchanged)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Errorf("got %+v, want %+v", got, record)
+	}
+}
+
+func TestValidateWorksheetItemsStatus(t *testing.T) {
+	db := openFakeDB(t, &fakeDB{
+		query: func(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+			return []string{"exists"}, [][]driver.Value{{true}}, nil
+		},
+	})
+
+	tests := []struct {
+		status  string
+		want    string
+		wantErr bool
+	}{
+		{"", "Not Inspected", false},
+		{"Inspected", "Inspected", false},
+		{"repair or replace", "Repair or Replace", false},
+		{" NOT PRESENT ", "Not Present", false},
+		{"Broken", "Broken", true},
+	}
+	for _, tt := range tests {
+		data := []WorksheetItem{{InspectionID: "A", ItemName: "Flashing", InspectionStatus: tt.status}}
+		recordErrors, err := validateWorksheetItems(db, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(recordErrors) > 0) != tt.wantErr {
+			t.Errorf("status %q: errors = %v, want error %v", tt.status, recordErrors, tt.wantErr)
+		}
+		if data[0].InspectionStatus != tt.want {
+			t.Errorf("status %q: stored as %q, want %q", tt.status, data[0].InspectionStatus, tt.want)
+		}
+	}
+}
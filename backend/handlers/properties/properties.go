@@ -3,6 +3,7 @@ package properties
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -69,30 +70,60 @@ func SaveAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the address already exists
+	propertyID, created, err := FindOrCreateProperty(db, address)
+	if err != nil {
+		log.Println("Error saving address:", err)
+		http.Error(w, "Failed to save address", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		log.Println("Address already exists with property_id:", propertyID)
+	}
+
+	inspectorID, err := inspections.CallerInspectorID(db, r)
+	if err != nil {
+		log.Println("Error looking up inspector:", err)
+		http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
+		return
+	}
+	inspectionID, err := inspections.CreateInspectionHelper(db, propertyID, "", inspectorID)
+	if err != nil {
+		log.Println("Error creating inspection form:", err)
+		http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"message": "Inspection form created successfully", "property_id": "%s", "inspection_id": "%s"}`, propertyID, inspectionID)))
+
+}
+
+// execQuerier is satisfied by both *sql.DB and *sql.Tx
+type execQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// FindOrCreateProperty returns the property at the given address, creating it if there is none.
+// A trashed property at the same address is brought back rather than duplicated. created reports
+// whether a new property was inserted.
+func FindOrCreateProperty(db execQuerier, address AddressDetails) (string, bool, error) {
 	var existingPropertyID string
-	checkExistingQuery := `SELECT property_id FROM properties
+	var trashed bool
+	checkExistingQuery := `SELECT property_id, deleted_at IS NOT NULL FROM properties
 	                       WHERE street = ? AND city = ? AND state = ? AND postal_code = ? AND postal_code_suffix = ? AND country = ?`
-	err = db.QueryRow(checkExistingQuery, address.Street, address.City, address.State, address.PostalCode, address.PostalCodeSuffix, address.Country).Scan(&existingPropertyID)
+	err := db.QueryRow(checkExistingQuery, address.Street, address.City, address.State, address.PostalCode, address.PostalCodeSuffix, address.Country).Scan(&existingPropertyID, &trashed)
 	if err != nil && err != sql.ErrNoRows {
-		log.Println("Error checking for existing address:", err)
-		http.Error(w, "Failed to validate address uniqueness", http.StatusInternalServerError)
-		return
+		return "", false, fmt.Errorf("failed to validate address uniqueness: %v", err)
 	}
 
 	if existingPropertyID != "" {
-		log.Println("Address already exists with property_id:", existingPropertyID)
-
-		inspectionID, err := inspections.CreateInspectionHelper(db, existingPropertyID, "")
-		if err != nil {
-			log.Println("Error creating inspection form:", err)
-			http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
-			return
+		if trashed {
+			if _, err := db.Exec(`UPDATE properties SET deleted_at = NULL WHERE property_id = ?`, existingPropertyID); err != nil {
+				return "", false, fmt.Errorf("failed to restore trashed property: %v", err)
+			}
 		}
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(fmt.Sprintf(`{"message": "Inspection form created successfully", "property_id": "%s", "inspection_id": "%s"}`, existingPropertyID, inspectionID)))
-		return
+		return existingPropertyID, false, nil
 	}
 
 	// Generate new property_id for the new address
@@ -100,35 +131,29 @@ func SaveAddress(w http.ResponseWriter, r *http.Request) {
 	checkIncrementQuery := `SELECT COALESCE(MAX(CAST(SUBSTRING(property_id, 8, 4) AS UNSIGNED)), 0) AS max_increment
 	                        FROM properties
 	                        WHERE postal_code = ? AND state = ?`
-	err = db.QueryRow(checkIncrementQuery, address.PostalCode, address.State).Scan(&maxIncrement)
-	if err != nil {
-		log.Println("Error checking for max increment:", err)
-		http.Error(w, "Failed to generate property_id", http.StatusInternalServerError)
-		return
+	if err := db.QueryRow(checkIncrementQuery, address.PostalCode, address.State).Scan(&maxIncrement); err != nil {
+		return "", false, fmt.Errorf("failed to generate property_id: %v", err)
 	}
-
-	newIncrement := maxIncrement + 1
-	propertyID := fmt.Sprintf("%s%s%04d", address.State, address.PostalCode, newIncrement)
+	propertyID := fmt.Sprintf("%s%s%04d", address.State, address.PostalCode, maxIncrement+1)
 
 	insertQuery := `INSERT INTO properties (property_id, street, city, state, postal_code, postal_code_suffix, country)
 	                VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err = db.Exec(insertQuery, propertyID, address.Street, address.City, address.State, address.PostalCode, address.PostalCodeSuffix, address.Country)
-	if err != nil {
-		log.Println("Error executing query:", err)
-		http.Error(w, "Failed to save address", http.StatusInternalServerError)
-		return
-	}
-
-	inspectionID, err := inspections.CreateInspectionHelper(db, propertyID, "")
-	if err != nil {
-		log.Println("Error creating inspection form:", err)
-		http.Error(w, "Failed to create inspection form", http.StatusInternalServerError)
-		return
+	if _, err := db.Exec(insertQuery, propertyID, address.Street, address.City, address.State, address.PostalCode, address.PostalCodeSuffix, address.Country); err != nil {
+		return "", false, err
 	}
+	return propertyID, true, nil
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf(`{"message": "Inspection form created successfully", "property_id": "%s", "inspection_id": "%s"}`, propertyID, inspectionID)))
-
+// FillPropertyDetails sets the details that are still empty on a property; values already
+// recorded are never overwritten.
+func FillPropertyDetails(db execQuerier, property PropertyDetails) error {
+	_, err := db.Exec(`UPDATE properties SET year_built = COALESCE(year_built, ?), square_footage = COALESCE(square_footage, ?),
+	                   bedrooms = COALESCE(bedrooms, ?), bathrooms = COALESCE(bathrooms, ?), lot_size = COALESCE(lot_size, ?),
+	                   property_type = COALESCE(property_type, ?), latitude = COALESCE(latitude, ?), longitude = COALESCE(longitude, ?)
+	                   WHERE property_id = ?`,
+		property.YearBuilt, property.SquareFootage, property.Bedrooms, property.Bathrooms, property.LotSize, property.PropertyType,
+		property.Latitude, property.Longitude, property.PropertyID)
+	return err
 }
 
 func GetAddressByPropertyID(w http.ResponseWriter, r *http.Request) {
@@ -177,7 +202,7 @@ func GetAddressByPropertyID(w http.ResponseWriter, r *http.Request) {
 	// Fetch the address details from the database
 	var address AddressDetails
 	query = `SELECT property_id, street, city, state, postal_code, postal_code_suffix, country
-              FROM properties WHERE property_id = ?`
+              FROM properties WHERE property_id = ? AND deleted_at IS NULL`
 	err = db.QueryRow(query, propertyID).Scan(&address.PropertyID, &address.Street, &address.City, &address.State, &address.PostalCode, &address.PostalCodeSuffix, &address.Country)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -204,6 +229,8 @@ type PropertyDetails struct {
 	Bathrooms     *float32 `json:"bathrooms"`
 	LotSize       *float64 `json:"lot_size"`
 	PropertyType  *string  `json:"property_type"`
+	Latitude      *float64 `json:"latitude"` // used to verify inspector check-ins
+	Longitude     *float64 `json:"longitude"`
 }
 
 func SaveOrUpdateProperty(w http.ResponseWriter, r *http.Request) {
@@ -249,9 +276,10 @@ func SaveOrUpdateProperty(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodPost {
 		// Insert the property
-		insertQuery := `INSERT INTO properties (property_id, year_built, square_footage, bedrooms, bathrooms, lot_size, property_type)
-		                VALUES (?, ?, ?, ?, ?, ?, ?)`
-		_, err := db.Exec(insertQuery, property.PropertyID, property.YearBuilt, property.SquareFootage, property.Bedrooms, property.Bathrooms, property.LotSize, property.PropertyType)
+		insertQuery := `INSERT INTO properties (property_id, year_built, square_footage, bedrooms, bathrooms, lot_size, property_type, latitude, longitude)
+		                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := db.Exec(insertQuery, property.PropertyID, property.YearBuilt, property.SquareFootage, property.Bedrooms, property.Bathrooms, property.LotSize, property.PropertyType,
+			property.Latitude, property.Longitude)
 		if err != nil {
 			log.Println("Error inserting property:", err)
 			http.Error(w, "Failed to insert property", http.StatusInternalServerError)
@@ -262,9 +290,30 @@ func SaveOrUpdateProperty(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`{"message": "Property created successfully"}`))
 	} else if r.Method == http.MethodPut {
 		// Update the property
-		updateQuery := `UPDATE properties SET year_built = ?, square_footage = ?, bedrooms = ?, bathrooms = ?, lot_size = ?, property_type = ?
+		// Coordinates are only overwritten when sent, so older clients don't clear them
+		updateQuery := `UPDATE properties SET year_built = ?, square_footage = ?, bedrooms = ?, bathrooms = ?, lot_size = ?, property_type = ?,
+		                latitude = COALESCE(?, latitude), longitude = COALESCE(?, longitude)
 		                WHERE property_id = ?`
-		_, err := db.Exec(updateQuery, property.YearBuilt, property.SquareFootage, property.Bedrooms, property.Bathrooms, property.LotSize, property.PropertyType, property.PropertyID)
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Failed to update property", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		if err := inspections.EnsurePropertyEditable(tx, property.PropertyID); err != nil {
+			if errors.Is(err, inspections.ErrInspectionLocked) {
+				http.Error(w, "The property has a published report; start an amendment to change it", http.StatusConflict)
+			} else {
+				log.Println("Error checking property inspections:", err)
+				http.Error(w, "Failed to update property", http.StatusInternalServerError)
+			}
+			return
+		}
+		_, err = tx.Exec(updateQuery, property.YearBuilt, property.SquareFootage, property.Bedrooms, property.Bathrooms, property.LotSize, property.PropertyType,
+			property.Latitude, property.Longitude, property.PropertyID)
+		if err == nil {
+			err = tx.Commit()
+		}
 		if err != nil {
 			log.Println("Error updating property:", err)
 			http.Error(w, "Failed to update property", http.StatusInternalServerError)
@@ -309,7 +358,7 @@ func GetPropertyDetails(w http.ResponseWriter, r *http.Request) {
 	var validationCount int
 	validationQuery := `SELECT COUNT(*) 
                         FROM inspections 
-                        WHERE property_id = ? AND inspection_id = ?`
+                        WHERE property_id = ? AND inspection_id = ? AND deleted_at IS NULL`
 	err = db.QueryRow(validationQuery, propertyID, inspectionID).Scan(&validationCount)
 	if err != nil {
 		log.Println("Error validating inspection ID:", err)
@@ -324,9 +373,10 @@ func GetPropertyDetails(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch property details
 	var property PropertyDetails
-	query := `SELECT property_id, year_built, square_footage, bedrooms, bathrooms, lot_size, property_type
-              FROM properties WHERE property_id = ?`
-	err = db.QueryRow(query, propertyID).Scan(&property.PropertyID, &property.YearBuilt, &property.SquareFootage, &property.Bedrooms, &property.Bathrooms, &property.LotSize, &property.PropertyType)
+	query := `SELECT property_id, year_built, square_footage, bedrooms, bathrooms, lot_size, property_type, latitude, longitude
+              FROM properties WHERE property_id = ? AND deleted_at IS NULL`
+	err = db.QueryRow(query, propertyID).Scan(&property.PropertyID, &property.YearBuilt, &property.SquareFootage, &property.Bedrooms, &property.Bathrooms, &property.LotSize, &property.PropertyType,
+		&property.Latitude, &property.Longitude)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Property not found", http.StatusNotFound)
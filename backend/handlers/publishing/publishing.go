@@ -0,0 +1,161 @@
+package publishing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"home_solutions/backend/handlers/compliance"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultSignOffStatement is used when the inspector certifies without writing their own statement
+const DefaultSignOffStatement = "I certify that I personally performed this inspection and that this report is a true and accurate record of my findings."
+
+type PublishRequest struct {
+	Standard       string `json:"standard,omitempty"`
+	OverrideReason string `json:"override_reason,omitempty"`
+	Certify        bool   `json:"certify"`             // the inspector's sign-off; required
+	Statement      string `json:"statement,omitempty"` // optional custom sign-off wording
+}
+
+// recordComplianceCheck keeps the report a publish decision was based on, with any override
+func recordComplianceCheck(tx *sql.Tx, report *compliance.Report, overrideReason string, userID *int) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	var reason interface{}
+	if overrideReason != "" {
+		reason = overrideReason
+	}
+	_, err = tx.Exec(`
+		INSERT INTO inspection_compliance_checks (inspection_id, standard, state, passed, report, override_reason, checked_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		report.InspectionID, report.Standard, report.State, report.Passed, reportJSON, reason, userID)
+	return err
+}
+
+// signerName returns the display name recorded with a sign-off
+func signerName(db querier, userID *int) (string, error) {
+	if userID == nil {
+		return "", nil
+	}
+	var first, last string
+	err := db.QueryRow(`SELECT first_name, last_name FROM users WHERE user_id = ?`, *userID).Scan(&first, &last)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return strings.TrimSpace(first + " " + last), err
+}
+
+// PublishInspection signs off an inspection and freezes it as a new report version once it meets its
+// standard of practice. If the completeness check fails, publishing is refused with the report unless
+// the request gives an override_reason; overrides are recorded with the report and who made them.
+// The first publish creates version 1; publishing an open amendment creates the next version.
+func PublishInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if !middleware.RequireStaff(w, r, "publish reports") {
+			return
+		}
+
+		var req PublishRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request payload", http.StatusBadRequest)
+				return
+			}
+		}
+		req.OverrideReason = strings.TrimSpace(req.OverrideReason)
+		req.Statement = strings.TrimSpace(req.Statement)
+		if !req.Certify {
+			http.Error(w, "Publishing requires the inspector's sign-off (certify: true)", http.StatusBadRequest)
+			return
+		}
+		if req.Statement == "" {
+			req.Statement = DefaultSignOffStatement
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Lock the inspection before checking it, so no worksheet edit lands between the check and the snapshot
+		var status sql.NullString
+		if err := tx.QueryRow(`SELECT status FROM inspections WHERE inspection_id = ? FOR UPDATE`, inspectionID).Scan(&status); err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if status.String == "published" {
+			http.Error(w, "Inspection is already published; start an amendment to change it", http.StatusConflict)
+			return
+		}
+
+		report, err := compliance.Check(tx, inspectionID, req.Standard)
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		case errors.Is(err, compliance.ErrNoRuleSet):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Printf("[Publish] Error checking compliance for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to check compliance", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Passed && req.OverrideReason == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":      "The inspection does not meet its standard of practice; complete the missing items or publish with an override_reason",
+				"compliance": report,
+			})
+			return
+		}
+
+		userID := middleware.CallerID(r)
+
+		overrideReason := ""
+		if !report.Passed {
+			overrideReason = req.OverrideReason
+		}
+		version, err := publishVersion(tx, inspectionID, report, req.Statement, userID)
+		if errors.Is(err, errAlreadyPublished) {
+			http.Error(w, "Inspection is already published; start an amendment to change it", http.StatusConflict)
+			return
+		}
+		if err == nil {
+			err = recordComplianceCheck(tx, report, overrideReason, userID)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[Publish] Error publishing %s: %v", inspectionID, err)
+			http.Error(w, "Failed to publish inspection", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("ETag", `"`+version.ContentHash+`"`)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":    "Inspection published",
+			"overridden": overrideReason != "",
+			"compliance": report,
+			"version":    version,
+		})
+	}
+}
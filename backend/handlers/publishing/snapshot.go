@@ -0,0 +1,308 @@
+package publishing
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"home_solutions/backend/handlers/compliance"
+	"home_solutions/backend/handlers/inspections"
+)
+
+// SnapshotSchemaVersion is bumped whenever the snapshot layout changes, so old hashes stay reproducible
+const SnapshotSchemaVersion = 3
+
+// querier matches both *sql.DB and *sql.Tx, so the snapshot can be read inside the publish transaction
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type SnapshotInspection struct {
+	InspectionID      string  `json:"inspection_id"`
+	ReportID          *string `json:"report_id"`
+	InspectionDate    *string `json:"inspection_date"`
+	Temperature       *int    `json:"temperature"`
+	Weather           *string `json:"weather"`
+	GroundCondition   *string `json:"ground_condition"`
+	RainLastThreeDays *bool   `json:"rain_last_three_days"`
+	RadonTest         *bool   `json:"radon_test"`
+	MoldTest          *bool   `json:"mold_test"`
+	SOPStandard       string  `json:"sop_standard"`
+}
+
+type SnapshotProperty struct {
+	PropertyID    string   `json:"property_id"`
+	Street        string   `json:"street"`
+	City          string   `json:"city"`
+	State         string   `json:"state"`
+	PostalCode    string   `json:"postal_code"`
+	Country       string   `json:"country"`
+	YearBuilt     *int     `json:"year_built"`
+	SquareFootage *int     `json:"square_footage"`
+	Bedrooms      *int     `json:"bedrooms"`
+	Bathrooms     *float64 `json:"bathrooms"`
+	PropertyType  *string  `json:"property_type"`
+}
+
+type SnapshotSection struct {
+	Key   string                      `json:"key"`
+	Title string                      `json:"title"`
+	Items []inspections.WorksheetItem `json:"items"`
+}
+
+// SnapshotPhoto records the file digest so a swapped image is detectable even though files live outside the DB
+type SnapshotPhoto struct {
+	PhotoID  int    `json:"photo_id"`
+	ItemName string `json:"item_name"`
+	URL      string `json:"photo_url"`
+	SHA256   string `json:"sha256"`
+}
+
+// SnapshotCover is the property photo on the report's cover, with its file digest like SnapshotPhoto
+type SnapshotCover struct {
+	URL    string `json:"photo_url"`
+	SHA256 string `json:"sha256"`
+}
+
+// SnapshotCondition is a flagged condition whose derived defect was dismissed, so the report leaves it out
+type SnapshotCondition struct {
+	Section   string `json:"section"`
+	ItemName  string `json:"item_name"`
+	Condition string `json:"condition_key"`
+}
+
+type SnapshotHealthScore struct {
+	Score     float64            `json:"score"`
+	Breakdown map[string]float64 `json:"breakdown"`
+}
+
+// Snapshot is everything a published report shows, frozen at sign-off
+type Snapshot struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Inspection    SnapshotInspection   `json:"inspection"`
+	Property      SnapshotProperty     `json:"property"`
+	Sections      []SnapshotSection    `json:"sections"`
+	Photos        []SnapshotPhoto      `json:"photos"`
+	Cover         *SnapshotCover       `json:"cover,omitempty"` // since schema 3
+	Defects       []inspections.Defect `json:"defects"`
+	Dismissed     []SnapshotCondition  `json:"dismissed_conditions,omitempty"` // since schema 2
+	Analysis      *string              `json:"analysis"`
+	HealthScore   *SnapshotHealthScore `json:"health_score"`
+	Compliance    *compliance.Report   `json:"compliance"`
+	SignOff       SignOff              `json:"sign_off"`
+	Version       int                  `json:"version"`
+	Kind          string               `json:"kind"`
+	Amendment     *string              `json:"amendment_reason"`
+	PreviousHash  *string              `json:"previous_hash"`
+}
+
+// SignOff is the inspector's certification that the snapshot is their report
+type SignOff struct {
+	UserID        *int   `json:"user_id"`
+	InspectorName string `json:"inspector_name"`
+	Statement     string `json:"statement"`
+	SignedAt      string `json:"signed_at"` // UTC, "2006-01-02 15:04:05"
+}
+
+// hashFile returns the hex SHA-256 of a stored upload, or "" if the file is missing
+func hashFile(url string) string {
+	if !strings.HasPrefix(url, "/uploads/") {
+		return ""
+	}
+	f, err := os.Open("." + url)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BuildSnapshot reads the inspection's current report contents. Version and sign-off fields are set by the caller.
+func BuildSnapshot(db querier, inspectionID string) (*Snapshot, error) {
+	snap := &Snapshot{SchemaVersion: SnapshotSchemaVersion, Sections: []SnapshotSection{}, Photos: []SnapshotPhoto{}}
+	in := &snap.Inspection
+	var date, standard sql.NullString
+	var temperature sql.NullInt64
+	var rain, radon, mold sql.NullBool
+	err := db.QueryRow(`
+		SELECT inspection_id, property_id, report_id, DATE_FORMAT(inspection_date, '%Y-%m-%d'), temperature, weather,
+			ground_condition, rain_last_three_days, radon_test, mold_test, sop_standard
+		FROM inspections WHERE inspection_id = ?`, inspectionID).Scan(
+		&in.InspectionID, &snap.Property.PropertyID, &in.ReportID, &date, &temperature, &in.Weather,
+		&in.GroundCondition, &rain, &radon, &mold, &standard)
+	if err != nil {
+		return nil, err
+	}
+	if date.Valid {
+		in.InspectionDate = &date.String
+	}
+	if temperature.Valid {
+		t := int(temperature.Int64)
+		in.Temperature = &t
+	}
+	if rain.Valid {
+		in.RainLastThreeDays = &rain.Bool
+	}
+	if radon.Valid {
+		in.RadonTest = &radon.Bool
+	}
+	if mold.Valid {
+		in.MoldTest = &mold.Bool
+	}
+	in.SOPStandard = standard.String
+
+	p := &snap.Property
+	var yearBuilt, squareFootage, bedrooms sql.NullInt64
+	var bathrooms sql.NullFloat64
+	err = db.QueryRow(`
+		SELECT street, city, state, postal_code, country, year_built, square_footage, bedrooms, bathrooms, property_type
+		FROM properties WHERE property_id = ?`, p.PropertyID).Scan(
+		&p.Street, &p.City, &p.State, &p.PostalCode, &p.Country, &yearBuilt, &squareFootage, &bedrooms, &bathrooms, &p.PropertyType)
+	if err != nil {
+		return nil, fmt.Errorf("loading property: %v", err)
+	}
+	if yearBuilt.Valid {
+		v := int(yearBuilt.Int64)
+		p.YearBuilt = &v
+	}
+	if squareFootage.Valid {
+		v := int(squareFootage.Int64)
+		p.SquareFootage = &v
+	}
+	if bedrooms.Valid {
+		v := int(bedrooms.Int64)
+		p.Bedrooms = &v
+	}
+	if bathrooms.Valid {
+		p.Bathrooms = &bathrooms.Float64
+	}
+
+	for _, section := range inspections.Sections {
+		items, err := inspections.LoadWorksheetItems(db, section, inspectionID)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %v", section.Key, err)
+		}
+		if items == nil {
+			items = []inspections.WorksheetItem{}
+		}
+		snap.Sections = append(snap.Sections, SnapshotSection{Key: section.Key, Title: section.Title, Items: items})
+	}
+
+	rows, err := db.Query(`SELECT photo_id, item_name, photo_url FROM inspection_photos WHERE inspection_id = ? AND deleted_at IS NULL ORDER BY photo_id`, inspectionID)
+	if err != nil {
+		return nil, fmt.Errorf("loading photos: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var photo SnapshotPhoto
+		if err := rows.Scan(&photo.PhotoID, &photo.ItemName, &photo.URL); err != nil {
+			return nil, err
+		}
+		photo.SHA256 = hashFile(photo.URL)
+		snap.Photos = append(snap.Photos, photo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if snap.Cover, err = LoadCover(db, inspectionID); err != nil {
+		return nil, fmt.Errorf("loading cover photo: %v", err)
+	}
+
+	defects, err := inspections.LoadDefects(db, inspectionID, inspections.DefectOpen)
+	if err != nil {
+		return nil, fmt.Errorf("loading defects: %v", err)
+	}
+	if defects == nil {
+		defects = []inspections.Defect{}
+	}
+	snap.Defects = defects
+
+	dismissed, err := inspections.LoadDefects(db, inspectionID, inspections.DefectDismissed)
+	if err != nil {
+		return nil, fmt.Errorf("loading dismissed defects: %v", err)
+	}
+	for _, d := range dismissed {
+		if d.Source == "derived" && d.ConditionKey != nil {
+			snap.Dismissed = append(snap.Dismissed, SnapshotCondition{Section: d.Section, ItemName: d.ItemName, Condition: *d.ConditionKey})
+		}
+	}
+
+	var analysis string
+	err = db.QueryRow(`SELECT analysis_text FROM inspection_analysis WHERE inspection_id = ?`, inspectionID).Scan(&analysis)
+	if err == nil {
+		snap.Analysis = &analysis
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("loading analysis: %v", err)
+	}
+
+	var score float64
+	var breakdownJSON sql.NullString
+	err = db.QueryRow(`
+		SELECT score, breakdown FROM home_health_score
+		WHERE inspection_id = ?
+		ORDER BY updated_at DESC, id DESC LIMIT 1`, inspectionID).Scan(&score, &breakdownJSON)
+	if err == nil {
+		health := &SnapshotHealthScore{Score: score, Breakdown: map[string]float64{}}
+		if breakdownJSON.Valid && breakdownJSON.String != "" {
+			if err := json.Unmarshal([]byte(breakdownJSON.String), &health.Breakdown); err != nil {
+				return nil, fmt.Errorf("invalid health score breakdown: %v", err)
+			}
+		}
+		snap.HealthScore = health
+	} else if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("loading health score: %v", err)
+	}
+
+	return snap, nil
+}
+
+// LoadCover reads the inspection's current cover photo, the newest property photo not in the trash.
+// It returns nil when there is none.
+func LoadCover(db querier, inspectionID string) (*SnapshotCover, error) {
+	var url string
+	err := db.QueryRow(`SELECT photo_url FROM property_photos WHERE inspection_id = ? AND deleted_at IS NULL ORDER BY uploaded_at DESC LIMIT 1`, inspectionID).Scan(&url)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotCover{URL: url, SHA256: hashFile(url)}, nil
+}
+
+// PublishedCover returns the cover of a published snapshot. Versions published before the cover
+// was snapshotted fall back to the current cover photo.
+func PublishedCover(db querier, inspectionID string, snap *Snapshot) (*SnapshotCover, error) {
+	if snap.SchemaVersion >= 3 {
+		return snap.Cover, nil
+	}
+	return LoadCover(db, inspectionID)
+}
+
+// canonicalize returns the exact bytes that are stored and hashed for a snapshot
+func canonicalize(snap *Snapshot) ([]byte, string, error) {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// HashSnapshot recomputes the content hash of stored snapshot bytes
+func HashSnapshot(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,348 @@
+package publishing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/compliance"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+var errAlreadyPublished = errors.New("inspection is already published")
+
+// ReportVersion describes one immutable published snapshot of an inspection report
+type ReportVersion struct {
+	VersionID        int     `json:"version_id"`
+	InspectionID     string  `json:"inspection_id"`
+	Version          int     `json:"version"`
+	Kind             string  `json:"kind"`
+	AmendmentReason  *string `json:"amendment_reason"`
+	ContentHash      string  `json:"content_hash"`
+	PreviousHash     *string `json:"previous_hash"`
+	SignedOffBy      *int    `json:"signed_off_by"`
+	InspectorName    string  `json:"inspector_name"`
+	SignOffStatement string  `json:"signoff_statement"`
+	PublishedAt      string  `json:"published_at"` // UTC
+	Superseded       bool    `json:"superseded"`
+}
+
+const versionSelect = `
+	SELECT version_id, inspection_id, version, kind, amendment_reason, content_hash, previous_hash,
+		signed_off_by, COALESCE(inspector_name, ''), signoff_statement, created_at
+	FROM report_versions`
+
+func scanVersion(scanner interface{ Scan(...interface{}) error }) (ReportVersion, error) {
+	var v ReportVersion
+	var signedOffBy sql.NullInt64
+	err := scanner.Scan(&v.VersionID, &v.InspectionID, &v.Version, &v.Kind, &v.AmendmentReason, &v.ContentHash,
+		&v.PreviousHash, &signedOffBy, &v.InspectorName, &v.SignOffStatement, &v.PublishedAt)
+	if signedOffBy.Valid {
+		id := int(signedOffBy.Int64)
+		v.SignedOffBy = &id
+	}
+	return v, err
+}
+
+// LatestVersion returns the newest version number of an inspection's report, or 0 if it was never published
+func LatestVersion(db querier, inspectionID string) (int, error) {
+	var latest int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM report_versions WHERE inspection_id = ?`, inspectionID).Scan(&latest)
+	return latest, err
+}
+
+// LoadVersionSnapshot decodes a stored report version; version 0 means the latest.
+// It returns sql.ErrNoRows when the inspection has no such version.
+func LoadVersionSnapshot(db *sql.DB, inspectionID string, version int) (*Snapshot, *ReportVersion, error) {
+	query := versionSelect + ` WHERE inspection_id = ? AND version = ?`
+	args := []interface{}{inspectionID, version}
+	if version == 0 {
+		query = versionSelect + ` WHERE inspection_id = ? ORDER BY version DESC LIMIT 1`
+		args = args[:1]
+	}
+	v, err := scanVersion(db.QueryRow(query, args...))
+	if err != nil {
+		return nil, nil, err
+	}
+	latest, err := LatestVersion(db, inspectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	v.Superseded = v.Version < latest
+
+	var data string
+	if err := db.QueryRow(`SELECT snapshot FROM report_versions WHERE version_id = ?`, v.VersionID).Scan(&data); err != nil {
+		return nil, nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(data), &snap); err != nil {
+		return nil, nil, err
+	}
+	return &snap, &v, nil
+}
+
+// publishVersion snapshots the inspection, stores it as the next report version and marks the inspection published.
+// It must run in the publish transaction so the snapshot matches what gets locked.
+func publishVersion(tx *sql.Tx, inspectionID string, report *compliance.Report, statement string, userID *int) (*ReportVersion, error) {
+	var status, amendmentReason sql.NullString
+	err := tx.QueryRow(`SELECT status, amendment_reason FROM inspections WHERE inspection_id = ? FOR UPDATE`, inspectionID).Scan(&status, &amendmentReason)
+	if err != nil {
+		return nil, err
+	}
+	if status.String == "published" {
+		return nil, errAlreadyPublished
+	}
+
+	version := &ReportVersion{InspectionID: inspectionID, Kind: "original", SignedOffBy: userID, SignOffStatement: statement}
+	var previousHash string
+	err = tx.QueryRow(`SELECT version, content_hash FROM report_versions WHERE inspection_id = ? ORDER BY version DESC LIMIT 1`,
+		inspectionID).Scan(&version.Version, &previousHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		version.Kind = "amendment"
+		version.PreviousHash = &previousHash
+		if amendmentReason.Valid {
+			version.AmendmentReason = &amendmentReason.String
+		}
+	}
+	version.Version++
+
+	if version.InspectorName, err = signerName(tx, userID); err != nil {
+		return nil, err
+	}
+	version.PublishedAt = time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	snap, err := BuildSnapshot(tx, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	snap.Inspection.SOPStandard = report.Standard
+	snap.Compliance = report
+	snap.Version = version.Version
+	snap.Kind = version.Kind
+	snap.Amendment = version.AmendmentReason
+	snap.PreviousHash = version.PreviousHash
+	snap.SignOff = SignOff{UserID: userID, InspectorName: version.InspectorName, Statement: statement, SignedAt: version.PublishedAt}
+
+	data, hash, err := canonicalize(snap)
+	if err != nil {
+		return nil, err
+	}
+	version.ContentHash = hash
+
+	res, err := tx.Exec(`
+		INSERT INTO report_versions (inspection_id, version, kind, amendment_reason, snapshot, content_hash, previous_hash,
+			signed_off_by, inspector_name, signoff_statement, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		inspectionID, version.Version, version.Kind, version.AmendmentReason, string(data), hash, version.PreviousHash,
+		userID, version.InspectorName, statement, version.PublishedAt)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	version.VersionID = int(id)
+
+	_, err = tx.Exec(`
+		UPDATE inspections SET status = 'published', sop_standard = ?, published_at = ?, amendment_reason = NULL
+		WHERE inspection_id = ?`, report.Standard, version.PublishedAt, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+type AmendmentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StartAmendment reopens a published inspection for edits. The published versions stay as they are;
+// publishing again creates an amendment version carrying the reason given here.
+func StartAmendment(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if !middleware.RequireStaff(w, r, "amend reports") {
+			return
+		}
+
+		var req AmendmentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			http.Error(w, "reason is required", http.StatusBadRequest)
+			return
+		}
+
+		res, err := db.Exec(`UPDATE inspections SET status = 'amending', amendment_reason = ? WHERE inspection_id = ? AND status = 'published'`,
+			req.Reason, inspectionID)
+		var affected int64
+		if err == nil {
+			affected, err = res.RowsAffected()
+		}
+		if err != nil {
+			log.Printf("[Publish] Error starting amendment for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to start amendment", http.StatusInternalServerError)
+			return
+		}
+		if affected == 0 {
+			var exists bool
+			if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspections WHERE inspection_id = ?)`, inspectionID).Scan(&exists); err != nil {
+				log.Printf("[Publish] Error checking inspection %s: %v", inspectionID, err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			if !exists {
+				http.Error(w, "Inspection not found", http.StatusNotFound)
+			} else {
+				http.Error(w, "Only published inspections can be amended", http.StatusConflict)
+			}
+			return
+		}
+
+		latest, err := LatestVersion(db, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":           "Amendment started",
+			"amends_version":    latest,
+			"next_version":      latest + 1,
+			"amendment_reason":  req.Reason,
+			"inspection_status": "amending",
+		})
+	}
+}
+
+// ListReportVersions lists every published version of an inspection report, newest first
+func ListReportVersions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		rows, err := db.Query(versionSelect+` WHERE inspection_id = ? ORDER BY version DESC`, inspectionID)
+		if err != nil {
+			log.Printf("[Publish] Error listing versions for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to fetch report versions", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		versions := []ReportVersion{}
+		for rows.Next() {
+			v, err := scanVersion(rows)
+			if err != nil {
+				http.Error(w, "Failed to read report versions", http.StatusInternalServerError)
+				return
+			}
+			v.Superseded = len(versions) > 0
+			versions = append(versions, v)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(versions)
+	}
+}
+
+// GetReportVersion serves the stored snapshot bytes of one version unchanged, so clients can hash
+// what they received and compare it with the ETag / X-Content-Hash header or the verification endpoint.
+// The version may be a number or "latest".
+func GetReportVersion(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		inspectionID := vars["inspection_id"]
+
+		var number int
+		var err error
+		if vars["version"] == "latest" {
+			number, err = LatestVersion(db, inspectionID)
+			if err != nil {
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+		} else if number, err = strconv.Atoi(vars["version"]); err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+
+		var snapshot, hash string
+		err = db.QueryRow(`SELECT snapshot, content_hash FROM report_versions WHERE inspection_id = ? AND version = ?`,
+			inspectionID, number).Scan(&snapshot, &hash)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Report version not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Publish] Error loading version %d of %s: %v", number, inspectionID, err)
+			http.Error(w, "Failed to fetch report version", http.StatusInternalServerError)
+			return
+		}
+
+		etag := `"` + hash + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Content-Hash", hash)
+		w.Header().Set("X-Report-Version", strconv.Itoa(number))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(snapshot))
+	}
+}
+
+// VerifyReport looks up a report by content hash. It is public so anyone holding a copy can check
+// that it is genuine, whether the stored snapshot still matches its hash, and whether it was superseded.
+func VerifyReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hash := strings.ToLower(mux.Vars(r)["content_hash"])
+
+		row := db.QueryRow(versionSelect+` WHERE content_hash = ?`, hash)
+		version, err := scanVersion(row)
+		w.Header().Set("Content-Type", "application/json")
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"verified": false, "error": "No published report has this hash"})
+			return
+		}
+		if err != nil {
+			log.Printf("[Publish] Error verifying %s: %v", hash, err)
+			http.Error(w, "Failed to verify report", http.StatusInternalServerError)
+			return
+		}
+
+		var snapshot, reportID sql.NullString
+		err = db.QueryRow(`SELECT snapshot FROM report_versions WHERE version_id = ?`, version.VersionID).Scan(&snapshot)
+		if err == nil {
+			err = db.QueryRow(`SELECT report_id FROM inspections WHERE inspection_id = ?`, version.InspectionID).Scan(&reportID)
+		}
+		if err != nil {
+			log.Printf("[Publish] Error verifying %s: %v", hash, err)
+			http.Error(w, "Failed to verify report", http.StatusInternalServerError)
+			return
+		}
+		latest, err := LatestVersion(db, version.InspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		version.Superseded = version.Version < latest
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"verified":       HashSnapshot([]byte(snapshot.String)) == version.ContentHash,
+			"report_id":      reportID.String,
+			"version":        version,
+			"latest_version": latest,
+		})
+	}
+}
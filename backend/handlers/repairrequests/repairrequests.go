@@ -0,0 +1,678 @@
+package repairrequests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/agreements"
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/handlers/reports"
+	"home_solutions/backend/middleware"
+	"home_solutions/backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxCredit = 10000000
+
+	// Share links expire after defaultShareDays unless the caller picks between 1 and maxShareDays
+	defaultShareDays = 30
+	maxShareDays     = 365
+)
+
+var requestTypes = map[string]bool{"repair": true, "replace": true, "evaluate": true, "credit": true}
+
+// errNotPublished means there is no published report to build a request from
+var errNotPublished = errors.New("inspection has no published report")
+
+// ItemInput selects a finding by defect id, or by section and item (and optionally a condition)
+type ItemInput struct {
+	DefectID        *int     `json:"defect_id"`
+	Section         string   `json:"section"`
+	ItemName        string   `json:"item_name"`
+	ConditionKey    *string  `json:"condition_key"`
+	RequestType     string   `json:"request_type"`
+	RequestedAction string   `json:"requested_action"`
+	CreditAmount    *float64 `json:"credit_amount"`
+}
+
+type RequestInput struct {
+	Title string      `json:"title"`
+	Notes string      `json:"notes"`
+	Items []ItemInput `json:"items"`
+}
+
+// Summary is a repair request as listed for an inspection
+type Summary struct {
+	RequestID      string  `json:"request_id"`
+	InspectionID   string  `json:"inspection_id"`
+	Title          string  `json:"title"`
+	CurrentVersion int     `json:"current_version"`
+	TotalCredit    float64 `json:"total_credit"`
+	ShareStatus    string  `json:"share_status"` // active, expired or revoked
+	ShareExpiresAt *string `json:"share_expires_at"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+// authorizeRequest resolves a repair request to its inspection and checks the caller may use it
+func authorizeRequest(db *sql.DB, w http.ResponseWriter, r *http.Request, requestID string) (string, bool) {
+	var inspectionID string
+	err := db.QueryRow(`SELECT inspection_id FROM repair_requests WHERE request_id = ?`, requestID).Scan(&inspectionID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repair request not found", http.StatusNotFound)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return "", false
+	}
+	return inspectionID, agreements.CanViewReport(db, w, r, inspectionID)
+}
+
+// shareStatus is the SQL for a request's link state, matching Summary.ShareStatus
+const shareStatus = `CASE WHEN r.share_revoked_at IS NOT NULL THEN 'revoked'
+	WHEN r.share_expires_at IS NOT NULL AND r.share_expires_at <= UTC_TIMESTAMP() THEN 'expired'
+	ELSE 'active' END`
+
+// shareExpiry returns when a new share link made now for days days should stop working
+func shareExpiry(days int) string {
+	return time.Now().UTC().AddDate(0, 0, days).Format("2006-01-02 15:04:05")
+}
+
+// publishedDocument loads the latest published version of the report
+func publishedDocument(db *sql.DB, inspectionID string) (*reports.Document, error) {
+	latest, err := publishing.LatestVersion(db, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	if latest == 0 {
+		return nil, errNotPublished
+	}
+	return reports.LoadDocument(db, inspectionID, latest)
+}
+
+// resolveItems checks every selection against the published report and copies in its finding
+func resolveItems(doc *reports.Document, inputs []ItemInput) ([]reports.AddendumItem, float64, error) {
+	if len(inputs) == 0 {
+		return nil, 0, fmt.Errorf("select at least one defect or item")
+	}
+	var items []reports.AddendumItem
+	var total float64
+	seen := map[string]bool{}
+	for i, in := range inputs {
+		it := reports.AddendumItem{Position: i + 1}
+		if in.DefectID != nil {
+			var found *inspections.Defect
+			for j := range doc.Defects {
+				if doc.Defects[j].DefectID == *in.DefectID {
+					found = &doc.Defects[j]
+				}
+			}
+			if found == nil {
+				return nil, 0, fmt.Errorf("item %d: defect %d is not in the published report", i+1, *in.DefectID)
+			}
+			it.DefectID = &found.DefectID
+			it.Section, it.ItemName, it.ConditionKey = found.Section, found.ItemName, found.ConditionKey
+			it.Severity, it.Finding = found.Severity, found.Recommendation
+		} else {
+			item, ok := findItem(doc, in.Section, in.ItemName)
+			if !ok {
+				return nil, 0, fmt.Errorf("item %d: %s / %s is not in the published report", i+1, in.Section, in.ItemName)
+			}
+			it.Section, it.ItemName, it.Finding = in.Section, item.ItemName, item.Comments
+			it.Severity = inspections.ItemSeverity(item).String()
+			if in.ConditionKey != nil && *in.ConditionKey != "" {
+				if !item.Conditions[*in.ConditionKey] {
+					return nil, 0, fmt.Errorf("item %d: condition %q is not checked on %s", i+1, *in.ConditionKey, item.ItemName)
+				}
+				it.ConditionKey = in.ConditionKey
+				it.Severity = inspections.ConditionSeverity(*in.ConditionKey).String()
+			}
+		}
+		if section, ok := inspections.SectionByKey(it.Section); ok {
+			it.SectionTitle = section.Title
+		}
+
+		key := it.Section + "\x00" + it.ItemName + "\x00"
+		if it.ConditionKey != nil {
+			key += *it.ConditionKey
+		}
+		if seen[key] {
+			return nil, 0, fmt.Errorf("item %d: %s is selected twice", i+1, it.Reference())
+		}
+		seen[key] = true
+
+		it.RequestType = strings.ToLower(strings.TrimSpace(in.RequestType))
+		if !requestTypes[it.RequestType] {
+			return nil, 0, fmt.Errorf("item %d: request_type must be repair, replace, evaluate or credit", i+1)
+		}
+		it.RequestedAction = strings.TrimSpace(in.RequestedAction)
+		if it.RequestedAction == "" {
+			return nil, 0, fmt.Errorf("item %d: requested_action is required", i+1)
+		}
+		if in.CreditAmount != nil {
+			if *in.CreditAmount < 0 || *in.CreditAmount > maxCredit {
+				return nil, 0, fmt.Errorf("item %d: credit_amount is out of range", i+1)
+			}
+			total += *in.CreditAmount
+		} else if it.RequestType == "credit" {
+			return nil, 0, fmt.Errorf("item %d: credit requests need a credit_amount", i+1)
+		}
+		it.CreditAmount = in.CreditAmount
+		items = append(items, it)
+	}
+	return items, total, nil
+}
+
+func findItem(doc *reports.Document, sectionKey, itemName string) (inspections.WorksheetItem, bool) {
+	for _, s := range doc.Sections {
+		if s.Key != sectionKey {
+			continue
+		}
+		for _, item := range s.Items {
+			if strings.EqualFold(item.ItemName, itemName) {
+				return item, true
+			}
+		}
+	}
+	return inspections.WorksheetItem{}, false
+}
+
+func decodeInput(r *http.Request) (*RequestInput, error) {
+	var in RequestInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return nil, fmt.Errorf("invalid request body")
+	}
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		in.Title = "Repair request"
+	}
+	if len(in.Title) > 255 {
+		return nil, fmt.Errorf("title is limited to 255 characters")
+	}
+	return &in, nil
+}
+
+func insertVersion(tx *sql.Tx, requestID string, version int, in *RequestInput, items []reports.AddendumItem, total float64, reportVersion int, userID *int) error {
+	_, err := tx.Exec(`
+		INSERT INTO repair_request_versions (request_id, version, title, notes, report_version, total_credit, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`,
+		requestID, version, in.Title, strings.TrimSpace(in.Notes), reportVersion, total, userID)
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		// A defect removed by a later amendment is still referenced by section and item
+		defectID := it.DefectID
+		if defectID != nil {
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM defects WHERE defect_id = ?)`, *defectID).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				defectID = nil
+			}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO repair_request_items (request_id, version, position, defect_id, section, item_name, condition_key,
+			                                  severity, finding, request_type, requested_action, credit_amount)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			requestID, version, it.Position, defectID, it.Section, it.ItemName, it.ConditionKey,
+			it.Severity, it.Finding, it.RequestType, it.RequestedAction, it.CreditAmount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildItems loads the published report and resolves the request's selections, writing any error response
+func buildItems(db *sql.DB, w http.ResponseWriter, inspectionID string, in *RequestInput) ([]reports.AddendumItem, float64, int, bool) {
+	doc, err := publishedDocument(db, inspectionID)
+	if err == errNotPublished {
+		http.Error(w, "Repair requests can only be built from a published report", http.StatusConflict)
+		return nil, 0, 0, false
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Inspection not found", http.StatusNotFound)
+		return nil, 0, 0, false
+	}
+	if err != nil {
+		log.Printf("[RepairRequests] Error loading report for %s: %v", inspectionID, err)
+		http.Error(w, "Failed to load report", http.StatusInternalServerError)
+		return nil, 0, 0, false
+	}
+	items, total, err := resolveItems(doc, in.Items)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, 0, 0, false
+	}
+	return items, total, doc.Published.Version, true
+}
+
+// CreateRepairRequest starts a repair request from the latest published report of an inspection
+func CreateRepairRequest(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if !agreements.CanViewReport(db, w, r, inspectionID) {
+			return
+		}
+		in, err := decodeInput(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		items, total, reportVersion, ok := buildItems(db, w, inspectionID, in)
+		if !ok {
+			return
+		}
+
+		requestID := uuid.New().String()
+		token, hash, err := utils.NewLinkToken()
+		if err != nil {
+			http.Error(w, "Failed to save repair request", http.StatusInternalServerError)
+			return
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		_, err = tx.Exec(`
+			INSERT INTO repair_requests (request_id, inspection_id, created_by, share_token_hash, share_expires_at, current_version, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, 1, UTC_TIMESTAMP(), UTC_TIMESTAMP())`,
+			requestID, inspectionID, middleware.CallerID(r), hash, shareExpiry(defaultShareDays))
+		if err == nil {
+			err = insertVersion(tx, requestID, 1, in, items, total, reportVersion, middleware.CallerID(r))
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[RepairRequests] Error creating request for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to save repair request", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[RepairRequests] Created %s for inspection %s with %d items", requestID, inspectionID, len(items))
+		writeRequest(db, w, requestID, 0, token, http.StatusCreated)
+	}
+}
+
+// UpdateRepairRequest saves a new version of a repair request; earlier versions stay as they were
+func UpdateRepairRequest(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := mux.Vars(r)["request_id"]
+		inspectionID, ok := authorizeRequest(db, w, r, requestID)
+		if !ok {
+			return
+		}
+		in, err := decodeInput(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		items, total, reportVersion, ok := buildItems(db, w, inspectionID, in)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		var current int
+		err = tx.QueryRow(`SELECT current_version FROM repair_requests WHERE request_id = ? FOR UPDATE`, requestID).Scan(&current)
+		if err == nil {
+			err = insertVersion(tx, requestID, current+1, in, items, total, reportVersion, middleware.CallerID(r))
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE repair_requests SET current_version = ?, updated_at = UTC_TIMESTAMP() WHERE request_id = ?`, current+1, requestID)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[RepairRequests] Error updating %s: %v", requestID, err)
+			http.Error(w, "Failed to save repair request", http.StatusInternalServerError)
+			return
+		}
+		writeRequest(db, w, requestID, 0, "", http.StatusOK)
+	}
+}
+
+// ListRepairRequests returns the repair requests built from an inspection
+func ListRepairRequests(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if !agreements.CanViewReport(db, w, r, inspectionID) {
+			return
+		}
+		rows, err := db.Query(`
+			SELECT r.request_id, r.inspection_id, v.title, r.current_version, v.total_credit, `+shareStatus+`, r.share_expires_at,
+			       r.created_at, r.updated_at
+			FROM repair_requests r
+			JOIN repair_request_versions v ON v.request_id = r.request_id AND v.version = r.current_version
+			WHERE r.inspection_id = ?
+			ORDER BY r.created_at DESC`, inspectionID)
+		if err != nil {
+			log.Printf("[RepairRequests] Error listing requests for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to fetch repair requests", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		list := []Summary{}
+		for rows.Next() {
+			var s Summary
+			if err := rows.Scan(&s.RequestID, &s.InspectionID, &s.Title, &s.CurrentVersion, &s.TotalCredit, &s.ShareStatus, &s.ShareExpiresAt, &s.CreatedAt, &s.UpdatedAt); err != nil {
+				http.Error(w, "Failed to fetch repair requests", http.StatusInternalServerError)
+				return
+			}
+			list = append(list, s)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// loadAddendum assembles one version (0 = current) of a repair request with the report details it refers to
+func loadAddendum(db *sql.DB, requestID string, version int) (*reports.Addendum, *reports.Document, error) {
+	a := &reports.Addendum{RequestID: requestID, Items: []reports.AddendumItem{}}
+	var inspectionID string
+	var createdBy sql.NullString
+	err := db.QueryRow(`
+		SELECT r.inspection_id, v.version, v.title, v.notes, v.report_version, v.total_credit, v.created_at,
+		       CONCAT(u.first_name, ' ', u.last_name)
+		FROM repair_requests r
+		JOIN repair_request_versions v ON v.request_id = r.request_id AND v.version = IF(? > 0, ?, r.current_version)
+		LEFT JOIN users u ON u.user_id = v.created_by
+		WHERE r.request_id = ?`, version, version, requestID).Scan(
+		&inspectionID, &a.Version, &a.Title, &a.Notes, &a.ReportVersion, &a.TotalCredit, &a.CreatedAt, &createdBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.CreatedBy = createdBy.String
+
+	doc, err := reports.LoadDocument(db, inspectionID, a.ReportVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	a.ReportID = doc.Inspection.ReportID
+	a.ReportHash = doc.Published.ContentHash
+	a.Street = doc.Property.Street
+	a.City = strings.TrimSpace(fmt.Sprintf("%s, %s %s", doc.Property.City, doc.Property.State, doc.Property.PostalCode))
+	a.InspectionDate = doc.Inspection.InspectionDate
+	a.InspectorName = doc.InspectorName
+	a.CompanyName = doc.CompanyName
+
+	rows, err := db.Query(`
+		SELECT position, defect_id, section, item_name, condition_key, severity, finding, request_type, requested_action, credit_amount
+		FROM repair_request_items WHERE request_id = ? AND version = ? ORDER BY position`, requestID, a.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it reports.AddendumItem
+		var defectID sql.NullInt64
+		var credit sql.NullFloat64
+		if err := rows.Scan(&it.Position, &defectID, &it.Section, &it.ItemName, &it.ConditionKey, &it.Severity,
+			&it.Finding, &it.RequestType, &it.RequestedAction, &credit); err != nil {
+			return nil, nil, err
+		}
+		if defectID.Valid {
+			id := int(defectID.Int64)
+			it.DefectID = &id
+		}
+		if credit.Valid {
+			it.CreditAmount = &credit.Float64
+		}
+		it.SectionTitle = it.Section
+		if section, ok := inspections.SectionByKey(it.Section); ok {
+			it.SectionTitle = section.Title
+		}
+		a.Items = append(a.Items, it)
+	}
+	return a, doc, rows.Err()
+}
+
+func versionParam(r *http.Request) (int, bool) {
+	raw := r.URL.Query().Get("version")
+	if raw == "" {
+		return 0, true
+	}
+	v, err := strconv.Atoi(raw)
+	return v, err == nil && v > 0
+}
+
+// writeRequest responds with a version of the request, the list of its versions and the state of its
+// share link. Only the hash of the link token is stored, so token is passed in right after a link is
+// made and the links are left out otherwise.
+func writeRequest(db *sql.DB, w http.ResponseWriter, requestID string, version int, token string, status int) {
+	a, _, err := loadAddendum(db, requestID, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repair request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[RepairRequests] Error loading %s: %v", requestID, err)
+		http.Error(w, "Failed to load repair request", http.StatusInternalServerError)
+		return
+	}
+
+	var current int
+	var shareState string
+	var shareExpiresAt *string
+	err = db.QueryRow(`SELECT current_version, `+shareStatus+`, r.share_expires_at FROM repair_requests r WHERE r.request_id = ?`, requestID).
+		Scan(&current, &shareState, &shareExpiresAt)
+	if err != nil {
+		http.Error(w, "Failed to load repair request", http.StatusInternalServerError)
+		return
+	}
+	rows, err := db.Query(`SELECT version, title, total_credit, report_version, created_at FROM repair_request_versions WHERE request_id = ? ORDER BY version`, requestID)
+	if err != nil {
+		http.Error(w, "Failed to load repair request", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	versions := []map[string]interface{}{}
+	for rows.Next() {
+		var v, reportVersion int
+		var title, createdAt string
+		var total float64
+		if err := rows.Scan(&v, &title, &total, &reportVersion, &createdAt); err != nil {
+			http.Error(w, "Failed to load repair request", http.StatusInternalServerError)
+			return
+		}
+		versions = append(versions, map[string]interface{}{
+			"version": v, "title": title, "total_credit": total, "report_version": reportVersion, "created_at": createdAt,
+		})
+	}
+
+	resp := map[string]interface{}{
+		"addendum":         a,
+		"current_version":  current,
+		"versions":         versions,
+		"share_status":     shareState,
+		"share_expires_at": shareExpiresAt,
+	}
+	if token != "" {
+		resp["share_token"] = token
+		resp["share_pdf_url"] = "/api/shared-repair-requests/" + token + "/addendum.pdf"
+		resp["share_html_url"] = "/api/shared-repair-requests/" + token + "/addendum.html"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetRepairRequest returns the current version of a repair request, or ?version=N
+func GetRepairRequest(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := mux.Vars(r)["request_id"]
+		_, ok := authorizeRequest(db, w, r, requestID)
+		if !ok {
+			return
+		}
+		version, ok := versionParam(r)
+		if !ok {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		writeRequest(db, w, requestID, version, "", http.StatusOK)
+	}
+}
+
+func renderAddendum(db *sql.DB, w http.ResponseWriter, r *http.Request, requestID string) {
+	version, ok := versionParam(r)
+	if !ok {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+	a, doc, err := loadAddendum(db, requestID, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Repair request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[RepairRequests] Error loading %s: %v", requestID, err)
+		http.Error(w, "Failed to load repair request", http.StatusInternalServerError)
+		return
+	}
+	theme, err := reports.LoadTheme(db, doc.OrganizationID)
+	if err != nil {
+		log.Printf("[RepairRequests] Error loading theme for %s: %v", requestID, err)
+		http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if mux.Vars(r)["format"] == "pdf" {
+		err = reports.RenderAddendumPDF(&buf, a, theme)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="repair-request-%s-v%d.pdf"`, requestID, a.Version))
+	} else {
+		err = reports.RenderAddendumHTML(&buf, a, theme)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if err != nil {
+		log.Printf("[RepairRequests] Error rendering %s: %v", requestID, err)
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "Failed to render addendum", http.StatusInternalServerError)
+		return
+	}
+	w.Write(buf.Bytes())
+}
+
+// GetAddendum renders a repair request as PDF or HTML for signed-in users
+func GetAddendum(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := mux.Vars(r)["request_id"]
+		_, ok := authorizeRequest(db, w, r, requestID)
+		if !ok {
+			return
+		}
+		renderAddendum(db, w, r, requestID)
+	}
+}
+
+// ShareRepairRequest makes a new share link for a repair request and returns its token once.
+// An earlier link stops working. The optional body {"expires_in_days": N} sets how long the link lasts.
+func ShareRepairRequest(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := mux.Vars(r)["request_id"]
+		if _, ok := authorizeRequest(db, w, r, requestID); !ok {
+			return
+		}
+		var in struct {
+			ExpiresInDays *int `json:"expires_in_days"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		days := defaultShareDays
+		if in.ExpiresInDays != nil {
+			if *in.ExpiresInDays < 1 || *in.ExpiresInDays > maxShareDays {
+				http.Error(w, fmt.Sprintf("expires_in_days must be between 1 and %d", maxShareDays), http.StatusBadRequest)
+				return
+			}
+			days = *in.ExpiresInDays
+		}
+
+		token, hash, err := utils.NewLinkToken()
+		if err != nil {
+			http.Error(w, "Failed to create link", http.StatusInternalServerError)
+			return
+		}
+		_, err = db.Exec(`
+			UPDATE repair_requests SET share_token_hash = ?, share_expires_at = ?, share_revoked_at = NULL
+			WHERE request_id = ?`, hash, shareExpiry(days), requestID)
+		if err != nil {
+			log.Printf("[RepairRequests] Error sharing %s: %v", requestID, err)
+			http.Error(w, "Failed to create link", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[RepairRequests] New share link for %s, valid %d days", requestID, days)
+		writeRequest(db, w, requestID, 0, token, http.StatusOK)
+	}
+}
+
+// RevokeRepairRequestShare turns a repair request's share link off
+func RevokeRepairRequestShare(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := mux.Vars(r)["request_id"]
+		if _, ok := authorizeRequest(db, w, r, requestID); !ok {
+			return
+		}
+		if _, err := db.Exec(`UPDATE repair_requests SET share_revoked_at = COALESCE(share_revoked_at, UTC_TIMESTAMP()) WHERE request_id = ?`, requestID); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[RepairRequests] Revoked share link of %s", requestID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetSharedAddendum renders a repair request for anyone holding a live share link. The link only
+// opens the addendum, not the report it was built from.
+func GetSharedAddendum(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestID string
+		err := db.QueryRow(`
+			SELECT r.request_id FROM repair_requests r
+			JOIN inspections i ON i.inspection_id = r.inspection_id
+			WHERE r.share_token_hash = ? AND r.share_revoked_at IS NULL
+			  AND (r.share_expires_at IS NULL OR r.share_expires_at > UTC_TIMESTAMP()) AND i.deleted_at IS NULL`,
+			utils.HashLinkToken(mux.Vars(r)["token"])).Scan(&requestID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "This link is invalid, expired or revoked", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		// The token is in the URL; keep it out of shared caches and referrers
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		renderAddendum(db, w, r, requestID)
+	}
+}
@@ -0,0 +1,262 @@
+package reports
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// Addendum is one version of a repair request built from a published report, ready to render
+type Addendum struct {
+	RequestID      string         `json:"request_id"`
+	Title          string         `json:"title"`
+	Version        int            `json:"version"`
+	CreatedAt      string         `json:"created_at"` // UTC
+	CreatedBy      string         `json:"created_by"`
+	Notes          string         `json:"notes"`
+	ReportID       *string        `json:"report_id"`
+	ReportVersion  int            `json:"report_version"`
+	ReportHash     string         `json:"report_hash"`
+	Street         string         `json:"street"`
+	City           string         `json:"city"` // city, state and postal code
+	InspectionDate *string        `json:"inspection_date"`
+	InspectorName  string         `json:"inspector_name"`
+	CompanyName    string         `json:"company_name"`
+	Items          []AddendumItem `json:"items"`
+	TotalCredit    float64        `json:"total_credit"`
+}
+
+// AddendumItem is one requested action with the finding it refers to
+type AddendumItem struct {
+	Position        int      `json:"position"`
+	DefectID        *int     `json:"defect_id"`
+	Section         string   `json:"section"`
+	SectionTitle    string   `json:"section_title"`
+	ItemName        string   `json:"item_name"`
+	ConditionKey    *string  `json:"condition_key"`
+	Severity        string   `json:"severity"`
+	Finding         string   `json:"finding"` // the inspector's comment or recommendation
+	RequestType     string   `json:"request_type"`
+	RequestedAction string   `json:"requested_action"`
+	CreditAmount    *float64 `json:"credit_amount"`
+}
+
+// Reference names the finding the way the report does, e.g. "Roof - Flashing: Improper Flashing"
+func (it AddendumItem) Reference() string {
+	ref := it.SectionTitle + " - " + it.ItemName
+	if it.ConditionKey != nil && *it.ConditionKey != "" {
+		ref += ": " + *it.ConditionKey
+	}
+	return ref
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("$%.2f", v)
+}
+
+// RenderAddendumPDF writes the repair request as a PDF with the organization's branding
+func RenderAddendumPDF(w io.Writer, a *Addendum, theme *Theme) error {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	l := &pdfLayout{pdf: newPDF(fmt.Sprintf("%s (version %d)", a.Title, a.Version)), style: theme.pdfStyle()}
+	if l.style.LogoURL != "" {
+		if logo, err := l.pdf.loadImage(l.style.LogoURL); err == nil {
+			l.logo = logo
+		}
+	}
+
+	l.newPage()
+	band := 96.0
+	l.page.rect(0, 0, pageWidth, band, l.style.Primary)
+	if a.CompanyName != "" {
+		l.page.text(marginX, 34, fontBold, 10, white, a.CompanyName)
+	}
+	l.page.text(marginX, 62, fontBold, 22, white, "Repair Request Addendum")
+	l.page.text(marginX, 80, fontRegular, 10, white, fmt.Sprintf("%s - version %d", a.Title, a.Version))
+	if l.logo != nil {
+		w, h := fit(l.logo, 120, 60)
+		l.page.image(l.logo, marginX+contentWidth-w, (band-h)/2, w, h)
+	}
+	l.y = band + 24
+
+	rows := [][2]string{{"Property", a.Street + ", " + a.City}}
+	report := fmt.Sprintf("Version %d", a.ReportVersion)
+	if a.ReportID != nil {
+		report = *a.ReportID + ", " + strings.ToLower(report)
+	}
+	if a.InspectionDate != nil {
+		report += ", inspected " + *a.InspectionDate
+	}
+	rows = append(rows, [2]string{"Inspection report", report})
+	if a.InspectorName != "" {
+		rows = append(rows, [2]string{"Inspector", a.InspectorName})
+	}
+	rows = append(rows, [2]string{"Report hash", a.ReportHash})
+	rows = append(rows, [2]string{"Prepared", strings.TrimSpace(a.CreatedAt + " UTC " + byLine(a.CreatedBy))})
+	for _, row := range rows {
+		l.need(14)
+		l.page.text(marginX, l.y+9, fontBold, 9, l.style.Muted, row[0])
+		l.paragraph(marginX+110, contentWidth-110, fontRegular, 9, l.style.Text, row[1])
+		l.y += 2
+	}
+	l.y += 8
+	l.paragraph(marginX, contentWidth, fontRegular, 10, l.style.Text,
+		"The buyer requests that the seller address the following items identified in the inspection report "+
+			"referenced above, prior to closing and at the seller's expense, or provide the credits listed.")
+	l.y += 8
+
+	for _, it := range a.Items {
+		l.need(60)
+		l.page.line(marginX, l.y, marginX+contentWidth, l.y, 0.5, l.style.Rule)
+		l.y += 6
+		c, ok := severityColors[it.Severity]
+		if !ok {
+			c = severityColors["none"]
+		}
+		l.page.rect(marginX, l.y+2, 9, 9, c)
+		l.page.text(marginX+14, l.y+10, fontBold, 10.5, l.style.Text, fmt.Sprintf("%d.", it.Position))
+		if it.CreditAmount != nil {
+			credit := "Credit " + money(*it.CreditAmount)
+			l.page.text(marginX+contentWidth-textWidth(credit, fontBold, 10.5), l.y+10, fontBold, 10.5, l.style.Primary, credit)
+		}
+		l.paragraph(marginX+32, contentWidth-140, fontBold, 10.5, l.style.Text, it.Reference())
+		if it.Finding != "" {
+			l.paragraph(marginX+32, contentWidth-32, fontRegular, 9, l.style.Muted, "Finding: "+it.Finding)
+		}
+		l.paragraph(marginX+32, contentWidth-32, fontRegular, 10, l.style.Text,
+			"Request ("+it.RequestType+"): "+it.RequestedAction)
+		l.y += 8
+	}
+
+	l.need(30)
+	l.page.line(marginX, l.y, marginX+contentWidth, l.y, 1, l.style.Primary)
+	l.y += 6
+	total := "Total credit requested: " + money(a.TotalCredit)
+	l.page.text(marginX+contentWidth-textWidth(total, fontBold, 12), l.y+12, fontBold, 12, l.style.Text, total)
+	l.y += 28
+
+	if strings.TrimSpace(a.Notes) != "" {
+		l.subheading("Notes")
+		l.paragraph(marginX, contentWidth, fontRegular, 10, l.style.Text, a.Notes)
+		l.y += 10
+	}
+
+	// Acceptance block
+	l.need(120)
+	l.subheading("Acceptance")
+	for _, party := range []string{"Buyer", "Seller"} {
+		l.y += 26
+		l.page.line(marginX, l.y, marginX+260, l.y, 0.75, l.style.Text)
+		l.page.line(marginX+300, l.y, marginX+contentWidth, l.y, 0.75, l.style.Text)
+		l.page.text(marginX, l.y+11, fontRegular, 8.5, l.style.Muted, party+" signature")
+		l.page.text(marginX+300, l.y+11, fontRegular, 8.5, l.style.Muted, "Date")
+		l.y += 14
+	}
+
+	footer := fmt.Sprintf("Repair request %s, version %d - %s", a.RequestID, a.Version, a.Street)
+	pageCount := len(l.pdf.pages)
+	for i, page := range l.pdf.pages {
+		y := pageHeight - 40
+		page.line(marginX, y, marginX+contentWidth, y, 0.5, l.style.Rule)
+		page.text(marginX, y+14, fontRegular, 8, l.style.Muted, footer)
+		number := fmt.Sprintf("Page %d of %d", i+1, pageCount)
+		page.text(marginX+contentWidth-textWidth(number, fontRegular, 8), y+14, fontRegular, 8, l.style.Muted, number)
+	}
+	return l.pdf.writeTo(w)
+}
+
+func byLine(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "by " + name
+}
+
+type addendumView struct {
+	*Addendum
+	Style pdfStyle
+}
+
+var addendumTemplate = template.Must(template.New("addendum").Funcs(htmlFuncs).Funcs(template.FuncMap{
+	"money":      money,
+	"byLine":     byLine,
+	"derefFloat": func(f *float64) float64 { return *f },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Repair Request Addendum - {{.Title}}</title>
+<style>
+  body { margin: 0; font-family: Helvetica, Arial, sans-serif; color: {{css .Style.Text}}; background: #f4f4f4; }
+  .page { max-width: 840px; margin: 0 auto; background: #fff; padding-bottom: 32px; }
+  .band { background: {{css .Style.Primary}}; color: #fff; padding: 24px 40px; display: flex; justify-content: space-between; align-items: center; }
+  .band h1 { margin: 6px 0 2px; font-size: 26px; }
+  .band img { max-width: 150px; max-height: 70px; }
+  .content { padding: 0 40px; }
+  table.details td { padding: 3px 16px 3px 0; font-size: 14px; vertical-align: top; }
+  table.details td:first-child { font-weight: bold; color: {{css .Style.Muted}}; white-space: nowrap; }
+  table.items { width: 100%; border-collapse: collapse; margin-top: 16px; }
+  table.items th { text-align: left; border-bottom: 2px solid {{css .Style.Primary}}; padding: 6px; font-size: 13px; }
+  table.items td { border-bottom: 1px solid {{css .Style.Rule}}; padding: 8px 6px; vertical-align: top; font-size: 14px; }
+  .finding { color: {{css .Style.Muted}}; font-size: 13px; }
+  .num { text-align: right; white-space: nowrap; }
+  .dot { display: inline-block; width: 10px; height: 10px; margin-right: 6px; border-radius: 2px; }
+  .total { text-align: right; font-weight: bold; font-size: 16px; margin-top: 12px; }
+  .notes { white-space: pre-wrap; }
+  .signatures { display: grid; grid-template-columns: 2fr 1fr; gap: 36px 24px; margin-top: 36px; }
+  .signatures div { border-top: 1px solid {{css .Style.Text}}; padding-top: 4px; font-size: 12px; color: {{css .Style.Muted}}; }
+</style>
+</head>
+<body>
+<div class="page">
+  <div class="band">
+    <div>
+      {{if .CompanyName}}<div>{{.CompanyName}}</div>{{end}}
+      <h1>Repair Request Addendum</h1>
+      <div>{{.Title}} - version {{.Version}}</div>
+    </div>
+    {{if .Style.LogoURL}}<img src="{{.Style.LogoURL}}" alt="Company logo">{{end}}
+  </div>
+  <div class="content">
+    <table class="details" style="margin-top: 20px;">
+      <tr><td>Property</td><td>{{.Street}}, {{.City}}</td></tr>
+      <tr><td>Inspection report</td><td>{{with .ReportID}}{{deref .}}, {{end}}version {{.ReportVersion}}{{with .InspectionDate}}, inspected {{deref .}}{{end}}</td></tr>
+      {{if .InspectorName}}<tr><td>Inspector</td><td>{{.InspectorName}}</td></tr>{{end}}
+      <tr><td>Report hash</td><td style="word-break: break-all;">{{.ReportHash}}</td></tr>
+      <tr><td>Prepared</td><td>{{.CreatedAt}} UTC {{byLine .CreatedBy}}</td></tr>
+    </table>
+    <p>The buyer requests that the seller address the following items identified in the inspection report
+    referenced above, prior to closing and at the seller's expense, or provide the credits listed.</p>
+    <table class="items">
+      <tr><th>#</th><th>Finding</th><th>Request</th><th class="num">Credit</th></tr>
+      {{range .Items}}
+      <tr>
+        <td>{{.Position}}</td>
+        <td><i class="dot" style="background: {{severityColor .Severity}};"></i><strong>{{.Reference}}</strong>{{if .Finding}}<div class="finding">{{.Finding}}</div>{{end}}</td>
+        <td><strong>{{.RequestType}}</strong>: {{.RequestedAction}}</td>
+        <td class="num">{{with .CreditAmount}}{{money (derefFloat .)}}{{end}}</td>
+      </tr>
+      {{end}}
+    </table>
+    <div class="total">Total credit requested: {{money .TotalCredit}}</div>
+    {{if .Notes}}<h3>Notes</h3><div class="notes">{{.Notes}}</div>{{end}}
+    <div class="signatures">
+      <div>Buyer signature</div><div>Date</div>
+      <div>Seller signature</div><div>Date</div>
+    </div>
+  </div>
+</div>
+</body>
+</html>
+`))
+
+// RenderAddendumHTML writes the repair request as a standalone HTML page
+func RenderAddendumHTML(w io.Writer, a *Addendum, theme *Theme) error {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	return addendumTemplate.Execute(w, addendumView{Addendum: a, Style: theme.pdfStyle()})
+}
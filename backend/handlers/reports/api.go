@@ -0,0 +1,217 @@
+package reports
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/compliance"
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportFormat names the canonical report document. ReportFormatVersion is bumped on any change
+// that breaks readers, so exports and imports can check what they are holding.
+const (
+	ReportFormat        = "home_solutions.inspection_report"
+	ReportFormatVersion = 1
+)
+
+// Report is the whole inspection as one document, replacing the address, details, per-section
+// and photo calls a client otherwise makes to render a report
+type Report struct {
+	Format           string                          `json:"format"`
+	FormatVersion    int                             `json:"format_version"`
+	InspectionID     string                          `json:"inspection_id"`
+	Status           string                          `json:"status"`
+	Inspection       publishing.SnapshotInspection   `json:"inspection"`
+	Property         publishing.SnapshotProperty     `json:"property"`
+	Cover            ReportCover                     `json:"cover"`
+	Sections         []ReportSection                 `json:"sections"`
+	UnassignedPhotos []publishing.SnapshotPhoto      `json:"unassigned_photos"` // photos whose item name matches no item
+	Defects          []inspections.Defect            `json:"defects"`
+	Analysis         *string                         `json:"analysis"`
+	HealthScore      *publishing.SnapshotHealthScore `json:"health_score"`
+	Compliance       *compliance.Report              `json:"compliance"`
+	Version          *publishing.ReportVersion       `json:"version"` // nil while the report is a draft
+}
+
+type ReportCover struct {
+	ReportID       *string `json:"report_id"`
+	InspectionDate *string `json:"inspection_date"`
+	InspectorName  string  `json:"inspector_name"`
+	CompanyName    string  `json:"company_name"`
+	PhotoURL       *string `json:"photo_url"`
+}
+
+type ReportSection struct {
+	Key   string       `json:"key"`
+	Title string       `json:"title"`
+	Items []ReportItem `json:"items"`
+}
+
+type ReportItem struct {
+	inspections.WorksheetItem
+	Photos []publishing.SnapshotPhoto `json:"photos"`
+}
+
+// reportFields are the top-level fields ?fields= can select; the format and inspection id are always sent
+var reportFields = map[string]bool{
+	"status": true, "inspection": true, "property": true, "cover": true, "sections": true,
+	"unassigned_photos": true, "defects": true, "analysis": true, "health_score": true,
+	"compliance": true, "version": true,
+}
+
+// NewReport arranges a loaded document into the canonical report. Photos are attached to the
+// first item with their item name, in section order.
+func NewReport(doc *Document) *Report {
+	report := &Report{
+		Format:        ReportFormat,
+		FormatVersion: ReportFormatVersion,
+		InspectionID:  doc.Inspection.InspectionID,
+		Status:        doc.Status,
+		Inspection:    doc.Inspection,
+		Property:      doc.Property,
+		Cover: ReportCover{
+			ReportID:       doc.Inspection.ReportID,
+			InspectionDate: doc.Inspection.InspectionDate,
+			InspectorName:  doc.InspectorName,
+			CompanyName:    doc.CompanyName,
+			PhotoURL:       doc.CoverPhotoURL,
+		},
+		Sections:         []ReportSection{},
+		UnassignedPhotos: []publishing.SnapshotPhoto{},
+		Defects:          doc.Defects,
+		Analysis:         doc.Analysis,
+		HealthScore:      doc.HealthScore,
+		Compliance:       doc.Compliance,
+		Version:          doc.Published,
+	}
+	if report.Defects == nil {
+		report.Defects = []inspections.Defect{}
+	}
+
+	photos := map[string][]publishing.SnapshotPhoto{}
+	for _, p := range doc.Photos {
+		photos[p.ItemName] = append(photos[p.ItemName], p)
+	}
+	for _, s := range doc.Sections {
+		section := ReportSection{Key: s.Key, Title: s.Title, Items: make([]ReportItem, 0, len(s.Items))}
+		for _, item := range s.Items {
+			itemPhotos := photos[item.ItemName]
+			if itemPhotos == nil {
+				itemPhotos = []publishing.SnapshotPhoto{}
+			}
+			delete(photos, item.ItemName)
+			section.Items = append(section.Items, ReportItem{WorksheetItem: item, Photos: itemPhotos})
+		}
+		report.Sections = append(report.Sections, section)
+	}
+	// Keep the original photo order for the leftovers
+	for _, p := range doc.Photos {
+		if _, left := photos[p.ItemName]; left {
+			report.UnassignedPhotos = append(report.UnassignedPhotos, p)
+		}
+	}
+	return report
+}
+
+// selectFields trims the encoded report down to the requested top-level fields
+func selectFields(body []byte, fields []string) ([]byte, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(body, &all); err != nil {
+		return nil, err
+	}
+	selected := map[string]json.RawMessage{
+		"format":         all["format"],
+		"format_version": all["format_version"],
+		"inspection_id":  all["inspection_id"],
+	}
+	for _, f := range fields {
+		selected[f] = all[f]
+	}
+	return json.Marshal(selected)
+}
+
+// etagMatches reports whether an If-None-Match header covers etag
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetReport returns the whole inspection report as one JSON document.
+// ?fields=property,sections,... limits the top-level fields, ?version=N returns a published version.
+// The ETag is the hash of the response body, so an unchanged report answers If-None-Match with 304.
+func GetReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		version, err := parseVersion(r)
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+
+		var fields []string
+		if raw := r.URL.Query().Get("fields"); raw != "" {
+			for _, f := range strings.Split(raw, ",") {
+				f = strings.TrimSpace(f)
+				if f == "" {
+					continue
+				}
+				if !reportFields[f] {
+					http.Error(w, "Unknown field: "+f, http.StatusBadRequest)
+					return
+				}
+				fields = append(fields, f)
+			}
+		}
+
+		doc, err := LoadDocument(db, inspectionID, version)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Reports] Error loading report for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := json.Marshal(NewReport(doc))
+		if err == nil && len(fields) > 0 {
+			body, err = selectFields(body, fields)
+		}
+		if err != nil {
+			log.Printf("[Reports] Error encoding report for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to encode report", http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if doc.Published != nil {
+			w.Header().Set("X-Content-Hash", doc.Published.ContentHash)
+			w.Header().Set("X-Report-Version", strconv.Itoa(doc.Published.Version))
+		}
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
@@ -0,0 +1,89 @@
+package reports
+
+import (
+	"database/sql"
+
+	"home_solutions/backend/handlers/publishing"
+)
+
+// Document is one inspection report with everything a renderer needs. Published inspections are
+// rendered from their signed snapshot so the output matches the hashed record; drafts are read live.
+type Document struct {
+	publishing.Snapshot
+	Status         string                    `json:"status"`
+	CoverPhotoURL  *string                   `json:"cover_photo_url"`
+	InspectorName  string                    `json:"inspector_name"`
+	CompanyName    string                    `json:"company_name"`
+	OrganizationID *int                      `json:"organization_id"` // picks the report theme
+	Published      *publishing.ReportVersion `json:"published"`       // nil for a live draft
+	dismissed      map[string]bool           // flagged conditions whose derived defect was dismissed
+	// ThumbnailURL links a photo's thumbnail in rendered output; nil uses the inspection's
+	// thumbnail endpoint
+	ThumbnailURL func(photoID int) string `json:"-"`
+}
+
+// LoadDocument assembles the report for an inspection. version 0 renders the current report: the
+// latest published version while the inspection is published, otherwise the live draft.
+// A positive version always renders that published version.
+func LoadDocument(db *sql.DB, inspectionID string, version int) (*Document, error) {
+	doc := &Document{}
+	var status, inspectorName, companyName sql.NullString
+	var orgID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT i.status, CONCAT(u.first_name, ' ', u.last_name), COALESCE(o.name, ins.company_name), ins.organization_id
+		FROM inspections i
+		LEFT JOIN inspectors ins ON ins.inspector_id = i.inspector_id
+		LEFT JOIN users u ON u.user_id = ins.user_id
+		LEFT JOIN organizations o ON o.organization_id = ins.organization_id
+		WHERE i.inspection_id = ? AND i.deleted_at IS NULL`, inspectionID).Scan(&status, &inspectorName, &companyName, &orgID)
+	if err != nil {
+		return nil, err
+	}
+	doc.Status = status.String
+	doc.InspectorName = inspectorName.String
+	doc.CompanyName = companyName.String
+	if orgID.Valid {
+		id := int(orgID.Int64)
+		doc.OrganizationID = &id
+	}
+
+	if version > 0 || doc.Status == "published" {
+		// LoadVersionSnapshot treats 0 as the latest version
+		snap, v, err := publishing.LoadVersionSnapshot(db, inspectionID, version)
+		if err != nil {
+			return nil, err
+		}
+		doc.Snapshot = *snap
+		doc.Published = v
+		if v.InspectorName != "" {
+			doc.InspectorName = v.InspectorName
+		}
+	} else {
+		snap, err := publishing.BuildSnapshot(db, inspectionID)
+		if err != nil {
+			return nil, err
+		}
+		doc.Snapshot = *snap
+	}
+
+	if doc.Published != nil && doc.SchemaVersion >= 2 {
+		doc.dismissed = map[string]bool{}
+		for _, c := range doc.Snapshot.Dismissed {
+			doc.dismissed[conditionKey(c.Section, c.ItemName, c.Condition)] = true
+		}
+	} else if doc.dismissed, err = loadDismissed(db, inspectionID); err != nil {
+		// Drafts, and versions published before dismissals were snapshotted, read the live table
+		return nil, err
+	}
+
+	cover := doc.Snapshot.Cover
+	if doc.Published != nil {
+		if cover, err = publishing.PublishedCover(db, inspectionID, &doc.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	if cover != nil {
+		doc.CoverPhotoURL = &cover.URL
+	}
+	return doc, nil
+}
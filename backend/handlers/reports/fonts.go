@@ -0,0 +1,42 @@
+package reports
+
+// Advance widths (1/1000 em) of the standard Helvetica fonts for WinAnsi codes 32-126,
+// from the Adobe Font Metrics files. Other codes fall back to glyphWidth's table.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space - /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 - ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ - O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P - _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` - o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p - ~
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// Punctuation outside ASCII that reports commonly contain; accented letters are close to 556
+var extendedWidths = map[byte]int{
+	0x85: 1000,                                 // ellipsis
+	0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, // curly quotes
+	0x95: 350,  // bullet
+	0x96: 556,  // en dash
+	0x97: 1000, // em dash
+	0xa0: 278,  // no-break space
+	0xb0: 400,  // degree
+}
+
+func glyphWidth(widths [95]int, c byte) int {
+	if c >= 32 && c <= 126 {
+		return widths[c-32]
+	}
+	if w, ok := extendedWidths[c]; ok {
+		return w
+	}
+	return 556
+}
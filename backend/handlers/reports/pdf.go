@@ -0,0 +1,321 @@
+package reports
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A minimal PDF 1.4 writer: Letter pages, the two standard Helvetica fonts (no embedding),
+// JPEG/PNG/GIF images and internal links. Enough for reports without a third-party library.
+
+const (
+	pageWidth  = 612.0
+	pageHeight = 792.0
+)
+
+// Font resource names used in content streams
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+type rgb struct{ R, G, B float64 }
+
+func hexColor(s string, fallback rgb) rgb {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	var r, g, b uint8
+	if len(s) != 6 {
+		return fallback
+	}
+	if _, err := fmt.Sscanf(s, "%02x%02x%02x", &r, &g, &b); err != nil {
+		return fallback
+	}
+	return rgb{float64(r) / 255, float64(g) / 255, float64(b) / 255}
+}
+
+type pdfLink struct {
+	x, y, w, h float64 // top-left based, like the layout
+	page       int     // 0-based target page
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+type pdfImage struct {
+	name       string
+	width      int
+	height     int
+	colorSpace string
+	filter     string
+	data       []byte
+}
+
+type pdfDoc struct {
+	title  string
+	pages  []*pdfPage
+	images []*pdfImage
+	byPath map[string]*pdfImage
+}
+
+func newPDF(title string) *pdfDoc {
+	return &pdfDoc{title: title, byPath: map[string]*pdfImage{}}
+}
+
+func (d *pdfDoc) addPage() *pdfPage {
+	p := &pdfPage{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// winAnsi converts text to the WinAnsiEncoding bytes the standard fonts use; anything else becomes '?'
+func winAnsi(s string) []byte {
+	special := map[rune]byte{
+		'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+		'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+	}
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			if b, ok := special[r]; ok {
+				out = append(out, b)
+			} else if r >= 0x20 {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+func pdfString(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// textWidth measures s in points for the given font and size
+func textWidth(s, font string, size float64) float64 {
+	widths := helveticaWidths
+	if font == fontBold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range winAnsi(s) {
+		total += glyphWidth(widths, c)
+	}
+	return float64(total) * size / 1000
+}
+
+// Drawing primitives. y is measured from the top of the page and converted here.
+
+func (p *pdfPage) text(x, y float64, font string, size float64, c rgb, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.3f %.3f %.3f rg %.2f %.2f Td %s Tj ET\n",
+		font, size, c.R, c.G, c.B, x, pageHeight-y, pdfString(winAnsi(s)))
+}
+
+func (p *pdfPage) rect(x, y, w, h float64, fill rgb) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", fill.R, fill.G, fill.B, x, pageHeight-y-h, w, h)
+}
+
+func (p *pdfPage) strokeRect(x, y, w, h, width float64, c rgb) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f RG %.2f w %.2f %.2f %.2f %.2f re S\n", c.R, c.G, c.B, width, x, pageHeight-y-h, w, h)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2, width float64, c rgb) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S\n", c.R, c.G, c.B, width, x1, pageHeight-y1, x2, pageHeight-y2)
+}
+
+func (p *pdfPage) image(img *pdfImage, x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, pageHeight-y-h, img.name)
+}
+
+func (p *pdfPage) link(x, y, w, h float64, page int) {
+	p.links = append(p.links, pdfLink{x, y, w, h, page})
+}
+
+// maxImagePixels bounds the longest side of re-encoded images so reports stay a reasonable size
+const maxImagePixels = 1600
+
+// loadImage reads an upload into an image XObject. JPEGs are embedded as-is; other formats are
+// decoded, downscaled if large, and stored as compressed RGB. Results are cached per path.
+func (d *pdfDoc) loadImage(url string) (*pdfImage, error) {
+	if img, ok := d.byPath[url]; ok {
+		return img, nil
+	}
+	if !strings.HasPrefix(url, "/uploads/") || strings.Contains(url, "..") {
+		return nil, fmt.Errorf("not an upload: %s", url)
+	}
+	data, err := os.ReadFile("." + url)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &pdfImage{name: fmt.Sprintf("Im%d", len(d.images)+1)}
+	if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil && (cfg.ColorModel == color.YCbCrModel || cfg.ColorModel == color.GrayModel) {
+		img.width, img.height = cfg.Width, cfg.Height
+		img.colorSpace = "DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			img.colorSpace = "DeviceGray"
+		}
+		img.filter = "DCTDecode"
+		img.data = data
+	} else {
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		img.width, img.height, img.data, err = flateRGB(decoded)
+		if err != nil {
+			return nil, err
+		}
+		img.colorSpace = "DeviceRGB"
+		img.filter = "FlateDecode"
+	}
+	d.images = append(d.images, img)
+	d.byPath[url] = img
+	return img, nil
+}
+
+// flateRGB flattens an image onto white, downsamples it to maxImagePixels and zlib-compresses the RGB bytes
+func flateRGB(src image.Image) (int, int, []byte, error) {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := 1.0
+	if w > maxImagePixels || h > maxImagePixels {
+		if w > h {
+			scale = float64(maxImagePixels) / float64(w)
+		} else {
+			scale = float64(maxImagePixels) / float64(h)
+		}
+	}
+	outW, outH := int(float64(w)*scale), int(float64(h)*scale)
+	if outW < 1 || outH < 1 {
+		return 0, 0, nil, fmt.Errorf("empty image")
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	row := make([]byte, outW*3)
+	for y := 0; y < outH; y++ {
+		sy := b.Min.Y + int(float64(y)/scale)
+		for x := 0; x < outW; x++ {
+			sx := b.Min.X + int(float64(x)/scale)
+			r, g, bl, a := src.At(sx, sy).RGBA()
+			// Composite over white; RGBA() is alpha-premultiplied
+			white := 0xffff - a
+			row[x*3] = uint8((r + white) >> 8)
+			row[x*3+1] = uint8((g + white) >> 8)
+			row[x*3+2] = uint8((bl + white) >> 8)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, 0, nil, err
+	}
+	return outW, outH, buf.Bytes(), nil
+}
+
+func compress(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// writeTo serializes the document. Object numbers: 1 catalog, 2 page tree, 3-4 fonts, 5 info,
+// then images, then a page and content stream per page.
+func (d *pdfDoc) writeTo(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	firstImage := 6
+	firstPage := firstImage + len(d.images)
+	pageRef := func(i int) string { return fmt.Sprintf("%d 0 R", firstPage+2*i) }
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = pageRef(i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Count %d /Kids [%s] >>", len(d.pages), strings.Join(kids, " ")))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title %s /Producer (HomeSolutions) /CreationDate (D:%s) >>",
+		pdfString(winAnsi(d.title)), time.Now().UTC().Format("20060102150405Z")))
+
+	xobjects := make([]string, len(d.images))
+	for i, img := range d.images {
+		stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s",
+			img.width, img.height, img.colorSpace, img.filter), img.data)
+		xobjects[i] = fmt.Sprintf("/%s %d 0 R", img.name, firstImage+i)
+	}
+	resources := fmt.Sprintf("<< /Font << /%s 3 0 R /%s 4 0 R >> /XObject << %s >> >>", fontRegular, fontBold, strings.Join(xobjects, " "))
+
+	for i, p := range d.pages {
+		var annots []string
+		for _, l := range p.links {
+			if l.page < 0 || l.page >= len(d.pages) {
+				continue
+			}
+			annots = append(annots, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Border [0 0 0] /Rect [%.2f %.2f %.2f %.2f] /Dest [%s /XYZ null null null] >>",
+				l.x, pageHeight-l.y-l.h, l.x+l.w, pageHeight-l.y, pageRef(l.page)))
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources %s /Contents %d 0 R /Annots [%s] >>",
+			pageWidth, pageHeight, resources, firstPage+2*i+1, strings.Join(annots, " ")))
+		stream("/Filter /FlateDecode", compress(p.content.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,107 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "()"},
+		{"Roof", "(Roof)"},
+		{"Panel (main)", `(Panel \(main\))`},
+		{`C:\path`, `(C:\\path)`},
+		{`\)`, `(\\\))`},
+	}
+	for _, tt := range tests {
+		if got := pdfString([]byte(tt.in)); got != tt.want {
+			t.Errorf("pdfString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWinAnsi(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{"plain", []byte("plain")},
+		{"a\tb", []byte("a b")},
+		{"line\nbreak", []byte("linebreak")},
+		{"café", []byte{'c', 'a', 'f', 0xe9}},
+		{"“quoted” – €5…", []byte{0x93, 'q', 'u', 'o', 't', 'e', 'd', 0x94, ' ', 0x96, ' ', 0x80, '5', 0x85}},
+		{"日本", []byte("??")},
+	}
+	for _, tt := range tests {
+		if got := winAnsi(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("winAnsi(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPDFXrefOffsets(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages []string
+	}{
+		{"empty", nil},
+		{"one page", []string{"Summary"}},
+		{"escaped text", []string{"Panel (main) \\ sub", "Roof – “flashing”", "Page three"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := newPDF("Report (draft)")
+			for i, text := range tt.pages {
+				p := doc.addPage()
+				p.text(72, 72, fontRegular, 12, rgb{}, text)
+				if i > 0 {
+					p.link(72, 100, 50, 12, 0)
+				}
+			}
+			var buf bytes.Buffer
+			if err := doc.writeTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.Bytes()
+
+			m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(out)
+			if m == nil {
+				t.Fatalf("no startxref trailer")
+			}
+			xref, _ := strconv.Atoi(string(m[1]))
+			if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+				t.Fatalf("startxref %d does not point at the xref table", xref)
+			}
+
+			lines := strings.Split(string(out[xref:]), "\n")
+			var first, count int
+			if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &count); err != nil || first != 0 {
+				t.Fatalf("bad xref subsection header %q", lines[1])
+			}
+			// Catalog, page tree, two fonts and info, then a page and content stream per page
+			if want := 1 + 5 + 2*len(tt.pages); count != want {
+				t.Fatalf("xref has %d entries, want %d", count, want)
+			}
+			if !strings.Contains(string(out), "/Size "+strconv.Itoa(count)+" ") {
+				t.Errorf("trailer /Size does not match the xref count %d", count)
+			}
+			for obj := 1; obj < count; obj++ {
+				entry := lines[2+obj]
+				if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+					t.Fatalf("xref entry %d is malformed: %q", obj, entry)
+				}
+				offset, _ := strconv.Atoi(entry[:10])
+				if want := strconv.Itoa(obj) + " 0 obj\n"; !bytes.HasPrefix(out[offset:], []byte(want)) {
+					t.Errorf("xref entry %d points at %q", obj, string(out[offset:offset+len(want)]))
+				}
+			}
+		})
+	}
+}
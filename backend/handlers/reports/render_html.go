@@ -0,0 +1,265 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+)
+
+func (c rgb) css() template.CSS {
+	return template.CSS(fmt.Sprintf("#%02x%02x%02x", int(c.R*255+0.5), int(c.G*255+0.5), int(c.B*255+0.5)))
+}
+
+type breakdownRow struct {
+	Name    string
+	Percent float64
+}
+
+// htmlView is what the report template renders
+type htmlView struct {
+	Report      *Report
+	Style       pdfStyle
+	Title       string
+	Street      string
+	City        string
+	Header      string
+	Footer      string
+	Weather     string
+	Breakdown   []breakdownRow
+	Summary     *Summary
+	SummaryHTML template.HTML // the embeddable findings summary
+	Analysis    string
+}
+
+var htmlFuncs = template.FuncMap{
+	"css":          func(c rgb) template.CSS { return c.css() },
+	"sectionTitle": sectionTitle,
+	"costRange":    costRange,
+	"severityColor": func(severity string) template.CSS {
+		if c, ok := severityColors[severity]; ok {
+			return c.css()
+		}
+		return severityColors["none"].css()
+	},
+	"conditionSeverity": func(condition string) string {
+		return inspections.ConditionSeverity(condition).String()
+	},
+	"checked": func(item ReportItem) []string {
+		var names []string
+		for name, on := range item.Conditions {
+			if on {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	},
+	"materials": func(item ReportItem) []string {
+		var list []string
+		for name, value := range item.Materials {
+			if strings.TrimSpace(value) != "" {
+				list = append(list, name+": "+value)
+			}
+		}
+		sort.Strings(list)
+		return list
+	},
+	"join":  strings.Join,
+	"deref": func(s *string) string { return *s },
+	"inc":   func(i int) int { return i + 1 },
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(htmlFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  body { margin: 0; font-family: Helvetica, Arial, sans-serif; color: {{css .Style.Text}}; background: #f4f4f4; }
+  .page { max-width: 840px; margin: 0 auto; background: #fff; padding: 0 0 32px; }
+  .band { background: {{css .Style.Primary}}; color: #fff; padding: 28px 40px; display: flex; justify-content: space-between; align-items: center; }
+  .band h1 { margin: 8px 0 4px; font-size: 30px; }
+  .band img { max-width: 180px; max-height: 90px; }
+  .header { padding: 8px 40px; font-size: 12px; color: {{css .Style.Muted}}; border-bottom: 1px solid {{css .Style.Rule}}; }
+  .content { padding: 0 40px; }
+  .cover-photo { width: 100%; max-height: 420px; object-fit: cover; margin-top: 24px; }
+  .placeholder { height: 220px; background: #eee; color: {{css .Style.Muted}}; display: flex; align-items: center; justify-content: center; margin-top: 24px; }
+  .address { font-size: 22px; font-weight: bold; margin: 20px 0 2px; }
+  .muted { color: {{css .Style.Muted}}; }
+  table.details td { padding: 3px 16px 3px 0; font-size: 14px; vertical-align: top; }
+  table.details td:first-child { font-weight: bold; color: {{css .Style.Muted}}; }
+  .score { display: inline-block; background: {{css .Style.Accent}}; color: #fff; padding: 10px 20px; text-align: center; border-radius: 4px; }
+  .score strong { display: block; font-size: 34px; }
+  .license { font-size: 12px; color: {{css .Style.Muted}}; white-space: pre-wrap; border-top: 1px solid {{css .Style.Rule}}; margin-top: 20px; padding-top: 8px; }
+  h2 { background: {{css .Style.Primary}}; color: #fff; padding: 8px 12px; font-size: 18px; margin: 36px 0 16px; }
+  h3 { color: {{css .Style.Primary}}; border-bottom: 1px solid {{css .Style.Rule}}; padding-bottom: 4px; }
+  .toc a { color: inherit; text-decoration: none; }
+  .toc ul { list-style: none; padding-left: 0; }
+  .toc li { margin: 4px 0; }
+  .bar { background: #eaeaea; height: 10px; width: 60%; display: inline-block; vertical-align: middle; }
+  .bar span { background: {{css .Style.Accent}}; height: 10px; display: block; }
+  .breakdown td { padding: 3px 12px 3px 0; font-size: 14px; }
+  .dot { display: inline-block; width: 10px; height: 10px; margin-right: 6px; border-radius: 2px; }
+  .counts span { margin-right: 18px; }
+  .analysis { white-space: pre-wrap; font-size: 14px; line-height: 1.45; }
+  .item { margin-bottom: 22px; }
+  .item-head { display: flex; justify-content: space-between; border-bottom: 1px solid {{css .Style.Rule}}; padding-bottom: 3px; }
+  .item-head strong { font-size: 16px; }
+  .conditions { list-style: none; padding: 0; margin: 6px 0; }
+  .comments { white-space: pre-wrap; }
+  .photos { display: grid; grid-template-columns: 1fr 1fr; gap: 10px; margin-top: 8px; }
+  .photos img { width: 100%; }
+  .disclaimer { white-space: pre-wrap; font-size: 13px; }
+  .footer { margin: 32px 40px 0; padding-top: 8px; border-top: 1px solid {{css .Style.Rule}}; font-size: 12px; color: {{css .Style.Muted}}; }
+  @media print { body { background: #fff; } h2 { break-before: page; } .toc h2, .cover h2 { break-before: auto; } }
+</style>
+</head>
+<body>
+<div class="page">
+  <div class="band">
+    <div>
+      {{if .Report.Cover.CompanyName}}<div>{{.Report.Cover.CompanyName}}</div>{{end}}
+      <h1>Home Inspection Report</h1>
+      {{with .Report.Cover.ReportID}}<div>Report {{deref .}}</div>{{end}}
+    </div>
+    {{if .Style.LogoURL}}<img src="{{.Style.LogoURL}}" alt="Company logo">{{end}}
+  </div>
+  {{if .Header}}<div class="header">{{.Header}}</div>{{end}}
+
+  <div class="content cover">
+    {{with .Report.Cover.PhotoURL}}<img class="cover-photo" src="{{deref .}}" alt="Property">{{else}}<div class="placeholder">No property photo</div>{{end}}
+    <div class="address">{{.Street}}</div>
+    <div class="muted">{{.City}}</div>
+    <div style="display: flex; justify-content: space-between; align-items: flex-start; margin-top: 16px;">
+      <table class="details">
+        {{with .Report.Cover.InspectionDate}}<tr><td>Inspection date</td><td>{{deref .}}</td></tr>{{end}}
+        {{if .Report.Cover.InspectorName}}<tr><td>Inspector</td><td>{{.Report.Cover.InspectorName}}</td></tr>{{end}}
+        {{if .Weather}}<tr><td>Conditions</td><td>{{.Weather}}</td></tr>{{end}}
+        {{with .Report.Version}}
+        <tr><td>Report version</td><td>{{.Version}} ({{.Kind}}), published {{.PublishedAt}} UTC</td></tr>
+        <tr><td>Content hash</td><td style="word-break: break-all;">{{.ContentHash}}</td></tr>
+        {{else}}
+        <tr><td>Status</td><td>Draft - not yet published</td></tr>
+        {{end}}
+      </table>
+      {{with .Report.HealthScore}}<div class="score"><strong>{{printf "%.0f" .Score}}</strong>Home Health Score</div>{{end}}
+    </div>
+    {{if .Style.LicenseBlock}}<div class="license">{{.Style.LicenseBlock}}</div>{{end}}
+  </div>
+
+  <div class="content toc">
+    <h2>Table of Contents</h2>
+    <ul>
+      <li><a href="#summary">Summary</a></li>
+      <li><a href="#findings">Summary of Findings</a></li>
+      {{range $i, $s := .Report.Sections}}<li><a href="#section-{{.Key}}">{{inc $i}}. {{.Title}}</a></li>{{end}}
+      {{if .Style.Disclaimer}}<li><a href="#disclaimer">Disclaimer</a></li>{{end}}
+    </ul>
+  </div>
+
+  <div class="content">
+    <h2 id="summary">Summary</h2>
+    {{with .Report.HealthScore}}
+    <h3>Home Health Score</h3>
+    <p><span style="font-size: 32px; font-weight: bold; color: {{css $.Style.Accent}};">{{printf "%.0f" .Score}}</span> <span class="muted">out of 100</span></p>
+    <table class="breakdown">
+      {{range $.Breakdown}}<tr><td>{{.Name}}</td><td style="width: 100%;"><div class="bar"><span style="width: {{printf "%.0f" .Percent}}%;"></span></div> {{printf "%.0f" .Percent}}%</td></tr>{{end}}
+    </table>
+    {{end}}
+    <h3>Findings</h3>
+    <p class="counts">{{range .Summary.Groups}}<span><i class="dot" style="background: {{severityColor .Severity}};"></i>{{.Title}}: {{len .Findings}}</span>{{end}}</p>
+    {{if .Analysis}}<h3>Analysis</h3><div class="analysis">{{.Analysis}}</div>{{end}}
+
+    <h2 id="findings">Summary of Findings</h2>
+    {{.SummaryHTML}}
+
+    {{range $i, $s := .Report.Sections}}
+    <h2 id="section-{{.Key}}">{{inc $i}}. {{.Title}}</h2>
+    {{range $j, $item := .Items}}
+    <div class="item" id="item-{{inc $i}}-{{inc $j}}">
+      <div class="item-head"><strong>{{inc $i}}.{{inc $j}} {{.ItemName}}</strong><span class="muted">{{.InspectionStatus}}</span></div>
+      {{with materials .}}<p class="muted">Materials - {{join . "; "}}</p>{{end}}
+      {{with checked .}}<ul class="conditions">{{range .}}<li><i class="dot" style="background: {{severityColor (conditionSeverity .)}};"></i>{{.}}</li>{{end}}</ul>{{end}}
+      {{if .Comments}}<p class="comments">{{.Comments}}</p>{{end}}
+      {{with .Photos}}<div class="photos">{{range .}}<img src="{{.URL}}" alt="{{.ItemName}}" loading="lazy">{{end}}</div>{{end}}
+    </div>
+    {{else}}
+    <p class="muted">No items were recorded in this section.</p>
+    {{end}}
+    {{end}}
+
+    {{if .Style.Disclaimer}}
+    <h2 id="disclaimer">Disclaimer</h2>
+    <div class="disclaimer">{{.Style.Disclaimer}}</div>
+    {{end}}
+  </div>
+
+  <div class="footer">{{.Footer}}</div>
+</div>
+</body>
+</html>
+`))
+
+// RenderHTML writes the report as a standalone HTML page with the same content and branding as
+// the PDF. A nil theme renders with the default branding.
+func RenderHTML(w io.Writer, doc *Document, theme *Theme) error {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	ordered := *doc
+	ordered.Sections = theme.orderSections(doc.Sections)
+	report := NewReport(&ordered)
+
+	view := htmlView{
+		Report:  report,
+		Style:   theme.pdfStyle(),
+		Title:   "Home Inspection Report",
+		Street:  doc.Property.Street,
+		City:    strings.TrimSpace(fmt.Sprintf("%s, %s %s", doc.Property.City, doc.Property.State, doc.Property.PostalCode)),
+		Weather: weatherLine(doc.Inspection),
+		Summary: BuildSummary(doc, theme),
+	}
+	if id := doc.Inspection.ReportID; id != nil {
+		view.Title += " " + *id
+	}
+	view.Header = view.Style.HeaderText
+	if view.Header == "" {
+		view.Header = doc.CompanyName
+	}
+	view.Footer = view.Style.FooterText
+	if view.Footer == "" {
+		view.Footer = view.Street + ", " + view.City
+		if id := doc.Inspection.ReportID; id != nil {
+			view.Footer = "Report " + *id + " - " + view.Footer
+		}
+	}
+
+	if hs := doc.HealthScore; hs != nil {
+		for name, v := range hs.Breakdown {
+			if v < 0 {
+				v = 0
+			} else if v > 1 {
+				v = 1
+			}
+			view.Breakdown = append(view.Breakdown, breakdownRow{Name: name, Percent: v * 100})
+		}
+		sort.Slice(view.Breakdown, func(i, j int) bool { return view.Breakdown[i].Name < view.Breakdown[j].Name })
+	}
+
+	var summary bytes.Buffer
+	if err := RenderSummaryHTML(&summary, view.Summary, theme); err != nil {
+		return err
+	}
+	view.SummaryHTML = template.HTML(summary.String())
+
+	if doc.Analysis != nil {
+		view.Analysis = strings.TrimSpace(plainText(*doc.Analysis))
+	}
+	return reportTemplate.Execute(w, view)
+}
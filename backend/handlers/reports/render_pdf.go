@@ -0,0 +1,643 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+)
+
+const (
+	marginX      = 54.0
+	marginTop    = 58.0
+	marginBottom = 62.0
+	contentWidth = pageWidth - 2*marginX
+)
+
+// pdfStyle holds the colors and text the PDF renderer uses
+type pdfStyle struct {
+	Primary      rgb // cover band and section headers
+	Accent       rgb // score bars and highlights
+	Text         rgb
+	Muted        rgb
+	Rule         rgb
+	HeaderText   string // small text at the top of every content page
+	FooterText   string // left side of the footer; defaults to the report id and address
+	LogoURL      string // drawn on the cover and in the page header
+	Disclaimer   string // printed on a closing page
+	LicenseBlock string // printed at the bottom of the cover
+}
+
+var defaultStyle = pdfStyle{
+	Primary: rgb{0.12, 0.25, 0.42},
+	Accent:  rgb{0.18, 0.55, 0.34},
+	Text:    rgb{0.13, 0.13, 0.13},
+	Muted:   rgb{0.45, 0.45, 0.45},
+	Rule:    rgb{0.82, 0.82, 0.82},
+}
+
+var white = rgb{1, 1, 1}
+
+var severityColors = map[string]rgb{
+	"safety":      {0.78, 0.11, 0.11},
+	"major":       {0.88, 0.40, 0.05},
+	"repair":      {0.85, 0.62, 0.05},
+	"maintenance": {0.20, 0.45, 0.75},
+	"none":        {0.55, 0.55, 0.55},
+}
+
+type tocEntry struct {
+	title string
+	page  int
+}
+
+type pdfLayout struct {
+	pdf      *pdfDoc
+	doc      *Document
+	style    pdfStyle
+	sections []publishing.SnapshotSection // in the theme's order
+	logo     *pdfImage
+	page     *pdfPage
+	y        float64 // distance of the cursor from the top of the page
+	toc      []tocEntry
+	findings *Summary
+	refLinks []refLink      // summary references, linked once the item pages exist
+	refPages map[string]int // page index of each item reference
+}
+
+// refLink is a clickable item reference in the findings summary
+type refLink struct {
+	page       *pdfPage
+	x, y, w, h float64
+	reference  string
+}
+
+func (l *pdfLayout) newPage() {
+	l.page = l.pdf.addPage()
+	l.y = marginTop
+}
+
+// need starts a new page unless h more points fit on the current one
+func (l *pdfLayout) need(h float64) {
+	if l.y+h > pageHeight-marginBottom {
+		l.newPage()
+	}
+}
+
+// wrap breaks text into lines no wider than width; explicit newlines start new lines
+func wrap(s, font string, size, width float64) []string {
+	var lines []string
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := ""
+		for _, word := range words {
+			// Break words that are wider than a whole line
+			for textWidth(word, font, size) > width {
+				cut := len(word) - 1
+				for cut > 1 && textWidth(word[:cut], font, size) > width {
+					cut--
+				}
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if textWidth(candidate, font, size) <= width {
+				line = candidate
+				continue
+			}
+			lines = append(lines, line)
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// paragraph writes wrapped text at x, continuing on new pages as needed
+func (l *pdfLayout) paragraph(x, width float64, font string, size float64, c rgb, s string) {
+	leading := size * 1.35
+	for _, line := range wrap(s, font, size, width) {
+		l.need(leading)
+		if line != "" {
+			l.page.text(x, l.y+size, font, size, c, line)
+		}
+		l.y += leading
+	}
+}
+
+// sectionHeading starts a report section on a new page and records it for the table of contents
+func (l *pdfLayout) sectionHeading(title string) {
+	l.newPage()
+	l.toc = append(l.toc, tocEntry{title: title, page: len(l.pdf.pages) - 1})
+	l.page.rect(marginX, l.y, contentWidth, 26, l.style.Primary)
+	l.page.text(marginX+10, l.y+18, fontBold, 14, white, title)
+	l.y += 40
+}
+
+func (l *pdfLayout) subheading(title string) {
+	l.need(30)
+	l.page.text(marginX, l.y+12, fontBold, 12, l.style.Primary, title)
+	l.y += 16
+	l.page.line(marginX, l.y, marginX+contentWidth, l.y, 0.75, l.style.Rule)
+	l.y += 8
+}
+
+func (l *pdfLayout) address() (string, string) {
+	p := l.doc.Property
+	street := p.Street
+	city := strings.TrimSpace(fmt.Sprintf("%s, %s %s", p.City, p.State, p.PostalCode))
+	return street, city
+}
+
+func (l *pdfLayout) cover() {
+	l.newPage()
+	band := 150.0
+	l.page.rect(0, 0, pageWidth, band, l.style.Primary)
+	if l.doc.CompanyName != "" {
+		l.page.text(marginX, 46, fontBold, 12, white, l.doc.CompanyName)
+	}
+	if l.logo != nil {
+		w, h := fit(l.logo, 150, 90)
+		l.page.image(l.logo, marginX+contentWidth-w, (band-h)/2, w, h)
+	}
+	l.page.text(marginX, 96, fontBold, 28, white, "Home Inspection Report")
+	if l.doc.Inspection.ReportID != nil {
+		l.page.text(marginX, 122, fontRegular, 11, white, "Report "+*l.doc.Inspection.ReportID)
+	}
+
+	// Property photo, scaled to fit the box
+	boxY, boxH := band+24, 300.0
+	drawn := false
+	if l.doc.CoverPhotoURL != nil {
+		if img, err := l.pdf.loadImage(*l.doc.CoverPhotoURL); err == nil {
+			w, h := fit(img, contentWidth, boxH)
+			l.page.image(img, marginX+(contentWidth-w)/2, boxY+(boxH-h)/2, w, h)
+			drawn = true
+		}
+	}
+	if !drawn {
+		l.page.rect(marginX, boxY, contentWidth, boxH, rgb{0.93, 0.93, 0.93})
+		msg := "No property photo"
+		l.page.text(marginX+(contentWidth-textWidth(msg, fontRegular, 12))/2, boxY+boxH/2, fontRegular, 12, l.style.Muted, msg)
+	}
+
+	l.y = boxY + boxH + 36
+	street, city := l.address()
+	l.page.text(marginX, l.y, fontBold, 18, l.style.Text, street)
+	l.y += 20
+	l.page.text(marginX, l.y, fontRegular, 12, l.style.Muted, city)
+	l.y += 30
+
+	rows := [][2]string{}
+	if d := l.doc.Inspection.InspectionDate; d != nil {
+		rows = append(rows, [2]string{"Inspection date", *d})
+	}
+	if l.doc.InspectorName != "" {
+		rows = append(rows, [2]string{"Inspector", l.doc.InspectorName})
+	}
+	if weather := weatherLine(l.doc.Inspection); weather != "" {
+		rows = append(rows, [2]string{"Conditions", weather})
+	}
+	if v := l.doc.Published; v != nil {
+		rows = append(rows, [2]string{"Report version", fmt.Sprintf("%d (%s), published %s UTC", v.Version, v.Kind, v.PublishedAt)})
+		rows = append(rows, [2]string{"Content hash", v.ContentHash})
+	} else {
+		rows = append(rows, [2]string{"Status", "Draft - not yet published"})
+	}
+	for _, row := range rows {
+		l.page.text(marginX, l.y, fontBold, 10, l.style.Muted, row[0])
+		l.page.text(marginX+110, l.y, fontRegular, 10, l.style.Text, row[1])
+		l.y += 16
+	}
+
+	if hs := l.doc.HealthScore; hs != nil {
+		x, y := marginX+contentWidth-120, boxY+boxH+20
+		l.page.rect(x, y, 120, 70, l.style.Accent)
+		score := fmt.Sprintf("%.0f", hs.Score)
+		l.page.text(x+(120-textWidth(score, fontBold, 30))/2, y+40, fontBold, 30, white, score)
+		label := "Home Health Score"
+		l.page.text(x+(120-textWidth(label, fontRegular, 9))/2, y+58, fontRegular, 9, white, label)
+	}
+
+	// The license block fills what is left of the cover and is cut off rather than spilling over
+	if block := strings.TrimSpace(l.style.LicenseBlock); block != "" {
+		l.y += 10
+		l.page.line(marginX, l.y, marginX+contentWidth, l.y, 0.5, l.style.Rule)
+		l.y += 4
+		for _, line := range wrap(block, fontRegular, 8, contentWidth) {
+			if l.y+11 > pageHeight-30 {
+				break
+			}
+			l.page.text(marginX, l.y+8, fontRegular, 8, l.style.Muted, line)
+			l.y += 11
+		}
+	}
+}
+
+func weatherLine(in publishing.SnapshotInspection) string {
+	var parts []string
+	if in.Weather != nil && *in.Weather != "" {
+		parts = append(parts, *in.Weather)
+	}
+	if in.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("%d°F", *in.Temperature))
+	}
+	if in.GroundCondition != nil && *in.GroundCondition != "" {
+		parts = append(parts, "ground "+strings.ToLower(*in.GroundCondition))
+	}
+	if in.RainLastThreeDays != nil && *in.RainLastThreeDays {
+		parts = append(parts, "rain in the last three days")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fit scales an image to the largest size within maxW x maxH, keeping its aspect ratio
+func fit(img *pdfImage, maxW, maxH float64) (float64, float64) {
+	w, h := float64(img.width), float64(img.height)
+	scale := maxW / w
+	if h*scale > maxH {
+		scale = maxH / h
+	}
+	return w * scale, h * scale
+}
+
+// plainText drops the markdown markers the analysis text comes with
+func plainText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "#")
+		line = strings.ReplaceAll(line, "**", "")
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (l *pdfLayout) summary() {
+	l.sectionHeading("Summary")
+
+	if hs := l.doc.HealthScore; hs != nil {
+		l.subheading("Home Health Score")
+		l.need(40)
+		l.page.text(marginX, l.y+26, fontBold, 28, l.style.Accent, fmt.Sprintf("%.0f", hs.Score))
+		l.page.text(marginX+60, l.y+26, fontRegular, 11, l.style.Muted, "out of 100")
+		l.y += 42
+
+		categories := make([]string, 0, len(hs.Breakdown))
+		for c := range hs.Breakdown {
+			categories = append(categories, c)
+		}
+		sort.Strings(categories)
+		barWidth := contentWidth - 190
+		for _, c := range categories {
+			// Category scores are stored as 0-1 fractions
+			v := hs.Breakdown[c]
+			if v < 0 {
+				v = 0
+			} else if v > 1 {
+				v = 1
+			}
+			l.need(18)
+			l.page.text(marginX, l.y+10, fontRegular, 10, l.style.Text, c)
+			l.page.rect(marginX+140, l.y+2, barWidth, 10, rgb{0.92, 0.92, 0.92})
+			l.page.rect(marginX+140, l.y+2, barWidth*v, 10, l.style.Accent)
+			l.page.text(marginX+150+barWidth, l.y+10, fontRegular, 10, l.style.Muted, fmt.Sprintf("%.0f%%", v*100))
+			l.y += 18
+		}
+		l.y += 10
+	}
+
+	l.subheading("Findings")
+	l.need(20)
+	x := marginX
+	for _, group := range l.findings.Groups {
+		label := fmt.Sprintf("%s: %d", group.Title, len(group.Findings))
+		l.page.rect(x, l.y+2, 9, 9, severityColors[group.Severity])
+		l.page.text(x+14, l.y+10, fontRegular, 10, l.style.Text, label)
+		x += 30 + textWidth(label, fontRegular, 10)
+	}
+	l.y += 26
+
+	if l.doc.Analysis != nil && strings.TrimSpace(*l.doc.Analysis) != "" {
+		l.subheading("Analysis")
+		l.paragraph(marginX, contentWidth, fontRegular, 10, l.style.Text, plainText(*l.doc.Analysis))
+	}
+}
+
+// findingsSummary lists the summary's findings by severity, each with its item reference and
+// thumbnails of its photos
+func (l *pdfLayout) findingsSummary() {
+	const refWidth, thumbW, thumbH, gap = 36.0, 72.0, 54.0, 6.0
+	l.sectionHeading("Summary of Findings")
+	for _, group := range l.findings.Groups {
+		l.need(40)
+		l.page.rect(marginX, l.y+2, 10, 10, severityColors[group.Severity])
+		l.page.text(marginX+16, l.y+11, fontBold, 12, l.style.Text, fmt.Sprintf("%s (%d)", group.Title, len(group.Findings)))
+		l.y += 22
+		if len(group.Findings) == 0 {
+			l.paragraph(marginX+16, contentWidth-16, fontRegular, 10, l.style.Muted, "None.")
+			l.y += 8
+			continue
+		}
+
+		x := marginX + 16 + refWidth
+		width := contentWidth - 16 - refWidth
+		for _, f := range group.Findings {
+			var details []string
+			if f.Location != "" {
+				details = append(details, "Location: "+f.Location)
+			}
+			if f.Recommendation != "" {
+				details = append(details, "Recommendation: "+f.Recommendation)
+			}
+			if f.ResponsibleTrade != "" {
+				details = append(details, "Trade: "+f.ResponsibleTrade)
+			}
+			if cost := costRange(f.CostMin, f.CostMax); cost != "" {
+				details = append(details, "Estimated cost: "+cost)
+			}
+			l.need(30)
+			if f.Reference != "" {
+				l.page.text(marginX+16, l.y+10, fontBold, 10, l.style.Muted, f.Reference)
+				l.refLinks = append(l.refLinks, refLink{l.page, marginX + 16, l.y, refWidth - 4, 13, f.Reference})
+			}
+			l.paragraph(x, width, fontBold, 10, l.style.Text, f.Title())
+			if len(details) > 0 {
+				l.paragraph(x, width, fontRegular, 9, l.style.Muted, strings.Join(details, "  |  "))
+			}
+			if f.Comments != "" {
+				l.paragraph(x, width, fontRegular, 9, l.style.Text, f.Comments)
+			}
+
+			var thumbs []*pdfImage
+			for _, p := range f.Photos {
+				// Same image objects as the section pages, so thumbnails add no size to the file
+				if img, err := l.pdf.loadImage(p.URL); err == nil {
+					thumbs = append(thumbs, img)
+				}
+			}
+			if len(thumbs) > 0 {
+				l.y += 3
+				l.need(thumbH)
+				for i, img := range thumbs {
+					w, h := fit(img, thumbW, thumbH)
+					l.page.image(img, x+float64(i)*(thumbW+gap)+(thumbW-w)/2, l.y+(thumbH-h)/2, w, h)
+				}
+				l.y += thumbH
+			}
+			l.y += 8
+		}
+		l.y += 8
+	}
+}
+
+// linkReferences points the summary's references at the pages of their items
+func (l *pdfLayout) linkReferences() {
+	for _, ref := range l.refLinks {
+		if page, ok := l.refPages[ref.reference]; ok {
+			ref.page.link(ref.x, ref.y, ref.w, ref.h, page)
+		}
+	}
+}
+
+func costRange(min, max *float64) string {
+	switch {
+	case min != nil && max != nil && *min != *max:
+		return fmt.Sprintf("$%.0f - $%.0f", *min, *max)
+	case min != nil:
+		return fmt.Sprintf("$%.0f", *min)
+	case max != nil:
+		return fmt.Sprintf("up to $%.0f", *max)
+	}
+	return ""
+}
+
+func sectionTitle(key string) string {
+	if s, ok := inspections.SectionByKey(key); ok {
+		return s.Title
+	}
+	return key
+}
+
+// photosByItem groups photos under the item name they were taken for
+func (l *pdfLayout) photosByItem() map[string][]string {
+	byItem := map[string][]string{}
+	for _, p := range l.doc.Photos {
+		byItem[p.ItemName] = append(byItem[p.ItemName], p.URL)
+	}
+	return byItem
+}
+
+func (l *pdfLayout) sectionPages() {
+	photos := l.photosByItem()
+	for i, section := range l.sections {
+		l.sectionHeading(fmt.Sprintf("%d. %s", i+1, section.Title))
+		if len(section.Items) == 0 {
+			l.paragraph(marginX, contentWidth, fontRegular, 10, l.style.Muted, "No items were recorded in this section.")
+			continue
+		}
+		for j, item := range section.Items {
+			reference := fmt.Sprintf("%d.%d", i+1, j+1)
+			l.item(reference, item, photos[item.ItemName])
+			// Photos belong to the first item with their name
+			delete(photos, item.ItemName)
+		}
+	}
+}
+
+func (l *pdfLayout) item(reference string, item inspections.WorksheetItem, photoURLs []string) {
+	l.need(48)
+	l.refPages[reference] = len(l.pdf.pages) - 1
+	l.page.text(marginX, l.y+12, fontBold, 12, l.style.Text, reference+" "+item.ItemName)
+	if item.InspectionStatus != "" {
+		l.page.text(marginX+contentWidth-textWidth(item.InspectionStatus, fontRegular, 10), l.y+12, fontRegular, 10, l.style.Muted, item.InspectionStatus)
+	}
+	l.y += 17
+	l.page.line(marginX, l.y, marginX+contentWidth, l.y, 0.5, l.style.Rule)
+	l.y += 6
+
+	var materials []string
+	for name, value := range item.Materials {
+		if strings.TrimSpace(value) != "" {
+			materials = append(materials, name+": "+value)
+		}
+	}
+	sort.Strings(materials)
+	if len(materials) > 0 {
+		l.paragraph(marginX, contentWidth, fontRegular, 9.5, l.style.Muted, "Materials - "+strings.Join(materials, "; "))
+	}
+
+	var conditions []string
+	for name, checked := range item.Conditions {
+		if checked {
+			conditions = append(conditions, name)
+		}
+	}
+	sort.Strings(conditions)
+	for _, condition := range conditions {
+		severity := inspections.ConditionSeverity(condition).String()
+		l.need(14)
+		l.page.rect(marginX+2, l.y+3, 7, 7, severityColors[severity])
+		l.paragraph(marginX+14, contentWidth-14, fontRegular, 10, l.style.Text, condition)
+	}
+
+	if strings.TrimSpace(item.Comments) != "" {
+		l.y += 2
+		l.paragraph(marginX, contentWidth, fontRegular, 10, l.style.Text, item.Comments)
+	}
+
+	l.photoGrid(photoURLs)
+	l.y += 14
+}
+
+// photoGrid lays photos out two per row
+func (l *pdfLayout) photoGrid(urls []string) {
+	const gap, maxH = 12.0, 185.0
+	cellW := (contentWidth - gap) / 2
+	var row []*pdfImage
+	flush := func() {
+		if len(row) == 0 {
+			return
+		}
+		rowH := 0.0
+		for _, img := range row {
+			if _, h := fit(img, cellW, maxH); h > rowH {
+				rowH = h
+			}
+		}
+		l.need(rowH + gap)
+		for i, img := range row {
+			w, h := fit(img, cellW, maxH)
+			l.page.image(img, marginX+float64(i)*(cellW+gap)+(cellW-w)/2, l.y, w, h)
+		}
+		l.y += rowH + gap
+		row = nil
+	}
+	for _, url := range urls {
+		img, err := l.pdf.loadImage(url)
+		if err != nil {
+			continue
+		}
+		row = append(row, img)
+		if len(row) == 2 {
+			flush()
+		}
+	}
+	flush()
+}
+
+// tableOfContents fills the page reserved after the cover once section page numbers are known
+func (l *pdfLayout) tableOfContents(pageIndex int) {
+	page := l.pdf.pages[pageIndex]
+	page.rect(marginX, marginTop, contentWidth, 26, l.style.Primary)
+	page.text(marginX+10, marginTop+18, fontBold, 14, white, "Table of Contents")
+	y := marginTop + 50
+	for _, entry := range l.toc {
+		number := fmt.Sprintf("%d", entry.page+1)
+		titleW := textWidth(entry.title, fontRegular, 11)
+		numberX := marginX + contentWidth - textWidth(number, fontRegular, 11)
+		page.text(marginX, y, fontRegular, 11, l.style.Text, entry.title)
+		dots := ""
+		for textWidth(dots+" .", fontRegular, 11) < numberX-marginX-titleW-12 {
+			dots += " ."
+		}
+		page.text(marginX+titleW+4, y, fontRegular, 11, l.style.Rule, dots)
+		page.text(numberX, y, fontRegular, 11, l.style.Text, number)
+		page.link(marginX, y-11, contentWidth, 15, entry.page)
+		y += 20
+	}
+}
+
+// decorate adds the header and "Page X of N" footer to every page after the cover
+func (l *pdfLayout) decorate() {
+	footer := l.style.FooterText
+	if footer == "" {
+		street, city := l.address()
+		footer = street + ", " + city
+		if id := l.doc.Inspection.ReportID; id != nil {
+			footer = "Report " + *id + " - " + footer
+		}
+	}
+	header := l.style.HeaderText
+	if header == "" {
+		header = l.doc.CompanyName
+	}
+	total := len(l.pdf.pages)
+	for i, page := range l.pdf.pages {
+		if i == 0 {
+			continue
+		}
+		if header != "" {
+			page.text(marginX, 34, fontRegular, 8, l.style.Muted, header)
+		}
+		if l.logo != nil {
+			w, h := fit(l.logo, 80, 24)
+			page.image(l.logo, marginX+contentWidth-w, 38-h, w, h)
+		}
+		y := pageHeight - 40
+		page.line(marginX, y, marginX+contentWidth, y, 0.5, l.style.Rule)
+		page.text(marginX, y+14, fontRegular, 8, l.style.Muted, footer)
+		number := fmt.Sprintf("Page %d of %d", i+1, total)
+		page.text(marginX+contentWidth-textWidth(number, fontRegular, 8), y+14, fontRegular, 8, l.style.Muted, number)
+	}
+}
+
+func (l *pdfLayout) disclaimer() {
+	if strings.TrimSpace(l.style.Disclaimer) == "" {
+		return
+	}
+	l.sectionHeading("Disclaimer")
+	l.paragraph(marginX, contentWidth, fontRegular, 9.5, l.style.Text, l.style.Disclaimer)
+}
+
+// RenderPDF writes the report as a paginated PDF: cover, table of contents, summary with the
+// health score, findings summary, every section's items with conditions, comments and photos, and
+// the theme's disclaimer. A nil theme renders with the default branding.
+func RenderPDF(w io.Writer, doc *Document, theme *Theme) error {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	title := "Home Inspection Report"
+	if doc.Inspection.ReportID != nil {
+		title += " " + *doc.Inspection.ReportID
+	}
+	l := &pdfLayout{
+		pdf:      newPDF(title),
+		doc:      doc,
+		style:    theme.pdfStyle(),
+		sections: theme.orderSections(doc.Sections),
+		findings: BuildSummary(doc, theme),
+		refPages: map[string]int{},
+	}
+	if l.style.LogoURL != "" {
+		// A missing logo file should not keep the report from rendering
+		if logo, err := l.pdf.loadImage(l.style.LogoURL); err == nil {
+			l.logo = logo
+		}
+	}
+
+	l.cover()
+	l.newPage() // table of contents, filled in last
+	tocPage := len(l.pdf.pages) - 1
+	l.summary()
+	l.findingsSummary()
+	l.sectionPages()
+	l.disclaimer()
+	l.tableOfContents(tocPage)
+	l.linkReferences()
+	l.decorate()
+	return l.pdf.writeTo(w)
+}
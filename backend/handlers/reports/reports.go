@@ -0,0 +1,119 @@
+package reports
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// parseVersion reads the optional ?version= parameter; 0 means the current report
+func parseVersion(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("version")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid version %q", v)
+	}
+	return n, nil
+}
+
+func reportFilename(doc *Document, ext string) string {
+	name := doc.Inspection.InspectionID
+	if doc.Inspection.ReportID != nil && *doc.Inspection.ReportID != "" {
+		name = *doc.Inspection.ReportID
+	}
+	if doc.Published != nil {
+		name += fmt.Sprintf("-v%d", doc.Published.Version)
+	}
+	return "inspection-report-" + name + "." + ext
+}
+
+// loadForRender loads the requested report version and its theme, writing any error response
+func loadForRender(db *sql.DB, w http.ResponseWriter, r *http.Request) (*Document, *Theme, bool) {
+	inspectionID := mux.Vars(r)["inspection_id"]
+	version, err := parseVersion(r)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	doc, err := LoadDocument(db, inspectionID, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Printf("[Reports] Error loading report for %s: %v", inspectionID, err)
+		http.Error(w, "Failed to load report", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	theme, err := LoadTheme(db, doc.OrganizationID)
+	if err != nil {
+		log.Printf("[Reports] Error loading theme for %s: %v", inspectionID, err)
+		http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return doc, theme, true
+}
+
+// GetReportPDF renders the inspection report as a PDF. ?version=N renders a published version,
+// ?download=true asks the browser to save the file instead of displaying it.
+func GetReportPDF(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, theme, ok := loadForRender(db, w, r)
+		if !ok {
+			return
+		}
+		inspectionID := doc.Inspection.InspectionID
+
+		// Render fully before writing so a failure can still return an error status
+		var buf bytes.Buffer
+		if err := RenderPDF(&buf, doc, theme); err != nil {
+			log.Printf("[Reports] Error rendering PDF for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+
+		disposition := "inline"
+		if r.URL.Query().Get("download") == "true" {
+			disposition = "attachment"
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, reportFilename(doc, "pdf")))
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		if doc.Published != nil {
+			w.Header().Set("X-Content-Hash", doc.Published.ContentHash)
+			w.Header().Set("X-Report-Version", strconv.Itoa(doc.Published.Version))
+		}
+		w.Write(buf.Bytes())
+	}
+}
+
+// GetReportHTML renders the inspection report as a standalone HTML page in the organization's theme.
+// ?version=N renders a published version.
+func GetReportHTML(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, theme, ok := loadForRender(db, w, r)
+		if !ok {
+			return
+		}
+		inspectionID := doc.Inspection.InspectionID
+
+		var buf bytes.Buffer
+		if err := RenderHTML(&buf, doc, theme); err != nil {
+			log.Printf("[Reports] Error rendering HTML for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
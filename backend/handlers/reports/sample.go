@@ -0,0 +1,134 @@
+package reports
+
+import (
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+)
+
+type sampleItem struct {
+	section    string
+	name       string
+	materials  map[string]string
+	conditions []string
+	comments   string
+	status     string
+}
+
+// sampleItems fill the dummy inspection with one finding of every severity
+var sampleItems = []sampleItem{
+	{"exterior", "Siding", map[string]string{"Type": "Vinyl"}, []string{"Normal Wear"}, "Siding is in serviceable condition with typical weathering on the south elevation.", "Inspected"},
+	{"exterior", "Decks & Balconies", map[string]string{"Material": "Wood"}, []string{"Loose Railings"}, "The deck railing moves noticeably under light pressure and is a fall hazard.", "Repair or Replace"},
+	{"roof", "Roof Covering", map[string]string{"Type": "Asphalt shingle", "Layers": "1"}, []string{"Granule Loss"}, "Moderate granule loss consistent with the roof's age. Monitor and budget for replacement.", "Inspected"},
+	{"roof", "Flashing", map[string]string{"Type": "Aluminum"}, []string{"Improper Flashing"}, "Step flashing at the chimney is missing; staining is visible in the attic below.", "Repair or Replace"},
+	{"basementFoundation", "Foundation Walls", map[string]string{"Material": "Poured concrete"}, []string{"Efflorescence"}, "Light efflorescence on the north wall; no active moisture at the time of inspection.", "Inspected"},
+	{"heating", "Furnace", map[string]string{"Fuel": "Natural gas", "Age": "12 years"}, []string{"Operational"}, "Furnace responded to the thermostat and produced heat.", "Inspected"},
+	{"plumbing", "Water Heater", map[string]string{"Capacity": "50 gal", "Fuel": "Gas"}, []string{"No Expansion Tank"}, "No thermal expansion tank is installed on the closed system.", "Repair or Replace"},
+	{"plumbing", "Supply Lines", map[string]string{"Material": "Copper"}, []string{"Leaking"}, "Active drip at the shut-off valve under the kitchen sink.", "Repair or Replace"},
+	{"electrical", "Main Panel", map[string]string{"Amperage": "200 A", "Type": "Breakers"}, []string{"Double Tapped"}, "Two conductors share a single breaker terminal. Have a licensed electrician correct.", "Repair or Replace"},
+	{"electrical", "Outlets", map[string]string{}, []string{"Reversed Polarity"}, "Reversed polarity at the second-floor bathroom outlet.", "Repair or Replace"},
+	{"attic", "Insulation", map[string]string{"Type": "Blown cellulose", "Depth": "10 in"}, []string{"Compressed"}, "Insulation is compressed near the attic hatch.", "Inspected"},
+	{"doorsWindows", "Windows", map[string]string{"Type": "Double-hung vinyl"}, []string{"Failed Seal"}, "Fogging between panes in the living room window indicates a failed seal.", "Repair or Replace"},
+	{"fireplace", "Chimney", map[string]string{"Type": "Masonry"}, []string{"Creosote Buildup"}, "Heavy creosote in the flue. Have the chimney cleaned before use.", "Repair or Replace"},
+	{"systemsComponents", "Garage Door Opener", map[string]string{}, []string{"Operational"}, "Opener operated and reversed on contact.", "Inspected"},
+}
+
+// SampleDocument is the dummy inspection used to preview report themes. It lives in code rather
+// than in the database so previews never show up in lists, dashboards or exports.
+func SampleDocument() *Document {
+	reportID := "SAMPLE-0001"
+	date := "2024-05-14"
+	weather := "Sunny"
+	ground := "Dry"
+	temperature := 68
+	yearBuilt := 1994
+	sqft := 2150
+	bedrooms := 4
+	bathrooms := 2.5
+	propertyType := "Single Family"
+	analysis := "## Overview\nThe home is in generally fair condition for its age. Safety items in the electrical " +
+		"system, the deck railing and the chimney should be addressed before occupancy.\n\n" +
+		"## Priorities\n**Safety:** correct the double-tapped breaker and reversed outlet, secure the deck railing, " +
+		"and have the chimney swept.\n**Major:** repair the chimney flashing and the leaking supply valve."
+
+	doc := &Document{
+		Status:        "draft",
+		InspectorName: "Alex Sample",
+		CompanyName:   "Sample Home Inspections",
+	}
+	doc.SchemaVersion = publishing.SnapshotSchemaVersion
+	doc.Inspection = publishing.SnapshotInspection{
+		InspectionID:    "sample",
+		ReportID:        &reportID,
+		InspectionDate:  &date,
+		Temperature:     &temperature,
+		Weather:         &weather,
+		GroundCondition: &ground,
+		SOPStandard:     "ASHI",
+	}
+	doc.Property = publishing.SnapshotProperty{
+		PropertyID:    "sample",
+		Street:        "123 Example Lane",
+		City:          "Springfield",
+		State:         "IL",
+		PostalCode:    "62704",
+		Country:       "US",
+		YearBuilt:     &yearBuilt,
+		SquareFootage: &sqft,
+		Bedrooms:      &bedrooms,
+		Bathrooms:     &bathrooms,
+		PropertyType:  &propertyType,
+	}
+	doc.Analysis = &analysis
+	doc.HealthScore = &publishing.SnapshotHealthScore{
+		Score: 72,
+		Breakdown: map[string]float64{
+			"Exterior": 0.8, "Roof": 0.6, "Plumbing": 0.65, "Electrical": 0.55, "Heating & Cooling": 0.9,
+		},
+	}
+	doc.Photos = []publishing.SnapshotPhoto{}
+	doc.Defects = []inspections.Defect{}
+
+	bySection := map[string][]inspections.WorksheetItem{}
+	for _, s := range sampleItems {
+		conditions := map[string]bool{}
+		for _, c := range s.conditions {
+			conditions[c] = true
+		}
+		bySection[s.section] = append(bySection[s.section], inspections.WorksheetItem{
+			InspectionID:     "sample",
+			ItemName:         s.name,
+			Materials:        s.materials,
+			Conditions:       conditions,
+			Comments:         s.comments,
+			InspectionStatus: s.status,
+			Version:          1,
+		})
+		for _, c := range s.conditions {
+			severity := inspections.ConditionSeverity(c)
+			if severity == inspections.SeverityNone {
+				continue
+			}
+			condition := c
+			doc.Defects = append(doc.Defects, inspections.Defect{
+				DefectID:       len(doc.Defects) + 1,
+				InspectionID:   "sample",
+				Section:        s.section,
+				ItemName:       s.name,
+				ConditionKey:   &condition,
+				Source:         "derived",
+				Status:         "open",
+				Severity:       severity.String(),
+				Recommendation: s.comments,
+				PhotoIDs:       []int{},
+			})
+		}
+	}
+	for _, section := range inspections.Sections {
+		items := bySection[section.Key]
+		if items == nil {
+			items = []inspections.WorksheetItem{}
+		}
+		doc.Sections = append(doc.Sections, publishing.SnapshotSection{Key: section.Key, Title: section.Title, Items: items})
+	}
+	return doc
+}
@@ -0,0 +1,303 @@
+package reports
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+)
+
+// summaryCategories are the summary's groups, most urgent first
+var summaryCategories = []struct{ Severity, Title string }{
+	{"safety", "Safety Hazards"},
+	{"major", "Major Defects"},
+	{"repair", "Repairs"},
+	{"maintenance", "Maintenance Items"},
+}
+
+// maxSummaryPhotos bounds the thumbnails shown per finding
+const maxSummaryPhotos = 4
+
+// Summary is the prioritized list of findings at the front of a report
+type Summary struct {
+	InspectionID string         `json:"inspection_id"`
+	Version      *int           `json:"version"` // published version summarized; nil for a draft
+	Counts       map[string]int `json:"counts"`  // findings per severity
+	Groups       []SummaryGroup `json:"groups"`
+}
+
+// SummaryGroup holds the findings of one severity; every category is present even when empty
+type SummaryGroup struct {
+	Severity string           `json:"severity"`
+	Title    string           `json:"title"`
+	Findings []SummaryFinding `json:"findings"`
+}
+
+// SummaryFinding is a tracked defect, or a flagged condition that has no defect record.
+// Reference numbers the item as the report body does, e.g. "3.2"; it is empty when the item
+// isn't in the worksheet.
+type SummaryFinding struct {
+	Reference        string         `json:"reference"`
+	Section          string         `json:"section"`
+	SectionTitle     string         `json:"section_title"`
+	ItemName         string         `json:"item_name"`
+	Condition        *string        `json:"condition"`
+	Source           string         `json:"source"` // "defect" or "condition"
+	DefectID         *int           `json:"defect_id"`
+	Severity         string         `json:"severity"`
+	Location         string         `json:"location"`
+	Recommendation   string         `json:"recommendation"`
+	ResponsibleTrade string         `json:"responsible_trade"`
+	CostMin          *float64       `json:"cost_min"`
+	CostMax          *float64       `json:"cost_max"`
+	Comments         string         `json:"comments"`
+	Photos           []SummaryPhoto `json:"photos"`
+}
+
+type SummaryPhoto struct {
+	PhotoID      int    `json:"photo_id"`
+	URL          string `json:"photo_url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// Title is the finding's one-line heading, e.g. "Roof - Flashing: Improper Flashing"
+func (f SummaryFinding) Title() string {
+	title := f.SectionTitle + " - " + f.ItemName
+	if f.Condition != nil && *f.Condition != "" {
+		title += ": " + *f.Condition
+	}
+	return title
+}
+
+// ThumbnailPath is where GetPhotoThumbnail serves a photo of an inspection
+func ThumbnailPath(inspectionID string, photoID int) string {
+	return fmt.Sprintf("/api/inspections/%s/photos/%d/thumbnail", inspectionID, photoID)
+}
+
+func conditionKey(section, item, condition string) string {
+	return strings.ToLower(section + "|" + item + "|" + condition)
+}
+
+// BuildSummary groups the document's open defects and its flagged conditions by severity. Sections
+// are numbered in the theme's order so references match the report body. A flagged condition
+// is folded into its defect when one is open, and left out when its derived defect was dismissed.
+func BuildSummary(doc *Document, theme *Theme) *Summary {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	summary := &Summary{InspectionID: doc.Inspection.InspectionID, Counts: map[string]int{}}
+	if doc.Published != nil {
+		v := doc.Published.Version
+		summary.Version = &v
+	}
+
+	type itemRef struct {
+		reference string
+		item      inspections.WorksheetItem
+	}
+	items := map[string]itemRef{}
+	for i, section := range theme.orderSections(doc.Sections) {
+		for j, item := range section.Items {
+			key := strings.ToLower(section.Key + "|" + item.ItemName)
+			if _, seen := items[key]; !seen {
+				items[key] = itemRef{reference: fmt.Sprintf("%d.%d", i+1, j+1), item: item}
+			}
+		}
+	}
+	photosByItem := map[string][]publishing.SnapshotPhoto{}
+	photoByID := map[int]publishing.SnapshotPhoto{}
+	for _, p := range doc.Photos {
+		photosByItem[p.ItemName] = append(photosByItem[p.ItemName], p)
+		photoByID[p.PhotoID] = p
+	}
+	thumbnailURL := doc.ThumbnailURL
+	if thumbnailURL == nil {
+		thumbnailURL = func(photoID int) string { return ThumbnailPath(summary.InspectionID, photoID) }
+	}
+	toSummaryPhotos := func(photos []publishing.SnapshotPhoto) []SummaryPhoto {
+		out := []SummaryPhoto{}
+		for _, p := range photos {
+			if len(out) == maxSummaryPhotos {
+				break
+			}
+			out = append(out, SummaryPhoto{PhotoID: p.PhotoID, URL: p.URL, ThumbnailURL: thumbnailURL(p.PhotoID)})
+		}
+		return out
+	}
+
+	var findings []SummaryFinding
+	covered := map[string]bool{}
+	for _, d := range doc.Defects {
+		if d.Status != "" && d.Status != inspections.DefectOpen {
+			continue
+		}
+		severity := d.Severity
+		if s, ok := inspections.ParseSeverity(severity); !ok || s == inspections.SeverityNone {
+			// Same fallback as conditions without a known severity
+			severity = inspections.SeverityRepair.String()
+		}
+		id := d.DefectID
+		f := SummaryFinding{
+			Section:          d.Section,
+			SectionTitle:     sectionTitle(d.Section),
+			ItemName:         d.ItemName,
+			Condition:        d.ConditionKey,
+			Source:           "defect",
+			DefectID:         &id,
+			Severity:         severity,
+			Location:         d.Location,
+			Recommendation:   d.Recommendation,
+			ResponsibleTrade: d.ResponsibleTrade,
+			CostMin:          d.CostMin,
+			CostMax:          d.CostMax,
+		}
+		if ref, ok := items[strings.ToLower(d.Section+"|"+d.ItemName)]; ok {
+			f.Reference = ref.reference
+			if comments := strings.TrimSpace(ref.item.Comments); comments != d.Recommendation {
+				f.Comments = comments
+			}
+		}
+		// Photos linked to the defect, otherwise the item's photos
+		var photos []publishing.SnapshotPhoto
+		for _, id := range d.PhotoIDs {
+			if p, ok := photoByID[id]; ok {
+				photos = append(photos, p)
+			}
+		}
+		if len(photos) == 0 {
+			photos = photosByItem[d.ItemName]
+		}
+		f.Photos = toSummaryPhotos(photos)
+		if d.ConditionKey != nil {
+			covered[conditionKey(d.Section, d.ItemName, *d.ConditionKey)] = true
+		}
+		findings = append(findings, f)
+	}
+
+	for _, section := range doc.Sections {
+		for _, item := range section.Items {
+			flagged := inspections.ItemDefects(item)
+			conditions := make([]string, 0, len(flagged))
+			for c := range flagged {
+				conditions = append(conditions, c)
+			}
+			sort.Strings(conditions)
+			for _, c := range conditions {
+				key := conditionKey(section.Key, item.ItemName, c)
+				if covered[key] || doc.dismissed[key] {
+					continue
+				}
+				covered[key] = true
+				condition := c
+				findings = append(findings, SummaryFinding{
+					Reference:    items[strings.ToLower(section.Key+"|"+item.ItemName)].reference,
+					Section:      section.Key,
+					SectionTitle: section.Title,
+					ItemName:     item.ItemName,
+					Condition:    &condition,
+					Source:       "condition",
+					Severity:     flagged[c].String(),
+					Comments:     strings.TrimSpace(item.Comments),
+					Photos:       toSummaryPhotos(photosByItem[item.ItemName]),
+				})
+			}
+		}
+	}
+
+	// Report order within each severity; findings without a reference go last
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i].Reference, findings[j].Reference
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return compareReferences(a, b) < 0
+	})
+	for _, c := range summaryCategories {
+		group := SummaryGroup{Severity: c.Severity, Title: c.Title, Findings: []SummaryFinding{}}
+		for _, f := range findings {
+			if f.Severity == c.Severity {
+				group.Findings = append(group.Findings, f)
+			}
+		}
+		summary.Counts[c.Severity] = len(group.Findings)
+		summary.Groups = append(summary.Groups, group)
+	}
+	return summary
+}
+
+// compareReferences orders "2.10" after "2.9"
+func compareReferences(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, _ := strconv.Atoi(pa[i])
+		y, _ := strconv.Atoi(pb[i])
+		if x != y {
+			return x - y
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// loadDismissed reads the conditions whose derived defects are currently dismissed. Published
+// versions keep their own list in the snapshot.
+func loadDismissed(db *sql.DB, inspectionID string) (map[string]bool, error) {
+	rows, err := db.Query(`
+		SELECT section, item_name, condition_key FROM defects
+		WHERE inspection_id = ? AND source = 'derived' AND status = ?`, inspectionID, inspections.DefectDismissed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dismissed := map[string]bool{}
+	for rows.Next() {
+		var section, item string
+		var condition sql.NullString
+		if err := rows.Scan(&section, &item, &condition); err != nil {
+			return nil, err
+		}
+		if condition.Valid {
+			dismissed[conditionKey(section, item, condition.String)] = true
+		}
+	}
+	return dismissed, rows.Err()
+}
+
+// GetReportSummary returns the prioritized findings of a report as JSON. ?version=N summarizes a
+// published version. Numbering follows the organization's section order.
+func GetReportSummary(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, theme, ok := loadForRender(db, w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(BuildSummary(doc, theme))
+	}
+}
+
+// GetReportSummaryHTML returns the findings summary as an HTML fragment in the organization's
+// theme, for embedding in another page
+func GetReportSummaryHTML(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, theme, ok := loadForRender(db, w, r)
+		if !ok {
+			return
+		}
+		var buf bytes.Buffer
+		if err := RenderSummaryHTML(&buf, BuildSummary(doc, theme), theme); err != nil {
+			log.Printf("[Reports] Error rendering summary for %s: %v", doc.Inspection.InspectionID, err)
+			http.Error(w, "Failed to render summary", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
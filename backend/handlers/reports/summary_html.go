@@ -0,0 +1,64 @@
+package reports
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// summaryView is what the findings summary template renders
+type summaryView struct {
+	Summary *Summary
+	Style   pdfStyle
+}
+
+// summaryTemplate is a self-contained fragment: its styles are scoped to the section so it can be
+// dropped into the full report or another page
+var summaryTemplate = template.Must(template.New("summary").Funcs(htmlFuncs).Funcs(template.FuncMap{
+	"anchor": func(reference string) string { return "item-" + strings.ReplaceAll(reference, ".", "-") },
+}).Parse(`<section class="findings-summary">
+<style>
+  .findings-summary { font-family: Helvetica, Arial, sans-serif; color: {{css .Style.Text}}; }
+  .findings-summary h3 { color: {{css .Style.Primary}}; border-bottom: 1px solid {{css .Style.Rule}}; padding-bottom: 4px; }
+  .findings-summary .dot { display: inline-block; width: 10px; height: 10px; margin-right: 6px; border-radius: 2px; }
+  .findings-summary .finding { display: flex; gap: 12px; margin: 0 0 14px 18px; }
+  .findings-summary .ref { min-width: 36px; font-weight: bold; color: {{css .Style.Muted}}; }
+  .findings-summary .ref a { color: inherit; text-decoration: none; }
+  .findings-summary .details { font-size: 13px; color: {{css .Style.Muted}}; }
+  .findings-summary .comments { font-size: 13px; white-space: pre-wrap; margin-top: 2px; }
+  .findings-summary .thumbs { display: flex; gap: 6px; margin-top: 6px; flex-wrap: wrap; }
+  .findings-summary .thumbs img { width: 96px; height: 72px; object-fit: cover; border: 1px solid {{css .Style.Rule}}; }
+  .findings-summary .muted { color: {{css .Style.Muted}}; }
+</style>
+{{range .Summary.Groups}}
+<h3><i class="dot" style="background: {{severityColor .Severity}};"></i>{{.Title}} ({{len .Findings}})</h3>
+{{range .Findings}}
+<div class="finding">
+  <div class="ref">{{if .Reference}}<a href="#{{anchor .Reference}}">{{.Reference}}</a>{{end}}</div>
+  <div>
+    <strong>{{.Title}}</strong>
+    <div class="details">
+      {{if .Location}}Location: {{.Location}} {{end}}
+      {{if .Recommendation}}Recommendation: {{.Recommendation}} {{end}}
+      {{if .ResponsibleTrade}}Trade: {{.ResponsibleTrade}} {{end}}
+      {{with costRange .CostMin .CostMax}}Estimated cost: {{.}}{{end}}
+    </div>
+    {{if .Comments}}<div class="comments">{{.Comments}}</div>{{end}}
+    {{with .Photos}}<div class="thumbs">{{range .}}<a href="{{.URL}}"><img src="{{.ThumbnailURL}}" alt="Photo {{.PhotoID}}" loading="lazy"></a>{{end}}</div>{{end}}
+  </div>
+</div>
+{{else}}
+<p class="muted">None.</p>
+{{end}}
+{{end}}
+</section>
+`))
+
+// RenderSummaryHTML writes the findings summary as an HTML fragment in the theme's colors.
+// A nil theme renders with the default branding.
+func RenderSummaryHTML(w io.Writer, s *Summary, theme *Theme) error {
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+	return summaryTemplate.Execute(w, summaryView{Summary: s, Style: theme.pdfStyle()})
+}
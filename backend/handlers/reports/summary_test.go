@@ -0,0 +1,36 @@
+package reports
+
+import "testing"
+
+func TestCompareReferences(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign of the result
+	}{
+		{"1", "1", 0},
+		{"2.9", "2.10", -1},
+		{"2.10", "2.9", 1},
+		{"3.1", "3.1", 0},
+		{"1", "1.1", -1},
+		{"1.1", "1", 1},
+		{"9", "10", -1},
+		{"1.2.3", "1.2.10", -1},
+		{"2", "1.99", 1},
+	}
+	for _, tt := range tests {
+		got := compareReferences(tt.a, tt.b)
+		if sign(got) != tt.want {
+			t.Errorf("compareReferences(%q, %q) = %d, want sign %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,374 @@
+package reports
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg" // logo formats accepted by UploadThemeLogo
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/middleware"
+
+	"github.com/google/uuid"
+)
+
+// Theme is an organization's report branding, applied by the HTML and PDF renderers
+type Theme struct {
+	OrganizationID *int     `json:"organization_id"` // nil for the built-in default
+	LogoURL        *string  `json:"logo_url"`
+	PrimaryColor   string   `json:"primary_color"` // #RRGGBB
+	AccentColor    string   `json:"accent_color"`
+	TextColor      string   `json:"text_color"`
+	HeaderText     string   `json:"header_text"`   // defaults to the company name
+	FooterText     string   `json:"footer_text"`   // defaults to the report id and address
+	Disclaimer     string   `json:"disclaimer"`    // printed on its own page at the end of the report
+	LicenseBlock   string   `json:"license_block"` // license numbers and certifications, shown on the cover
+	SectionOrder   []string `json:"section_order"` // section keys; sections not listed follow in the usual order
+	UpdatedAt      *string  `json:"updated_at"`
+}
+
+const maxLogoSize = 2 << 20
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// DefaultTheme is used for inspectors without an organization and organizations without a theme
+func DefaultTheme() *Theme {
+	return &Theme{
+		PrimaryColor: "#1F406B",
+		AccentColor:  "#2E8C57",
+		TextColor:    "#212121",
+		SectionOrder: []string{},
+	}
+}
+
+func (t *Theme) validate() error {
+	for name, c := range map[string]string{"primary_color": t.PrimaryColor, "accent_color": t.AccentColor, "text_color": t.TextColor} {
+		if !colorPattern.MatchString(c) {
+			return fmt.Errorf("%s must be a #RRGGBB color", name)
+		}
+	}
+	if len(t.HeaderText) > 255 || len(t.FooterText) > 255 {
+		return fmt.Errorf("header and footer text are limited to 255 characters")
+	}
+	if len(t.Disclaimer) > 20000 || len(t.LicenseBlock) > 2000 {
+		return fmt.Errorf("disclaimer or license block is too long")
+	}
+	if t.LogoURL != nil && (!strings.HasPrefix(*t.LogoURL, "/uploads/logos/") || strings.Contains(*t.LogoURL, "..")) {
+		return fmt.Errorf("logo_url must be an uploaded logo")
+	}
+	seen := map[string]bool{}
+	for _, key := range t.SectionOrder {
+		if _, ok := inspections.SectionByKey(key); !ok {
+			return fmt.Errorf("unknown section: %s", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("section listed twice: %s", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// orderSections returns the sections in the theme's order; unlisted sections keep their relative order
+func (t *Theme) orderSections(sections []publishing.SnapshotSection) []publishing.SnapshotSection {
+	if len(t.SectionOrder) == 0 {
+		return sections
+	}
+	rank := map[string]int{}
+	for i, key := range t.SectionOrder {
+		rank[key] = i
+	}
+	ordered := make([]publishing.SnapshotSection, 0, len(sections))
+	for _, key := range t.SectionOrder {
+		for _, s := range sections {
+			if s.Key == key {
+				ordered = append(ordered, s)
+			}
+		}
+	}
+	for _, s := range sections {
+		if _, listed := rank[s.Key]; !listed {
+			ordered = append(ordered, s)
+		}
+	}
+	return ordered
+}
+
+func (t *Theme) pdfStyle() pdfStyle {
+	style := defaultStyle
+	style.Primary = hexColor(t.PrimaryColor, defaultStyle.Primary)
+	style.Accent = hexColor(t.AccentColor, defaultStyle.Accent)
+	style.Text = hexColor(t.TextColor, defaultStyle.Text)
+	style.HeaderText = t.HeaderText
+	style.FooterText = t.FooterText
+	if t.LogoURL != nil {
+		style.LogoURL = *t.LogoURL
+	}
+	style.Disclaimer = t.Disclaimer
+	style.LicenseBlock = t.LicenseBlock
+	return style
+}
+
+// LoadTheme returns the organization's theme, or the default when it has none
+func LoadTheme(db *sql.DB, organizationID *int) (*Theme, error) {
+	if organizationID == nil {
+		return DefaultTheme(), nil
+	}
+	t := DefaultTheme()
+	var logo, updatedAt sql.NullString
+	var order string
+	err := db.QueryRow(`
+		SELECT logo_url, primary_color, accent_color, text_color, header_text, footer_text,
+		       disclaimer, license_block, section_order, updated_at
+		FROM report_themes WHERE organization_id = ?`, *organizationID).Scan(
+		&logo, &t.PrimaryColor, &t.AccentColor, &t.TextColor, &t.HeaderText, &t.FooterText,
+		&t.Disclaimer, &t.LicenseBlock, &order, &updatedAt)
+	if err == sql.ErrNoRows {
+		t.OrganizationID = organizationID
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.OrganizationID = organizationID
+	if logo.Valid {
+		t.LogoURL = &logo.String
+	}
+	if updatedAt.Valid {
+		t.UpdatedAt = &updatedAt.String
+	}
+	if err := json.Unmarshal([]byte(order), &t.SectionOrder); err != nil {
+		return nil, fmt.Errorf("invalid section order for organization %d: %v", *organizationID, err)
+	}
+	return t, nil
+}
+
+func saveTheme(db *sql.DB, t *Theme) error {
+	order, err := json.Marshal(t.SectionOrder)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO report_themes (organization_id, logo_url, primary_color, accent_color, text_color,
+		                           header_text, footer_text, disclaimer, license_block, section_order, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+		ON DUPLICATE KEY UPDATE logo_url = VALUES(logo_url), primary_color = VALUES(primary_color),
+		    accent_color = VALUES(accent_color), text_color = VALUES(text_color), header_text = VALUES(header_text),
+		    footer_text = VALUES(footer_text), disclaimer = VALUES(disclaimer), license_block = VALUES(license_block),
+		    section_order = VALUES(section_order), updated_at = VALUES(updated_at)`,
+		*t.OrganizationID, t.LogoURL, t.PrimaryColor, t.AccentColor, t.TextColor,
+		t.HeaderText, t.FooterText, t.Disclaimer, t.LicenseBlock, string(order))
+	return err
+}
+
+// callerOrganization resolves whose theme a request manages: an admin names the organization
+// with ?organization_id=, an inspector always gets their own
+func callerOrganization(db *sql.DB, r *http.Request) (*int, error) {
+	userType, _ := r.Context().Value(middleware.UserTypeKey).(string)
+	if userType == "admin" {
+		if raw := r.URL.Query().Get("organization_id"); raw != "" {
+			id, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid organization_id")
+			}
+			return &id, nil
+		}
+		return nil, nil
+	}
+	userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+	var orgID sql.NullInt64
+	err := db.QueryRow(`SELECT organization_id FROM inspectors WHERE user_id = ?`, userID).Scan(&orgID)
+	if err == sql.ErrNoRows || (err == nil && !orgID.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	id := int(orgID.Int64)
+	return &id, nil
+}
+
+// themeForCaller loads the caller's theme; ok is false once an error response has been written
+func themeForCaller(db *sql.DB, w http.ResponseWriter, r *http.Request) (*Theme, bool) {
+	orgID, err := callerOrganization(db, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	theme, err := LoadTheme(db, orgID)
+	if err != nil {
+		log.Printf("[Themes] Error loading theme: %v", err)
+		http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+		return nil, false
+	}
+	return theme, true
+}
+
+// GetReportTheme returns the caller's organization theme, or the default theme
+func GetReportTheme(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		theme, ok := themeForCaller(db, w, r)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(theme)
+	}
+}
+
+// SaveReportTheme replaces the organization's theme. Inspectors without an organization use the default.
+func SaveReportTheme(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := callerOrganization(db, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if orgID == nil {
+			http.Error(w, "Report themes belong to an organization", http.StatusBadRequest)
+			return
+		}
+
+		theme := DefaultTheme()
+		if err := json.NewDecoder(r.Body).Decode(theme); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if theme.SectionOrder == nil {
+			theme.SectionOrder = []string{}
+		}
+		theme.OrganizationID = orgID
+		if err := theme.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := saveTheme(db, theme); err != nil {
+			log.Printf("[Themes] Error saving theme for organization %d: %v", *orgID, err)
+			http.Error(w, "Failed to save report theme", http.StatusInternalServerError)
+			return
+		}
+
+		saved, err := LoadTheme(db, orgID)
+		if err != nil {
+			http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(saved)
+	}
+}
+
+// UploadThemeLogo stores a PNG or JPEG logo (form field "logo") and sets it on the organization's theme
+func UploadThemeLogo(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		theme, ok := themeForCaller(db, w, r)
+		if !ok {
+			return
+		}
+		if theme.OrganizationID == nil {
+			http.Error(w, "Report themes belong to an organization", http.StatusBadRequest)
+			return
+		}
+
+		file, _, err := r.FormFile("logo")
+		if err != nil {
+			http.Error(w, "Missing logo file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxLogoSize+1))
+		if err != nil || len(data) > maxLogoSize {
+			http.Error(w, "Logo must be at most 2 MB", http.StatusBadRequest)
+			return
+		}
+		_, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || (format != "png" && format != "jpeg") {
+			http.Error(w, "Logo must be a PNG or JPEG image", http.StatusBadRequest)
+			return
+		}
+
+		if err := os.MkdirAll("./uploads/logos/", 0755); err != nil {
+			http.Error(w, "Failed to save logo", http.StatusInternalServerError)
+			return
+		}
+		ext := ".png"
+		if format == "jpeg" {
+			ext = ".jpg"
+		}
+		filename := uuid.New().String() + ext
+		if err := os.WriteFile("./uploads/logos/"+filename, data, 0644); err != nil {
+			log.Printf("[Themes] Error writing logo: %v", err)
+			http.Error(w, "Failed to save logo", http.StatusInternalServerError)
+			return
+		}
+
+		previous := theme.LogoURL
+		logoURL := "/uploads/logos/" + filename
+		theme.LogoURL = &logoURL
+		if err := saveTheme(db, theme); err != nil {
+			log.Printf("[Themes] Error saving logo for organization %d: %v", *theme.OrganizationID, err)
+			http.Error(w, "Failed to save report theme", http.StatusInternalServerError)
+			return
+		}
+		if previous != nil && *previous != logoURL {
+			os.Remove("." + *previous)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(theme)
+	}
+}
+
+// PreviewReportTheme renders the sample inspection. GET uses the saved theme; POST previews the
+// theme in the request body without saving it. ?format=html renders HTML instead of PDF.
+func PreviewReportTheme(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		theme, ok := themeForCaller(db, w, r)
+		if !ok {
+			return
+		}
+		if r.Method == http.MethodPost {
+			draft := DefaultTheme()
+			if err := json.NewDecoder(r.Body).Decode(draft); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := draft.validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			draft.OrganizationID = theme.OrganizationID
+			theme = draft
+		}
+
+		doc := SampleDocument()
+		var buf bytes.Buffer
+		var err error
+		if r.URL.Query().Get("format") == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = RenderHTML(&buf, doc, theme)
+		} else {
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", `inline; filename="theme-preview.pdf"`)
+			err = RenderPDF(&buf, doc, theme)
+		}
+		if err != nil {
+			log.Printf("[Themes] Error rendering preview: %v", err)
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "Failed to render preview", http.StatusInternalServerError)
+			return
+		}
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,130 @@
+package reports
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ThumbnailSize is the longest side of a summary thumbnail, in pixels
+const ThumbnailSize = 320
+
+// Thumbnail reads an upload and scales it down to fit within size pixels, as a JPEG. Each output
+// pixel averages the source pixels it covers, and transparency is flattened onto white.
+func Thumbnail(url string, size int) ([]byte, error) {
+	if !strings.HasPrefix(url, "/uploads/") || strings.Contains(url, "..") {
+		return nil, fmt.Errorf("not an upload: %s", url)
+	}
+	f, err := os.Open("." + url)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 1 || h < 1 {
+		return nil, fmt.Errorf("empty image")
+	}
+	outW, outH := w, h
+	if w > size || h > size {
+		if w > h {
+			outW, outH = size, h*size/w
+		} else {
+			outW, outH = w*size/h, size
+		}
+	}
+	if outW < 1 {
+		outW = 1
+	}
+	if outH < 1 {
+		outH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, outW, outH))
+	for y := 0; y < outH; y++ {
+		y0, y1 := b.Min.Y+y*h/outH, b.Min.Y+(y+1)*h/outH
+		if y1 == y0 {
+			y1++
+		}
+		for x := 0; x < outW; x++ {
+			x0, x1 := b.Min.X+x*w/outW, b.Min.X+(x+1)*w/outW
+			if x1 == x0 {
+				x1++
+			}
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					// RGBA() is alpha-premultiplied
+					white := uint64(0xffff - pa)
+					r += uint64(pr) + white
+					g += uint64(pg) + white
+					bl += uint64(pb) + white
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetPhotoThumbnail serves a small JPEG of one of an inspection's photos, for the findings summary.
+// Trashed photos are still served while their file exists, since published versions show them.
+func GetPhotoThumbnail(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		photoID, err := strconv.Atoi(vars["photo_id"])
+		if err != nil {
+			http.Error(w, "Invalid photo id", http.StatusBadRequest)
+			return
+		}
+		var url string
+		err = db.QueryRow(`SELECT photo_url FROM inspection_photos WHERE photo_id = ? AND inspection_id = ?`, photoID, vars["inspection_id"]).Scan(&url)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		data, err := Thumbnail(url, ThumbnailSize)
+		if os.IsNotExist(err) {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Reports] Error making thumbnail of photo %d: %v", photoID, err)
+			http.Error(w, "Failed to read photo", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		w.Write(data)
+	}
+}
@@ -0,0 +1,81 @@
+package scheduling
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const icalTimeLayout = "20060102T150405Z"
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// BuildICalendar renders appointments as an RFC 5545 VCALENDAR document
+func BuildICalendar(name string, appointments []Appointment, now time.Time) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(foldLine(line))
+		b.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//Home Solutions//Inspection Schedule//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("METHOD:PUBLISH")
+	writeLine("X-WR-CALNAME:" + icalEscaper.Replace(name))
+
+	for _, a := range appointments {
+		summary := "Home inspection"
+		if a.ReportID != "" {
+			summary += " " + a.ReportID
+		}
+		description := fmt.Sprintf("Report: %s\nStatus: %s\nEstimated duration: %d minutes", a.ReportID, a.Status, a.DurationMinutes)
+
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + a.InspectionID + "@homesolutions")
+		writeLine("DTSTAMP:" + now.UTC().Format(icalTimeLayout))
+		writeLine("DTSTART:" + a.start.UTC().Format(icalTimeLayout))
+		writeLine("DTEND:" + a.end.UTC().Format(icalTimeLayout))
+		writeLine("SUMMARY:" + icalEscaper.Replace(summary))
+		writeLine("LOCATION:" + icalEscaper.Replace(a.Address))
+		writeLine("DESCRIPTION:" + icalEscaper.Replace(description))
+		if a.Status == "cancelled" {
+			writeLine("STATUS:CANCELLED")
+		} else {
+			writeLine("STATUS:CONFIRMED")
+		}
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+// foldLine splits content lines longer than 75 octets as required by RFC 5545,
+// taking care not to break inside a multi-byte UTF-8 sequence.
+func foldLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var b strings.Builder
+	width := limit
+	for len(line) > width {
+		cut := width
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		width = limit - 1 // continuation lines start with a space
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,522 @@
+package scheduling
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"home_solutions/backend/middleware"
+	users "home_solutions/backend/models/users"
+	"home_solutions/backend/utils"
+
+	"github.com/gorilla/mux"
+)
+
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// Base appointment length covers a home up to baseSquareFootage; every
+// additional started block of extraSquareFootageBlock adds extraBlockMinutes.
+const (
+	baseMinutes             = 120
+	baseSquareFootage       = 2000
+	extraSquareFootageBlock = 1000
+	extraBlockMinutes       = 30
+)
+
+// Extra on-site minutes for each add-on service
+var addOnMinutes = map[string]int{
+	"radon":       15,
+	"mold":        30,
+	"sewer_scope": 45,
+	"pool":        30,
+	"sprinkler":   20,
+	"wdo":         30,
+	"outbuilding": 30,
+}
+
+type ScheduleRequest struct {
+	InspectorID     int      `json:"inspector_id"`
+	Start           string   `json:"start"`
+	DurationMinutes *int     `json:"duration_minutes,omitempty"` // overrides the estimate
+	AddOns          []string `json:"add_ons"`
+}
+
+type Appointment struct {
+	InspectionID    string   `json:"inspection_id"`
+	ReportID        string   `json:"report_id"`
+	InspectorID     int      `json:"inspector_id"`
+	Address         string   `json:"address"`
+	Status          string   `json:"status"`
+	Start           string   `json:"start"`
+	End             string   `json:"end"`
+	DurationMinutes int      `json:"duration_minutes"`
+	ConflictsWith   []string `json:"conflicts_with,omitempty"`
+
+	start time.Time
+	end   time.Time
+}
+
+type DurationEstimate struct {
+	SquareFootage   *int     `json:"square_footage"`
+	AddOns          []string `json:"add_ons"`
+	DurationMinutes int      `json:"duration_minutes"`
+}
+
+// EstimateDuration derives the expected time on site from square footage and add-on services.
+// Unknown add-ons are ignored.
+func EstimateDuration(squareFootage int, addOns []string) int {
+	minutes := baseMinutes
+	if squareFootage > baseSquareFootage {
+		extra := squareFootage - baseSquareFootage
+		blocks := (extra + extraSquareFootageBlock - 1) / extraSquareFootageBlock
+		minutes += blocks * extraBlockMinutes
+	}
+	for _, addOn := range normalizeAddOns(addOns) {
+		minutes += addOnMinutes[addOn]
+	}
+	return minutes
+}
+
+func normalizeAddOns(addOns []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, a := range addOns {
+		key := strings.ToLower(strings.TrimSpace(a))
+		if _, ok := addOnMinutes[key]; !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// parseStart reads an RFC 3339 timestamp. The offset is required: a bare datetime-local value
+// doesn't say which time zone the client meant, and guessing books the wrong hour.
+func parseStart(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time %q; send RFC 3339 with a UTC offset, e.g. 2025-06-01T09:00:00-05:00", value)
+	}
+	return t.UTC(), nil
+}
+
+// estimateForInspection combines the property's square footage with the inspection's own add-on tests
+func estimateForInspection(db *sql.DB, inspectionID string, requested []string) (DurationEstimate, error) {
+	var squareFootage sql.NullInt64
+	var radon, mold sql.NullBool
+	err := db.QueryRow(`
+		SELECT p.square_footage, i.radon_test, i.mold_test
+		FROM inspections i
+		JOIN properties p ON p.property_id = i.property_id
+		WHERE i.inspection_id = ?`, inspectionID).Scan(&squareFootage, &radon, &mold)
+	if err != nil {
+		return DurationEstimate{}, err
+	}
+
+	addOns := append([]string{}, requested...)
+	if radon.Valid && radon.Bool {
+		addOns = append(addOns, "radon")
+	}
+	if mold.Valid && mold.Bool {
+		addOns = append(addOns, "mold")
+	}
+
+	estimate := DurationEstimate{AddOns: normalizeAddOns(addOns)}
+	sqft := 0
+	if squareFootage.Valid {
+		sqft = int(squareFootage.Int64)
+		estimate.SquareFootage = &sqft
+	}
+	estimate.DurationMinutes = EstimateDuration(sqft, estimate.AddOns)
+	if estimate.AddOns == nil {
+		estimate.AddOns = []string{}
+	}
+	return estimate, nil
+}
+
+// findConflicts returns the inspections already booked for the inspector that overlap [start, end).
+// The rows stay locked until the transaction ends.
+func findConflicts(tx *sql.Tx, inspectorID int, inspectionID string, start, end time.Time) ([]Appointment, error) {
+	rows, err := tx.Query(appointmentSelect+`
+		WHERE i.inspector_id = ? AND i.inspection_id <> ? AND i.deleted_at IS NULL
+		  AND i.scheduled_start < ? AND i.scheduled_end > ?
+		ORDER BY i.scheduled_start
+		FOR UPDATE`,
+		inspectorID, inspectionID, end.Format(dbTimeLayout), start.Format(dbTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAppointments(rows)
+}
+
+const appointmentSelect = `
+	SELECT i.inspection_id, COALESCE(i.report_id, ''), i.inspector_id, COALESCE(i.status, ''),
+	       i.scheduled_start, i.scheduled_end, COALESCE(i.estimated_duration_minutes, 0),
+	       CONCAT(p.street, ', ', p.city, ', ', p.state, ' ', p.postal_code)
+	FROM inspections i
+	JOIN properties p ON p.property_id = i.property_id`
+
+func scanAppointments(rows *sql.Rows) ([]Appointment, error) {
+	appointments := []Appointment{}
+	for rows.Next() {
+		var a Appointment
+		var startRaw, endRaw string
+		if err := rows.Scan(&a.InspectionID, &a.ReportID, &a.InspectorID, &a.Status, &startRaw, &endRaw, &a.DurationMinutes, &a.Address); err != nil {
+			return nil, err
+		}
+		start, err := time.Parse(dbTimeLayout, startRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduled_start %q: %v", startRaw, err)
+		}
+		end, err := time.Parse(dbTimeLayout, endRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scheduled_end %q: %v", endRaw, err)
+		}
+		a.start, a.end = start, end
+		a.Start = start.Format(time.RFC3339)
+		a.End = end.Format(time.RFC3339)
+		appointments = append(appointments, a)
+	}
+	return appointments, rows.Err()
+}
+
+// callerCanManageInspector allows admins and the inspector themselves
+func callerCanManageInspector(db *sql.DB, r *http.Request, inspectorID int) bool {
+	userType, _ := r.Context().Value(middleware.UserTypeKey).(string)
+	if userType == "admin" {
+		return true
+	}
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok || userType != "inspector" {
+		return false
+	}
+	ownID, err := users.GetInspectorIDByUserID(db, userID)
+	return err == nil && ownID == inspectorID
+}
+
+// GetDurationEstimate returns the estimated appointment length for an inspection
+func GetDurationEstimate(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if inspectionID == "" {
+			http.Error(w, "inspection_id required", http.StatusBadRequest)
+			return
+		}
+
+		var requested []string
+		if raw := r.URL.Query().Get("add_ons"); raw != "" {
+			requested = strings.Split(raw, ",")
+		}
+
+		estimate, err := estimateForInspection(db, inspectionID, requested)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Inspection not found", http.StatusNotFound)
+			} else {
+				log.Printf("[GetDurationEstimate] Error estimating inspection %s: %v", inspectionID, err)
+				http.Error(w, "Failed to estimate duration", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(estimate)
+	}
+}
+
+// ScheduleInspection books an appointment for an inspector, rejecting double bookings
+func ScheduleInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		if inspectionID == "" {
+			http.Error(w, "inspection_id required", http.StatusBadRequest)
+			return
+		}
+
+		var req ScheduleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if req.InspectorID == 0 || req.Start == "" {
+			http.Error(w, "inspector_id and start are required", http.StatusBadRequest)
+			return
+		}
+
+		start, err := parseStart(req.Start)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Booking needs rights over the inspector being assigned and over any inspector being replaced
+		if !callerCanManageInspector(db, r, req.InspectorID) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		var current sql.NullInt64
+		err = db.QueryRow(`SELECT inspector_id FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionID).Scan(&current)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[ScheduleInspection] Error reading inspection %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if current.Valid && int(current.Int64) != req.InspectorID && !callerCanManageInspector(db, r, int(current.Int64)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		estimate, err := estimateForInspection(db, inspectionID, req.AddOns)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Inspection not found", http.StatusNotFound)
+			} else {
+				log.Printf("[ScheduleInspection] Error estimating inspection %s: %v", inspectionID, err)
+				http.Error(w, "Failed to estimate duration", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		duration := estimate.DurationMinutes
+		if req.DurationMinutes != nil {
+			if *req.DurationMinutes <= 0 {
+				http.Error(w, "duration_minutes must be positive", http.StatusBadRequest)
+				return
+			}
+			duration = *req.DurationMinutes
+		}
+		end := start.Add(time.Duration(duration) * time.Minute)
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Locking the inspector row serializes bookings for the inspector, so two requests can't
+		// both pass the conflict check before either is saved
+		var locked int
+		err = tx.QueryRow(`SELECT inspector_id FROM inspectors WHERE inspector_id = ? FOR UPDATE`, req.InspectorID).Scan(&locked)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspector not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("[ScheduleInspection] Error checking inspector %d: %v", req.InspectorID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		conflicts, err := findConflicts(tx, req.InspectorID, inspectionID, start, end)
+		if err != nil {
+			log.Printf("[ScheduleInspection] Error checking conflicts: %v", err)
+			http.Error(w, "Failed to check schedule conflicts", http.StatusInternalServerError)
+			return
+		}
+		if len(conflicts) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     "Inspector is already booked during this time",
+				"conflicts": conflicts,
+			})
+			return
+		}
+
+		_, err = tx.Exec(`
+			UPDATE inspections
+			SET inspector_id = ?, scheduled_start = ?, scheduled_end = ?, estimated_duration_minutes = ?, inspection_date = ?
+			WHERE inspection_id = ?`,
+			req.InspectorID, start.Format(dbTimeLayout), end.Format(dbTimeLayout), duration, start.Format("2006-01-02"), inspectionID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("[ScheduleInspection] Error updating inspection %s: %v", inspectionID, err)
+			http.Error(w, "Failed to schedule inspection", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"inspection_id":    inspectionID,
+			"inspector_id":     req.InspectorID,
+			"start":            start.Format(time.RFC3339),
+			"end":              end.Format(time.RFC3339),
+			"duration_minutes": duration,
+			"estimate":         estimate,
+		})
+	}
+}
+
+// GetInspectorSchedule lists an inspector's appointments in a date range and flags overlaps
+func GetInspectorSchedule(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var inspectorID int
+		if _, err := fmt.Sscan(mux.Vars(r)["inspector_id"], &inspectorID); err != nil {
+			http.Error(w, "Invalid inspector_id", http.StatusBadRequest)
+			return
+		}
+		if !callerCanManageInspector(db, r, inspectorID) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		from := time.Now().UTC().AddDate(0, 0, -7)
+		to := time.Now().UTC().AddDate(0, 0, 30)
+		if v := r.URL.Query().Get("from"); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, "Invalid from date", http.StatusBadRequest)
+				return
+			}
+			from = t
+		}
+		if v := r.URL.Query().Get("to"); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, "Invalid to date", http.StatusBadRequest)
+				return
+			}
+			to = t.AddDate(0, 0, 1)
+		}
+
+		rows, err := db.Query(appointmentSelect+`
+			WHERE i.inspector_id = ? AND i.deleted_at IS NULL AND i.scheduled_start < ? AND i.scheduled_end > ?
+			ORDER BY i.scheduled_start`,
+			inspectorID, to.Format(dbTimeLayout), from.Format(dbTimeLayout))
+		if err != nil {
+			log.Printf("[GetInspectorSchedule] Query error: %v", err)
+			http.Error(w, "Failed to fetch schedule", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		appointments, err := scanAppointments(rows)
+		if err != nil {
+			log.Printf("[GetInspectorSchedule] Scan error: %v", err)
+			http.Error(w, "Failed to read schedule", http.StatusInternalServerError)
+			return
+		}
+
+		// Appointments are sorted by start, so only later entries can overlap an earlier one
+		for i := range appointments {
+			for j := i + 1; j < len(appointments) && appointments[j].start.Before(appointments[i].end); j++ {
+				appointments[i].ConflictsWith = append(appointments[i].ConflictsWith, appointments[j].InspectionID)
+				appointments[j].ConflictsWith = append(appointments[j].ConflictsWith, appointments[i].InspectionID)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(appointments)
+	}
+}
+
+// CreateCalendarFeed issues (or rotates) the private iCalendar feed URL for an inspector. Only the
+// token's hash is stored, so the URL is shown once.
+func CreateCalendarFeed(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var inspectorID int
+		if _, err := fmt.Sscan(mux.Vars(r)["inspector_id"], &inspectorID); err != nil {
+			http.Error(w, "Invalid inspector_id", http.StatusBadRequest)
+			return
+		}
+
+		if !callerCanManageInspector(db, r, inspectorID) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		token, hash, err := utils.NewLinkToken()
+		if err != nil {
+			http.Error(w, "Failed to create calendar feed", http.StatusInternalServerError)
+			return
+		}
+		res, err := db.Exec(`UPDATE inspectors SET calendar_token_hash = ? WHERE inspector_id = ?`, hash, inspectorID)
+		if err != nil {
+			log.Printf("[CreateCalendarFeed] Error saving token for inspector %d: %v", inspectorID, err)
+			http.Error(w, "Failed to create calendar feed", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Inspector not found", http.StatusNotFound)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"feed_url":    fmt.Sprintf("%s://%s/api/calendar/%s.ics", scheme, r.Host, token),
+			"webcal_url":  fmt.Sprintf("webcal://%s/api/calendar/%s.ics", r.Host, token),
+			"description": "Subscribe to this URL from any calendar app. Creating a new feed revokes the previous URL.",
+		})
+	}
+}
+
+// GetCalendarFeed serves an inspector's appointments as an iCalendar document
+func GetCalendarFeed(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := mux.Vars(r)["token"]
+		if token == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var inspectorID int
+		var companyName sql.NullString
+		err := db.QueryRow(`SELECT inspector_id, company_name FROM inspectors WHERE calendar_token_hash = ?`, utils.HashLinkToken(token)).Scan(&inspectorID, &companyName)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Printf("[GetCalendarFeed] Token lookup error: %v", err)
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		// Past appointments stay in the feed for a while so calendars keep recent history
+		rows, err := db.Query(appointmentSelect+`
+			WHERE i.inspector_id = ? AND i.deleted_at IS NULL AND i.scheduled_start IS NOT NULL AND i.scheduled_end > ?
+			ORDER BY i.scheduled_start`,
+			inspectorID, time.Now().UTC().AddDate(0, -3, 0).Format(dbTimeLayout))
+		if err != nil {
+			log.Printf("[GetCalendarFeed] Query error: %v", err)
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		appointments, err := scanAppointments(rows)
+		if err != nil {
+			log.Printf("[GetCalendarFeed] Scan error: %v", err)
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+
+		calendarName := "Inspections"
+		if companyName.Valid && companyName.String != "" {
+			calendarName = companyName.String + " Inspections"
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="inspections.ics"`)
+		w.Write([]byte(BuildICalendar(calendarName, appointments, time.Now().UTC())))
+	}
+}
@@ -0,0 +1,251 @@
+package sharing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/agreements"
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/middleware"
+	"home_solutions/backend/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxExpiryDays     = 365
+	minPasswordLength = 6
+)
+
+type ShareInput struct {
+	Version       *int   `json:"version"`  // published version to show; default the latest
+	Password      string `json:"password"` // empty means no password
+	Label         string `json:"label"`
+	ExpiresInDays *int   `json:"expires_in_days"` // nil never expires
+}
+
+// Share is a public link to one published report version. The token is only filled in when the
+// share is created; afterwards only its hash is stored.
+type Share struct {
+	ShareID           string  `json:"share_id"`
+	InspectionID      string  `json:"inspection_id"`
+	ReportVersion     int     `json:"report_version"`
+	Label             *string `json:"label"`
+	PasswordProtected bool    `json:"password_protected"`
+	Status            string  `json:"status"` // active, expired or revoked
+	ExpiresAt         *string `json:"expires_at"`
+	RevokedAt         *string `json:"revoked_at"`
+	CreatedBy         *int    `json:"created_by"`
+	CreatedAt         string  `json:"created_at"`
+	ViewCount         int     `json:"view_count"`
+	LastViewedAt      *string `json:"last_viewed_at"`
+	Token             string  `json:"token,omitempty"`
+	URL               string  `json:"url,omitempty"`
+}
+
+// ViewerPath is the public page of a share
+func ViewerPath(token string) string {
+	return "/api/shared-reports/" + token
+}
+
+const shareSelect = `
+	SELECT share_id, inspection_id, report_version, label, password_hash IS NOT NULL,
+	       CASE WHEN revoked_at IS NOT NULL THEN 'revoked'
+	            WHEN expires_at IS NOT NULL AND expires_at <= UTC_TIMESTAMP() THEN 'expired'
+	            ELSE 'active' END,
+	       expires_at, revoked_at, created_by, created_at, view_count, last_viewed_at
+	FROM report_shares`
+
+func scanShare(scanner interface{ Scan(...interface{}) error }) (Share, error) {
+	var s Share
+	var createdBy sql.NullInt64
+	err := scanner.Scan(&s.ShareID, &s.InspectionID, &s.ReportVersion, &s.Label, &s.PasswordProtected, &s.Status,
+		&s.ExpiresAt, &s.RevokedAt, &createdBy, &s.CreatedAt, &s.ViewCount, &s.LastViewedAt)
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		s.CreatedBy = &id
+	}
+	return s, err
+}
+
+// CreateShare makes a public link to a published version of an inspection's report. baseURL is the
+// public origin of the API, used to build the link.
+func CreateShare(db *sql.DB, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "share reports") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+
+		var in ShareInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		var problems []string
+		if in.Password != "" && len(in.Password) < minPasswordLength {
+			problems = append(problems, fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+		}
+		if len(in.Password) > 72 {
+			// bcrypt only reads the first 72 bytes
+			problems = append(problems, "password must be at most 72 characters")
+		}
+		in.Label = strings.TrimSpace(in.Label)
+		if len(in.Label) > 255 {
+			problems = append(problems, "label must be at most 255 characters")
+		}
+		if in.ExpiresInDays != nil && (*in.ExpiresInDays < 1 || *in.ExpiresInDays > maxExpiryDays) {
+			problems = append(problems, fmt.Sprintf("expires_in_days must be between 1 and %d", maxExpiryDays))
+		}
+		if in.Version != nil && *in.Version < 1 {
+			problems = append(problems, "version must be a published version number")
+		}
+		if len(problems) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"message": "Invalid share", "errors": problems})
+			return
+		}
+
+		var exists int
+		err := db.QueryRow(`SELECT 1 FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`, inspectionID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		latest, err := publishing.LatestVersion(db, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if latest == 0 {
+			http.Error(w, "Publish the report before sharing it", http.StatusConflict)
+			return
+		}
+		version := latest
+		if in.Version != nil {
+			if *in.Version > latest {
+				http.Error(w, "Report version not found", http.StatusNotFound)
+				return
+			}
+			version = *in.Version
+		}
+		// Shared links skip the agreement gate, so the agreement has to be in place first
+		signed, err := agreements.HasSignedAgreement(db, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if !signed {
+			http.Error(w, "The inspection agreement must be signed before the report is shared", http.StatusConflict)
+			return
+		}
+
+		token, hash, err := utils.NewLinkToken()
+		if err != nil {
+			http.Error(w, "Failed to create link", http.StatusInternalServerError)
+			return
+		}
+		var passwordHash, label, expiresAt interface{}
+		if in.Password != "" {
+			h, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcrypt.DefaultCost)
+			if err != nil {
+				http.Error(w, "Failed to create link", http.StatusInternalServerError)
+				return
+			}
+			passwordHash = string(h)
+		}
+		if in.Label != "" {
+			label = in.Label
+		}
+		if in.ExpiresInDays != nil {
+			expiresAt = time.Now().UTC().AddDate(0, 0, *in.ExpiresInDays).Format("2006-01-02 15:04:05")
+		}
+
+		shareID := uuid.New().String()
+		_, err = db.Exec(`
+			INSERT INTO report_shares (share_id, inspection_id, report_version, token_hash, password_hash, label, expires_at, created_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`,
+			shareID, inspectionID, version, hash, passwordHash, label, expiresAt, middleware.CallerID(r))
+		if err != nil {
+			log.Printf("[Sharing] Error creating share for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to create link", http.StatusInternalServerError)
+			return
+		}
+
+		share, err := scanShare(db.QueryRow(shareSelect+` WHERE share_id = ?`, shareID))
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		share.Token = token
+		share.URL = strings.TrimSuffix(baseURL, "/") + ViewerPath(token)
+		log.Printf("[Sharing] Shared report %s v%d as %s", inspectionID, version, shareID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(share)
+	}
+}
+
+// ListShares returns an inspection's links, newest first, without their tokens
+func ListShares(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "share reports") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+		rows, err := db.Query(shareSelect+` WHERE inspection_id = ? ORDER BY created_at DESC`, inspectionID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		shares := []Share{}
+		for rows.Next() {
+			s, err := scanShare(rows)
+			if err != nil {
+				log.Printf("[Sharing] Error reading shares for %s: %v", inspectionID, err)
+				http.Error(w, "Database error", http.StatusInternalServerError)
+				return
+			}
+			shares = append(shares, s)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(shares)
+	}
+}
+
+// RevokeShare turns a link off for good
+func RevokeShare(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "share reports") {
+			return
+		}
+		shareID := mux.Vars(r)["share_id"]
+		res, err := db.Exec(`UPDATE report_shares SET revoked_at = COALESCE(revoked_at, UTC_TIMESTAMP()) WHERE share_id = ?`, shareID)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			var exists int
+			if err := db.QueryRow(`SELECT 1 FROM report_shares WHERE share_id = ?`, shareID).Scan(&exists); err == sql.ErrNoRows {
+				http.Error(w, "Share not found", http.StatusNotFound)
+				return
+			}
+		}
+		log.Printf("[Sharing] Revoked share %s", shareID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
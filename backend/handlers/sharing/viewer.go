@@ -0,0 +1,398 @@
+package sharing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"home_solutions/backend/handlers/publishing"
+	"home_solutions/backend/handlers/reports"
+	"home_solutions/backend/utils"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const unlockCookie = "report_share"
+
+// shareTarget is what a share token unlocks
+type shareTarget struct {
+	ShareID       string
+	Token         string
+	InspectionID  string
+	ReportVersion int
+	PasswordHash  sql.NullString
+	Revoked       bool
+	Expired       bool
+}
+
+// lookupShare resolves a token; links to trashed inspections don't resolve
+func lookupShare(db *sql.DB, token string) (*shareTarget, error) {
+	t := &shareTarget{Token: token}
+	err := db.QueryRow(`
+		SELECT s.share_id, s.inspection_id, s.report_version, s.password_hash, s.revoked_at IS NOT NULL,
+		       s.expires_at IS NOT NULL AND s.expires_at <= UTC_TIMESTAMP()
+		FROM report_shares s
+		JOIN inspections i ON i.inspection_id = s.inspection_id
+		WHERE s.token_hash = ? AND i.deleted_at IS NULL`, utils.HashLinkToken(token)).
+		Scan(&t.ShareID, &t.InspectionID, &t.ReportVersion, &t.PasswordHash, &t.Revoked, &t.Expired)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// unlockValue is the cookie proving the password was entered. It is tied to the stored hash, so
+// changing or removing the password invalidates it.
+func (t *shareTarget) unlockValue() string {
+	sum := sha256.Sum256([]byte(t.Token + "\x00" + t.PasswordHash.String))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *shareTarget) unlocked(r *http.Request) bool {
+	if !t.PasswordHash.Valid {
+		return true
+	}
+	c, err := r.Cookie(unlockCookie)
+	return err == nil && hmac.Equal([]byte(c.Value), []byte(t.unlockValue()))
+}
+
+func (t *shareTarget) path(suffix string) string {
+	return ViewerPath(t.Token) + suffix
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: Helvetica, Arial, sans-serif; color: #222; background: #f4f4f4; margin: 0; }
+  .box { max-width: 360px; margin: 12vh auto; background: #fff; padding: 28px; border-radius: 6px; box-shadow: 0 1px 4px rgba(0,0,0,.12); }
+  h1 { font-size: 20px; margin-top: 0; }
+  input { width: 100%; box-sizing: border-box; padding: 8px; margin: 8px 0 12px; font-size: 15px; }
+  button { padding: 8px 16px; font-size: 15px; }
+  .error { color: #b00020; }
+</style>
+</head>
+<body>
+<div class="box">
+  <h1>{{.Title}}</h1>
+  {{if .Message}}<p{{if .Form}} class="error"{{end}}>{{.Message}}</p>{{end}}
+  {{if .Form}}
+  <form method="post" action="{{.Action}}">
+    <label for="password">Enter the password you were given to view this report.</label>
+    <input id="password" name="password" type="password" autocomplete="current-password" autofocus required>
+    <button type="submit">View report</button>
+  </form>
+  {{end}}
+</div>
+</body>
+</html>
+`))
+
+type pageView struct {
+	Title   string
+	Message string
+	Form    bool
+	Action  string
+}
+
+func writePage(w http.ResponseWriter, status int, view pageView) {
+	setPrivate(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, view); err != nil {
+		log.Printf("[Sharing] Error rendering page: %v", err)
+	}
+}
+
+// setPrivate keeps shared pages out of caches, search engines and other sites' referrer logs
+func setPrivate(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+}
+
+// openShare resolves the request's token and checks it is live and unlocked, writing the error
+// response otherwise. page selects HTML error pages over plain text for the report page itself.
+func openShare(db *sql.DB, w http.ResponseWriter, r *http.Request, page bool) (*shareTarget, bool) {
+	fail := func(status int, title, message string) {
+		if page {
+			writePage(w, status, pageView{Title: title, Message: message})
+			return
+		}
+		setPrivate(w)
+		http.Error(w, message, status)
+	}
+
+	t, err := lookupShare(db, mux.Vars(r)["token"])
+	if err == sql.ErrNoRows {
+		fail(http.StatusNotFound, "Report not found", "This report link is not valid.")
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[Sharing] Error looking up share: %v", err)
+		fail(http.StatusInternalServerError, "Something went wrong", "The report could not be loaded. Please try again later.")
+		return nil, false
+	}
+	if t.Revoked || t.Expired {
+		fail(http.StatusGone, "Link no longer available", "This report link has expired. Ask your inspector for a new one.")
+		return nil, false
+	}
+	if !t.unlocked(r) {
+		if page {
+			writePage(w, http.StatusUnauthorized, pageView{Title: "Password required", Form: true, Action: t.path("")})
+			return nil, false
+		}
+		fail(http.StatusUnauthorized, "", "Password required")
+		return nil, false
+	}
+	return t, true
+}
+
+func loadShared(db *sql.DB, w http.ResponseWriter, t *shareTarget) (*reports.Document, *reports.Theme, bool) {
+	doc, err := reports.LoadDocument(db, t.InspectionID, t.ReportVersion)
+	if err != nil {
+		log.Printf("[Sharing] Error loading report for share %s: %v", t.ShareID, err)
+		http.Error(w, "Failed to load report", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	theme, err := reports.LoadTheme(db, doc.OrganizationID)
+	if err != nil {
+		log.Printf("[Sharing] Error loading theme for share %s: %v", t.ShareID, err)
+		http.Error(w, "Failed to load report theme", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return doc, theme, true
+}
+
+// ViewSharedReport renders the shared report version as a web page. No account is needed; the
+// token in the URL is the credential. Photos are linked through the share so the page doesn't
+// expose upload paths.
+func ViewSharedReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := openShare(db, w, r, true)
+		if !ok {
+			return
+		}
+		doc, theme, ok := loadShared(db, w, t)
+		if !ok {
+			return
+		}
+
+		photos := make([]publishing.SnapshotPhoto, len(doc.Photos))
+		for i, p := range doc.Photos {
+			p.URL = t.path(fmt.Sprintf("/photos/%d", p.PhotoID))
+			photos[i] = p
+		}
+		doc.Photos = photos
+		if doc.CoverPhotoURL != nil {
+			cover := t.path("/cover")
+			doc.CoverPhotoURL = &cover
+		}
+		doc.ThumbnailURL = func(photoID int) string { return t.path(fmt.Sprintf("/photos/%d/thumbnail", photoID)) }
+
+		var buf bytes.Buffer
+		if err := reports.RenderHTML(&buf, doc, theme); err != nil {
+			log.Printf("[Sharing] Error rendering share %s: %v", t.ShareID, err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		if _, err := db.Exec(`UPDATE report_shares SET view_count = view_count + 1, last_viewed_at = UTC_TIMESTAMP() WHERE share_id = ?`, t.ShareID); err != nil {
+			log.Printf("[Sharing] Error recording view of share %s: %v", t.ShareID, err)
+		}
+		setPrivate(w)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
+
+// UnlockSharedReport checks the password posted from the viewer's form and, when it matches,
+// sets a cookie scoped to the share's path and returns to the report
+func UnlockSharedReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := lookupShare(db, mux.Vars(r)["token"])
+		if err == sql.ErrNoRows {
+			writePage(w, http.StatusNotFound, pageView{Title: "Report not found", Message: "This report link is not valid."})
+			return
+		}
+		if err != nil {
+			log.Printf("[Sharing] Error looking up share: %v", err)
+			writePage(w, http.StatusInternalServerError, pageView{Title: "Something went wrong", Message: "The report could not be loaded. Please try again later."})
+			return
+		}
+		if t.Revoked || t.Expired {
+			writePage(w, http.StatusGone, pageView{Title: "Link no longer available", Message: "This report link has expired. Ask your inspector for a new one."})
+			return
+		}
+		if !t.PasswordHash.Valid {
+			http.Redirect(w, r, t.path(""), http.StatusSeeOther)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, 4096)
+		password := ""
+		if err := r.ParseForm(); err == nil {
+			password = r.PostForm.Get("password")
+		}
+		if bcrypt.CompareHashAndPassword([]byte(t.PasswordHash.String), []byte(password)) != nil {
+			log.Printf("[Sharing] Wrong password for share %s", t.ShareID)
+			writePage(w, http.StatusUnauthorized, pageView{Title: "Password required", Message: "That password is not correct.", Form: true, Action: t.path("")})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     unlockCookie,
+			Value:    t.unlockValue(),
+			Path:     t.path(""),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, t.path(""), http.StatusSeeOther)
+	}
+}
+
+// DownloadSharedReport serves the shared version as a PDF
+func DownloadSharedReport(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := openShare(db, w, r, false)
+		if !ok {
+			return
+		}
+		doc, theme, ok := loadShared(db, w, t)
+		if !ok {
+			return
+		}
+		// Render fully before writing so a failure can still return an error status
+		var buf bytes.Buffer
+		if err := reports.RenderPDF(&buf, doc, theme); err != nil {
+			log.Printf("[Sharing] Error rendering PDF for share %s: %v", t.ShareID, err)
+			http.Error(w, "Failed to render report", http.StatusInternalServerError)
+			return
+		}
+		name := t.InspectionID
+		if doc.Inspection.ReportID != nil && *doc.Inspection.ReportID != "" {
+			name = *doc.Inspection.ReportID
+		}
+		setPrivate(w)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("inspection-report-%s-v%d.pdf", name, t.ReportVersion)))
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Write(buf.Bytes())
+	}
+}
+
+// sharedPhotoURL finds a photo in the shared version's snapshot. Only photos published in that
+// version are reachable through the share, even if the inspection has others.
+func sharedPhotoURL(db *sql.DB, w http.ResponseWriter, r *http.Request, t *shareTarget) (string, bool) {
+	photoID, err := strconv.Atoi(mux.Vars(r)["photo_id"])
+	if err != nil {
+		http.Error(w, "Invalid photo id", http.StatusBadRequest)
+		return "", false
+	}
+	snap, _, err := publishing.LoadVersionSnapshot(db, t.InspectionID, t.ReportVersion)
+	if err != nil {
+		log.Printf("[Sharing] Error loading snapshot for share %s: %v", t.ShareID, err)
+		http.Error(w, "Failed to load report", http.StatusInternalServerError)
+		return "", false
+	}
+	for _, p := range snap.Photos {
+		if p.PhotoID == photoID && strings.HasPrefix(p.URL, "/uploads/") && !strings.Contains(p.URL, "..") {
+			return p.URL, true
+		}
+	}
+	http.Error(w, "Photo not found", http.StatusNotFound)
+	return "", false
+}
+
+// GetSharedPhoto serves a full-size photo of the shared report
+func GetSharedPhoto(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := openShare(db, w, r, false)
+		if !ok {
+			return
+		}
+		url, ok := sharedPhotoURL(db, w, r, t)
+		if !ok {
+			return
+		}
+		serveUpload(w, r, url)
+	}
+}
+
+// GetSharedThumbnail serves a thumbnail of a photo of the shared report, for the findings summary
+func GetSharedThumbnail(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := openShare(db, w, r, false)
+		if !ok {
+			return
+		}
+		url, ok := sharedPhotoURL(db, w, r, t)
+		if !ok {
+			return
+		}
+		data, err := reports.Thumbnail(url, reports.ThumbnailSize)
+		if os.IsNotExist(err) {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Sharing] Error making thumbnail for share %s: %v", t.ShareID, err)
+			http.Error(w, "Failed to read photo", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Write(data)
+	}
+}
+
+// GetSharedCover serves the cover photo recorded in the shared version's snapshot
+func GetSharedCover(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := openShare(db, w, r, false)
+		if !ok {
+			return
+		}
+		snap, _, err := publishing.LoadVersionSnapshot(db, t.InspectionID, t.ReportVersion)
+		if err != nil {
+			log.Printf("[Sharing] Error loading snapshot for share %s: %v", t.ShareID, err)
+			http.Error(w, "Failed to load report", http.StatusInternalServerError)
+			return
+		}
+		cover, err := publishing.PublishedCover(db, t.InspectionID, snap)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if cover == nil || !strings.HasPrefix(cover.URL, "/uploads/") || strings.Contains(cover.URL, "..") {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		serveUpload(w, r, cover.URL)
+	}
+}
+
+func serveUpload(w http.ResponseWriter, r *http.Request, url string) {
+	if _, err := os.Stat("." + url); err != nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.ServeFile(w, r, "."+url)
+}
@@ -0,0 +1,405 @@
+package sitevisits
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+// OnSiteRadiusMeters is how far from the property's coordinates a fix may be and still count as on site.
+// The fix's reported accuracy is added on top, so a coarse GPS reading isn't flagged as off site.
+const OnSiteRadiusMeters = 150.0
+
+// sectionIdleGap caps the time credited between two worksheet saves; longer gaps are breaks
+const sectionIdleGap = 15 * time.Minute
+
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+type CheckRequest struct {
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Accuracy  *float64 `json:"accuracy,omitempty"` // meters, as reported by the device
+}
+
+// Fix is one recorded GPS position and how it compares with the property location
+type Fix struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	AccuracyM *float64 `json:"accuracy_m"`
+	DistanceM *float64 `json:"distance_m"` // nil when the property has no coordinates
+	OnSite    *bool    `json:"on_site"`
+}
+
+type Visit struct {
+	VisitID         int     `json:"visit_id"`
+	InspectionID    string  `json:"inspection_id"`
+	UserID          *int    `json:"user_id"`
+	CheckInAt       string  `json:"check_in_at"` // UTC
+	CheckIn         Fix     `json:"check_in"`
+	CheckOutAt      *string `json:"check_out_at"`
+	CheckOut        *Fix    `json:"check_out"`
+	DurationMinutes float64 `json:"duration_minutes"` // up to now while still checked in
+}
+
+// SectionTime is the worksheet time attributed to one section, derived from its save timestamps
+type SectionTime struct {
+	Section       string  `json:"section"`
+	Title         string  `json:"title"`
+	Saves         int     `json:"saves"`
+	FirstSave     string  `json:"first_save"`
+	LastSave      string  `json:"last_save"`
+	ActiveMinutes float64 `json:"active_minutes"`
+}
+
+type Summary struct {
+	InspectionID      string        `json:"inspection_id"`
+	PropertyLocated   bool          `json:"property_located"`
+	CheckedIn         bool          `json:"checked_in"`
+	TimeOnSiteMinutes float64       `json:"time_on_site_minutes"`
+	LocationVerified  *bool         `json:"location_verified"` // every fix was on site; nil when it can't be told
+	Visits            []Visit       `json:"visits"`
+	Sections          []SectionTime `json:"sections"`
+	WorksheetMinutes  float64       `json:"worksheet_minutes"`
+}
+
+// distanceMeters is the great-circle distance between two coordinates
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return earthRadius * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// compare fills in the fix's distance from the property and whether that counts as on site
+func (f *Fix) compare(propertyLat, propertyLon sql.NullFloat64) {
+	if !propertyLat.Valid || !propertyLon.Valid {
+		return
+	}
+	distance := roundTo(distanceMeters(f.Latitude, f.Longitude, propertyLat.Float64, propertyLon.Float64), 1)
+	f.DistanceM = &distance
+	allowed := OnSiteRadiusMeters
+	if f.AccuracyM != nil {
+		allowed += *f.AccuracyM
+	}
+	onSite := distance <= allowed
+	f.OnSite = &onSite
+}
+
+func propertyLocation(db *sql.DB, inspectionID string) (sql.NullFloat64, sql.NullFloat64, error) {
+	var lat, lon sql.NullFloat64
+	err := db.QueryRow(`
+		SELECT p.latitude, p.longitude FROM inspections i JOIN properties p ON p.property_id = i.property_id
+		WHERE i.inspection_id = ? AND i.deleted_at IS NULL`, inspectionID).Scan(&lat, &lon)
+	return lat, lon, err
+}
+
+func loadVisits(db *sql.DB, inspectionID string, propertyLat, propertyLon sql.NullFloat64) ([]Visit, error) {
+	rows, err := db.Query(`
+		SELECT visit_id, inspection_id, user_id, check_in_at, check_in_latitude, check_in_longitude, check_in_accuracy_m,
+			check_out_at, check_out_latitude, check_out_longitude, check_out_accuracy_m
+		FROM inspection_site_visits WHERE inspection_id = ? ORDER BY check_in_at, visit_id`, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	visits := []Visit{}
+	for rows.Next() {
+		var v Visit
+		var userID sql.NullInt64
+		var outLat, outLon, outAccuracy sql.NullFloat64
+		if err := rows.Scan(&v.VisitID, &v.InspectionID, &userID, &v.CheckInAt, &v.CheckIn.Latitude, &v.CheckIn.Longitude,
+			&v.CheckIn.AccuracyM, &v.CheckOutAt, &outLat, &outLon, &outAccuracy); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			v.UserID = &id
+		}
+		v.CheckIn.compare(propertyLat, propertyLon)
+
+		end := now
+		if v.CheckOutAt != nil {
+			out := &Fix{Latitude: outLat.Float64, Longitude: outLon.Float64}
+			if outAccuracy.Valid {
+				out.AccuracyM = &outAccuracy.Float64
+			}
+			out.compare(propertyLat, propertyLon)
+			v.CheckOut = out
+			if t, err := time.Parse(dbTimeLayout, *v.CheckOutAt); err == nil {
+				end = t
+			}
+		}
+		if start, err := time.Parse(dbTimeLayout, v.CheckInAt); err == nil && end.After(start) {
+			v.DurationMinutes = roundTo(end.Sub(start).Minutes(), 1)
+		}
+		visits = append(visits, v)
+	}
+	return visits, rows.Err()
+}
+
+// sectionTimes walks every worksheet save in order and credits the time since the previous save
+// (capped at sectionIdleGap) to the section that was saved.
+func sectionTimes(db *sql.DB, inspectionID string) ([]SectionTime, float64, error) {
+	rows, err := db.Query(`
+		SELECT section, created_at FROM inspection_item_revisions
+		WHERE inspection_id = ? AND action <> 'baseline'
+		ORDER BY created_at, revision_id`, inspectionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	bySection := map[string]*SectionTime{}
+	var previous time.Time
+	var total float64
+	for rows.Next() {
+		var key, createdAt string
+		if err := rows.Scan(&key, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		savedAt, err := time.Parse(dbTimeLayout, createdAt)
+		if err != nil {
+			continue
+		}
+		st, ok := bySection[key]
+		if !ok {
+			st = &SectionTime{Section: key, Title: key, FirstSave: createdAt}
+			if section, found := inspections.SectionByKey(key); found {
+				st.Title = section.Title
+			}
+			bySection[key] = st
+		}
+		st.Saves++
+		st.LastSave = createdAt
+		if !previous.IsZero() {
+			if gap := savedAt.Sub(previous); gap > 0 && gap <= sectionIdleGap {
+				st.ActiveMinutes += gap.Minutes()
+				total += gap.Minutes()
+			}
+		}
+		previous = savedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Report order follows the worksheet, not the order sections were touched
+	sections := []SectionTime{}
+	for _, section := range inspections.Sections {
+		if st, ok := bySection[section.Key]; ok {
+			st.ActiveMinutes = roundTo(st.ActiveMinutes, 1)
+			sections = append(sections, *st)
+		}
+	}
+	return sections, roundTo(total, 1), nil
+}
+
+// LoadSummary gathers an inspection's site visits, time on site and per-section worksheet time
+func LoadSummary(db *sql.DB, inspectionID string) (*Summary, error) {
+	lat, lon, err := propertyLocation(db, inspectionID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{InspectionID: inspectionID, PropertyLocated: lat.Valid && lon.Valid}
+
+	if summary.Visits, err = loadVisits(db, inspectionID, lat, lon); err != nil {
+		return nil, err
+	}
+	var onSite *bool
+	for _, v := range summary.Visits {
+		summary.TimeOnSiteMinutes += v.DurationMinutes
+		if v.CheckOut == nil {
+			summary.CheckedIn = true
+		}
+		for _, fix := range []*Fix{&v.CheckIn, v.CheckOut} {
+			if fix == nil || fix.OnSite == nil {
+				continue
+			}
+			verified := *fix.OnSite && (onSite == nil || *onSite)
+			onSite = &verified
+		}
+	}
+	summary.TimeOnSiteMinutes = roundTo(summary.TimeOnSiteMinutes, 1)
+	summary.LocationVerified = onSite
+
+	if summary.Sections, summary.WorksheetMinutes, err = sectionTimes(db, inspectionID); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func decodeFix(w http.ResponseWriter, r *http.Request) (*Fix, bool) {
+	var req CheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Latitude == nil || req.Longitude == nil ||
+		*req.Latitude < -90 || *req.Latitude > 90 || *req.Longitude < -180 || *req.Longitude > 180 {
+		http.Error(w, "latitude and longitude are required and must be valid coordinates", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Accuracy != nil && *req.Accuracy < 0 {
+		http.Error(w, "accuracy must not be negative", http.StatusBadRequest)
+		return nil, false
+	}
+	return &Fix{Latitude: *req.Latitude, Longitude: *req.Longitude, AccuracyM: req.Accuracy}, true
+}
+
+// respondVisit returns the inspection's visit with visitID along with the refreshed summary
+func respondVisit(w http.ResponseWriter, db *sql.DB, inspectionID string, visitID int, status int) {
+	summary, err := LoadSummary(db, inspectionID)
+	if err != nil {
+		log.Printf("[SiteVisits] Error loading summary for %s: %v", inspectionID, err)
+		http.Error(w, "Failed to load site visits", http.StatusInternalServerError)
+		return
+	}
+	var visit *Visit
+	for i := range summary.Visits {
+		if summary.Visits[i].VisitID == visitID {
+			visit = &summary.Visits[i]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"visit":   visit,
+		"summary": summary,
+	})
+}
+
+// CheckIn records that the inspector arrived, with the device's GPS fix. Off-site fixes are still
+// recorded and flagged, so the record shows where the check-in really happened.
+func CheckIn(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "check in") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+		fix, ok := decodeFix(w, r)
+		if !ok {
+			return
+		}
+
+		lat, lon, err := propertyLocation(db, inspectionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		var open bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM inspection_site_visits WHERE inspection_id = ? AND check_out_at IS NULL)`,
+			inspectionID).Scan(&open); err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if open {
+			http.Error(w, "Already checked in; check out first", http.StatusConflict)
+			return
+		}
+
+		fix.compare(lat, lon)
+		userID := middleware.CallerID(r)
+		res, err := db.Exec(`
+			INSERT INTO inspection_site_visits (inspection_id, user_id, check_in_at, check_in_latitude, check_in_longitude,
+				check_in_accuracy_m, check_in_distance_m)
+			VALUES (?, ?, UTC_TIMESTAMP(), ?, ?, ?, ?)`,
+			inspectionID, userID, fix.Latitude, fix.Longitude, fix.AccuracyM, fix.DistanceM)
+		if err != nil {
+			log.Printf("[SiteVisits] Error checking in to %s: %v", inspectionID, err)
+			http.Error(w, "Failed to check in", http.StatusInternalServerError)
+			return
+		}
+		visitID, _ := res.LastInsertId()
+		respondVisit(w, db, inspectionID, int(visitID), http.StatusCreated)
+	}
+}
+
+// CheckOut closes the inspection's open visit with the departure GPS fix
+func CheckOut(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !middleware.RequireStaff(w, r, "check in") {
+			return
+		}
+		inspectionID := mux.Vars(r)["inspection_id"]
+		fix, ok := decodeFix(w, r)
+		if !ok {
+			return
+		}
+
+		lat, lon, err := propertyLocation(db, inspectionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		var visitID int
+		err = db.QueryRow(`SELECT visit_id FROM inspection_site_visits WHERE inspection_id = ? AND check_out_at IS NULL ORDER BY visit_id DESC LIMIT 1`,
+			inspectionID).Scan(&visitID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Not checked in", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		fix.compare(lat, lon)
+		_, err = db.Exec(`
+			UPDATE inspection_site_visits SET check_out_at = UTC_TIMESTAMP(), check_out_latitude = ?, check_out_longitude = ?,
+				check_out_accuracy_m = ?, check_out_distance_m = ?
+			WHERE visit_id = ? AND check_out_at IS NULL`,
+			fix.Latitude, fix.Longitude, fix.AccuracyM, fix.DistanceM, visitID)
+		if err != nil {
+			log.Printf("[SiteVisits] Error checking out of %s: %v", inspectionID, err)
+			http.Error(w, "Failed to check out", http.StatusInternalServerError)
+			return
+		}
+		respondVisit(w, db, inspectionID, visitID, http.StatusOK)
+	}
+}
+
+// GetSiteVisits returns the visit log, time on site and per-section worksheet time for an inspection
+func GetSiteVisits(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		summary, err := LoadSummary(db, inspectionID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[SiteVisits] Error loading summary for %s: %v", inspectionID, err)
+			http.Error(w, "Failed to load site visits", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
@@ -0,0 +1,277 @@
+package trash
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+)
+
+// DefaultRetention is how long trashed records are kept when TRASH_RETENTION_DAYS is not set
+const DefaultRetention = 30 * 24 * time.Hour
+
+// RetentionFromEnv reads TRASH_RETENTION_DAYS
+func RetentionFromEnv() time.Duration {
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+		log.Printf("[Trash] Ignoring invalid TRASH_RETENTION_DAYS %q", v)
+	}
+	return DefaultRetention
+}
+
+// PurgeResult counts what a retention run hard-deleted
+type PurgeResult struct {
+	Properties     int `json:"properties"`
+	Inspections    int `json:"inspections"`
+	Photos         int `json:"photos"`
+	PropertyPhotos int `json:"property_photos"`
+	// Kept counts expired inspections and properties left in the trash because they have published report
+	// versions or signed agreements; those records are immutable and must outlive the retention window
+	Kept int `json:"kept"`
+}
+
+// Tables that reference inspections without ON DELETE CASCADE, or without a foreign key at all.
+// They are cleared by hand before the inspection row goes; everything else cascades.
+var inspectionChildTables = []string{
+	"inspection_exterior",
+	"inspection_photos",
+	"inspection_analysis",
+	"home_health_score",
+	"property_photos",
+}
+
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// removeUploadFile deletes a file under uploads/ given its public URL
+func removeUploadFile(url string) {
+	if !strings.HasPrefix(url, "/uploads/") || strings.Contains(url, "..") {
+		return
+	}
+	if err := os.Remove("." + url); err != nil && !os.IsNotExist(err) {
+		log.Printf("[Trash] Failed to delete file %s: %v", url, err)
+	}
+}
+
+// hasImmutableRecords reports whether an inspection has published report versions or a signed
+// agreement. Purging would cascade-delete them, and cascades don't fire the triggers that
+// otherwise refuse those deletes.
+func hasImmutableRecords(db *sql.DB, inspectionID string) (bool, error) {
+	var immutable bool
+	err := db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM report_versions WHERE inspection_id = ?)
+		    OR EXISTS(SELECT 1 FROM inspection_agreements WHERE inspection_id = ? AND status = 'signed')`,
+		inspectionID, inspectionID).Scan(&immutable)
+	return immutable, err
+}
+
+// purgeInspection hard-deletes an inspection with all its rows, then removes its files once nothing
+// else references them (photos linked into cloned inspections share files).
+func purgeInspection(db *sql.DB, inspectionID string) error {
+	photoURLs, err := queryStrings(db, `SELECT DISTINCT photo_url FROM inspection_photos WHERE inspection_id = ?`, inspectionID)
+	if err != nil {
+		return err
+	}
+	coverURLs, err := queryStrings(db, `SELECT photo_url FROM property_photos WHERE inspection_id = ?`, inspectionID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range inspectionChildTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE inspection_id = ?`, inspectionID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE inspections SET cloned_from = NULL WHERE cloned_from = ?`, inspectionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM inspections WHERE inspection_id = ?`, inspectionID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, url := range photoURLs {
+		inspections.RemoveUnreferencedPhotoFile(db, url)
+	}
+	for _, url := range coverURLs {
+		removeUploadFile(url)
+	}
+	return nil
+}
+
+// purgeProperty hard-deletes a property together with every inspection recorded for it. A property
+// with a published or signed inspection is kept whole; kept reports that.
+func purgeProperty(db *sql.DB, propertyID string) (purged int, kept bool, err error) {
+	inspectionIDs, err := queryStrings(db, `SELECT inspection_id FROM inspections WHERE property_id = ?`, propertyID)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, id := range inspectionIDs {
+		immutable, err := hasImmutableRecords(db, id)
+		if err != nil {
+			return 0, false, err
+		}
+		if immutable {
+			return 0, true, nil
+		}
+	}
+	for _, id := range inspectionIDs {
+		if err := purgeInspection(db, id); err != nil {
+			return 0, false, err
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"user_properties", "home_health_score"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE property_id = ?`, propertyID); err != nil {
+			return 0, false, err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM properties WHERE property_id = ?`, propertyID); err != nil {
+		return 0, false, err
+	}
+	return len(inspectionIDs), false, tx.Commit()
+}
+
+// Purge hard-deletes everything that was trashed before cutoff, including files under uploads/.
+// Records are purged one at a time, so a failure leaves the rest for the next run.
+func Purge(db *sql.DB, cutoff time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	before := cutoff.UTC().Format(dbTimeLayout)
+
+	photos, err := db.Query(`SELECT photo_id, photo_url FROM inspection_photos WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+	if err != nil {
+		return result, err
+	}
+	type trashedPhoto struct {
+		id  int
+		url string
+	}
+	var trashedPhotos []trashedPhoto
+	for photos.Next() {
+		var p trashedPhoto
+		if err := photos.Scan(&p.id, &p.url); err != nil {
+			photos.Close()
+			return result, err
+		}
+		trashedPhotos = append(trashedPhotos, p)
+	}
+	photos.Close()
+	for _, p := range trashedPhotos {
+		if _, err := db.Exec(`DELETE FROM inspection_photos WHERE photo_id = ?`, p.id); err != nil {
+			return result, err
+		}
+		inspections.RemoveUnreferencedPhotoFile(db, p.url)
+		result.Photos++
+	}
+
+	covers, err := queryStrings(db, `SELECT photo_id FROM property_photos WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+	if err != nil {
+		return result, err
+	}
+	for _, id := range covers {
+		var url string
+		if err := db.QueryRow(`SELECT photo_url FROM property_photos WHERE photo_id = ?`, id).Scan(&url); err != nil {
+			return result, err
+		}
+		if _, err := db.Exec(`DELETE FROM property_photos WHERE photo_id = ?`, id); err != nil {
+			return result, err
+		}
+		removeUploadFile(url)
+		result.PropertyPhotos++
+	}
+
+	inspectionIDs, err := queryStrings(db, `SELECT inspection_id FROM inspections WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+	if err != nil {
+		return result, err
+	}
+	for _, id := range inspectionIDs {
+		immutable, err := hasImmutableRecords(db, id)
+		if err != nil {
+			return result, err
+		}
+		if immutable {
+			log.Printf("[Trash] Keeping inspection %s: it has published report versions or a signed agreement", id)
+			result.Kept++
+			continue
+		}
+		if err := purgeInspection(db, id); err != nil {
+			return result, err
+		}
+		result.Inspections++
+	}
+
+	propertyIDs, err := queryStrings(db, `SELECT property_id FROM properties WHERE deleted_at IS NOT NULL AND deleted_at < ?`, before)
+	if err != nil {
+		return result, err
+	}
+	for _, id := range propertyIDs {
+		purged, kept, err := purgeProperty(db, id)
+		if err != nil {
+			return result, err
+		}
+		if kept {
+			log.Printf("[Trash] Keeping property %s: an inspection has published report versions or a signed agreement", id)
+			result.Kept++
+			continue
+		}
+		result.Properties++
+		result.Inspections += purged
+	}
+	return result, nil
+}
+
+// StartRetentionJob purges expired trash once at startup and then daily in the background
+func StartRetentionJob(db *sql.DB, retention time.Duration) {
+	run := func() {
+		result, err := Purge(db, time.Now().UTC().Add(-retention))
+		if err != nil {
+			log.Printf("[Trash] Retention run failed: %v", err)
+			return
+		}
+		if result.Properties+result.Inspections+result.Photos+result.PropertyPhotos > 0 {
+			log.Printf("[Trash] Purged %d properties, %d inspections, %d photos, %d cover photos",
+				result.Properties, result.Inspections, result.Photos, result.PropertyPhotos)
+		}
+	}
+	go func() {
+		run()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
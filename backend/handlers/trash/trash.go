@@ -0,0 +1,328 @@
+package trash
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"home_solutions/backend/handlers/inspections"
+	"home_solutions/backend/middleware"
+	"home_solutions/backend/models/users"
+
+	"github.com/gorilla/mux"
+)
+
+// Item is one trashed record as shown in the admin trash view
+type Item struct {
+	Type         string `json:"type"` // inspection, property, photo or property_photo (a cover photo)
+	ID           string `json:"id"`
+	Label        string `json:"label"`
+	PropertyID   string `json:"property_id,omitempty"`
+	InspectionID string `json:"inspection_id,omitempty"`
+	PhotoURL     string `json:"photo_url,omitempty"`
+	DeletedAt    string `json:"deleted_at"`  // UTC
+	PurgeAfter   string `json:"purge_after"` // UTC; the retention job hard-deletes it after this
+}
+
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if userType, _ := r.Context().Value(middleware.UserTypeKey).(string); userType != "admin" {
+		http.Error(w, "Only admins can manage the trash", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// DeleteInspection moves an inspection to the trash. Admins can trash any inspection; inspectors
+// only their own, and not once the report is published.
+func DeleteInspection(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspectionID := mux.Vars(r)["inspection_id"]
+		userType, _ := r.Context().Value(middleware.UserTypeKey).(string)
+		userID, _ := r.Context().Value(middleware.UserIDKey).(int)
+
+		var inspectorID sql.NullInt64
+		var status sql.NullString
+		err := db.QueryRow(`SELECT inspector_id, status FROM inspections WHERE inspection_id = ? AND deleted_at IS NULL`,
+			inspectionID).Scan(&inspectorID, &status)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inspection not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[Trash] Error loading inspection %s: %v", inspectionID, err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		switch userType {
+		case "admin":
+		case "inspector":
+			ownID, err := users.GetInspectorIDByUserID(db, userID)
+			if err != nil || !inspectorID.Valid || int64(ownID) != inspectorID.Int64 {
+				http.Error(w, "You can only delete your own inspections", http.StatusForbidden)
+				return
+			}
+			if status.String == "published" {
+				http.Error(w, "Published inspections can only be deleted by an admin", http.StatusForbidden)
+				return
+			}
+		default:
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if _, err := db.Exec(`UPDATE inspections SET deleted_at = UTC_TIMESTAMP() WHERE inspection_id = ?`, inspectionID); err != nil {
+			log.Printf("[Trash] Error deleting inspection %s: %v", inspectionID, err)
+			http.Error(w, "Failed to delete inspection", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]string{"message": "Inspection moved to trash"})
+	}
+}
+
+// DeleteProperty moves a property to the trash. Its inspections disappear from listings with it and are
+// purged together with the property.
+func DeleteProperty(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		propertyID := mux.Vars(r)["property_id"]
+		res, err := db.Exec(`UPDATE properties SET deleted_at = UTC_TIMESTAMP() WHERE property_id = ? AND deleted_at IS NULL`, propertyID)
+		var affected int64
+		if err == nil {
+			affected, err = res.RowsAffected()
+		}
+		if err != nil {
+			log.Printf("[Trash] Error deleting property %s: %v", propertyID, err)
+			http.Error(w, "Failed to delete property", http.StatusInternalServerError)
+			return
+		}
+		if affected == 0 {
+			http.Error(w, "Property not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]string{"message": "Property moved to trash"})
+	}
+}
+
+func listTrashed(db *sql.DB, query string, retention time.Duration, scan func(*sql.Rows, *Item) error) ([]Item, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		var item Item
+		if err := scan(rows, &item); err != nil {
+			return nil, err
+		}
+		if deletedAt, err := time.Parse(dbTimeLayout, item.DeletedAt); err == nil {
+			item.PurgeAfter = deletedAt.Add(retention).Format(dbTimeLayout)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ListTrash is the admin trash view: every trashed inspection, property and photo, newest first.
+// ?type=inspection|property|photo|property_photo narrows it to one kind.
+func ListTrash(db *sql.DB, retention time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		kind := r.URL.Query().Get("type")
+		items := []Item{}
+
+		if kind == "" || kind == "property" {
+			found, err := listTrashed(db, `
+				SELECT property_id, CONCAT(street, ', ', city, ', ', state, ' ', postal_code), deleted_at
+				FROM properties WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`, retention,
+				func(rows *sql.Rows, item *Item) error {
+					item.Type = "property"
+					err := rows.Scan(&item.ID, &item.Label, &item.DeletedAt)
+					item.PropertyID = item.ID
+					return err
+				})
+			if err != nil {
+				log.Printf("[Trash] Error listing properties: %v", err)
+				http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, found...)
+		}
+		if kind == "" || kind == "inspection" {
+			found, err := listTrashed(db, `
+				SELECT i.inspection_id, CONCAT(COALESCE(i.report_id, i.inspection_id), ' - ', p.street, ', ', p.city), i.property_id, i.deleted_at
+				FROM inspections i JOIN properties p ON p.property_id = i.property_id
+				WHERE i.deleted_at IS NOT NULL ORDER BY i.deleted_at DESC`, retention,
+				func(rows *sql.Rows, item *Item) error {
+					item.Type = "inspection"
+					err := rows.Scan(&item.ID, &item.Label, &item.PropertyID, &item.DeletedAt)
+					item.InspectionID = item.ID
+					return err
+				})
+			if err != nil {
+				log.Printf("[Trash] Error listing inspections: %v", err)
+				http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, found...)
+		}
+		if kind == "" || kind == "photo" {
+			found, err := listTrashed(db, `
+				SELECT photo_id, item_name, inspection_id, photo_url, deleted_at
+				FROM inspection_photos WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC`, retention,
+				func(rows *sql.Rows, item *Item) error {
+					item.Type = "photo"
+					return rows.Scan(&item.ID, &item.Label, &item.InspectionID, &item.PhotoURL, &item.DeletedAt)
+				})
+			if err != nil {
+				log.Printf("[Trash] Error listing photos: %v", err)
+				http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, found...)
+		}
+		if kind == "" || kind == "property_photo" {
+			found, err := listTrashed(db, `
+				SELECT pp.photo_id, CONCAT('Cover photo - ', COALESCE(i.report_id, pp.inspection_id)), pp.inspection_id, pp.photo_url, pp.deleted_at
+				FROM property_photos pp LEFT JOIN inspections i ON i.inspection_id = pp.inspection_id
+				WHERE pp.deleted_at IS NOT NULL ORDER BY pp.deleted_at DESC`, retention,
+				func(rows *sql.Rows, item *Item) error {
+					item.Type = "property_photo"
+					return rows.Scan(&item.ID, &item.Label, &item.InspectionID, &item.PhotoURL, &item.DeletedAt)
+				})
+			if err != nil {
+				log.Printf("[Trash] Error listing cover photos: %v", err)
+				http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+				return
+			}
+			items = append(items, found...)
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"retention_days": int(retention.Hours() / 24),
+			"items":          items,
+		})
+	}
+}
+
+// RestoreItem takes an inspection, property, photo or cover photo out of the trash. Children can't be restored
+// while their parent is still trashed.
+func RestoreItem(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		vars := mux.Vars(r)
+		id := vars["id"]
+
+		var parentTrashed bool
+		var err error
+		var res sql.Result
+		switch vars["type"] {
+		case "property":
+			res, err = db.Exec(`UPDATE properties SET deleted_at = NULL WHERE property_id = ? AND deleted_at IS NOT NULL`, id)
+		case "inspection":
+			err = db.QueryRow(`
+				SELECT p.deleted_at IS NOT NULL FROM inspections i JOIN properties p ON p.property_id = i.property_id
+				WHERE i.inspection_id = ? AND i.deleted_at IS NOT NULL`, id).Scan(&parentTrashed)
+			if err == nil && !parentTrashed {
+				res, err = db.Exec(`UPDATE inspections SET deleted_at = NULL WHERE inspection_id = ?`, id)
+			}
+		case "photo":
+			var photoID int
+			var inspectionID string
+			if photoID, err = strconv.Atoi(id); err != nil {
+				http.Error(w, "Invalid photo id", http.StatusBadRequest)
+				return
+			}
+			err = db.QueryRow(`
+				SELECT i.inspection_id, i.deleted_at IS NOT NULL FROM inspection_photos ph JOIN inspections i ON i.inspection_id = ph.inspection_id
+				WHERE ph.photo_id = ? AND ph.deleted_at IS NOT NULL`, photoID).Scan(&inspectionID, &parentTrashed)
+			if err == nil && !parentTrashed {
+				var tx *sql.Tx
+				if tx, err = db.Begin(); err == nil {
+					if err = inspections.RestoreInspectionPhotoRecord(tx, inspectionID, photoID); err == nil {
+						err = tx.Commit()
+					} else {
+						tx.Rollback()
+					}
+				}
+			}
+		case "property_photo":
+			var inspectionID string
+			err = db.QueryRow(`
+				SELECT i.inspection_id, i.deleted_at IS NOT NULL FROM property_photos pp JOIN inspections i ON i.inspection_id = pp.inspection_id
+				WHERE pp.photo_id = ? AND pp.deleted_at IS NOT NULL`, id).Scan(&inspectionID, &parentTrashed)
+			if err == nil && !parentTrashed {
+				// The cover is part of the report, so it can't come back while the report is published
+				var tx *sql.Tx
+				if tx, err = db.Begin(); err == nil {
+					if err = inspections.EnsureEditable(tx, inspectionID); err == nil {
+						if res, err = tx.Exec(`UPDATE property_photos SET deleted_at = NULL WHERE photo_id = ?`, id); err == nil {
+							err = tx.Commit()
+						}
+					}
+					if err != nil {
+						tx.Rollback()
+					}
+				}
+			}
+		default:
+			http.Error(w, "type must be inspection, property, photo or property_photo", http.StatusBadRequest)
+			return
+		}
+
+		if err == nil && res != nil {
+			var affected int64
+			if affected, err = res.RowsAffected(); err == nil && affected == 0 {
+				err = sql.ErrNoRows
+			}
+		}
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Item not found in trash", http.StatusNotFound)
+		case errors.Is(err, inspections.ErrInspectionLocked):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			log.Printf("[Trash] Error restoring %s %s: %v", vars["type"], id, err)
+			http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		case parentTrashed:
+			http.Error(w, "Restore the parent record first", http.StatusConflict)
+		default:
+			writeJSON(w, map[string]string{"message": "Restored"})
+		}
+	}
+}
+
+// PurgeTrash runs the retention job now instead of waiting for its next tick
+func PurgeTrash(db *sql.DB, retention time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		result, err := Purge(db, time.Now().UTC().Add(-retention))
+		if err != nil {
+			log.Printf("[Trash] Purge error: %v", err)
+			http.Error(w, "Failed to purge trash", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
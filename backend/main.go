@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"home_solutions/backend/database"
+	"home_solutions/backend/handlers/trash"
 	"home_solutions/backend/middleware"
 	"home_solutions/backend/routes"
 	"home_solutions/backend/utils"
@@ -82,6 +83,9 @@ func main() {
 		log.Printf("Error inserting dummy inspection data: %v", err)
 	}
 
+	// Hard-delete trashed records once their retention period is over
+	trash.StartRetentionJob(db, trash.RetentionFromEnv())
+
 	// Register API routes
 	router := routes.RegisterRoutes(db)
 
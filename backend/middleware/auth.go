@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
@@ -25,34 +26,55 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		// Parse token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			return
-		}
-
-		userID, okID := claims["user_id"].(float64) // jwt converts numbers to float64
-		userType, okType := claims["user_type"].(string)
-		if !okID || !okType {
-			http.Error(w, "Invalid token data", http.StatusUnauthorized)
+		userID, userType, err := parseBearerToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
 		// Add to context
-		ctx := context.WithValue(r.Context(), UserIDKey, int(userID))
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		ctx = context.WithValue(ctx, UserTypeKey, userType)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// OptionalJWTAuthMiddleware adds the caller to the context when a valid token is sent,
+// but lets anonymous requests through (used by endpoints that only need attribution)
+func OptionalJWTAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			if userID, userType, err := parseBearerToken(strings.TrimPrefix(authHeader, "Bearer ")); err == nil {
+				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				ctx = context.WithValue(ctx, UserTypeKey, userType)
+				r = r.WithContext(ctx)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func parseBearerToken(tokenString string) (int, string, error) {
+	// Parse token
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+
+	if err != nil || !token.Valid {
+		return 0, "", errors.New("Invalid or expired token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", errors.New("Invalid token claims")
+	}
+
+	userID, okID := claims["user_id"].(float64) // jwt converts numbers to float64
+	userType, okType := claims["user_type"].(string)
+	if !okID || !okType {
+		return 0, "", errors.New("Invalid token data")
+	}
+
+	return int(userID), userType, nil
+}
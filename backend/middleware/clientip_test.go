@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"no proxies configured ignores the header", "", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"untrusted peer ignores the header", "10.0.0.1", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted peer uses the forwarded client", "10.0.0.1", "10.0.0.1:5000", "198.51.100.1", "198.51.100.1"},
+		{"spoofed leading hop is skipped", "10.0.0.0/8", "10.0.0.1:5000", "1.2.3.4, 198.51.100.1, 10.0.0.2", "198.51.100.1"},
+		{"trusted peer without the header", "10.0.0.1", "10.0.0.1:5000", "", "10.0.0.1"},
+		{"ipv6 proxy", "::1", "[::1]:5000", "198.51.100.1", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trusted)
+			r := httptest.NewRequest("POST", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import "net/http"
+
+// IsStaff reports whether the authenticated caller is an inspector or an admin
+func IsStaff(r *http.Request) bool {
+	userType, _ := r.Context().Value(UserTypeKey).(string)
+	return userType == "inspector" || userType == "admin"
+}
+
+// RequireStaff answers 403 "Only inspectors and admins can <action>" unless the caller is staff,
+// and reports whether the handler may go on
+func RequireStaff(w http.ResponseWriter, r *http.Request, action string) bool {
+	if !IsStaff(r) {
+		http.Error(w, "Only inspectors and admins can "+action, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// CallerID returns the authenticated user's id, or nil for an anonymous request
+func CallerID(r *http.Request) *int {
+	if id, ok := r.Context().Value(UserIDKey).(int); ok {
+		return &id
+	}
+	return nil
+}
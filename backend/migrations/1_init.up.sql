@@ -10,13 +10,24 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 
+-- Inspection companies; inspectors in the same organization share libraries and settings
+CREATE TABLE IF NOT EXISTS organizations (
+    organization_id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
 CREATE TABLE inspectors (
     inspector_id INT AUTO_INCREMENT PRIMARY KEY,
     user_id INT NOT NULL,
     company_name VARCHAR(255),
+    organization_id INT NULL,
+    calendar_token_hash CHAR(64) UNIQUE, -- SHA-256 of the private iCalendar feed token
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(organization_id) ON DELETE SET NULL
 );
 
 -- Create Properties table
@@ -28,8 +39,19 @@ CREATE TABLE IF NOT EXISTS properties (
     postal_code VARCHAR(10) NOT NULL,
     postal_code_suffix VARCHAR(10),
     country VARCHAR(100) NOT NULL,
+    owner_id INT NULL,
+    year_built INT,
+    square_footage INT,
+    bedrooms INT,
+    bathrooms DECIMAL(3,1),
+    lot_size DECIMAL(10,2),
+    property_type VARCHAR(50),
+    latitude DECIMAL(9,6) NULL, -- property location, used to verify inspector check-ins
+    longitude DECIMAL(9,6) NULL,
+    deleted_at DATETIME NULL, -- UTC; set while the property is in the trash
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_property_deleted (deleted_at)
 );
 
 CREATE TABLE IF NOT EXISTS user_properties (
@@ -55,10 +77,13 @@ CREATE TABLE IF NOT EXISTS user_properties (
 CREATE TABLE IF NOT EXISTS inspections (
     inspection_id CHAR(36) PRIMARY KEY, -- Use CHAR(36) to store UUIDs
     property_id VARCHAR(255) NOT NULL, -- Foreign key
-    customer_id INT NOT NULL, -- references users.user_id
-    inspector_id INT NOT NULL, -- references inspectors.inspector_id
+    customer_id INT NULL, -- references users.user_id
+    inspector_id INT NULL, -- references inspectors.inspector_id, assigned when scheduled
     report_id VARCHAR(255) UNIQUE,
     inspection_date DATE,
+    scheduled_start DATETIME NULL, -- appointment start (UTC)
+    scheduled_end DATETIME NULL, -- appointment end (UTC)
+    estimated_duration_minutes INT NULL,
     status VARCHAR(50) DEFAULT 'in-progress',
     temperature INT NULL, -- Outside temperature during inspection
     weather VARCHAR(50), -- Weather condition
@@ -66,11 +91,18 @@ CREATE TABLE IF NOT EXISTS inspections (
     rain_last_three_days BOOLEAN,
     radon_test BOOLEAN,
     mold_test BOOLEAN,
+    cloned_from CHAR(36) NULL, -- prior inspection the worksheet was seeded from
+    sop_standard VARCHAR(50) NULL, -- standard of practice the report is checked against, e.g. 'InterNACHI'
+    published_at DATETIME NULL,
+    amendment_reason TEXT NULL, -- why a published report was reopened; cleared when the amendment is published
+    deleted_at DATETIME NULL, -- UTC; set while the inspection is in the trash
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (property_id) REFERENCES properties(property_id) ON DELETE CASCADE,
     FOREIGN KEY (customer_id) REFERENCES users(user_id) ON DELETE CASCADE,
-    FOREIGN KEY (inspector_id) REFERENCES inspectors(inspector_id) ON DELETE CASCADE
+    FOREIGN KEY (inspector_id) REFERENCES inspectors(inspector_id) ON DELETE CASCADE,
+    INDEX idx_inspector_schedule (inspector_id, scheduled_start),
+    INDEX idx_inspection_deleted (deleted_at)
 );
 
 CREATE TABLE IF NOT EXISTS invoices (
@@ -109,6 +141,7 @@ CREATE TABLE IF NOT EXISTS inspection_exterior (
     materials JSON NOT NULL,
     conditions JSON NOT NULL,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -122,6 +155,7 @@ CREATE TABLE IF NOT EXISTS inspection_roof (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -135,6 +169,7 @@ CREATE TABLE IF NOT EXISTS inspection_basementFoundation (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -148,6 +183,7 @@ CREATE TABLE IF NOT EXISTS inspection_heating (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -161,6 +197,7 @@ CREATE TABLE IF NOT EXISTS inspection_cooling (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -174,6 +211,7 @@ CREATE TABLE IF NOT EXISTS inspection_plumbing (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -187,6 +225,7 @@ CREATE TABLE IF NOT EXISTS inspection_electrical (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -200,6 +239,7 @@ CREATE TABLE IF NOT EXISTS inspection_attic (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -213,6 +253,7 @@ CREATE TABLE IF NOT EXISTS inspection_doorsWindows (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -226,6 +267,7 @@ CREATE TABLE IF NOT EXISTS inspection_fireplace (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -239,6 +281,7 @@ CREATE TABLE IF NOT EXISTS inspection_systemsComponents (
     materials JSON,
     conditions JSON,
     comments TEXT,
+    version INT NOT NULL DEFAULT 1, -- optimistic concurrency token, bumped on every change
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     UNIQUE KEY unique_item (inspection_id, item_name),
@@ -250,17 +293,23 @@ CREATE TABLE IF NOT EXISTS inspection_photos (
   inspection_id VARCHAR(255) NOT NULL,
   item_name VARCHAR(255) NOT NULL,
   photo_url VARCHAR(1024) NOT NULL,
+  client_id CHAR(36) NULL, -- set by offline clients so retried uploads are stored once
+  deleted_at DATETIME NULL, -- UTC; trashed photos keep their file until the retention job purges them
   uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
   FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id),
-  INDEX idx_inspection_item (inspection_id, item_name)
+  UNIQUE KEY uniq_photo_client (inspection_id, client_id),
+  INDEX idx_inspection_item (inspection_id, item_name),
+  INDEX idx_photo_deleted (deleted_at)
 );
 
 CREATE TABLE IF NOT EXISTS property_photos (
   photo_id VARCHAR(255) PRIMARY KEY,
   inspection_id VARCHAR(255),
   photo_url TEXT NOT NULL,
-  uploaded_at DATETIME NOT NULL
+  uploaded_at DATETIME NOT NULL,
+  deleted_at DATETIME NULL, -- UTC; trashed cover photos keep their file until the retention job purges them
+  INDEX idx_property_photo_deleted (deleted_at)
 );
 
 CREATE TABLE IF NOT EXISTS invitations (
@@ -292,4 +341,475 @@ CREATE TABLE IF NOT EXISTS home_health_score (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (property_id) REFERENCES properties(property_id)
-);
\ No newline at end of file
+);
+
+-- Every change to a worksheet item (any inspection_* section table), with author and diff
+CREATE TABLE IF NOT EXISTS inspection_item_revisions (
+    revision_id INT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id VARCHAR(36) NOT NULL,
+    section VARCHAR(50) NOT NULL, -- worksheet key, e.g. 'roof' or 'basementFoundation'
+    item_name VARCHAR(255) NOT NULL,
+    revision INT NOT NULL,
+    action ENUM('baseline', 'create', 'update', 'restore', 'delete') NOT NULL,
+    author_id INT NULL,
+    changes JSON NOT NULL, -- [{"field": "comments", "from": "...", "to": "..."}]
+    snapshot JSON NOT NULL, -- full item state after this revision; for 'delete', the state that was removed
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_item_revision (inspection_id, section, item_name, revision),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- Per-inspection change feed for offline clients; change_id doubles as the sync cursor
+CREATE TABLE IF NOT EXISTS sync_changes (
+    change_id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id VARCHAR(36) NOT NULL,
+    entity ENUM('item', 'photo') NOT NULL,
+    section VARCHAR(50) NULL,
+    item_name VARCHAR(255) NULL,
+    photo_id INT NULL,
+    action ENUM('upsert', 'delete') NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_inspection_change (inspection_id, change_id),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE
+);
+
+-- Operations already applied by the sync endpoint, so retried batches are idempotent
+CREATE TABLE IF NOT EXISTS sync_operations (
+    op_id VARCHAR(64) NOT NULL,
+    inspection_id VARCHAR(36) NOT NULL,
+    device_id VARCHAR(255) NULL,
+    op_type VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    result JSON NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (inspection_id, op_id), -- op ids are generated per device and only unique within an inspection
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE
+);
+
+-- Tracked findings per worksheet item; 'derived' rows mirror checked defect conditions
+CREATE TABLE IF NOT EXISTS defects (
+    defect_id INT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id VARCHAR(36) NOT NULL,
+    section VARCHAR(50) NOT NULL, -- worksheet key, e.g. 'roof'
+    item_name VARCHAR(255) NOT NULL,
+    condition_key VARCHAR(255) NULL, -- condition checkbox a derived defect mirrors; NULL for manual ones
+    source ENUM('derived', 'manual') NOT NULL DEFAULT 'manual',
+    status ENUM('open', 'cleared', 'dismissed') NOT NULL DEFAULT 'open',
+    severity ENUM('maintenance', 'repair', 'major', 'safety') NOT NULL,
+    location VARCHAR(255) NULL,
+    recommendation TEXT NULL,
+    responsible_trade VARCHAR(100) NULL,
+    cost_min DECIMAL(10, 2) NULL,
+    cost_max DECIMAL(10, 2) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_derived_defect (inspection_id, section, item_name, condition_key),
+    INDEX idx_defect_inspection (inspection_id, status),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS defect_photos (
+    defect_id INT NOT NULL,
+    photo_id INT NOT NULL,
+    PRIMARY KEY (defect_id, photo_id),
+    FOREIGN KEY (defect_id) REFERENCES defects(defect_id) ON DELETE CASCADE,
+    FOREIGN KEY (photo_id) REFERENCES inspection_photos(photo_id) ON DELETE CASCADE
+);
+
+-- Reusable narrative comments; NULL section/item_name/condition_key match any
+CREATE TABLE IF NOT EXISTS canned_comments (
+    comment_id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_inspector_id INT NOT NULL,
+    organization_id INT NULL, -- set when shared with the author's company
+    section VARCHAR(50) NULL,
+    item_name VARCHAR(255) NULL,
+    condition_key VARCHAR(255) NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL, -- may contain placeholders like {{material}} and {{location}}
+    is_default BOOLEAN NOT NULL DEFAULT FALSE, -- inserted automatically when the condition is checked
+    usage_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_comment_target (section, item_name, condition_key),
+    FOREIGN KEY (owner_inspector_id) REFERENCES inspectors(inspector_id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(organization_id) ON DELETE SET NULL
+);
+
+-- Standards of practice: components a report must cover, per standard and optionally per state
+CREATE TABLE IF NOT EXISTS compliance_rule_sets (
+    rule_set_id INT AUTO_INCREMENT PRIMARY KEY,
+    standard VARCHAR(50) NOT NULL, -- e.g. 'InterNACHI', 'ASHI'
+    state VARCHAR(2) NULL, -- NULL for the standard's baseline; state sets add to it
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY unique_standard_state (standard, state)
+);
+
+CREATE TABLE IF NOT EXISTS compliance_rules (
+    rule_id INT AUTO_INCREMENT PRIMARY KEY,
+    rule_set_id INT NOT NULL,
+    section VARCHAR(50) NOT NULL, -- worksheet key, e.g. 'electrical'
+    item_name VARCHAR(255) NOT NULL,
+    reason_allowed BOOLEAN NOT NULL DEFAULT TRUE, -- may be marked Not Inspected / Not Present with a reason in the comments
+    description TEXT NULL,
+    FOREIGN KEY (rule_set_id) REFERENCES compliance_rule_sets(rule_set_id) ON DELETE CASCADE
+);
+
+-- Completeness reports that publish decisions were based on, with any override
+CREATE TABLE IF NOT EXISTS inspection_compliance_checks (
+    check_id INT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id VARCHAR(36) NOT NULL,
+    standard VARCHAR(50) NOT NULL,
+    state VARCHAR(2) NULL,
+    passed BOOLEAN NOT NULL,
+    report JSON NOT NULL,
+    override_reason TEXT NULL,
+    checked_by INT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (checked_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+INSERT INTO compliance_rule_sets (rule_set_id, standard, state, name) VALUES
+    (1, 'InterNACHI', NULL, 'InterNACHI Home Inspection Standards of Practice'),
+    (2, 'ASHI', NULL, 'ASHI Standard of Practice for Home Inspections');
+
+INSERT INTO compliance_rules (rule_set_id, section, item_name, reason_allowed) VALUES
+    (1, 'exterior', 'Siding, Flashing, and Trim', TRUE),
+    (1, 'exterior', 'Eaves, Soffits, and Fascia', TRUE),
+    (1, 'exterior', 'Porches, Balconies, Decks, and Steps', TRUE),
+    (1, 'exterior', 'Driveways, Walkways, and Patios', TRUE),
+    (1, 'exterior', 'Vegetation, Grading, Drainage', TRUE),
+    (1, 'roof', 'Roof Coverings', TRUE),
+    (1, 'roof', 'Flashing', TRUE),
+    (1, 'roof', 'Gutters', TRUE),
+    (1, 'roof', 'Downspouts', TRUE),
+    (1, 'roof', 'Skylights, Chimneys, and Roof Penetrations', TRUE),
+    (1, 'basementFoundation', 'Foundation Walls', TRUE),
+    (1, 'basementFoundation', 'Floors', TRUE),
+    (1, 'basementFoundation', 'Support Beams and Columns', TRUE),
+    (1, 'basementFoundation', 'Moisture Intrusion', TRUE),
+    (1, 'heating', 'Heating Equipment', TRUE),
+    (1, 'heating', 'Distribution Systems', TRUE),
+    (1, 'heating', 'Venting Systems', TRUE),
+    (1, 'heating', 'Thermostats', TRUE),
+    (1, 'cooling', 'Cooling Equipment', TRUE),
+    (1, 'cooling', 'Distribution Systems', TRUE),
+    (1, 'cooling', 'Thermostats', TRUE),
+    (1, 'plumbing', 'Water Supply Piping', TRUE),
+    (1, 'plumbing', 'Drain, Waste, and Vent Piping', TRUE),
+    (1, 'plumbing', 'Water Heater', TRUE),
+    (1, 'plumbing', 'Fixtures and Faucets', TRUE),
+    (1, 'plumbing', 'Sump Pump and Drainage', TRUE),
+    (1, 'electrical', 'Service Entrance', TRUE),
+    (1, 'electrical', 'Main Panel', TRUE),
+    (1, 'electrical', 'Branch Wiring', TRUE),
+    (1, 'electrical', 'Outlets and Fixtures', TRUE),
+    (1, 'electrical', 'Grounding & Bonding', TRUE),
+    (1, 'attic', 'Access', TRUE),
+    (1, 'attic', 'Structure', TRUE),
+    (1, 'attic', 'Ventilation', TRUE),
+    (1, 'attic', 'Insulation', TRUE),
+    (1, 'doorsWindows', 'Exterior Doors', TRUE),
+    (1, 'doorsWindows', 'Interior Doors', TRUE),
+    (1, 'doorsWindows', 'Windows', TRUE),
+    (1, 'doorsWindows', 'Garage Doors', TRUE),
+    (1, 'doorsWindows', 'Garage Door Openers', TRUE),
+    (1, 'fireplace', 'Fireplaces and Stoves', TRUE),
+    (1, 'fireplace', 'Chimneys and Vents', TRUE),
+    (1, 'fireplace', 'Damper Operation', TRUE),
+    (1, 'systemsComponents', 'Installed Appliances', TRUE),
+    (1, 'systemsComponents', 'Smoke and Carbon Monoxide Detectors', TRUE),
+    (1, 'systemsComponents', 'Exhaust Systems', TRUE),
+    (2, 'exterior', 'Siding, Flashing, and Trim', TRUE),
+    (2, 'exterior', 'Eaves, Soffits, and Fascia', TRUE),
+    (2, 'exterior', 'Porches, Balconies, Decks, and Steps', TRUE),
+    (2, 'exterior', 'Driveways, Walkways, and Patios', TRUE),
+    (2, 'exterior', 'Vegetation, Grading, Drainage', TRUE),
+    (2, 'roof', 'Roof Coverings', TRUE),
+    (2, 'roof', 'Flashing', TRUE),
+    (2, 'roof', 'Gutters', TRUE),
+    (2, 'roof', 'Downspouts', TRUE),
+    (2, 'roof', 'Skylights, Chimneys, and Roof Penetrations', TRUE),
+    (2, 'basementFoundation', 'Foundation Walls', TRUE),
+    (2, 'basementFoundation', 'Floors', TRUE),
+    (2, 'basementFoundation', 'Support Beams and Columns', TRUE),
+    (2, 'basementFoundation', 'Moisture Intrusion', TRUE),
+    (2, 'heating', 'Heating Equipment', TRUE),
+    (2, 'heating', 'Distribution Systems', TRUE),
+    (2, 'heating', 'Venting Systems', TRUE),
+    (2, 'heating', 'Thermostats', TRUE),
+    (2, 'cooling', 'Cooling Equipment', TRUE),
+    (2, 'cooling', 'Distribution Systems', TRUE),
+    (2, 'cooling', 'Thermostats', TRUE),
+    (2, 'plumbing', 'Water Supply Piping', TRUE),
+    (2, 'plumbing', 'Drain, Waste, and Vent Piping', TRUE),
+    (2, 'plumbing', 'Water Heater', TRUE),
+    (2, 'plumbing', 'Fixtures and Faucets', TRUE),
+    (2, 'plumbing', 'Sump Pump and Drainage', TRUE),
+    (2, 'electrical', 'Service Entrance', TRUE),
+    (2, 'electrical', 'Main Panel', TRUE),
+    (2, 'electrical', 'Branch Wiring', TRUE),
+    (2, 'electrical', 'Outlets and Fixtures', TRUE),
+    (2, 'electrical', 'Grounding & Bonding', TRUE),
+    (2, 'attic', 'Access', TRUE),
+    (2, 'attic', 'Structure', TRUE),
+    (2, 'attic', 'Ventilation', TRUE),
+    (2, 'attic', 'Insulation', TRUE),
+    (2, 'doorsWindows', 'Exterior Doors', TRUE),
+    (2, 'doorsWindows', 'Interior Doors', TRUE),
+    (2, 'doorsWindows', 'Windows', TRUE),
+    (2, 'doorsWindows', 'Garage Doors', TRUE),
+    (2, 'doorsWindows', 'Garage Door Openers', TRUE),
+    (2, 'fireplace', 'Fireplaces and Stoves', TRUE),
+    (2, 'fireplace', 'Chimneys and Vents', TRUE),
+    (2, 'fireplace', 'Damper Operation', TRUE),
+    (2, 'systemsComponents', 'Installed Appliances', TRUE),
+    (2, 'systemsComponents', 'Smoke and Carbon Monoxide Detectors', TRUE),
+    (2, 'systemsComponents', 'Exhaust Systems', TRUE);
+
+-- Pre-inspection agreement texts; body may use {{client_name}}, {{property_address}}, {{fee}},
+-- {{inspection_date}}, {{inspector_name}} and {{company_name}}
+CREATE TABLE IF NOT EXISTS agreement_templates (
+    template_id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+-- Agreements issued per inspection; signed rows are frozen by the triggers below
+CREATE TABLE IF NOT EXISTS inspection_agreements (
+    agreement_id CHAR(36) PRIMARY KEY,
+    inspection_id VARCHAR(36) NOT NULL,
+    template_id INT NULL,
+    status ENUM('pending', 'signed', 'void') NOT NULL DEFAULT 'pending',
+    client_name VARCHAR(255) NOT NULL,
+    client_email VARCHAR(255) NULL,
+    fee DECIMAL(10, 2) NULL,
+    body MEDIUMTEXT NOT NULL, -- merged text the client signs
+    body_hash CHAR(64) NOT NULL, -- SHA-256 of body
+    signing_token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the signing link token; the token is only returned when the agreement is issued
+    signer_name VARCHAR(255) NULL,
+    signature_type ENUM('typed', 'drawn') NULL,
+    signature MEDIUMTEXT NULL, -- typed name or PNG data URL
+    signer_ip VARCHAR(45) NULL,
+    signer_user_agent VARCHAR(512) NULL,
+    signed_at DATETIME NULL, -- UTC
+    document_hash CHAR(64) NULL, -- SHA-256 of the signed record
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_agreement_inspection (inspection_id, status),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (template_id) REFERENCES agreement_templates(template_id) ON DELETE SET NULL
+);
+
+DELIMITER //
+CREATE TRIGGER signed_agreements_immutable BEFORE UPDATE ON inspection_agreements
+FOR EACH ROW
+BEGIN
+    IF OLD.status = 'signed' THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Signed agreements cannot be modified';
+    END IF;
+END//
+
+CREATE TRIGGER signed_agreements_undeletable BEFORE DELETE ON inspection_agreements
+FOR EACH ROW
+BEGIN
+    IF OLD.status = 'signed' THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Signed agreements cannot be deleted';
+    END IF;
+END//
+DELIMITER ;
+
+CREATE TABLE IF NOT EXISTS report_versions (
+    version_id INT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id CHAR(36) NOT NULL,
+    version INT NOT NULL, -- 1 is the original report, later versions are amendments
+    kind ENUM('original', 'amendment') NOT NULL,
+    amendment_reason TEXT NULL,
+    snapshot LONGTEXT NOT NULL, -- canonical JSON of everything the report showed
+    content_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of snapshot
+    previous_hash CHAR(64) NULL, -- content_hash of the version this one supersedes
+    signed_off_by INT NULL, -- references users.user_id
+    inspector_name VARCHAR(255) NULL,
+    signoff_statement TEXT NOT NULL,
+    created_at DATETIME NOT NULL, -- UTC, also the version's published time
+    UNIQUE KEY uniq_report_version (inspection_id, version),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (signed_off_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+DELIMITER //
+CREATE TRIGGER report_versions_immutable BEFORE UPDATE ON report_versions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Published report versions cannot be modified';
+END//
+
+CREATE TRIGGER report_versions_undeletable BEFORE DELETE ON report_versions
+FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Published report versions cannot be deleted';
+END//
+DELIMITER ;
+
+-- Inspector check-in/check-out on site, with GPS fixes compared against the property location
+CREATE TABLE IF NOT EXISTS inspection_site_visits (
+    visit_id INT AUTO_INCREMENT PRIMARY KEY,
+    inspection_id CHAR(36) NOT NULL,
+    user_id INT NULL, -- inspector who checked in
+    check_in_at DATETIME NOT NULL, -- UTC
+    check_in_latitude DECIMAL(9,6) NOT NULL,
+    check_in_longitude DECIMAL(9,6) NOT NULL,
+    check_in_accuracy_m DECIMAL(8,1) NULL, -- reported GPS accuracy
+    check_in_distance_m DECIMAL(10,1) NULL, -- from the property; NULL when the property has no coordinates
+    check_out_at DATETIME NULL,
+    check_out_latitude DECIMAL(9,6) NULL,
+    check_out_longitude DECIMAL(9,6) NULL,
+    check_out_accuracy_m DECIMAL(8,1) NULL,
+    check_out_distance_m DECIMAL(10,1) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_visit_inspection (inspection_id, check_in_at),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- Report branding per organization, applied by the PDF and HTML renderers
+CREATE TABLE IF NOT EXISTS report_themes (
+    organization_id INT PRIMARY KEY,
+    logo_url VARCHAR(1024) NULL, -- under /uploads/logos/
+    primary_color CHAR(7) NOT NULL DEFAULT '#1F406B',
+    accent_color CHAR(7) NOT NULL DEFAULT '#2E8C57',
+    text_color CHAR(7) NOT NULL DEFAULT '#212121',
+    header_text VARCHAR(255) NOT NULL DEFAULT '',
+    footer_text VARCHAR(255) NOT NULL DEFAULT '',
+    disclaimer TEXT NOT NULL,
+    license_block TEXT NOT NULL,
+    section_order JSON NOT NULL, -- array of worksheet keys; unlisted sections follow in the usual order
+    updated_at DATETIME NOT NULL, -- UTC
+    FOREIGN KEY (organization_id) REFERENCES organizations(organization_id) ON DELETE CASCADE
+);
+
+-- Repair requests buyers and agents build from a published report; every save adds a version
+CREATE TABLE IF NOT EXISTS repair_requests (
+    request_id CHAR(36) PRIMARY KEY,
+    inspection_id CHAR(36) NOT NULL,
+    created_by INT NULL,
+    share_token_hash CHAR(64) NULL UNIQUE, -- SHA-256 of the share link token; anyone with the link can open the addendum
+    share_expires_at DATETIME NULL, -- UTC
+    share_revoked_at DATETIME NULL, -- UTC
+    current_version INT NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL, -- UTC
+    updated_at DATETIME NOT NULL, -- UTC
+    INDEX idx_repair_inspection (inspection_id),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS repair_request_versions (
+    request_id CHAR(36) NOT NULL,
+    version INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    notes TEXT NOT NULL,
+    report_version INT NOT NULL, -- published report version the items were taken from
+    total_credit DECIMAL(12,2) NOT NULL DEFAULT 0,
+    created_by INT NULL,
+    created_at DATETIME NOT NULL, -- UTC
+    PRIMARY KEY (request_id, version),
+    FOREIGN KEY (request_id) REFERENCES repair_requests(request_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+-- One requested action per row; section/item/condition and the defect link point back at the report
+CREATE TABLE IF NOT EXISTS repair_request_items (
+    request_id CHAR(36) NOT NULL,
+    version INT NOT NULL,
+    position INT NOT NULL,
+    defect_id INT NULL,
+    section VARCHAR(50) NOT NULL,
+    item_name VARCHAR(255) NOT NULL,
+    condition_key VARCHAR(255) NULL,
+    severity VARCHAR(20) NOT NULL, -- as reported in the referenced version
+    finding TEXT NOT NULL, -- inspector's comment or recommendation, copied so old versions keep their wording
+    request_type ENUM('repair', 'replace', 'evaluate', 'credit') NOT NULL,
+    requested_action TEXT NOT NULL,
+    credit_amount DECIMAL(12,2) NULL,
+    PRIMARY KEY (request_id, version, position),
+    INDEX idx_repair_item_defect (defect_id),
+    FOREIGN KEY (request_id, version) REFERENCES repair_request_versions(request_id, version) ON DELETE CASCADE,
+    FOREIGN KEY (defect_id) REFERENCES defects(defect_id) ON DELETE SET NULL
+);
+
+-- Saved column mappings for importing other inspection tools' exports, reused across files
+CREATE TABLE IF NOT EXISTS import_mappings (
+    mapping_id INT AUTO_INCREMENT PRIMARY KEY,
+    owner_user_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    format VARCHAR(50) NOT NULL, -- parser name, e.g. 'generic_csv'
+    mapping JSON NOT NULL,
+    created_at DATETIME NOT NULL, -- UTC
+    updated_at DATETIME NOT NULL, -- UTC
+    UNIQUE KEY unique_import_mapping (owner_user_id, name),
+    FOREIGN KEY (owner_user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- A published report emailed to its recipients; each recipient gets their own expiring link
+CREATE TABLE IF NOT EXISTS report_deliveries (
+    delivery_id CHAR(36) PRIMARY KEY,
+    inspection_id CHAR(36) NOT NULL,
+    report_version INT NOT NULL, -- published version the links open
+    subject VARCHAR(255) NOT NULL,
+    message TEXT NULL, -- personal note from the sender
+    sent_by INT NULL,
+    expires_at DATETIME NOT NULL, -- UTC; links stop working after this
+    created_at DATETIME NOT NULL, -- UTC
+    INDEX idx_delivery_inspection (inspection_id, created_at),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (sent_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS report_delivery_recipients (
+    recipient_id INT AUTO_INCREMENT PRIMARY KEY,
+    delivery_id CHAR(36) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NULL,
+    role ENUM('client', 'agent', 'other') NOT NULL DEFAULT 'client',
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the link token; the token itself is only in the email
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    error VARCHAR(500) NULL,
+    FOREIGN KEY (delivery_id) REFERENCES report_deliveries(delivery_id) ON DELETE CASCADE
+);
+
+-- 'opened' comes from the tracking image in the email, 'viewed' from following the report link
+CREATE TABLE IF NOT EXISTS report_delivery_events (
+    event_id INT AUTO_INCREMENT PRIMARY KEY,
+    recipient_id INT NOT NULL,
+    event ENUM('sent', 'failed', 'opened', 'viewed') NOT NULL,
+    ip_address VARCHAR(45) NULL,
+    user_agent VARCHAR(255) NULL,
+    created_at DATETIME NOT NULL, -- UTC
+    INDEX idx_delivery_event_recipient (recipient_id, event),
+    FOREIGN KEY (recipient_id) REFERENCES report_delivery_recipients(recipient_id) ON DELETE CASCADE
+);
+
+-- Public, read-only links to one published report version, for clients and agents without an account
+CREATE TABLE IF NOT EXISTS report_shares (
+    share_id CHAR(36) PRIMARY KEY,
+    inspection_id CHAR(36) NOT NULL,
+    report_version INT NOT NULL, -- the link always shows this version, even after amendments
+    token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 of the link token; the token is only returned when the share is created
+    password_hash VARCHAR(255) NULL, -- bcrypt; NULL means the link alone is enough
+    label VARCHAR(255) NULL, -- who the link was made for, e.g. "Buyer's agent"
+    expires_at DATETIME NULL, -- UTC; NULL never expires
+    revoked_at DATETIME NULL, -- UTC
+    created_by INT NULL,
+    created_at DATETIME NOT NULL, -- UTC
+    view_count INT NOT NULL DEFAULT 0,
+    last_viewed_at DATETIME NULL, -- UTC
+    INDEX idx_share_inspection (inspection_id, created_at),
+    FOREIGN KEY (inspection_id) REFERENCES inspections(inspection_id) ON DELETE CASCADE,
+    FOREIGN KEY (created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
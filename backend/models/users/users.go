@@ -47,3 +47,13 @@ func GetUserByEmail(db *sql.DB, email string) (*User, error) {
 	log.Printf("User found: ID=%d, Email=%s, Type=%s", user.ID, user.Email, user.UserType)
 	return &user, nil
 }
+
+// GetInspectorIDByUserID resolves the inspectors row that belongs to a user
+func GetInspectorIDByUserID(db *sql.DB, userID int) (int, error) {
+	var inspectorID int
+	err := db.QueryRow(`SELECT inspector_id FROM inspectors WHERE user_id = ?`, userID).Scan(&inspectorID)
+	if err != nil {
+		return 0, err
+	}
+	return inspectorID, nil
+}
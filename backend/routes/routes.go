@@ -4,13 +4,27 @@ import (
 	"database/sql"
 	"net/http"
 
+	agreements "home_solutions/backend/handlers/agreements"
 	analysis "home_solutions/backend/handlers/analysis"
+	archive "home_solutions/backend/handlers/archive"
 	auth "home_solutions/backend/handlers/auth"
+	comparison "home_solutions/backend/handlers/comparison"
+	compliance "home_solutions/backend/handlers/compliance"
 	dashboards "home_solutions/backend/handlers/dashboards"
+	delivery "home_solutions/backend/handlers/delivery"
+	exports "home_solutions/backend/handlers/exports"
 	homeowner "home_solutions/backend/handlers/homeowner"
+	imports "home_solutions/backend/handlers/imports"
 	inspection "home_solutions/backend/handlers/inspections"
 	invitations "home_solutions/backend/handlers/invitations"
 	properties "home_solutions/backend/handlers/properties"
+	publishing "home_solutions/backend/handlers/publishing"
+	repairrequests "home_solutions/backend/handlers/repairrequests"
+	reports "home_solutions/backend/handlers/reports"
+	scheduling "home_solutions/backend/handlers/scheduling"
+	sharing "home_solutions/backend/handlers/sharing"
+	sitevisits "home_solutions/backend/handlers/sitevisits"
+	trash "home_solutions/backend/handlers/trash"
 	middleware "home_solutions/backend/middleware"
 
 	"github.com/gorilla/mux"
@@ -24,6 +38,11 @@ func RegisterRoutes(db *sql.DB) *mux.Router {
 		return middleware.EnableCORS(http.HandlerFunc(h))
 	}
 
+	// Report reads are limited to staff and the inspection's homeowner, once the agreement is signed
+	withReportGate := func(h http.HandlerFunc) http.HandlerFunc {
+		return middleware.JWTAuthMiddleware(agreements.RequireSignedAgreement(db, h)).ServeHTTP
+	}
+
 	// Auth routes
 	router.Handle("/api/login", withCORS(auth.Login(db))).Methods("POST", "OPTIONS")
 	router.Handle("/api/refresh-token", withCORS(http.HandlerFunc(auth.RefreshToken))).Methods("POST", "OPTIONS")
@@ -41,14 +60,22 @@ func RegisterRoutes(db *sql.DB) *mux.Router {
 
 	// Address and property routes
 	router.Handle("/api/get-address/{property_id}", withCORS(properties.GetAddressByPropertyID)).Methods("GET", "OPTIONS")
-	router.Handle("/api/save-address", withCORS(properties.SaveAddress)).Methods("POST", "OPTIONS")
+	router.Handle("/api/save-address", withCORS(middleware.OptionalJWTAuthMiddleware(http.HandlerFunc(properties.SaveAddress)).ServeHTTP)).Methods("POST", "OPTIONS")
 	router.Handle("/api/property-details/{property_id}/{inspection_id}", withCORS(properties.GetPropertyDetails)).Methods("GET", "OPTIONS")
 	router.Handle("/api/property-details", withCORS(properties.SaveOrUpdateProperty)).Methods("POST", "PUT", "OPTIONS")
 
 	// Inspection routes
 	router.Handle("/api/inspection-details/{inspection_id}/{property_id}", withCORS(inspection.GetInspectionForm)).Methods("GET", "OPTIONS")
-	router.Handle("/api/create-inspection", withCORS(inspection.CreateInspection)).Methods("POST", "OPTIONS")
+	router.Handle("/api/create-inspection", withCORS(middleware.OptionalJWTAuthMiddleware(http.HandlerFunc(inspection.CreateInspection)).ServeHTTP)).Methods("POST", "OPTIONS")
 	router.Handle("/api/update-inspection", withCORS(inspection.UpdateInspection)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/inspections", withCORS(middleware.JWTAuthMiddleware(inspection.ListInspections(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+
+	// Scheduling routes
+	router.Handle("/api/inspections/{inspection_id}/duration-estimate", withCORS(scheduling.GetDurationEstimate(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/schedule", withCORS(middleware.JWTAuthMiddleware(scheduling.ScheduleInspection(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/inspectors/{inspector_id}/schedule", withCORS(middleware.JWTAuthMiddleware(scheduling.GetInspectorSchedule(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspectors/{inspector_id}/calendar-feed", withCORS(middleware.JWTAuthMiddleware(scheduling.CreateCalendarFeed(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/calendar/{token}.ics", scheduling.GetCalendarFeed(db)).Methods("GET")
 
 	// Worksheet routes
 	worksheets := map[string]struct {
@@ -69,15 +96,33 @@ func RegisterRoutes(db *sql.DB) *mux.Router {
 	}
 
 	for section, handlers := range worksheets {
-		router.Handle("/api/inspection-"+section+"/{inspection_id}", withCORS(handlers.Get)).Methods("GET", "OPTIONS")
-		router.Handle("/api/inspection-"+section, withCORS(handlers.Post)).Methods("POST", "OPTIONS")
+		router.Handle("/api/inspection-"+section+"/{inspection_id}", withCORS(withReportGate(handlers.Get))).Methods("GET", "OPTIONS")
+		router.Handle("/api/inspection-"+section, withCORS(middleware.OptionalJWTAuthMiddleware(handlers.Post).ServeHTTP)).Methods("POST", "OPTIONS")
 	}
 
+	// Worksheet item history
+	router.Handle("/api/inspection-history/{inspection_id}/{section}/{item_name}", withCORS(withReportGate(inspection.GetItemHistory(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspection-history/revisions/{revision_id}/restore", withCORS(middleware.JWTAuthMiddleware(inspection.RestoreItemRevision(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/defects", withCORS(withReportGate(inspection.ListDefects(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/defects", withCORS(middleware.JWTAuthMiddleware(inspection.CreateDefect(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/defects/{defect_id}", withCORS(middleware.JWTAuthMiddleware(inspection.GetDefect(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/defects/{defect_id}", withCORS(middleware.JWTAuthMiddleware(inspection.UpdateDefect(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/defects/{defect_id}", withCORS(middleware.JWTAuthMiddleware(inspection.DeleteDefect(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+
+	// Canned comment library
+	router.Handle("/api/comment-library", withCORS(middleware.JWTAuthMiddleware(inspection.ListCannedComments(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/comment-library", withCORS(middleware.JWTAuthMiddleware(inspection.CreateCannedComment(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/comment-library/{comment_id}", withCORS(middleware.JWTAuthMiddleware(inspection.UpdateCannedComment(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/comment-library/{comment_id}", withCORS(middleware.JWTAuthMiddleware(inspection.DeleteCannedComment(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/comment-library/{comment_id}/share", withCORS(middleware.JWTAuthMiddleware(inspection.ShareCannedComment(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/comment-library/{comment_id}/use", withCORS(middleware.JWTAuthMiddleware(inspection.UseCannedComment(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/sync", withCORS(middleware.JWTAuthMiddleware(inspection.SyncInspection(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+
 	// Inspection photo routes
 	router.Handle("/api/inspection-photo", withCORS(http.HandlerFunc(inspection.UploadInspectionPhoto))).Methods("POST", "OPTIONS")
 	router.Handle("/api/inspection-photo/{inspection_id}/{item_name}", withCORS(http.HandlerFunc(inspection.GetInspectionPhotos))).Methods("GET", "OPTIONS")
 	router.Handle("/api/inspection-photo/{photo_id}", withCORS(http.HandlerFunc(inspection.DeleteInspectionPhoto))).Methods("DELETE", "OPTIONS")
-	router.Handle("/api/inspection-photo-all/{inspection_id}", withCORS(http.HandlerFunc(inspection.GetAllInspectionPhotos))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspection-photo-all/{inspection_id}", withCORS(withReportGate(inspection.GetAllInspectionPhotos))).Methods("GET", "OPTIONS")
 
 	// Property photo routes
 	router.Handle("/api/property-photo/{inspection_id}", withCORS(http.HandlerFunc(inspection.UploadPropertyPhoto))).Methods("POST", "OPTIONS")
@@ -88,8 +133,102 @@ func RegisterRoutes(db *sql.DB) *mux.Router {
 	router.PathPrefix("/uploads/").Handler(middleware.CORSFileServer(http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads/")))))
 
 	// Analyze home inspection
-	router.Handle("/api/inspection-analysis/{inspection_id}", withCORS(analysis.GetAnalysisHandler(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspection-analysis/{inspection_id}", withCORS(withReportGate(analysis.GetAnalysisHandler(db)))).Methods("GET", "OPTIONS")
 	router.Handle("/api/analyze", withCORS(analysis.AnalyzeAndSaveHandler(db).ServeHTTP)).Methods("POST", "OPTIONS")
 
+	// Standards of practice and publishing
+	router.Handle("/api/compliance/rule-sets", withCORS(compliance.ListRuleSets(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/compliance/rule-sets", withCORS(middleware.JWTAuthMiddleware(compliance.SaveRuleSet(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/compliance/rule-sets/{rule_set_id}", withCORS(middleware.JWTAuthMiddleware(compliance.SaveRuleSet(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/compliance/rule-sets/{rule_set_id}", withCORS(middleware.JWTAuthMiddleware(compliance.DeleteRuleSet(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/compliance", withCORS(compliance.GetComplianceReport(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/publish", withCORS(middleware.JWTAuthMiddleware(publishing.PublishInspection(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/amendments", withCORS(middleware.JWTAuthMiddleware(publishing.StartAmendment(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report-versions", withCORS(withReportGate(publishing.ListReportVersions(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report-versions/{version}", withCORS(withReportGate(publishing.GetReportVersion(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-verification/{content_hash}", withCORS(publishing.VerifyReport(db))).Methods("GET", "OPTIONS")
+
+	// Rendered reports
+	router.Handle("/api/inspections/{inspection_id}/report", withCORS(withReportGate(reports.GetReport(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report.pdf", withCORS(withReportGate(reports.GetReportPDF(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report.html", withCORS(withReportGate(reports.GetReportHTML(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report/summary", withCORS(withReportGate(reports.GetReportSummary(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/report/summary.html", withCORS(withReportGate(reports.GetReportSummaryHTML(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/photos/{photo_id}/thumbnail", withCORS(withReportGate(reports.GetPhotoThumbnail(db)))).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-theme", withCORS(middleware.JWTAuthMiddleware(reports.GetReportTheme(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-theme", withCORS(middleware.JWTAuthMiddleware(reports.SaveReportTheme(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/report-theme/logo", withCORS(middleware.JWTAuthMiddleware(reports.UploadThemeLogo(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/report-theme/preview", withCORS(middleware.JWTAuthMiddleware(reports.PreviewReportTheme(db)).ServeHTTP)).Methods("GET", "POST", "OPTIONS")
+
+	// Repair request addenda built from published reports
+	router.Handle("/api/inspections/{inspection_id}/repair-requests", withCORS(middleware.JWTAuthMiddleware(repairrequests.CreateRepairRequest(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/repair-requests", withCORS(middleware.JWTAuthMiddleware(repairrequests.ListRepairRequests(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/repair-requests/{request_id}", withCORS(middleware.JWTAuthMiddleware(repairrequests.GetRepairRequest(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/repair-requests/{request_id}", withCORS(middleware.JWTAuthMiddleware(repairrequests.UpdateRepairRequest(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/repair-requests/{request_id}/share", withCORS(middleware.JWTAuthMiddleware(repairrequests.ShareRepairRequest(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/repair-requests/{request_id}/share", withCORS(middleware.JWTAuthMiddleware(repairrequests.RevokeRepairRequestShare(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/repair-requests/{request_id}/addendum.{format:pdf|html}", withCORS(middleware.JWTAuthMiddleware(repairrequests.GetAddendum(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/shared-repair-requests/{token}/addendum.{format:pdf|html}", withCORS(repairrequests.GetSharedAddendum(db))).Methods("GET", "OPTIONS")
+
+	// Report delivery by email, with per-recipient tracked links
+	mail := delivery.ConfigFromEnv()
+	router.Handle("/api/inspections/{inspection_id}/deliveries", withCORS(middleware.JWTAuthMiddleware(delivery.CreateDelivery(db, mail)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/deliveries", withCORS(middleware.JWTAuthMiddleware(delivery.ListDeliveries(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-links/{token}", withCORS(delivery.OpenReportLink(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-links/{token}/open.gif", withCORS(delivery.TrackOpen(db))).Methods("GET", "OPTIONS")
+
+	// Read-only report pages at share links; the token is the credential, no login
+	router.Handle("/api/inspections/{inspection_id}/shares", withCORS(middleware.JWTAuthMiddleware(sharing.CreateShare(db, mail.BaseURL)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/shares", withCORS(middleware.JWTAuthMiddleware(sharing.ListShares(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/report-shares/{share_id}", withCORS(middleware.JWTAuthMiddleware(sharing.RevokeShare(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/shared-reports/{token}", sharing.ViewSharedReport(db)).Methods("GET")
+	router.Handle("/api/shared-reports/{token}", sharing.UnlockSharedReport(db)).Methods("POST")
+	router.Handle("/api/shared-reports/{token}/report.pdf", sharing.DownloadSharedReport(db)).Methods("GET")
+	router.Handle("/api/shared-reports/{token}/cover", sharing.GetSharedCover(db)).Methods("GET")
+	router.Handle("/api/shared-reports/{token}/photos/{photo_id}", sharing.GetSharedPhoto(db)).Methods("GET")
+	router.Handle("/api/shared-reports/{token}/photos/{photo_id}/thumbnail", sharing.GetSharedThumbnail(db)).Methods("GET")
+
+	// Portable inspection archives
+	router.Handle("/api/inspections/{inspection_id}/export.zip", withCORS(middleware.JWTAuthMiddleware(archive.ExportInspection(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/import", withCORS(middleware.JWTAuthMiddleware(archive.ImportInspection(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+
+	// Imports from other inspection software
+	router.Handle("/api/imports/formats", withCORS(middleware.JWTAuthMiddleware(imports.ListFormats()).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/imports", withCORS(middleware.JWTAuthMiddleware(imports.ImportInspections(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/import-mappings", withCORS(middleware.JWTAuthMiddleware(imports.ListMappings(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/import-mappings", withCORS(middleware.JWTAuthMiddleware(imports.SaveMapping(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/import-mappings/{mapping_id}", withCORS(middleware.JWTAuthMiddleware(imports.DeleteMapping(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+
+	// Spreadsheet exports across inspections
+	router.Handle("/api/exports/inspections.{format:csv|xlsx}", withCORS(middleware.JWTAuthMiddleware(exports.ExportInspections(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/exports/conditions.{format:csv|xlsx}", withCORS(middleware.JWTAuthMiddleware(exports.ExportConditions(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/exports/defects.{format:csv|xlsx}", withCORS(middleware.JWTAuthMiddleware(exports.ExportDefects(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+
+	// Field check-in and time on site
+	router.Handle("/api/inspections/{inspection_id}/check-in", withCORS(middleware.JWTAuthMiddleware(sitevisits.CheckIn(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/check-out", withCORS(middleware.JWTAuthMiddleware(sitevisits.CheckOut(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/site-visits", withCORS(middleware.JWTAuthMiddleware(sitevisits.GetSiteVisits(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+
+	// Trash: soft delete, restore and retention
+	retention := trash.RetentionFromEnv()
+	router.Handle("/api/inspections/{inspection_id}", withCORS(middleware.JWTAuthMiddleware(trash.DeleteInspection(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/properties/{property_id}", withCORS(middleware.JWTAuthMiddleware(trash.DeleteProperty(db)).ServeHTTP)).Methods("DELETE", "OPTIONS")
+	router.Handle("/api/admin/trash", withCORS(middleware.JWTAuthMiddleware(trash.ListTrash(db, retention)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/admin/trash/purge", withCORS(middleware.JWTAuthMiddleware(trash.PurgeTrash(db, retention)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/admin/trash/{type}/{id}/restore", withCORS(middleware.JWTAuthMiddleware(trash.RestoreItem(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+
+	// Inspection agreements and e-signature
+	router.Handle("/api/agreement-templates", withCORS(middleware.JWTAuthMiddleware(agreements.ListTemplates(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/agreement-templates", withCORS(middleware.JWTAuthMiddleware(agreements.SaveTemplate(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/agreement-templates/{template_id}", withCORS(middleware.JWTAuthMiddleware(agreements.SaveTemplate(db)).ServeHTTP)).Methods("PUT", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/agreement", withCORS(middleware.JWTAuthMiddleware(agreements.GetInspectionAgreement(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+	router.Handle("/api/inspections/{inspection_id}/agreement", withCORS(middleware.JWTAuthMiddleware(agreements.IssueAgreement(db)).ServeHTTP)).Methods("POST", "OPTIONS")
+	router.Handle("/api/agreements/{agreement_id}/verify", withCORS(agreements.VerifyAgreement(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/agreements/sign/{token}", withCORS(agreements.GetAgreementForSigning(db))).Methods("GET", "OPTIONS")
+	router.Handle("/api/agreements/sign/{token}", withCORS(agreements.SignAgreement(db))).Methods("POST", "OPTIONS")
+
+	// Compare inspections of a property
+	router.Handle("/api/properties/{property_id}/comparison", withCORS(middleware.JWTAuthMiddleware(comparison.GetComparison(db)).ServeHTTP)).Methods("GET", "OPTIONS")
+
 	return router
 }
@@ -103,10 +103,11 @@ func InsertDummyInspectionData(db *sql.DB) error {
 	}
 
 	_, err = db.Exec(`
-		INSERT INTO inspections (inspection_id, property_id, report_id, inspection_date, inspection_time, status)
-		VALUES (?, ?, ?, CURDATE(), '12:00 PM', 'completed')
+		INSERT INTO inspections (inspection_id, property_id, report_id, inspection_date, scheduled_start, scheduled_end, estimated_duration_minutes, status)
+		VALUES (?, ?, ?, CURDATE(), TIMESTAMP(CURDATE(), '12:00:00'), TIMESTAMP(CURDATE(), '14:00:00'), 120, 'completed')
 		ON DUPLICATE KEY UPDATE 
-		property_id=VALUES(property_id), report_id=VALUES(report_id), inspection_date=VALUES(inspection_date), inspection_time=VALUES(inspection_time), status=VALUES(status)
+		property_id=VALUES(property_id), report_id=VALUES(report_id), inspection_date=VALUES(inspection_date), scheduled_start=VALUES(scheduled_start),
+		scheduled_end=VALUES(scheduled_end), estimated_duration_minutes=VALUES(estimated_duration_minutes), status=VALUES(status)
 	`, inspectionID, propertyID, reportID)
 	if err != nil {
 		log.Printf("Error inserting inspection: %v", err)
@@ -1,6 +1,7 @@
 import React, { useEffect, useState } from "react";
 import { useParams } from "react-router-dom";
 import InspectionAnalysisCard from "./InspectionAnalysisCard";
+import axios from "../utils/axios";
 
 const InspectionAnalysis = () => {
   const { inspectionId } = useParams();
@@ -11,8 +12,8 @@ const InspectionAnalysis = () => {
 
     const fetchAnalysis = async () => {
       try {
-        const res = await fetch(`http://localhost:8080/api/inspection-analysis/${inspectionId}`);
-        const data = await res.json();
+        const res = await axios.get(`/inspection-analysis/${inspectionId}`);
+        const data = res.data;
 
         const analysisText = data.analysisText;
         console.log("🧠 Raw analysis:", analysisText);
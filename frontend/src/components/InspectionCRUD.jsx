@@ -1,5 +1,5 @@
 // InspectionCRUD.jsx
-import { useState, useCallback, useEffect } from "react";
+import { useState, useCallback, useEffect, useRef } from "react";
 import axios from "../utils/axios";
 import { debounce } from "../utils/debounce";
 import React from "react";
@@ -22,9 +22,122 @@ export const InspectionStatusDropdown = ({ value, onChange }) => {
   );
 };
 
+// Tells the user which items were replaced by another device's edit
+export const ConflictNotice = ({ conflicts, onDismiss }) => {
+  if (!conflicts || conflicts.length === 0) return null;
+  return (
+    <div className="conflict-notice" role="alert">
+      <span>
+        {conflicts.map((c) => c.item_name).join(", ")}{" "}
+        {conflicts.length === 1 ? "was" : "were"} changed on another device. The latest version is shown; re-enter any edits you still need.
+      </span>
+      <button type="button" onClick={onDismiss}>Dismiss</button>
+    </div>
+  );
+};
+
+const toCamelCase = (str) => {
+  return str
+    .replace(/\s(.)/g, (match, group1) => group1.toUpperCase())
+    .replace(/\s/g, '')
+    .replace(/^(.)/, (match, group1) => group1.toLowerCase())
+    .replace(/Details$/, 'Details'); // Keep 'Details' properly capitalized
+};
+
+// Maps a worksheet row from the API to its formData key and value; details items keep their
+// fields as JSON in comments. Returns [null] for rows without an item name.
+const toFormEntry = (item) => {
+  if (!item || !item.item_name) return [null]; // guard against bad rows
+
+  if (item.item_name.endsWith("System Details") || item.item_name.endsWith("Management Details")) {
+    let details = {};
+    try {
+      details = typeof item.comments === "string" && item.comments.trim().startsWith("{")
+        ? JSON.parse(item.comments)
+        : {};
+    } catch (e) {
+      console.error(`Error parsing JSON for ${item.item_name}:`, e);
+    }
+    return [toCamelCase(item.item_name), details];
+  }
+  return [item.item_name, {
+    componentTypeConditions: typeof item.materials === "object" ? item.materials : {},
+    comment: item.comments || "",
+    inspection_status: item.inspection_status || "Not Inspected",
+  }];
+};
+
 export function InspectionCRUD(inspectionId, section) {
   const [formData, setFormData] = useState({});
   const [photos, setPhotos] = useState({});
+  // Last version seen per "section:item_name"; the backend rejects saves based on stale versions
+  const versionsRef = useRef({});
+
+  const rememberVersions = (targetSection, items = []) => {
+    items.forEach((item) => {
+      if (item && item.item_name) {
+        versionsRef.current[`${targetSection}:${item.item_name}`] = item.version || 0;
+      }
+    });
+  };
+
+  // The server returns comments when it added default library text to them. Keep that text, or the
+  // next autosave would send the old comments and erase it. Anything typed while the save was in
+  // flight stays, with the added text after it.
+  const adoptSavedComments = (targetSection, payload, saved = []) => {
+    if (targetSection !== section) return;
+    saved.forEach((item) => {
+      if (typeof item.comments !== "string") return;
+      const sent = payload.find((p) => p.item_name === item.item_name)?.comments || "";
+      setFormData((prev) => {
+        const current = prev[item.item_name];
+        if (!current) return prev;
+        const local = current.comment || "";
+        let comment = item.comments;
+        if (local !== sent && item.comments.startsWith(sent)) {
+          comment = local + item.comments.slice(sent.length);
+        }
+        return { ...prev, [item.item_name]: { ...current, comment } };
+      });
+    });
+  };
+
+  // Conflicts from the last save: items another device changed first. Their content has been
+  // replaced with the server's, so the user can review it and edit again.
+  const [conflicts, setConflicts] = useState([]);
+
+  // Posts worksheet items with their known versions. On 409 another device saved first: take the
+  // server's content together with its version, so the next autosave can't overwrite that edit.
+  const postItems = async (targetSection, payload) => {
+    const versioned = payload.map((item) => ({
+      ...item,
+      version: versionsRef.current[`${targetSection}:${item.item_name}`] || 0,
+    }));
+
+    try {
+      const response = await axios.post(`http://localhost:8080/api/inspection-${targetSection}`, versioned);
+      rememberVersions(targetSection, response.data?.saved);
+      adoptSavedComments(targetSection, payload, response.data?.saved);
+    } catch (error) {
+      if (error.response?.status === 409) {
+        const { conflicts: conflicted = [], saved = [] } = error.response.data || {};
+        rememberVersions(targetSection, saved);
+        adoptSavedComments(targetSection, payload, saved);
+        // Only items this form holds can be merged; others keep their old version and conflict again
+        const current = conflicted.map((c) => c.current).filter(Boolean);
+        if (targetSection === section && current.length > 0) {
+          rememberVersions(targetSection, current);
+          setFormData((prev) => current.reduce((acc, item) => {
+            const [key, value] = toFormEntry(item);
+            return key ? { ...acc, [key]: value } : acc;
+          }, prev));
+        }
+        setConflicts(conflicted);
+        console.warn(`Items in ${targetSection} were changed on another device:`, conflicted);
+      }
+      throw error;
+    }
+  };
 
   const fetchPhotos = useCallback(async (itemName) => {
     const url = `http://localhost:8080/api/inspection-photo/${inspectionId}/${encodeURIComponent(itemName)}`;
@@ -36,36 +149,11 @@ export function InspectionCRUD(inspectionId, section) {
     const url = `http://localhost:8080/api/inspection-${section}/${inspectionId}`;
     const response = await axios.get(url);
     const resData = response.data || [];
-  
-    const toCamelCase = (str) => {
-      return str
-        .replace(/\s(.)/g, (match, group1) => group1.toUpperCase())
-        .replace(/\s/g, '')
-        .replace(/^(.)/, (match, group1) => group1.toLowerCase())
-        .replace(/Details$/, 'Details'); // Keep 'Details' properly capitalized
-    };
+    rememberVersions(section, resData);
   
     const data = resData.reduce((acc, item) => {
-      if (!item || !item.item_name) return acc; // guard against bad rows
-    
-      const key = toCamelCase(item.item_name);
-    
-      if (item.item_name.endsWith("System Details") || item.item_name.endsWith("Management Details")) {
-        try {
-          acc[key] = typeof item.comments === "string" && item.comments.trim().startsWith("{")
-            ? JSON.parse(item.comments)
-            : {};
-        } catch (e) {
-          console.error(`Error parsing JSON for ${item.item_name}:`, e);
-          acc[key] = {};
-        }        
-      } else {
-        acc[item.item_name] = {
-          componentTypeConditions: typeof item.materials === "object" ? item.materials : {},
-          comment: item.comments || "",
-          inspection_status: item.inspection_status || "Not Inspected",
-        };
-      }
+      const [key, value] = toFormEntry(item);
+      if (key) acc[key] = value;
       return acc;
     }, {});
     
@@ -86,7 +174,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting payload to backend:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-${section}`, payload);
+      await postItems(section, payload);
     } catch (error) {
       console.error(`Error updating item ${itemName}:`, error);
     }
@@ -145,7 +233,7 @@ export function InspectionCRUD(inspectionId, section) {
     }];
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-roof`, payload);
+      await postItems("roof", payload);
     } catch (error) {
       console.error("Error updating Roof System Details:", error);
     }
@@ -166,7 +254,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Heating System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-heating`, payload);
+      await postItems("heating", payload);
     } catch (error) {
       console.error("Error updating Heating System Details:", error);
     }
@@ -187,7 +275,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Cooling System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-cooling`, payload);
+      await postItems("cooling", payload);
     } catch (error) {
       console.error("Error updating Cooling System Details:", error);
     }
@@ -208,7 +296,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Water Heating System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-plumbing`, payload);
+      await postItems("plumbing", payload);
     } catch (error) {
       console.error("Error updating Water Heating System Details:", error);
     }
@@ -229,7 +317,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Water Filtration System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-plumbing`, payload);
+      await postItems("plumbing", payload);
     } catch (error) {
       console.error("Error updating Water Filtration System Details:", error);
     }
@@ -250,7 +338,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Electrical System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-electrical`, payload);
+      await postItems("electrical", payload);
     } catch (error) {
       console.error("Error updating Electrical System Details:", error);
     }
@@ -271,7 +359,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Water Intrusion Management Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-exterior`, payload);
+      await postItems("exterior", payload);
     } catch (error) {
       console.error("Error updating Water Intrusion Management Details:", error);
     }
@@ -292,7 +380,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Irrigation System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-exterior`, payload);
+      await postItems("exterior", payload);
     } catch (error) {
       console.error("Error updating Irrigation System Details:", error);
     }
@@ -313,7 +401,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Swimming Pool Spa System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-systemsComponents`, payload);
+      await postItems("systemsComponents", payload);
     } catch (error) {
       console.error("Error updating Swimming Pool Spa System Details:", error);
     }
@@ -334,7 +422,7 @@ export function InspectionCRUD(inspectionId, section) {
     console.log("Posting Solar Energy System Details payload:", payload);
   
     try {
-      await axios.post(`http://localhost:8080/api/inspection-systemsComponents`, payload);
+      await postItems("systemsComponents", payload);
     } catch (error) {
       console.error("Error updating Solar Energy System Details:", error);
     }
@@ -409,5 +497,7 @@ export function InspectionCRUD(inspectionId, section) {
     handlePhotoRemove,
     fetchPhotos,
     photos,
+    conflicts,
+    dismissConflicts: () => setConflicts([]),
   };
 }
@@ -1,6 +1,6 @@
 import React, { useMemo } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import "../styles/InspectionWorksheets.css";
 
@@ -17,6 +17,8 @@ const BasementFoundation = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "basementFoundation");
 
   const items = useMemo(() => [
@@ -55,6 +57,7 @@ const BasementFoundation = () => {
   return (
     <div>
       <h1 className="component-title">9. BASEMENT / FOUNDATION</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
       <InspectionSections
         items={items}
         formData={formData}
@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -19,6 +19,8 @@ const Cooling = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "cooling");
 
   const [coolingDetails, setCoolingDetails] = useState(formData.coolingSystemDetails || {});
@@ -86,6 +88,7 @@ const Cooling = () => {
   return (
     <div>
       <h1 className="component-title">11. COOLING SYSTEMS</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ====== Cooling System Details Section ====== */}
       <div className="roof-system-details">
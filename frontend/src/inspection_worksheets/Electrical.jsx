@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -19,6 +19,8 @@ const Electrical = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "electrical");
 
   const [electricalDetails, setElectricalDetails] = useState(formData.electricalSystemDetails || {});
@@ -98,6 +100,7 @@ const Electrical = () => {
   return (
     <div>
       <h1 className="component-title">8. ELECTRICAL SYSTEMS</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ====== Electrical System Details Section ====== */}
       <div className="roof-system-details">
@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -19,6 +19,8 @@ const Exterior = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "exterior");
 
   const [waterIntrusionExists, setWaterIntrusionExists] = useState(formData.waterIntrusionManagementDetails?.exists || "No");
@@ -140,6 +142,7 @@ const Exterior = () => {
   return (
     <div>
       <h1 className="component-title">6. EXTERIOR</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ===== Water Intrusion Management Details (only if exists) ===== */}
       <div className="roof-system-details">
@@ -1,6 +1,6 @@
 import React, { useMemo } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import "../styles/InspectionWorksheets.css";
 
@@ -17,6 +17,8 @@ const Fireplace = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "fireplace");
 
   const items = useMemo(() => [
@@ -49,6 +51,7 @@ const Fireplace = () => {
   return (
     <div>
       <h1 className="component-title">14. FIREPLACE, WOOD STOVE, CHIMNEY</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
       <InspectionSections
         items={items}
         formData={formData}
@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -20,6 +20,8 @@ const Heating = () => {
     updateHeatingSystemDetails,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "heating");
 
   const [heatingDetails, setHeatingDetails] = useState(formData.heatingSystemDetails || {});
@@ -75,6 +77,7 @@ const Heating = () => {
   return (
     <div>
       <h1 className="component-title">7. HEATING SYSTEM</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ====== New Heating System Details Section ====== */}
       <div className="roof-system-details">
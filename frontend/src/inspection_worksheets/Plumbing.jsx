@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -19,6 +19,8 @@ const Plumbing = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "plumbing");
 
   const [waterHeatingDetails, setWaterHeatingDetails] = useState(formData.waterHeatingSystemDetails || {});
@@ -166,6 +168,7 @@ const Plumbing = () => {
   return (
     <div>
       <h1 className="component-title">7. PLUMBING SYSTEMS</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ====== Water Heating System Details Section ====== */}
       <div className="roof-system-details">
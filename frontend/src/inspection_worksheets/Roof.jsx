@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import { debounce } from "../utils/debounce";
 import "../styles/InspectionWorksheets.css";
@@ -19,6 +19,8 @@ const Roof = () => {
     updateRoofSystemDetails,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "roof");
 
   const [roofDetails, setRoofDetails] = useState(formData.roofSystemDetails || {});
@@ -73,6 +75,7 @@ const Roof = () => {
   return (
     <div>
       <h1 className="component-title">5. ROOF SYSTEM</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* ====== New Roof System Details Section ====== */}
       <div className="roof-system-details">
@@ -10,6 +10,7 @@ const Summary = () => {
   const [inspectionData, setInspectionData] = useState(null);
   const [photosByItem, setPhotosByItem] = useState({});
   const [sectionData, setSectionData] = useState({});
+  const [findings, setFindings] = useState(null);
 
   const sections = useMemo(() => [
     "roof",
@@ -96,6 +97,9 @@ useEffect(() => {
       });
       setPhotosByItem(groupedPhotos);
 
+      const summaryRes = await axios.get(`${apiBase}/api/inspections/${inspectionId}/report/summary`);
+      setFindings(summaryRes.data);
+
     } catch (error) {
       console.error("Error fetching inspection report data:", error);
     }
@@ -183,6 +187,36 @@ return (
       </section>
     )}
 
+    {findings && (
+      <section className="report-summary">
+        <h2 className="section-header">SUMMARY OF FINDINGS</h2>
+        {findings.groups.map(group => (
+          <div key={group.severity} className={`findings-group ${group.severity}`}>
+            <h3 className="item-header">{group.title} ({group.findings.length})</h3>
+            {group.findings.length === 0 && <div className="item-block">None.</div>}
+            {group.findings.map(finding => (
+              <div className="item-block" key={`${finding.source}-${finding.defect_id || ""}-${finding.reference}-${finding.condition || ""}`}>
+                <strong>{finding.reference} {finding.section_title} - {finding.item_name}{finding.condition ? `: ${finding.condition}` : ""}</strong>
+                {finding.recommendation && <div>Recommendation: {finding.recommendation}</div>}
+                {finding.photos.length > 0 && (
+                  <div className="photo-gallery">
+                    {finding.photos.map(photo => (
+                      <img
+                        key={photo.photo_id}
+                        src={`http://localhost:8080${photo.thumbnail_url}`}
+                        alt={finding.item_name}
+                        className="report-photo"
+                      />
+                    ))}
+                  </div>
+                )}
+              </div>
+            ))}
+          </div>
+        ))}
+      </section>
+    )}
+
     {sections.reduce((acc, section) => {
       const data = sectionData[section];
       const hasPhotos = data?.some(item => photosByItem[item.item_name || item.itemName]);
@@ -1,6 +1,6 @@
 import React, { useMemo, useState, useEffect } from "react";
 import { useParams } from "react-router-dom";
-import { InspectionCRUD } from "../components/InspectionCRUD";
+import { ConflictNotice, InspectionCRUD } from "../components/InspectionCRUD";
 import InspectionSections from "../components/InspectionSections";
 import SystemPhotoUpload from "../components/SystemPhotoUpload";
 import { debounce } from "../utils/debounce";
@@ -19,6 +19,8 @@ const SystemsComponents = () => {
     updateComponentTypeConditions,
     photos,
     fetchPhotos,
+    conflicts,
+    dismissConflicts,
   } = InspectionCRUD(inspectionId, "systemsComponents");
 
   const [poolExists, setPoolExists] = useState(formData.swimmingPoolSpaSystemDetails?.exists || "No");
@@ -134,6 +136,7 @@ const SystemsComponents = () => {
   return (
     <div>
       <h1 className="component-title">15. SYSTEMS AND COMPONENTS</h1>
+      <ConflictNotice conflicts={conflicts} onDismiss={dismissConflicts} />
 
       {/* Swimming Pool/Spa System Details Section */}
       <div className="roof-system-details">
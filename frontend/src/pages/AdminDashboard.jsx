@@ -1,4 +1,5 @@
 import React, { useState, useEffect } from "react";
+import axios from "../utils/axios";
 import "../styles/AdminDashboard.css";
 
 function AdminDashboard() {
@@ -6,6 +7,8 @@ function AdminDashboard() {
   const [inviteStatus, setInviteStatus] = useState("");
   const [inviteError, setInviteError] = useState("");
   const [invitations, setInvitations] = useState([]);
+  const [trash, setTrash] = useState([]);
+  const [retentionDays, setRetentionDays] = useState(null);
 
   const fetchInvitations = async () => {
     try {
@@ -17,8 +20,28 @@ function AdminDashboard() {
     }
   };
 
+  const fetchTrash = async () => {
+    try {
+      const res = await axios.get("/admin/trash");
+      setTrash(res.data.items || []);
+      setRetentionDays(res.data.retention_days);
+    } catch (err) {
+      console.error("Failed to fetch trash:", err);
+    }
+  };
+
+  const handleRestore = async (item) => {
+    try {
+      await axios.post(`/admin/trash/${item.type}/${item.id}/restore`);
+      fetchTrash();
+    } catch (err) {
+      alert(err.response?.data || "Failed to restore item.");
+    }
+  };
+
   useEffect(() => {
     fetchInvitations();
+    fetchTrash();
   }, []);
 
   const handleSendInvite = async () => {
@@ -93,6 +116,41 @@ function AdminDashboard() {
           </table>
         )}
       </section>
+
+      <section className="invites-list trash-list">
+        <h2>Trash</h2>
+        {retentionDays !== null && (
+          <p>Deleted items are permanently removed after {retentionDays} days.</p>
+        )}
+        {trash.length === 0 ? (
+          <p>The trash is empty.</p>
+        ) : (
+          <table>
+            <thead>
+              <tr>
+                <th>Type</th>
+                <th>Item</th>
+                <th>Deleted</th>
+                <th>Purged after</th>
+                <th></th>
+              </tr>
+            </thead>
+            <tbody>
+              {trash.map((item) => (
+                <tr key={`${item.type}-${item.id}`}>
+                  <td>{item.type}</td>
+                  <td>{item.label}</td>
+                  <td>{new Date(item.deleted_at.replace(" ", "T") + "Z").toLocaleDateString()}</td>
+                  <td>{new Date(item.purge_after.replace(" ", "T") + "Z").toLocaleDateString()}</td>
+                  <td>
+                    <button onClick={() => handleRestore(item)}>Restore</button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+      </section>
     </div>
   );
 }
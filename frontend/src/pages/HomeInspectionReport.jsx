@@ -13,6 +13,7 @@ const HomeInspectionReport = () => {
   const [sectionData, setSectionData] = useState({});
   const [propertyPhotoUrl, setPropertyPhotoUrl] = useState(null);
   const [analyzing, setAnalyzing] = useState(false);
+  const [downloading, setDownloading] = useState(false);
 
   const sections = useMemo(() => [
     "roof", "exterior", "basementFoundation", "heating", "cooling",
@@ -146,6 +147,28 @@ const HomeInspectionReport = () => {
     }
   };  
 
+  const downloadPdf = async () => {
+    try {
+      setDownloading(true);
+      const res = await axios.get(`/inspections/${inspectionId}/report.pdf?download=true`, {
+        responseType: "blob"
+      });
+      const url = window.URL.createObjectURL(new Blob([res.data], { type: "application/pdf" }));
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = `inspection-report-${inspectionId}.pdf`;
+      document.body.appendChild(link);
+      link.click();
+      link.remove();
+      window.URL.revokeObjectURL(url);
+    } catch (err) {
+      console.error("❌ PDF download failed:", err);
+      alert("Failed to download the PDF report.");
+    } finally {
+      setDownloading(false);
+    }
+  };
+
   const renderItem = (item, indexPrefix) => {
     const itemName = item.item_name || item.itemName;
     const materialList = item.materials
@@ -213,6 +236,22 @@ const HomeInspectionReport = () => {
         >
           {analyzing ? "Analyzing..." : "Analyze Report"}
         </button>
+        <button
+          className="download-pdf-button"
+          onClick={downloadPdf}
+          disabled={downloading}
+          style={{
+            padding: "8px 16px",
+            backgroundColor: "#1F3F6B",
+            color: "white",
+            border: "none",
+            borderRadius: "4px",
+            cursor: "pointer",
+            marginRight: "1rem"
+          }}
+        >
+          {downloading ? "Preparing PDF..." : "Download PDF"}
+        </button>
       </div>
 
       <section className="cover-page">
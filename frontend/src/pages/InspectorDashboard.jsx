@@ -62,6 +62,14 @@ const InspectorDashboard = () => {
                 <Link to={`/property/${report.property_id}/inspection/${report.inspection_id}`}>
                   {report.address} - {report.status} ({report.date})
                 </Link>
+                <span className="site-time">
+                  {report.checked_in
+                    ? " On site now"
+                    : report.time_on_site_minutes > 0
+                    ? ` ${Math.round(report.time_on_site_minutes)} min on site`
+                    : ""}
+                  {report.location_verified === false && " (location not verified)"}
+                </span>
               </li>
             ))}
           </ul>